@@ -6,11 +6,140 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/oapi-codegen/runtime"
 )
 
+// Defines values for PortfolioRuleCondition.
+const (
+	DrawdownAbove PortfolioRuleCondition = "drawdown_above"
+	PnlAbove      PortfolioRuleCondition = "pnl_above"
+	PnlBelow      PortfolioRuleCondition = "pnl_below"
+	WeightAbove   PortfolioRuleCondition = "weight_above"
+)
+
+// Defines values for GetMoexAssetsTickerHistoryParamsInterval.
+const (
+	N1d GetMoexAssetsTickerHistoryParamsInterval = "1d"
+	N1h GetMoexAssetsTickerHistoryParamsInterval = "1h"
+	N1m GetMoexAssetsTickerHistoryParamsInterval = "1m"
+	Raw GetMoexAssetsTickerHistoryParamsInterval = "raw"
+)
+
+// Holding defines model for Holding.
+type Holding struct {
+	AveragePrice float32 `json:"average_price"`
+
+	// Currency RUB by default
+	Currency    *string `json:"currency,omitempty"`
+	Id          *int    `json:"id,omitempty"`
+	PortfolioId *int    `json:"portfolio_id,omitempty"`
+	Quantity    float32 `json:"quantity"`
+	Ticker      string  `json:"ticker"`
+}
+
+// MOEXAlert defines model for MOEXAlert.
+type MOEXAlert struct {
+	Condition *string  `json:"condition,omitempty"`
+	Id        *int64   `json:"id,omitempty"`
+	Price     *float32 `json:"price,omitempty"`
+	Published *bool    `json:"published,omitempty"`
+
+	// SecondPrice Price of the second leg of the pair rules
+	SecondPrice *float32 `json:"second_price,omitempty"`
+
+	// SecondTicker Second leg of the pair rules
+	SecondTicker *string    `json:"second_ticker,omitempty"`
+	TargetPrice  *float32   `json:"target_price,omitempty"`
+	Ticker       *string    `json:"ticker,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	WatchlistId  *int64     `json:"watchlist_id,omitempty"`
+}
+
+// MOEXAsset defines model for MOEXAsset.
+type MOEXAsset struct {
+	Board     *string    `json:"board,omitempty"`
+	ClassId   *string    `json:"class_id,omitempty"`
+	Currency  *string    `json:"currency,omitempty"`
+	Delisted  *bool      `json:"delisted,omitempty"`
+	Engine    *string    `json:"engine,omitempty"`
+	Isin      *string    `json:"isin,omitempty"`
+	LotSize   *int       `json:"lot_size,omitempty"`
+	Market    *string    `json:"market,omitempty"`
+	Name      *string    `json:"name,omitempty"`
+	ShortName *string    `json:"short_name,omitempty"`
+	SyncedAt  *time.Time `json:"synced_at,omitempty"`
+	Ticker    *string    `json:"ticker,omitempty"`
+}
+
+// MOEXEventSubscription defines model for MOEXEventSubscription.
+type MOEXEventSubscription struct {
+	DaysBefore     *int    `json:"days_before,omitempty"`
+	Id             *int    `json:"id,omitempty"`
+	NotificationId *int    `json:"notification_id,omitempty"`
+	Ticker         *string `json:"ticker,omitempty"`
+}
+
+// MOEXEventSubscriptionRequest defines model for MOEXEventSubscriptionRequest.
+type MOEXEventSubscriptionRequest struct {
+	// DaysBefore Days before the registry close, coupon or offer date to notify
+	DaysBefore     *int `json:"days_before,omitempty"`
+	NotificationId int  `json:"notification_id"`
+
+	// Ticker Asset to be notified of, all the assets if not set
+	Ticker *string `json:"ticker,omitempty"`
+}
+
+// MOEXQuoteBar defines model for MOEXQuoteBar.
+type MOEXQuoteBar struct {
+	Close     *float32   `json:"close,omitempty"`
+	High      *float32   `json:"high,omitempty"`
+	Low       *float32   `json:"low,omitempty"`
+	Open      *float32   `json:"open,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// Portfolio defines model for Portfolio.
+type Portfolio struct {
+	Currency       *string          `json:"currency,omitempty"`
+	Holdings       *[]Holding       `json:"holdings,omitempty"`
+	Id             *int             `json:"id,omitempty"`
+	Name           *string          `json:"name,omitempty"`
+	NotificationId *int             `json:"notification_id,omitempty"`
+	Rules          *[]PortfolioRule `json:"rules,omitempty"`
+}
+
+// PortfolioRequest defines model for PortfolioRequest.
+type PortfolioRequest struct {
+	// Currency Currency the portfolio is valued in, RUB by default
+	Currency *string `json:"currency,omitempty"`
+	Name     string  `json:"name"`
+
+	// NotificationId Notification of the portfolio alerts
+	NotificationId *int `json:"notification_id,omitempty"`
+}
+
+// PortfolioRule defines model for PortfolioRule.
+type PortfolioRule struct {
+	Active *bool `json:"active,omitempty"`
+	Armed  *bool `json:"armed,omitempty"`
+
+	// Condition drawdown_above - loss of the portfolio value today in % of the previous close, pnl_above and pnl_below - unrealized P&L in % of the cost, weight_above - value of the holding in % of the portfolio value
+	Condition       PortfolioRuleCondition `json:"condition"`
+	Id              *int                   `json:"id,omitempty"`
+	LastTriggeredAt *time.Time             `json:"last_triggered_at,omitempty"`
+	PortfolioId     *int                   `json:"portfolio_id,omitempty"`
+	Threshold       float32                `json:"threshold"`
+
+	// Ticker Holding of the weight rule, any holding if not set
+	Ticker *string `json:"ticker,omitempty"`
+}
+
+// PortfolioRuleCondition drawdown_above - loss of the portfolio value today in % of the previous close, pnl_above and pnl_below - unrealized P&L in % of the cost, weight_above - value of the holding in % of the portfolio value
+type PortfolioRuleCondition string
+
 // User defines model for User.
 type User struct {
 	Id       *int64  `json:"id,omitempty"`
@@ -18,6 +147,63 @@ type User struct {
 	Password *string `json:"password,omitempty"`
 }
 
+// GetMoexAlertsParams defines parameters for GetMoexAlerts.
+type GetMoexAlertsParams struct {
+	// Ticker Return only the alerts for this ticker
+	Ticker *string `form:"ticker,omitempty" json:"ticker,omitempty"`
+
+	// From Return only the alerts triggered at or after this time (RFC3339)
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To Return only the alerts triggered at or before this time (RFC3339)
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+}
+
+// GetMoexAssetsParams defines parameters for GetMoexAssets.
+type GetMoexAssetsParams struct {
+	// Q Part of the ticker, ISIN, short or full name
+	Q string `form:"q" json:"q"`
+
+	// Limit Maximal number of the assets returned
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Delisted Include the delisted assets
+	Delisted *bool `form:"delisted,omitempty" json:"delisted,omitempty"`
+}
+
+// GetMoexAssetsTickerHistoryParams defines parameters for GetMoexAssetsTickerHistory.
+type GetMoexAssetsTickerHistoryParams struct {
+	// From Start of the period, a day before 'to' by default
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To End of the period, now by default
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+
+	// Interval Bar interval, raw returns the polled quotes
+	Interval *GetMoexAssetsTickerHistoryParamsInterval `form:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// GetMoexAssetsTickerHistoryParamsInterval defines parameters for GetMoexAssetsTickerHistory.
+type GetMoexAssetsTickerHistoryParamsInterval string
+
+// PostMoexEventSubscriptionsJSONRequestBody defines body for PostMoexEventSubscriptions for application/json ContentType.
+type PostMoexEventSubscriptionsJSONRequestBody = MOEXEventSubscriptionRequest
+
+// PostPortfoliosJSONRequestBody defines body for PostPortfolios for application/json ContentType.
+type PostPortfoliosJSONRequestBody = PortfolioRequest
+
+// PutPortfoliosIdJSONRequestBody defines body for PutPortfoliosId for application/json ContentType.
+type PutPortfoliosIdJSONRequestBody = PortfolioRequest
+
+// PostPortfoliosIdHoldingsJSONRequestBody defines body for PostPortfoliosIdHoldings for application/json ContentType.
+type PostPortfoliosIdHoldingsJSONRequestBody = Holding
+
+// PutPortfoliosIdHoldingsHoldingJSONRequestBody defines body for PutPortfoliosIdHoldingsHolding for application/json ContentType.
+type PutPortfoliosIdHoldingsHoldingJSONRequestBody = Holding
+
+// PostPortfoliosIdRulesJSONRequestBody defines body for PostPortfoliosIdRules for application/json ContentType.
+type PostPortfoliosIdRulesJSONRequestBody = PortfolioRule
+
 // PostUsersJSONRequestBody defines body for PostUsers for application/json ContentType.
 type PostUsersJSONRequestBody = User
 
@@ -26,6 +212,54 @@ type PutUsersIdJSONRequestBody = User
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Get triggered MOEX alerts
+	// (GET /moex/alerts)
+	GetMoexAlerts(ctx echo.Context, params GetMoexAlertsParams) error
+	// Search MOEX assets by ticker, ISIN or name
+	// (GET /moex/assets)
+	GetMoexAssets(ctx echo.Context, params GetMoexAssetsParams) error
+	// Get the price history of a MOEX asset
+	// (GET /moex/assets/{ticker}/history)
+	GetMoexAssetsTickerHistory(ctx echo.Context, ticker string, params GetMoexAssetsTickerHistoryParams) error
+	// Get the subscriptions to the MOEX corporate events
+	// (GET /moex/event-subscriptions)
+	GetMoexEventSubscriptions(ctx echo.Context) error
+	// Subscribe a notification to the dividends, coupons and offers
+	// (POST /moex/event-subscriptions)
+	PostMoexEventSubscriptions(ctx echo.Context) error
+	// Delete a subscription to the MOEX corporate events
+	// (DELETE /moex/event-subscriptions/{id})
+	DeleteMoexEventSubscriptionsId(ctx echo.Context, id int) error
+	// Get all portfolios without their holdings and rules
+	// (GET /portfolios)
+	GetPortfolios(ctx echo.Context) error
+	// Create a portfolio
+	// (POST /portfolios)
+	PostPortfolios(ctx echo.Context) error
+	// Delete a portfolio with its holdings and rules
+	// (DELETE /portfolios/{id})
+	DeletePortfoliosId(ctx echo.Context, id int) error
+	// Get a portfolio with its holdings and rules
+	// (GET /portfolios/{id})
+	GetPortfoliosId(ctx echo.Context, id int) error
+	// Update a portfolio
+	// (PUT /portfolios/{id})
+	PutPortfoliosId(ctx echo.Context, id int) error
+	// Add a holding to the portfolio
+	// (POST /portfolios/{id}/holdings)
+	PostPortfoliosIdHoldings(ctx echo.Context, id int) error
+	// Delete a holding
+	// (DELETE /portfolios/{id}/holdings/{holding})
+	DeletePortfoliosIdHoldingsHolding(ctx echo.Context, id int, holding int) error
+	// Update the quantity, average price and currency of a holding
+	// (PUT /portfolios/{id}/holdings/{holding})
+	PutPortfoliosIdHoldingsHolding(ctx echo.Context, id int, holding int) error
+	// Add an alert rule to the portfolio
+	// (POST /portfolios/{id}/rules)
+	PostPortfoliosIdRules(ctx echo.Context, id int) error
+	// Delete a portfolio rule
+	// (DELETE /portfolios/{id}/rules/{rule})
+	DeletePortfoliosIdRulesRule(ctx echo.Context, id int, rule int) error
 	// Get all users
 	// (GET /users)
 	GetUsers(ctx echo.Context) error
@@ -45,6 +279,313 @@ type ServerInterfaceWrapper struct {
 	Handler ServerInterface
 }
 
+// GetMoexAlerts converts echo context to params.
+func (w *ServerInterfaceWrapper) GetMoexAlerts(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetMoexAlertsParams
+	// ------------- Optional query parameter "ticker" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "ticker", ctx.QueryParams(), &params.Ticker)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter ticker: %s", err))
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", ctx.QueryParams(), &params.From)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter from: %s", err))
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", ctx.QueryParams(), &params.To)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter to: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetMoexAlerts(ctx, params)
+	return err
+}
+
+// GetMoexAssets converts echo context to params.
+func (w *ServerInterfaceWrapper) GetMoexAssets(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetMoexAssetsParams
+	// ------------- Required query parameter "q" -------------
+
+	err = runtime.BindQueryParameter("form", true, true, "q", ctx.QueryParams(), &params.Q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter q: %s", err))
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", ctx.QueryParams(), &params.Limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter limit: %s", err))
+	}
+
+	// ------------- Optional query parameter "delisted" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "delisted", ctx.QueryParams(), &params.Delisted)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter delisted: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetMoexAssets(ctx, params)
+	return err
+}
+
+// GetMoexAssetsTickerHistory converts echo context to params.
+func (w *ServerInterfaceWrapper) GetMoexAssetsTickerHistory(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "ticker" -------------
+	var ticker string
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "ticker", runtime.ParamLocationPath, ctx.Param("ticker"), &ticker)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter ticker: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetMoexAssetsTickerHistoryParams
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", ctx.QueryParams(), &params.From)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter from: %s", err))
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", ctx.QueryParams(), &params.To)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter to: %s", err))
+	}
+
+	// ------------- Optional query parameter "interval" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "interval", ctx.QueryParams(), &params.Interval)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter interval: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetMoexAssetsTickerHistory(ctx, ticker, params)
+	return err
+}
+
+// GetMoexEventSubscriptions converts echo context to params.
+func (w *ServerInterfaceWrapper) GetMoexEventSubscriptions(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetMoexEventSubscriptions(ctx)
+	return err
+}
+
+// PostMoexEventSubscriptions converts echo context to params.
+func (w *ServerInterfaceWrapper) PostMoexEventSubscriptions(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PostMoexEventSubscriptions(ctx)
+	return err
+}
+
+// DeleteMoexEventSubscriptionsId converts echo context to params.
+func (w *ServerInterfaceWrapper) DeleteMoexEventSubscriptionsId(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.DeleteMoexEventSubscriptionsId(ctx, id)
+	return err
+}
+
+// GetPortfolios converts echo context to params.
+func (w *ServerInterfaceWrapper) GetPortfolios(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetPortfolios(ctx)
+	return err
+}
+
+// PostPortfolios converts echo context to params.
+func (w *ServerInterfaceWrapper) PostPortfolios(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PostPortfolios(ctx)
+	return err
+}
+
+// DeletePortfoliosId converts echo context to params.
+func (w *ServerInterfaceWrapper) DeletePortfoliosId(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.DeletePortfoliosId(ctx, id)
+	return err
+}
+
+// GetPortfoliosId converts echo context to params.
+func (w *ServerInterfaceWrapper) GetPortfoliosId(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetPortfoliosId(ctx, id)
+	return err
+}
+
+// PutPortfoliosId converts echo context to params.
+func (w *ServerInterfaceWrapper) PutPortfoliosId(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PutPortfoliosId(ctx, id)
+	return err
+}
+
+// PostPortfoliosIdHoldings converts echo context to params.
+func (w *ServerInterfaceWrapper) PostPortfoliosIdHoldings(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PostPortfoliosIdHoldings(ctx, id)
+	return err
+}
+
+// DeletePortfoliosIdHoldingsHolding converts echo context to params.
+func (w *ServerInterfaceWrapper) DeletePortfoliosIdHoldingsHolding(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// ------------- Path parameter "holding" -------------
+	var holding int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "holding", runtime.ParamLocationPath, ctx.Param("holding"), &holding)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter holding: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.DeletePortfoliosIdHoldingsHolding(ctx, id, holding)
+	return err
+}
+
+// PutPortfoliosIdHoldingsHolding converts echo context to params.
+func (w *ServerInterfaceWrapper) PutPortfoliosIdHoldingsHolding(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// ------------- Path parameter "holding" -------------
+	var holding int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "holding", runtime.ParamLocationPath, ctx.Param("holding"), &holding)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter holding: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PutPortfoliosIdHoldingsHolding(ctx, id, holding)
+	return err
+}
+
+// PostPortfoliosIdRules converts echo context to params.
+func (w *ServerInterfaceWrapper) PostPortfoliosIdRules(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PostPortfoliosIdRules(ctx, id)
+	return err
+}
+
+// DeletePortfoliosIdRulesRule converts echo context to params.
+func (w *ServerInterfaceWrapper) DeletePortfoliosIdRulesRule(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "id", runtime.ParamLocationPath, ctx.Param("id"), &id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// ------------- Path parameter "rule" -------------
+	var rule int
+
+	err = runtime.BindStyledParameterWithLocation("simple", false, "rule", runtime.ParamLocationPath, ctx.Param("rule"), &rule)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter rule: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.DeletePortfoliosIdRulesRule(ctx, id, rule)
+	return err
+}
+
 // GetUsers converts echo context to params.
 func (w *ServerInterfaceWrapper) GetUsers(ctx echo.Context) error {
 	var err error
@@ -123,6 +664,22 @@ func RegisterHandlersWithBaseURL(router EchoRouter, si ServerInterface, baseURL
 		Handler: si,
 	}
 
+	router.GET(baseURL+"/moex/alerts", wrapper.GetMoexAlerts)
+	router.GET(baseURL+"/moex/assets", wrapper.GetMoexAssets)
+	router.GET(baseURL+"/moex/assets/:ticker/history", wrapper.GetMoexAssetsTickerHistory)
+	router.GET(baseURL+"/moex/event-subscriptions", wrapper.GetMoexEventSubscriptions)
+	router.POST(baseURL+"/moex/event-subscriptions", wrapper.PostMoexEventSubscriptions)
+	router.DELETE(baseURL+"/moex/event-subscriptions/:id", wrapper.DeleteMoexEventSubscriptionsId)
+	router.GET(baseURL+"/portfolios", wrapper.GetPortfolios)
+	router.POST(baseURL+"/portfolios", wrapper.PostPortfolios)
+	router.DELETE(baseURL+"/portfolios/:id", wrapper.DeletePortfoliosId)
+	router.GET(baseURL+"/portfolios/:id", wrapper.GetPortfoliosId)
+	router.PUT(baseURL+"/portfolios/:id", wrapper.PutPortfoliosId)
+	router.POST(baseURL+"/portfolios/:id/holdings", wrapper.PostPortfoliosIdHoldings)
+	router.DELETE(baseURL+"/portfolios/:id/holdings/:holding", wrapper.DeletePortfoliosIdHoldingsHolding)
+	router.PUT(baseURL+"/portfolios/:id/holdings/:holding", wrapper.PutPortfoliosIdHoldingsHolding)
+	router.POST(baseURL+"/portfolios/:id/rules", wrapper.PostPortfoliosIdRules)
+	router.DELETE(baseURL+"/portfolios/:id/rules/:rule", wrapper.DeletePortfoliosIdRulesRule)
 	router.GET(baseURL+"/users", wrapper.GetUsers)
 	router.POST(baseURL+"/users", wrapper.PostUsers)
 	router.DELETE(baseURL+"/users/:id", wrapper.DeleteUsersId)
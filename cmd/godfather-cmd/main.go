@@ -159,6 +159,26 @@ func main() {
 	r.PUT("/users/:id", updateUserHandler(db))
 	r.DELETE("/users/:id", deleteUserHandler(db))
 
+	// MOEX routes
+	r.GET("/moex/alerts", getMOEXAlertsHandler(db))
+	r.GET("/moex/assets", searchMOEXAssetsHandler(db))
+	r.GET("/moex/assets/:ticker/history", getMOEXQuoteHistoryHandler(db))
+	r.GET("/moex/event-subscriptions", getMOEXEventSubscriptionsHandler(db))
+	r.POST("/moex/event-subscriptions", addMOEXEventSubscriptionHandler(db))
+	r.DELETE("/moex/event-subscriptions/:id", deleteMOEXEventSubscriptionHandler(db))
+
+	// Portfolio routes
+	r.POST("/portfolios", createPortfolioHandler(db))
+	r.GET("/portfolios", getPortfoliosHandler(db))
+	r.GET("/portfolios/:id", getPortfolioHandler(db))
+	r.PUT("/portfolios/:id", updatePortfolioHandler(db))
+	r.DELETE("/portfolios/:id", deletePortfolioHandler(db))
+	r.POST("/portfolios/:id/holdings", addHoldingHandler(db))
+	r.PUT("/portfolios/:id/holdings/:holding", updateHoldingHandler(db))
+	r.DELETE("/portfolios/:id/holdings/:holding", deleteHoldingHandler(db))
+	r.POST("/portfolios/:id/rules", addPortfolioRuleHandler(db))
+	r.DELETE("/portfolios/:id/rules/:rule", deletePortfolioRuleHandler(db))
+
 	// Catch-all route for SPA - must be after static and API routes
 	service.GET("/*", func(c echo.Context) error {
 		// Check if the file exists in the static directory first
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/labstack/echo/v4"
+)
+
+// ----------------------------------------------------------------
+func parseTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Invalid '%s' parameter, RFC3339 timestamp expected", name))
+	}
+	return t, nil
+}
+
+// ----------------------------------------------------------------
+func getMOEXAlertsHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		from, err := parseTimeParam(c, "from")
+		if err != nil {
+			return err
+		}
+		to, err := parseTimeParam(c, "to")
+		if err != nil {
+			return err
+		}
+		if !from.IsZero() && !to.IsZero() && to.Before(from) {
+			return echo.NewHTTPError(http.StatusBadRequest, "'to' must not be earlier than 'from'")
+		}
+
+		alerts, err := db.GetMOEXAlerts(godfather.MOEXAlertFilter{
+			Ticker: c.QueryParam("ticker"),
+			From:   from,
+			To:     to,
+		})
+		if err != nil {
+			slog.Error("Failed to retrieve MOEX alerts", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+		}
+		if alerts == nil {
+			alerts = []godfather.MOEXAlert{}
+		}
+		return c.JSON(http.StatusOK, alerts)
+	}
+}
+
+// Limits of the number of assets returned by the search
+const (
+	defaultAssetSearchLimit = 20
+	maxAssetSearchLimit     = 100
+)
+
+// ----------------------------------------------------------------
+// Search the MOEX assets by ticker, ISIN or name to pick a valid
+// ticker for the watchlist rule
+// ----------------------------------------------------------------
+func searchMOEXAssetsHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		pattern := c.QueryParam("q")
+		if pattern == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "'q' parameter is required")
+		}
+
+		limit := defaultAssetSearchLimit
+		if value := c.QueryParam("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 || parsed > maxAssetSearchLimit {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					fmt.Sprintf("Invalid 'limit' parameter, 1..%d expected", maxAssetSearchLimit))
+			}
+			limit = parsed
+		}
+		includeDelisted := c.QueryParam("delisted") == "true"
+
+		assets, err := db.SearchMOEXAssets(pattern, includeDelisted, limit)
+		if err != nil {
+			slog.Error("Failed to search MOEX assets", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+		}
+		if assets == nil {
+			assets = []godfather.MOEXAsset{}
+		}
+		return c.JSON(http.StatusOK, assets)
+	}
+}
+
+// Maximal number of bars returned by the price history query
+const maxQuoteHistoryBars = 10000
+
+// ----------------------------------------------------------------
+// Get the price history of the asset, the last day of the hourly
+// bars by default
+// ----------------------------------------------------------------
+func getMOEXQuoteHistoryHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		interval := c.QueryParam("interval")
+		switch interval {
+		case "":
+			interval = godfather.MOEXQuoteHour
+		case godfather.MOEXQuoteRaw, godfather.MOEXQuoteMinute, godfather.MOEXQuoteHour, godfather.MOEXQuoteDay:
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid 'interval' parameter, raw, 1m, 1h or 1d expected")
+		}
+
+		from, err := parseTimeParam(c, "from")
+		if err != nil {
+			return err
+		}
+		to, err := parseTimeParam(c, "to")
+		if err != nil {
+			return err
+		}
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if from.IsZero() {
+			from = to.Add(-24 * time.Hour)
+		}
+		if to.Before(from) {
+			return echo.NewHTTPError(http.StatusBadRequest, "'to' must not be earlier than 'from'")
+		}
+
+		bars, err := db.GetMOEXQuoteHistory(c.Param("ticker"), interval, from, to, maxQuoteHistoryBars)
+		if err != nil {
+			slog.Error("Failed to retrieve MOEX price history", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+		}
+		if bars == nil {
+			bars = []godfather.MOEXQuoteBar{}
+		}
+		return c.JSON(http.StatusOK, bars)
+	}
+}
+
+// Default and maximal notice periods of the corporate events, days
+const (
+	defaultEventDaysBefore = 3
+	maxEventDaysBefore     = 90
+)
+
+// ----------------------------------------------------------------
+type MOEXEventSubscriptionRequest struct {
+	NotificationID int    `json:"notification_id"`
+	Ticker         string `json:"ticker"`
+	DaysBefore     *int   `json:"days_before"`
+}
+
+// ----------------------------------------------------------------
+func (r *MOEXEventSubscriptionRequest) Validate() error {
+	if r.NotificationID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Notification is required")
+	}
+	if r.DaysBefore == nil {
+		days := defaultEventDaysBefore
+		r.DaysBefore = &days
+	}
+	if *r.DaysBefore < 0 || *r.DaysBefore > maxEventDaysBefore {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Invalid days before the event, 0..%d expected", maxEventDaysBefore))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+func getMOEXEventSubscriptionsHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		subscriptions, err := db.GetMOEXEventSubscriptions()
+		if err != nil {
+			slog.Error("Failed to retrieve MOEX event subscriptions", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+		}
+		if subscriptions == nil {
+			subscriptions = []godfather.MOEXEventSubscription{}
+		}
+		return c.JSON(http.StatusOK, subscriptions)
+	}
+}
+
+// ----------------------------------------------------------------
+// Subscribe the notification to the dividends, coupons and offers of
+// the asset, or of all the assets if the ticker is not set
+// ----------------------------------------------------------------
+func addMOEXEventSubscriptionHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := new(MOEXEventSubscriptionRequest)
+		if err := c.Bind(r); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if err := c.Validate(r); err != nil {
+			return err
+		}
+
+		subscription := &godfather.MOEXEventSubscription{
+			NotificationID: r.NotificationID,
+			Ticker:         r.Ticker,
+			DaysBefore:     *r.DaysBefore,
+		}
+		if err := db.AddMOEXEventSubscription(subscription); err != nil {
+			if httpErr := constraintError(err, "Unknown ticker or notification"); httpErr != nil {
+				return httpErr
+			}
+			slog.Error("Failed to add MOEX event subscription", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+		}
+		return c.JSON(http.StatusCreated, subscription)
+	}
+}
+
+// ----------------------------------------------------------------
+func deleteMOEXEventSubscriptionHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		deleted, err := db.DeleteMOEXEventSubscription(id)
+		if err != nil {
+			slog.Error("Failed to delete MOEX event subscription", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+		}
+		if !deleted {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Subscription %d not found", id))
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
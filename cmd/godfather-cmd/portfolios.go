@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// Default currency of the portfolios and holdings
+const defaultCurrency = "RUB"
+
+// ----------------------------------------------------------------
+type PortfolioRequest struct {
+	Name           string `json:"name"`
+	Currency       string `json:"currency"`
+	NotificationID int    `json:"notification_id"`
+}
+
+// ----------------------------------------------------------------
+func (r *PortfolioRequest) Validate() error {
+	if r.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Name is required")
+	}
+	if r.Currency == "" {
+		r.Currency = defaultCurrency
+	}
+	if len(r.Currency) != 3 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Currency must be a 3-letter code")
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+type HoldingRequest struct {
+	Ticker       string  `json:"ticker"`
+	Quantity     float64 `json:"quantity"`
+	AveragePrice float64 `json:"average_price"`
+	Currency     string  `json:"currency"`
+}
+
+// ----------------------------------------------------------------
+func (r *HoldingRequest) Validate() error {
+	if r.Quantity <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Quantity must be positive")
+	}
+	if r.AveragePrice < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Average price must not be negative")
+	}
+	if r.Currency == "" {
+		r.Currency = defaultCurrency
+	}
+	if len(r.Currency) != 3 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Currency must be a 3-letter code")
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+type PortfolioRuleRequest struct {
+	Condition string  `json:"condition"`
+	Threshold float64 `json:"threshold"`
+	Ticker    string  `json:"ticker"`
+	Active    *bool   `json:"active"`
+}
+
+// ----------------------------------------------------------------
+func (r *PortfolioRuleRequest) Validate() error {
+	switch r.Condition {
+	case godfather.PortfolioWeightAbove:
+	case godfather.PortfolioDrawdownAbove, godfather.PortfolioPnLAbove, godfather.PortfolioPnLBelow:
+		if r.Ticker != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Ticker is only allowed for the weight rules")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"Invalid condition, drawdown_above, pnl_above, pnl_below or weight_above expected")
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+func parseIDParam(c echo.Context, name string) (int, error) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid '%s' parameter", name))
+	}
+	return id, nil
+}
+
+// ----------------------------------------------------------------
+// Translate the constraint violation to the client's error, unknown
+// describes the entities referenced by the foreign keys. Returns nil
+// for the other errors.
+// ----------------------------------------------------------------
+func constraintError(err error, unknown string) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return echo.NewHTTPError(http.StatusConflict, "Already exists")
+	case "23503": // foreign_key_violation
+		return echo.NewHTTPError(http.StatusBadRequest, unknown)
+	case "23514": // check_violation
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid values")
+	default:
+		return nil
+	}
+}
+
+// ----------------------------------------------------------------
+// Translate the database error to the HTTP one: the missing entities
+// and the constraint violations are the client's errors
+// ----------------------------------------------------------------
+func portfolioError(err error, action string) error {
+	var notFound *godfather.PortfolioNotFound
+	if errors.As(err, &notFound) {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("%s %d not found", notFound.Entity, notFound.ID))
+	}
+	if httpErr := constraintError(err, "Unknown ticker, portfolio or notification"); httpErr != nil {
+		return httpErr
+	}
+	slog.Error(fmt.Sprintf("Failed to %s", action), "error", err)
+	return echo.NewHTTPError(http.StatusInternalServerError, "Database error")
+}
+
+// ----------------------------------------------------------------
+func createPortfolioHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := new(PortfolioRequest)
+		if err := c.Bind(r); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if err := c.Validate(r); err != nil {
+			return err
+		}
+
+		portfolio := &godfather.Portfolio{Name: r.Name, Currency: r.Currency, NotificationID: r.NotificationID}
+		if err := db.CreatePortfolio(portfolio); err != nil {
+			return portfolioError(err, "create portfolio")
+		}
+		portfolio.Holdings = []godfather.Holding{}
+		portfolio.Rules = []godfather.PortfolioRule{}
+		return c.JSON(http.StatusCreated, portfolio)
+	}
+}
+
+// ----------------------------------------------------------------
+func getPortfoliosHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		portfolios, err := db.GetPortfolios()
+		if err != nil {
+			return portfolioError(err, "retrieve portfolios")
+		}
+		if portfolios == nil {
+			portfolios = []godfather.Portfolio{}
+		}
+		return c.JSON(http.StatusOK, portfolios)
+	}
+}
+
+// ----------------------------------------------------------------
+// Get the portfolio with its holdings and rules
+// ----------------------------------------------------------------
+func getPortfolioHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		portfolio, err := db.GetPortfolio(id)
+		if err != nil {
+			return portfolioError(err, "retrieve portfolio")
+		}
+		if portfolio.Holdings == nil {
+			portfolio.Holdings = []godfather.Holding{}
+		}
+		if portfolio.Rules == nil {
+			portfolio.Rules = []godfather.PortfolioRule{}
+		}
+		return c.JSON(http.StatusOK, portfolio)
+	}
+}
+
+// ----------------------------------------------------------------
+func updatePortfolioHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		r := new(PortfolioRequest)
+		if err := c.Bind(r); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if err := c.Validate(r); err != nil {
+			return err
+		}
+
+		portfolio := &godfather.Portfolio{ID: id, Name: r.Name, Currency: r.Currency, NotificationID: r.NotificationID}
+		if err := db.UpdatePortfolio(portfolio); err != nil {
+			return portfolioError(err, "update portfolio")
+		}
+		return c.JSON(http.StatusOK, portfolio)
+	}
+}
+
+// ----------------------------------------------------------------
+func deletePortfolioHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		if err := db.DeletePortfolio(id); err != nil {
+			return portfolioError(err, "delete portfolio")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// ----------------------------------------------------------------
+func addHoldingHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		portfolioID, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		r := new(HoldingRequest)
+		if err := c.Bind(r); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if r.Ticker == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Ticker is required")
+		}
+		if err := c.Validate(r); err != nil {
+			return err
+		}
+
+		holding := &godfather.Holding{
+			PortfolioID:  portfolioID,
+			Ticker:       r.Ticker,
+			Quantity:     r.Quantity,
+			AveragePrice: r.AveragePrice,
+			Currency:     r.Currency,
+		}
+		if err := db.AddHolding(holding); err != nil {
+			return portfolioError(err, "add holding")
+		}
+		return c.JSON(http.StatusCreated, holding)
+	}
+}
+
+// ----------------------------------------------------------------
+// Update the quantity, the average price and the currency of the
+// holding, the ticker can't be changed
+// ----------------------------------------------------------------
+func updateHoldingHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		portfolioID, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		id, err := parseIDParam(c, "holding")
+		if err != nil {
+			return err
+		}
+		r := new(HoldingRequest)
+		if err := c.Bind(r); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if err := c.Validate(r); err != nil {
+			return err
+		}
+
+		holding := &godfather.Holding{
+			ID:           id,
+			PortfolioID:  portfolioID,
+			Quantity:     r.Quantity,
+			AveragePrice: r.AveragePrice,
+			Currency:     r.Currency,
+		}
+		if err := db.UpdateHolding(holding); err != nil {
+			return portfolioError(err, "update holding")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// ----------------------------------------------------------------
+func deleteHoldingHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		portfolioID, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		id, err := parseIDParam(c, "holding")
+		if err != nil {
+			return err
+		}
+		if err := db.DeleteHolding(portfolioID, id); err != nil {
+			return portfolioError(err, "delete holding")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// ----------------------------------------------------------------
+func addPortfolioRuleHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		portfolioID, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		r := new(PortfolioRuleRequest)
+		if err := c.Bind(r); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if err := c.Validate(r); err != nil {
+			return err
+		}
+
+		rule := &godfather.PortfolioRule{
+			PortfolioID: portfolioID,
+			Condition:   r.Condition,
+			Threshold:   r.Threshold,
+			Ticker:      r.Ticker,
+			Active:      r.Active == nil || *r.Active,
+		}
+		if err := db.AddPortfolioRule(rule); err != nil {
+			return portfolioError(err, "add portfolio rule")
+		}
+		return c.JSON(http.StatusCreated, rule)
+	}
+}
+
+// ----------------------------------------------------------------
+func deletePortfolioRuleHandler(db *godfather.Database) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		portfolioID, err := parseIDParam(c, "id")
+		if err != nil {
+			return err
+		}
+		id, err := parseIDParam(c, "rule")
+		if err != nil {
+			return err
+		}
+		if err := db.DeletePortfolioRule(portfolioID, id); err != nil {
+			return portfolioError(err, "delete portfolio rule")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
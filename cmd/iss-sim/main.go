@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+// Simulator of the MOEX ISS endpoints queried by moexmon, for the
+// development and the end-to-end tests
+// ----------------------------------------------------------------
+func main() {
+	var scenarioPath string
+	var address string
+	var verbose bool
+	var help bool
+
+	flag.StringVar(&scenarioPath, "c", "iss-sim.json", "path to scenario file")
+	flag.StringVar(&address, "addr", ":8080", "address to listen on")
+	flag.BoolVar(&verbose, "v", false, "verbose logging")
+	flag.BoolVar(&help, "h", false, "show help")
+	flag.Parse()
+
+	if help {
+		flag.Usage()
+		os.Exit(0)
+	}
+
+	logger := godfather.SetupLogger(verbose)
+
+	scenario, err := ParseScenario(scenarioPath)
+	if err != nil {
+		logger.Error("Failed to parse scenario", "error", err)
+		os.Exit(1)
+	}
+
+	// Create a context that will be canceled on interrupt/termination
+	ctx, stop := signal.NotifyContext(context.Background(),
+		os.Interrupt,    // SIGINT (Ctrl+C)
+		syscall.SIGTERM, // Kubernetes/Systemd termination
+		syscall.SIGQUIT, // Graceful shutdown
+	)
+	defer stop() // Release signal resources when main exits
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           NewServer(NewMarket(scenario, time.Now())),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		slog.Info("Received termination signal, shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shut down ISS simulator", "error", err)
+		}
+	}()
+
+	slog.Info(fmt.Sprintf("Simulating ISS with %d securities on %s", len(scenario.Securities), address))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Failed to serve ISS simulator", "error", err)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Moscow time of the ISS sessions and dates
+var moscowTime = time.FixedZone("MSK", 3*60*60)
+
+// ----------------------------------------------------------------
+// Price and volume of a simulation step
+// ----------------------------------------------------------------
+type tick struct {
+	time   time.Time
+	price  float64
+	volume float64
+}
+
+// ----------------------------------------------------------------
+// Aggregated ticks of a candle, a trading day or the current session
+// ----------------------------------------------------------------
+type bar struct {
+	begin  time.Time
+	end    time.Time // time of the last tick
+	open   float64
+	close  float64
+	high   float64
+	low    float64
+	volume float64
+	value  float64
+}
+
+// ----------------------------------------------------------------
+func (b *bar) add(t tick) {
+	if b.end.IsZero() {
+		b.open, b.high, b.low = t.price, t.price, t.price
+	}
+	b.end = t.time
+	b.close = t.price
+	b.high = max(b.high, t.price)
+	b.low = min(b.low, t.price)
+	b.volume += t.volume
+	b.value += t.volume * t.price
+}
+
+// ----------------------------------------------------------------
+// Group the ticks into the bars beginning at the time returned by
+// begin, in chronological order
+// ----------------------------------------------------------------
+func aggregate(ticks []tick, begin func(time.Time) time.Time) []bar {
+	var bars []bar
+	for _, t := range ticks {
+		start := begin(t.time)
+		if len(bars) == 0 || !bars[len(bars)-1].begin.Equal(start) {
+			bars = append(bars, bar{begin: start})
+		}
+		bars[len(bars)-1].add(t)
+	}
+	return bars
+}
+
+// ----------------------------------------------------------------
+// Current session of the security
+// ----------------------------------------------------------------
+type session struct {
+	bar
+	last      float64
+	prevClose float64
+}
+
+// ----------------------------------------------------------------
+// Simulated security with the walk generated up to the last
+// requested step
+// ----------------------------------------------------------------
+type security struct {
+	config  SecurityConfig
+	random  *rand.Rand
+	walk    []float64 // cumulative log return since the epoch
+	volumes []float64
+}
+
+// ----------------------------------------------------------------
+// Simulated market. The steps are counted from the epoch, the
+// history days before the simulator start.
+// ----------------------------------------------------------------
+type Market struct {
+	mutex      sync.Mutex
+	scenario   *Scenario
+	start      time.Time
+	epoch      time.Time
+	step       time.Duration
+	startStep  int
+	securities map[string]*security
+	listed     []*security // in the scenario order
+	errors     *rand.Rand
+	now        func() time.Time
+}
+
+// ----------------------------------------------------------------
+func NewMarket(scenario *Scenario, start time.Time) *Market {
+	step := time.Duration(scenario.StepSeconds) * time.Second
+	epoch := start.Add(-time.Duration(scenario.HistoryDays) * 24 * time.Hour).Truncate(step)
+	market := &Market{
+		scenario:   scenario,
+		start:      start,
+		epoch:      epoch,
+		step:       step,
+		startStep:  int(start.Sub(epoch) / step),
+		securities: make(map[string]*security, len(scenario.Securities)),
+		errors:     rand.New(rand.NewPCG(scenario.Seed, 0)), //nolint:gosec
+		now:        time.Now,
+	}
+	for _, config := range scenario.Securities {
+		// Seeded by the ticker to keep the walk when the scenario
+		// is reordered
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(config.Ticker))
+		sec := &security{
+			config: config,
+			random: rand.New(rand.NewPCG(scenario.Seed, hash.Sum64())), //nolint:gosec
+		}
+		market.extend(sec, market.startStep)
+		market.securities[config.Ticker] = sec
+		market.listed = append(market.listed, sec)
+	}
+	return market
+}
+
+// ----------------------------------------------------------------
+// Generate the walk of the security up to the step
+// ----------------------------------------------------------------
+func (market *Market) extend(sec *security, step int) {
+	for len(sec.walk) <= step {
+		walk := 0.0
+		if len(sec.walk) > 0 {
+			walk = sec.walk[len(sec.walk)-1] + sec.config.Volatility/100*sec.random.NormFloat64()
+		}
+		sec.walk = append(sec.walk, walk)
+		sec.volumes = append(sec.volumes, math.Round(sec.config.Volume*(0.5+sec.random.Float64())))
+	}
+}
+
+// ----------------------------------------------------------------
+// Seconds of the simulation at the time, negative in the history
+// ----------------------------------------------------------------
+func (market *Market) elapsed(at time.Time) float64 {
+	return at.Sub(market.start).Seconds()
+}
+
+// ----------------------------------------------------------------
+// Step at the time, -1 before the epoch
+// ----------------------------------------------------------------
+func (market *Market) stepAt(at time.Time) int {
+	if at.Before(market.epoch) {
+		return -1
+	}
+	return int(at.Sub(market.epoch) / market.step)
+}
+
+// ----------------------------------------------------------------
+func (market *Market) tick(sec *security, step int) tick {
+	market.extend(sec, step)
+	at := market.epoch.Add(time.Duration(step) * market.step)
+
+	config := sec.config
+	var price float64
+	if len(config.Path) > 0 {
+		index := min(max(step-market.startStep, 0), len(config.Path)-1)
+		price = config.Path[index]
+	} else {
+		price = config.Price * math.Exp(sec.walk[step]-sec.walk[market.startStep])
+	}
+	seconds := market.elapsed(at)
+	for _, spike := range config.Spikes {
+		begin := float64(spike.AtSeconds)
+		if seconds >= begin && (spike.DurationSeconds == 0 || seconds < begin+float64(spike.DurationSeconds)) {
+			price *= 1 + spike.ChangePct/100
+		}
+	}
+	return tick{time: at, price: roundPrice(price, *config.Decimals), volume: sec.volumes[step]}
+}
+
+// ----------------------------------------------------------------
+func roundPrice(price float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(price*scale) / scale
+}
+
+// ----------------------------------------------------------------
+// Ticks of the security within [from, till) up to the current step
+// ----------------------------------------------------------------
+func (market *Market) ticks(sec *security, from time.Time, till time.Time) []tick {
+	last := market.stepAt(market.now())
+	first := max(market.stepAt(from), 0)
+	if market.epoch.Add(time.Duration(first) * market.step).Before(from) {
+		first++
+	}
+
+	var ticks []tick
+	for step := first; step <= last; step++ {
+		t := market.tick(sec, step)
+		if !t.time.Before(till) {
+			break
+		}
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+// ----------------------------------------------------------------
+// Find the security listed now
+// ----------------------------------------------------------------
+func (market *Market) Lookup(ticker string) (*security, bool) {
+	sec, found := market.securities[ticker]
+	if !found || market.missing(sec) {
+		return nil, false
+	}
+	return sec, true
+}
+
+// ----------------------------------------------------------------
+// Securities listed now on the board, in the scenario order
+// ----------------------------------------------------------------
+func (market *Market) Board(engine string, marketName string, board string) []*security {
+	var listed []*security
+	for _, sec := range market.listed {
+		config := sec.config
+		if config.Engine == engine && config.Market == marketName && config.Board == board && !market.missing(sec) {
+			listed = append(listed, sec)
+		}
+	}
+	return listed
+}
+
+// ----------------------------------------------------------------
+func (market *Market) missing(sec *security) bool {
+	seconds := market.elapsed(market.now())
+	for _, window := range sec.config.Missing {
+		if window.contains(seconds) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------
+// HTTP status of the failed response, zero if the request is served
+// ----------------------------------------------------------------
+func (market *Market) Failure() int {
+	market.mutex.Lock()
+	defer market.mutex.Unlock()
+
+	config := market.scenario.Errors
+	seconds := market.elapsed(market.now())
+	for _, outage := range config.Outages {
+		if outage.contains(seconds) {
+			return config.Status
+		}
+	}
+	if config.Rate > 0 && market.errors.Float64() < config.Rate {
+		return config.Status
+	}
+	return 0
+}
+
+// ----------------------------------------------------------------
+// Session of the current day: the trading never stops in the
+// simulation, the day starts at midnight
+// ----------------------------------------------------------------
+func (market *Market) Session(sec *security) session {
+	market.mutex.Lock()
+	defer market.mutex.Unlock()
+
+	now := market.now()
+	current := market.tick(sec, market.stepAt(now))
+	ticks := market.ticks(sec, moscowDay(now), now.Add(time.Nanosecond))
+	if len(ticks) == 0 {
+		ticks = []tick{current}
+	}
+	var today bar
+	for _, t := range ticks {
+		today.add(t)
+	}
+
+	prevClose := today.open
+	if first := market.stepAt(ticks[0].time); first > 0 {
+		prevClose = market.tick(sec, first-1).price
+	}
+	return session{bar: today, last: current.price, prevClose: prevClose}
+}
+
+// ----------------------------------------------------------------
+// Candles of the interval (in ISS terms) beginning within the days
+// [from, till]
+// ----------------------------------------------------------------
+func (market *Market) Candles(sec *security, interval int, from time.Time, till time.Time) []bar {
+	market.mutex.Lock()
+	defer market.mutex.Unlock()
+
+	ticks := market.ticks(sec, moscowDay(from), moscowDay(till).AddDate(0, 0, 1))
+	return aggregate(ticks, func(at time.Time) time.Time { return candleBegin(at, interval) })
+}
+
+// ----------------------------------------------------------------
+// Trading days within [from, till], the current day is not in the
+// history yet
+// ----------------------------------------------------------------
+func (market *Market) History(sec *security, from time.Time, till time.Time) []bar {
+	market.mutex.Lock()
+	defer market.mutex.Unlock()
+
+	end := moscowDay(till).AddDate(0, 0, 1)
+	if today := moscowDay(market.now()); today.Before(end) {
+		end = today
+	}
+	return aggregate(market.ticks(sec, moscowDay(from), end), moscowDay)
+}
+
+// ----------------------------------------------------------------
+func moscowDay(at time.Time) time.Time {
+	at = at.In(moscowTime)
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, moscowTime)
+}
+
+// ----------------------------------------------------------------
+// Begin of the candle containing the time. The intervals are the
+// ISS ones: minutes (1, 10, 60), day (24), week (7), month (31)
+// and quarter (4).
+// ----------------------------------------------------------------
+func candleBegin(at time.Time, interval int) time.Time {
+	day := moscowDay(at)
+	switch interval {
+	case 24:
+		return day
+	case 7:
+		// The weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case 31:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, moscowTime)
+	case 4:
+		return time.Date(day.Year(), day.Month()-(day.Month()-1)%3, 1, 0, 0, 0, 0, moscowTime)
+	default:
+		return day.Add(at.Sub(day).Truncate(time.Duration(interval) * time.Minute))
+	}
+}
+
+// ----------------------------------------------------------------
+// Check the ISS candle interval
+// ----------------------------------------------------------------
+func validInterval(interval int) bool {
+	switch interval {
+	case 1, 10, 60, 24, 7, 31, 4:
+		return true
+	default:
+		return false
+	}
+}
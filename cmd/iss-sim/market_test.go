@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// Market started at 10:00 on Wednesday 2025-03-05 with one minute
+// steps and the clock set by the returned function
+// ----------------------------------------------------------------
+func testMarket(t *testing.T, scenario Scenario) (*Market, func(time.Duration)) {
+	if err := scenario.validate(); err != nil {
+		t.Fatalf("invalid scenario: %v", err)
+	}
+	start := time.Date(2025, 3, 5, 10, 0, 0, 0, moscowTime)
+	market := NewMarket(&scenario, start)
+	now := start
+	market.now = func() time.Time { return now }
+	return market, func(elapsed time.Duration) { now = start.Add(elapsed) }
+}
+
+// ----------------------------------------------------------------
+func TestMarket_RandomWalk(t *testing.T) {
+	scenario := Scenario{Seed: 1, HistoryDays: 2, Securities: []SecurityConfig{{Ticker: "SBER", Price: 300, Volatility: 0.5}}}
+	market, setClock := testMarket(t, scenario)
+	sber, _ := market.Lookup("SBER")
+
+	// The walk ends at the configured price on the start
+	if session := market.Session(sber); session.last != 300 {
+		t.Errorf("expected the start price, got %+v", session)
+	}
+	setClock(30 * time.Minute)
+	first := market.Session(sber)
+	if first.last == 300 || first.volume <= 0 || first.high < first.low || first.open <= 0 {
+		t.Errorf("expected the price to walk, got %+v", first)
+	}
+
+	// The same seed replays the same walk
+	replayed, setReplayClock := testMarket(t, scenario)
+	setReplayClock(30 * time.Minute)
+	sber, _ = replayed.Lookup("SBER")
+	if second := replayed.Session(sber); second.last != first.last || second.volume != first.volume {
+		t.Errorf("expected the same walk, got %+v and %+v", first, second)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMarket_PathAndSpikes(t *testing.T) {
+	market, setClock := testMarket(t, Scenario{Securities: []SecurityConfig{{
+		Ticker: "SBER",
+		Path:   []float64{300, 310, 320},
+		Spikes: []SpikeConfig{
+			{AtSeconds: 120, DurationSeconds: 60, ChangePct: 10},
+			{AtSeconds: 300, ChangePct: -50},
+		},
+	}}})
+	sber, _ := market.Lookup("SBER")
+
+	tests := []struct {
+		elapsed  time.Duration
+		expected float64
+	}{
+		{0, 300},
+		{time.Minute, 310},
+		{2 * time.Minute, 352},
+		{3 * time.Minute, 320},
+		{5 * time.Minute, 160},
+		{time.Hour, 160},
+	}
+	for _, test := range tests {
+		setClock(test.elapsed)
+		if last := market.Session(sber).last; last != test.expected {
+			t.Errorf("expected %g after %v, got %g", test.expected, test.elapsed, last)
+		}
+	}
+
+	// The history before the start is the first price of the path
+	session := market.Session(sber)
+	if session.open != 300 || session.high != 352 || session.low != 160 || session.prevClose != 300 {
+		t.Errorf("unexpected session: %+v", session)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMarket_CandlesAndHistory(t *testing.T) {
+	market, setClock := testMarket(t, Scenario{HistoryDays: 3, Securities: []SecurityConfig{{Ticker: "SBER", Path: []float64{300}, Volume: 10}}})
+	setClock(25 * time.Minute)
+	sber, _ := market.Lookup("SBER")
+
+	day := time.Date(2025, 3, 5, 0, 0, 0, 0, moscowTime)
+	candles := market.Candles(sber, 10, day, day)
+	// From midnight to 10:25
+	if len(candles) != 63 {
+		t.Fatalf("expected 63 candles, got %d", len(candles))
+	}
+	last := candles[len(candles)-1]
+	if !last.begin.Equal(day.Add(10*time.Hour+20*time.Minute)) || !last.end.Equal(day.Add(10*time.Hour+25*time.Minute)) ||
+		last.close != 300 {
+		t.Errorf("unexpected last candle: %+v", last)
+	}
+	for _, candle := range candles {
+		if candle.volume < 50 || candle.volume > 150 || math.Abs(candle.value-candle.volume*300) > 1e-6 {
+			t.Errorf("unexpected candle volume: %+v", candle)
+		}
+	}
+
+	// The days of the history before today, the first one is partial
+	days := market.History(sber, day.AddDate(0, 0, -10), day)
+	if len(days) != 3 || !days[0].begin.Equal(day.AddDate(0, 0, -3)) || !days[2].begin.Equal(day.AddDate(0, 0, -1)) {
+		t.Errorf("unexpected history: %+v", days)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMarket_MissingAndFailures(t *testing.T) {
+	market, setClock := testMarket(t, Scenario{
+		Errors: ErrorConfig{Status: 503, Outages: []Window{{FromSeconds: 60, ToSeconds: 120}}},
+		Securities: []SecurityConfig{
+			{Ticker: "SBER", Price: 300},
+			{Ticker: "GAZP", Price: 150, Missing: []Window{{FromSeconds: 60}}},
+			{Ticker: "SU26238RMFS4", Market: "bonds", Board: "TQOB", Price: 60},
+		},
+	})
+	if listed := market.Board("stock", "shares", "TQBR"); len(listed) != 2 || market.Failure() != 0 {
+		t.Errorf("expected both shares listed, got %d", len(listed))
+	}
+
+	setClock(90 * time.Second)
+	if _, found := market.Lookup("GAZP"); found {
+		t.Error("expected GAZP to be missing")
+	}
+	if listed := market.Board("stock", "shares", "TQBR"); len(listed) != 1 || listed[0].config.Ticker != "SBER" {
+		t.Errorf("expected SBER only, got %d", len(listed))
+	}
+	if status := market.Failure(); status != 503 {
+		t.Errorf("expected the outage, got %d", status)
+	}
+
+	setClock(2 * time.Minute)
+	if status := market.Failure(); status != 0 {
+		t.Errorf("expected the outage to end, got %d", status)
+	}
+	market.scenario.Errors.Rate = 1
+	if status := market.Failure(); status != 503 {
+		t.Errorf("expected the random failure, got %d", status)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestCandleBegin(t *testing.T) {
+	// 2025-03-05 is Wednesday
+	at := time.Date(2025, 3, 5, 10, 17, 0, 0, moscowTime)
+	tests := []struct {
+		interval int
+		expected string
+	}{
+		{1, "2025-03-05 10:17"},
+		{10, "2025-03-05 10:10"},
+		{60, "2025-03-05 10:00"},
+		{24, "2025-03-05 00:00"},
+		{7, "2025-03-03 00:00"},
+		{31, "2025-03-01 00:00"},
+		{4, "2025-01-01 00:00"},
+	}
+	for _, test := range tests {
+		if begin := candleBegin(at, test.interval).Format("2006-01-02 15:04"); begin != test.expected {
+			t.Errorf("expected %s for interval %d, got %s", test.expected, test.interval, begin)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Defaults of the scenario
+const (
+	defaultStepSeconds = 60
+	defaultHistoryDays = 30
+	defaultVolatility  = 0.2
+	defaultVolume      = 1000
+	defaultDecimals    = 2
+	defaultErrorStatus = 500
+)
+
+// ----------------------------------------------------------------
+// Period of the simulation in seconds since the simulator start,
+// open ended if to_seconds is not set
+// ----------------------------------------------------------------
+type Window struct {
+	FromSeconds int `json:"from_seconds"`
+	ToSeconds   int `json:"to_seconds"`
+}
+
+// ----------------------------------------------------------------
+func (window Window) contains(seconds float64) bool {
+	return seconds >= float64(window.FromSeconds) && (window.ToSeconds == 0 || seconds < float64(window.ToSeconds))
+}
+
+// ----------------------------------------------------------------
+// Price jump of the security. The price returns to the walk after
+// the duration, the jump is kept if the duration is not set.
+// ----------------------------------------------------------------
+type SpikeConfig struct {
+	AtSeconds       int     `json:"at_seconds"`
+	DurationSeconds int     `json:"duration_seconds"`
+	ChangePct       float64 `json:"change_pct"`
+}
+
+// ----------------------------------------------------------------
+// Failed ISS responses: a share of the requests failed at random
+// and the outages failing all the requests
+// ----------------------------------------------------------------
+type ErrorConfig struct {
+	Rate    float64  `json:"rate"`
+	Status  int      `json:"status"`
+	Outages []Window `json:"outages"`
+}
+
+// ----------------------------------------------------------------
+// Simulated security. The price follows a random walk with the
+// volatility (standard deviation of the step return in percent)
+// ending at the price on the simulator start, or the scripted path
+// of the step prices. The prices are rounded to the decimals, two
+// if not set. The fields are reported as is in the ISS
+// columns of the same name, e.g. ASSETCODE or MATDATE.
+// ----------------------------------------------------------------
+type SecurityConfig struct {
+	Ticker     string         `json:"ticker"`
+	Name       string         `json:"name"`
+	Engine     string         `json:"engine"`
+	Market     string         `json:"market"`
+	Board      string         `json:"board"`
+	LotSize    int            `json:"lot_size"`
+	Currency   string         `json:"currency"`
+	Price      float64        `json:"price"`
+	Decimals   *int           `json:"decimals"`
+	Volatility float64        `json:"volatility"`
+	Volume     float64        `json:"volume"`
+	Path       []float64      `json:"path"`
+	Spikes     []SpikeConfig  `json:"spikes"`
+	Missing    []Window       `json:"missing"`
+	Fields     map[string]any `json:"fields"`
+}
+
+// ----------------------------------------------------------------
+// Simulated market: the prices change every step, the history of
+// the walk covers the days before the simulator start
+// ----------------------------------------------------------------
+type Scenario struct {
+	Seed        uint64           `json:"seed"`
+	StepSeconds int              `json:"step_seconds"`
+	HistoryDays int              `json:"history_days"`
+	Errors      ErrorConfig      `json:"errors"`
+	Securities  []SecurityConfig `json:"securities"`
+}
+
+// ----------------------------------------------------------------
+func ParseScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario %s: %w", path, err)
+	}
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if err := scenario.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// ----------------------------------------------------------------
+// Check the scenario and fill in the defaults
+// ----------------------------------------------------------------
+func (scenario *Scenario) validate() error {
+	if scenario.StepSeconds == 0 {
+		scenario.StepSeconds = defaultStepSeconds
+	}
+	if scenario.HistoryDays == 0 {
+		scenario.HistoryDays = defaultHistoryDays
+	}
+	if scenario.StepSeconds < 0 || scenario.HistoryDays < 0 {
+		return fmt.Errorf("step_seconds and history_days must be positive")
+	}
+	if scenario.Errors.Rate < 0 || scenario.Errors.Rate > 1 {
+		return fmt.Errorf("error rate %g is out of [0, 1]", scenario.Errors.Rate)
+	}
+	if scenario.Errors.Status == 0 {
+		scenario.Errors.Status = defaultErrorStatus
+	}
+	if scenario.Errors.Status < 400 || scenario.Errors.Status > 599 {
+		return fmt.Errorf("error status %d is not an HTTP error", scenario.Errors.Status)
+	}
+	if len(scenario.Securities) == 0 {
+		return fmt.Errorf("no securities")
+	}
+
+	tickers := make(map[string]bool, len(scenario.Securities))
+	for i := range scenario.Securities {
+		security := &scenario.Securities[i]
+		if security.Ticker == "" {
+			return fmt.Errorf("security %d has no ticker", i+1)
+		}
+		if tickers[security.Ticker] {
+			return fmt.Errorf("duplicate security %s", security.Ticker)
+		}
+		tickers[security.Ticker] = true
+		if err := security.validate(); err != nil {
+			return fmt.Errorf("security %s: %w", security.Ticker, err)
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+func (security *SecurityConfig) validate() error {
+	if security.Engine == "" {
+		security.Engine = "stock"
+	}
+	if security.Market == "" {
+		security.Market = "shares"
+	}
+	if security.Board == "" {
+		security.Board = "TQBR"
+	}
+	if security.Name == "" {
+		security.Name = security.Ticker
+	}
+	if security.LotSize == 0 {
+		security.LotSize = 1
+	}
+	if security.Currency == "" {
+		security.Currency = "SUR"
+	}
+	if security.Volatility == 0 {
+		security.Volatility = defaultVolatility
+	}
+	if security.Volume == 0 {
+		security.Volume = defaultVolume
+	}
+	if security.Decimals == nil {
+		decimals := defaultDecimals
+		security.Decimals = &decimals
+	}
+
+	if len(security.Path) == 0 && security.Price <= 0 {
+		return fmt.Errorf("either a positive price or the path is required")
+	}
+	for _, price := range security.Path {
+		if price <= 0 {
+			return fmt.Errorf("path price %g must be positive", price)
+		}
+	}
+	if security.Volatility < 0 || security.Volume < 0 || security.LotSize < 0 || *security.Decimals < 0 {
+		return fmt.Errorf("volatility, volume, lot_size and decimals must be positive")
+	}
+	for _, spike := range security.Spikes {
+		if spike.ChangePct <= -100 {
+			return fmt.Errorf("spike of %g%% makes the price negative", spike.ChangePct)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ----------------------------------------------------------------
+func writeScenario(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write scenario: %v", err)
+	}
+	return path
+}
+
+// ----------------------------------------------------------------
+func TestParseScenario_Defaults(t *testing.T) {
+	path := writeScenario(t, `{
+		"seed": 7,
+		"securities": [
+			{ "ticker": "SBER", "price": 300 },
+			{ "ticker": "SiZ6", "engine": "futures", "market": "forts", "board": "RFUD", "price": 92000, "decimals": 0,
+			  "fields": { "ASSETCODE": "Si" } }
+		]
+	}`)
+	scenario, err := ParseScenario(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scenario.StepSeconds != defaultStepSeconds || scenario.HistoryDays != defaultHistoryDays || scenario.Errors.Status != defaultErrorStatus {
+		t.Errorf("unexpected defaults: %+v", scenario)
+	}
+	sber := scenario.Securities[0]
+	if sber.Engine != "stock" || sber.Market != "shares" || sber.Board != "TQBR" || sber.Name != "SBER" ||
+		sber.LotSize != 1 || sber.Currency != "SUR" || *sber.Decimals != defaultDecimals || sber.Volume != defaultVolume {
+		t.Errorf("unexpected security defaults: %+v", sber)
+	}
+	futures := scenario.Securities[1]
+	if futures.Board != "RFUD" || *futures.Decimals != 0 || futures.Fields["ASSETCODE"] != "Si" {
+		t.Errorf("unexpected security: %+v", futures)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestParseScenario_Invalid(t *testing.T) {
+	tests := []string{
+		`{"securities": []}`,
+		`{"securities": [{"price": 300}]}`,
+		`{"securities": [{"ticker": "SBER", "price": 300}, {"ticker": "SBER", "price": 301}]}`,
+		`{"securities": [{"ticker": "SBER"}]}`,
+		`{"securities": [{"ticker": "SBER", "path": [300, 0]}]}`,
+		`{"securities": [{"ticker": "SBER", "price": 300, "spikes": [{"change_pct": -100}]}]}`,
+		`{"errors": {"rate": 2}, "securities": [{"ticker": "SBER", "price": 300}]}`,
+		`{"errors": {"status": 200}, "securities": [{"ticker": "SBER", "price": 300}]}`,
+		`{"step_seconds": -60, "securities": [{"ticker": "SBER", "price": 300}]}`,
+		`{"securities": `,
+	}
+	for _, content := range tests {
+		if _, err := ParseScenario(writeScenario(t, content)); err == nil {
+			t.Errorf("expected error for %s, got nil", content)
+		}
+	}
+	if _, err := ParseScenario(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for the missing file, got nil")
+	}
+}
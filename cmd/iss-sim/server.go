@@ -0,0 +1,484 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes of the ISS responses
+const (
+	candlesPageSize = 500
+	historyPageSize = 100
+)
+
+// ----------------------------------------------------------------
+// ISS table: the column names and the rows of the values
+// ----------------------------------------------------------------
+type block struct {
+	Columns []string `json:"columns"`
+	Data    [][]any  `json:"data"`
+}
+
+// ----------------------------------------------------------------
+// Build the table of the requested columns (<name>.columns, all
+// by default) with the values looked up by column name
+// ----------------------------------------------------------------
+func newBlock(query url.Values, name string, defaults []string, rows []func(string) any) block {
+	columns := defaults
+	if requested := query.Get(name + ".columns"); requested != "" {
+		columns = strings.Split(requested, ",")
+	}
+	data := make([][]any, 0, len(rows))
+	for _, value := range rows {
+		row := make([]any, len(columns))
+		for i, column := range columns {
+			row[i] = value(column)
+		}
+		data = append(data, row)
+	}
+	return block{Columns: columns, Data: data}
+}
+
+// ----------------------------------------------------------------
+// Blocks of the response, all the supported ones unless iss.only
+// is set
+// ----------------------------------------------------------------
+func requestedBlocks(query url.Values, supported ...string) []string {
+	only := query.Get("iss.only")
+	if only == "" {
+		return supported
+	}
+	var blocks []string
+	for _, name := range strings.Split(only, ",") {
+		if slices.Contains(supported, name) {
+			blocks = append(blocks, name)
+		}
+	}
+	return blocks
+}
+
+// ----------------------------------------------------------------
+// Number as reported by ISS, null if not defined
+// ----------------------------------------------------------------
+func number(value float64) any {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return value
+}
+
+// ----------------------------------------------------------------
+// Page of the rows starting at the start query parameter
+// ----------------------------------------------------------------
+func page[T any](query url.Values, rows []T, size int) []T {
+	start, err := strconv.Atoi(query.Get("start"))
+	if err != nil || start < 0 {
+		start = 0
+	}
+	if start >= len(rows) {
+		return nil
+	}
+	return rows[start:min(start+size, len(rows))]
+}
+
+// ----------------------------------------------------------------
+// Date of the query parameter, the fallback if it is not set
+// ----------------------------------------------------------------
+func queryDate(query url.Values, name string, fallback time.Time) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	date, err := time.ParseInLocation(time.DateOnly, value, moscowTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date '%s'", name, value)
+	}
+	return date, nil
+}
+
+// ----------------------------------------------------------------
+// Simulated ISS server
+// ----------------------------------------------------------------
+type Server struct {
+	market *Market
+}
+
+// ----------------------------------------------------------------
+// Create the handler of the ISS endpoints queried by moexmon
+// ----------------------------------------------------------------
+func NewServer(market *Market) http.Handler {
+	server := &Server{market: market}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /iss/engines/{engine}/markets/{market}/boards/{board}/securities.json", server.handleBoardSecurities)
+	mux.HandleFunc("GET /iss/engines/{engine}/markets/{market}/boards/{board}/securities/{security}", server.handleBoardSecurities)
+	mux.HandleFunc("GET /iss/engines/{engine}/markets/{market}/boards/{board}/securities/{security}/candles.json", server.handleCandles)
+	mux.HandleFunc("GET /iss/history/engines/{engine}/markets/{market}/boards/{board}/securities/{security}", server.handleHistory)
+	mux.HandleFunc("GET /iss/securities/{security}", server.handleSecurityBoards)
+	mux.HandleFunc("GET /iss/securities/{security}/dividends.json", server.handleDividends)
+	mux.HandleFunc("GET /iss/securities/{security}/bondization.json", server.handleBondization)
+	mux.HandleFunc("GET /iss/engines/{engine}", server.handleEngine)
+	return server.serve(mux)
+}
+
+// ----------------------------------------------------------------
+// Log the requests and fail them as scripted by the scenario
+// ----------------------------------------------------------------
+func (server *Server) serve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Debug(fmt.Sprintf("%s %s", r.Method, r.URL.String()))
+		if status := server.market.Failure(); status != 0 {
+			slog.Debug(fmt.Sprintf("Failing %s with status %d", r.URL.Path, status))
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ----------------------------------------------------------------
+func writeBlocks(w http.ResponseWriter, blocks map[string]block) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(blocks); err != nil {
+		slog.Error("Failed to write the response", "error", err)
+	}
+}
+
+// ----------------------------------------------------------------
+// Security of the path without the .json suffix, false if it is not
+// a JSON request
+// ----------------------------------------------------------------
+func jsonSecurity(r *http.Request) (string, bool) {
+	return strings.CutSuffix(r.PathValue("security"), ".json")
+}
+
+// ----------------------------------------------------------------
+// Market data and the description of the securities listed on the
+// board, either all of them, the ones of the securities query
+// parameter or the one of the path
+// ----------------------------------------------------------------
+func (server *Server) handleBoardSecurities(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	listed := server.market.Board(r.PathValue("engine"), r.PathValue("market"), r.PathValue("board"))
+	var requested []string
+	if r.PathValue("security") != "" {
+		security, isJSON := jsonSecurity(r)
+		if !isJSON {
+			http.NotFound(w, r)
+			return
+		}
+		requested = []string{security}
+	} else if securities := query.Get("securities"); securities != "" {
+		requested = strings.Split(securities, ",")
+	}
+	if requested != nil {
+		listed = slices.DeleteFunc(listed, func(sec *security) bool {
+			return !slices.Contains(requested, sec.config.Ticker)
+		})
+	}
+
+	blocks := make(map[string]block)
+	for _, name := range requestedBlocks(query, "securities", "marketdata", "marketdata_yields") {
+		rows := make([]func(string) any, 0, len(listed))
+		for _, sec := range listed {
+			switch name {
+			case "securities":
+				rows = append(rows, server.securityColumns(sec))
+			case "marketdata":
+				rows = append(rows, server.marketdataColumns(sec))
+			default:
+				rows = append(rows, fieldColumns(sec, nil))
+			}
+		}
+		defaults := map[string][]string{
+			"securities":        {"SECID", "BOARDID", "SHORTNAME", "SECNAME", "PREVPRICE", "LOTSIZE", "DECIMALS", "CURRENCYID"},
+			"marketdata":        {"SECID", "BOARDID", "LAST", "OPEN", "LOW", "HIGH", "WAPRICE", "LASTTOPREVPRICE", "VOLTODAY", "VALTODAY", "UPDATETIME"},
+			"marketdata_yields": {"SECID", "BOARDID"},
+		}[name]
+		blocks[name] = newBlock(query, name, defaults, rows)
+	}
+	writeBlocks(w, blocks)
+}
+
+// ----------------------------------------------------------------
+// Columns of the scenario fields, the values are looked up in the
+// computed ones first
+// ----------------------------------------------------------------
+func fieldColumns(sec *security, values map[string]any) func(string) any {
+	return func(column string) any {
+		switch column {
+		case "SECID":
+			return sec.config.Ticker
+		case "BOARDID":
+			return sec.config.Board
+		}
+		if value, found := values[column]; found {
+			return value
+		}
+		return sec.config.Fields[column]
+	}
+}
+
+// ----------------------------------------------------------------
+func (server *Server) securityColumns(sec *security) func(string) any {
+	config := sec.config
+	return fieldColumns(sec, map[string]any{
+		"SHORTNAME":  config.Name,
+		"SECNAME":    config.Name,
+		"PREVPRICE":  number(server.market.Session(sec).prevClose),
+		"LOTSIZE":    config.LotSize,
+		"DECIMALS":   *config.Decimals,
+		"CURRENCYID": config.Currency,
+	})
+}
+
+// ----------------------------------------------------------------
+func (server *Server) marketdataColumns(sec *security) func(string) any {
+	session := server.market.Session(sec)
+	change := math.NaN()
+	if session.prevClose > 0 {
+		change = math.Round((session.last/session.prevClose-1)*1e4) / 100
+	}
+	wap := math.NaN()
+	if session.volume > 0 {
+		wap = roundPrice(session.value/session.volume, *sec.config.Decimals)
+	}
+	return fieldColumns(sec, map[string]any{
+		"LAST":            number(session.last),
+		"OPEN":            number(session.open),
+		"LOW":             number(session.low),
+		"HIGH":            number(session.high),
+		"WAPRICE":         number(wap),
+		"CHANGE":          number(roundPrice(session.last-session.prevClose, *sec.config.Decimals)),
+		"LASTTOPREVPRICE": number(change),
+		"VOLTODAY":        number(session.volume),
+		"VALTODAY":        number(math.Round(session.value)),
+		"UPDATETIME":      session.end.In(moscowTime).Format(time.TimeOnly),
+		"SYSTIME":         server.market.now().In(moscowTime).Format(time.DateTime),
+	})
+}
+
+// ----------------------------------------------------------------
+// Candles of the security within the from and till days, the
+// newest first if iss.reverse is set
+// ----------------------------------------------------------------
+func (server *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	interval := 10
+	if value := query.Get("interval"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || !validInterval(parsed) {
+			http.Error(w, fmt.Sprintf("invalid interval '%s'", value), http.StatusBadRequest)
+			return
+		}
+		interval = parsed
+	}
+	now := server.market.now()
+	from, err := queryDate(query, "from", server.market.epoch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	till, err := queryDate(query, "till", now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var candles []bar
+	if sec, found := server.listedOn(r); found {
+		candles = server.market.Candles(sec, interval, from, till)
+	}
+	if query.Get("iss.reverse") == "true" {
+		slices.Reverse(candles)
+	}
+	rows := make([]func(string) any, 0, candlesPageSize)
+	for _, candle := range page(query, candles, candlesPageSize) {
+		rows = append(rows, barColumns(candle))
+	}
+	columns := []string{"open", "close", "high", "low", "value", "volume", "begin", "end"}
+	writeBlocks(w, map[string]block{"candles": newBlock(query, "candles", columns, rows)})
+}
+
+// ----------------------------------------------------------------
+func barColumns(b bar) func(string) any {
+	return func(column string) any {
+		switch column {
+		case "open", "OPEN":
+			return b.open
+		case "close", "CLOSE", "LEGALCLOSEPRICE":
+			return b.close
+		case "high", "HIGH":
+			return b.high
+		case "low", "LOW":
+			return b.low
+		case "value", "VALUE":
+			return math.Round(b.value)
+		case "volume", "VOLUME":
+			return b.volume
+		case "WAPRICE":
+			return number(b.value / b.volume)
+		case "begin":
+			return b.begin.In(moscowTime).Format(time.DateTime)
+		case "end":
+			return b.end.In(moscowTime).Format(time.DateTime)
+		case "TRADEDATE":
+			return b.begin.In(moscowTime).Format(time.DateOnly)
+		default:
+			return nil
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Security of the path listed on the board of the path
+// ----------------------------------------------------------------
+func (server *Server) listedOn(r *http.Request) (*security, bool) {
+	ticker, _ := jsonSecurity(r)
+	sec, found := server.market.Lookup(ticker)
+	if !found {
+		return nil, false
+	}
+	config := sec.config
+	return sec, config.Engine == r.PathValue("engine") && config.Market == r.PathValue("market") && config.Board == r.PathValue("board")
+}
+
+// ----------------------------------------------------------------
+// Daily history of the security before the current day
+// ----------------------------------------------------------------
+func (server *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if _, isJSON := jsonSecurity(r); !isJSON {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	from, err := queryDate(query, "from", server.market.epoch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	till, err := queryDate(query, "till", server.market.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var days []bar
+	sec, found := server.listedOn(r)
+	if found {
+		days = server.market.History(sec, from, till)
+	}
+	rows := make([]func(string) any, 0, historyPageSize)
+	for _, day := range page(query, days, historyPageSize) {
+		values, fields := barColumns(day), fieldColumns(sec, nil)
+		rows = append(rows, func(column string) any {
+			if value := values(column); value != nil {
+				return value
+			}
+			return fields(column)
+		})
+	}
+	columns := []string{"BOARDID", "TRADEDATE", "SECID", "OPEN", "LOW", "HIGH", "CLOSE", "WAPRICE", "VOLUME", "VALUE"}
+	writeBlocks(w, map[string]block{"history": newBlock(query, "history", columns, rows)})
+}
+
+// ----------------------------------------------------------------
+// Boards the security is traded on, the simulated securities have
+// a single primary one
+// ----------------------------------------------------------------
+func (server *Server) handleSecurityBoards(w http.ResponseWriter, r *http.Request) {
+	ticker, isJSON := jsonSecurity(r)
+	if !isJSON {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	var rows []func(string) any
+	if sec, found := server.market.Lookup(ticker); found {
+		config := sec.config
+		rows = append(rows, func(column string) any {
+			return map[string]any{
+				"secid":      config.Ticker,
+				"boardid":    config.Board,
+				"title":      config.Name,
+				"market":     config.Market,
+				"engine":     config.Engine,
+				"is_traded":  1,
+				"is_primary": 1,
+				"currencyid": config.Currency,
+			}[column]
+		})
+	}
+	blocks := make(map[string]block)
+	for _, name := range requestedBlocks(query, "boards") {
+		columns := []string{"secid", "boardid", "title", "market", "engine", "is_traded", "is_primary", "currencyid"}
+		blocks[name] = newBlock(query, name, columns, rows)
+	}
+	writeBlocks(w, blocks)
+}
+
+// ----------------------------------------------------------------
+// No dividends are paid in the simulation
+// ----------------------------------------------------------------
+func (server *Server) handleDividends(w http.ResponseWriter, r *http.Request) {
+	columns := []string{"secid", "isin", "registryclosedate", "value", "currencyid"}
+	writeBlocks(w, map[string]block{"dividends": newBlock(r.URL.Query(), "dividends", columns, nil)})
+}
+
+// ----------------------------------------------------------------
+// No coupons nor offers are scheduled in the simulation
+// ----------------------------------------------------------------
+func (server *Server) handleBondization(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	blocks := make(map[string]block)
+	for _, name := range requestedBlocks(query, "coupons", "offers") {
+		columns := map[string][]string{
+			"coupons": {"isin", "name", "issuevalue", "coupondate", "recorddate", "startdate", "initialfacevalue", "facevalue", "faceunit", "value", "valueprc", "value_rub", "secid", "primary_boardid"},
+			"offers":  {"isin", "name", "issuevalue", "offerdate", "offerdatestart", "offerdateend", "facevalue", "faceunit", "price", "value", "agent", "offertype", "secid", "primary_boardid"},
+		}[name]
+		blocks[name] = newBlock(query, name, columns, nil)
+	}
+	writeBlocks(w, blocks)
+}
+
+// ----------------------------------------------------------------
+// Engine schedule: the simulated market trades every day
+// ----------------------------------------------------------------
+func (server *Server) handleEngine(w http.ResponseWriter, r *http.Request) {
+	engine, isJSON := strings.CutSuffix(r.PathValue("engine"), ".json")
+	if !isJSON {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	blocks := make(map[string]block)
+	for _, name := range requestedBlocks(query, "engine", "timetable", "dailytable") {
+		var columns []string
+		var rows []func(string) any
+		switch name {
+		case "engine":
+			columns = []string{"id", "name", "title"}
+			rows = append(rows, func(column string) any {
+				return map[string]any{"id": 1, "name": engine, "title": engine}[column]
+			})
+		case "timetable":
+			columns = []string{"week_day", "is_work_day", "start_time", "stop_time"}
+			for day := 1; day <= 7; day++ {
+				rows = append(rows, func(column string) any {
+					return map[string]any{"week_day": day, "is_work_day": 1, "start_time": "00:00:00", "stop_time": "23:59:59"}[column]
+				})
+			}
+		case "dailytable":
+			columns = []string{"date", "is_work_day", "start_time", "stop_time"}
+		}
+		blocks[name] = newBlock(query, name, columns, rows)
+	}
+	writeBlocks(w, blocks)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+func testServer(t *testing.T) (*httptest.Server, func(time.Duration)) {
+	market, setClock := testMarket(t, Scenario{HistoryDays: 3, Securities: []SecurityConfig{
+		{Ticker: "SBER", Path: []float64{300, 303}, Volume: 10},
+		{Ticker: "GAZP", Price: 150, Missing: []Window{{FromSeconds: 60}}},
+		{Ticker: "SU26238RMFS4", Market: "bonds", Board: "TQOB", Price: 60,
+			Fields: map[string]any{"FACEVALUE": 1000, "MATDATE": "2041-05-15", "EFFECTIVEYIELD": 14.2}},
+	}})
+	server := httptest.NewServer(NewServer(market))
+	t.Cleanup(server.Close)
+	return server, setClock
+}
+
+// ----------------------------------------------------------------
+func getBlocks(t *testing.T, server *httptest.Server, path string) map[string]block {
+	res, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("failed to query %s: %v", path, err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d for %s", res.StatusCode, path)
+	}
+	var blocks map[string]block
+	if err := json.NewDecoder(res.Body).Decode(&blocks); err != nil {
+		t.Fatalf("failed to decode %s: %v", path, err)
+	}
+	return blocks
+}
+
+// ----------------------------------------------------------------
+func TestServer_BoardSecurities(t *testing.T) {
+	server, setClock := testServer(t)
+	setClock(time.Minute)
+
+	blocks := getBlocks(t, server, "/iss/engines/stock/markets/shares/boards/TQBR/securities.json?iss.meta=off&iss.only=marketdata"+
+		"&marketdata.columns=SECID,LAST,OPEN,LASTTOPREVPRICE,VOLTODAY&securities=SBER,GAZP")
+	marketdata := blocks["marketdata"]
+	if len(blocks) != 1 || len(marketdata.Data) != 1 || len(marketdata.Columns) != 5 {
+		t.Fatalf("expected the SBER marketdata only, got %+v", blocks)
+	}
+	row := marketdata.Data[0]
+	// The history is the first price of the path
+	if row[0] != "SBER" || row[1] != 303.0 || row[2] != 300.0 || row[3] != 1.0 || row[4].(float64) <= 0 {
+		t.Errorf("unexpected marketdata: %v", row)
+	}
+
+	blocks = getBlocks(t, server, "/iss/engines/stock/markets/bonds/boards/TQOB/securities.json?iss.meta=off"+
+		"&iss.only=marketdata,marketdata_yields,securities&marketdata_yields.columns=SECID,EFFECTIVEYIELD,DURATION"+
+		"&securities.columns=SECID,FACEVALUE,MATDATE,LOTSIZE&securities=SU26238RMFS4")
+	if yields := blocks["marketdata_yields"].Data; len(yields) != 1 || yields[0][1] != 14.2 || yields[0][2] != nil {
+		t.Errorf("unexpected yields: %v", yields)
+	}
+	if securities := blocks["securities"].Data; len(securities) != 1 || securities[0][1] != 1000.0 ||
+		securities[0][2] != "2041-05-15" || securities[0][3] != 1.0 {
+		t.Errorf("unexpected securities: %v", securities)
+	}
+
+	// The single security, missing or on another board
+	blocks = getBlocks(t, server, "/iss/engines/stock/markets/shares/boards/TQBR/securities/SBER.json?iss.only=marketdata&marketdata.columns=LAST")
+	if data := blocks["marketdata"].Data; len(data) != 1 || data[0][0] != 303.0 {
+		t.Errorf("unexpected price: %v", data)
+	}
+	for _, path := range []string{
+		"/iss/engines/stock/markets/shares/boards/TQBR/securities/GAZP.json?iss.only=marketdata",
+		"/iss/engines/stock/markets/shares/boards/TQTF/securities/SBER.json?iss.only=marketdata",
+	} {
+		if data := getBlocks(t, server, path)["marketdata"].Data; len(data) != 0 {
+			t.Errorf("expected no marketdata for %s, got %v", path, data)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestServer_Candles(t *testing.T) {
+	server, setClock := testServer(t)
+	setClock(15 * time.Minute)
+
+	path := "/iss/engines/stock/markets/shares/boards/TQBR/securities/SBER/candles.json?iss.meta=off&interval=10&from=2025-03-04&till=2025-03-05"
+	candles := getBlocks(t, server, path+"&start=0")["candles"]
+	// The 24 hours of the previous day and today until 10:15
+	if len(candles.Data) != 144+62 || candles.Columns[0] != "open" || candles.Data[0][6] != "2025-03-04 00:00:00" {
+		t.Fatalf("unexpected candles: %d candles from %v", len(candles.Data), candles.Data[0])
+	}
+	if rest := getBlocks(t, server, path+"&start=206")["candles"].Data; len(rest) != 0 {
+		t.Errorf("expected no more candles, got %d", len(rest))
+	}
+	minutes := "/iss/engines/stock/markets/shares/boards/TQBR/securities/SBER/candles.json?interval=1&from=2025-03-05"
+	if page := getBlocks(t, server, minutes+"&start=500")["candles"].Data; len(page) != 616-500 {
+		t.Errorf("unexpected second page: %d candles", len(page))
+	}
+
+	reversed := getBlocks(t, server, path+"&iss.reverse=true&start=0")["candles"].Data
+	if reversed[0][6] != "2025-03-05 10:10:00" || reversed[0][7] != "2025-03-05 10:15:00" || reversed[0][1] != 303.0 {
+		t.Errorf("unexpected last candle: %v", reversed[0])
+	}
+
+	res, err := http.Get(server.URL + "/iss/engines/stock/markets/shares/boards/TQBR/securities/SBER/candles.json?interval=5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close() //nolint:errcheck,gosec
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for the invalid interval, got %d", res.StatusCode)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestServer_HistoryAndBoards(t *testing.T) {
+	server, _ := testServer(t)
+
+	history := getBlocks(t, server, "/iss/history/engines/stock/markets/shares/boards/TQBR/securities/SBER.json?iss.meta=off"+
+		"&iss.only=history&history.columns=TRADEDATE,VOLUME,VALUE,SECID&from=2025-03-01&start=0")["history"]
+	if len(history.Data) != 3 || history.Data[2][0] != "2025-03-04" || history.Data[2][3] != "SBER" ||
+		history.Data[2][2] != history.Data[2][1].(float64)*300 {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	boards := getBlocks(t, server, "/iss/securities/SU26238RMFS4.json?iss.meta=off&iss.only=boards&boards.columns=boardid,market,engine,is_traded,is_primary")["boards"]
+	if len(boards.Data) != 1 || boards.Data[0][0] != "TQOB" || boards.Data[0][1] != "bonds" || boards.Data[0][4] != 1.0 {
+		t.Errorf("unexpected boards: %+v", boards)
+	}
+	if data := getBlocks(t, server, "/iss/securities/LKOH.json?iss.only=boards")["boards"].Data; len(data) != 0 {
+		t.Errorf("expected no boards for the unknown security, got %v", data)
+	}
+
+	schedule := getBlocks(t, server, "/iss/engines/stock.json?iss.meta=off&iss.only=timetable,dailytable")
+	if len(schedule) != 2 || len(schedule["timetable"].Data) != 7 || schedule["timetable"].Data[6][0] != 7.0 {
+		t.Errorf("unexpected schedule: %+v", schedule)
+	}
+	if coupons := getBlocks(t, server, "/iss/securities/SU26238RMFS4/bondization.json?iss.only=coupons,offers")["coupons"]; len(coupons.Data) != 0 {
+		t.Errorf("expected no coupons, got %+v", coupons)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestServer_Failures(t *testing.T) {
+	market, _ := testMarket(t, Scenario{Errors: ErrorConfig{Rate: 1, Status: 502}, Securities: []SecurityConfig{{Ticker: "SBER", Price: 300}}})
+	server := httptest.NewServer(NewServer(market))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/iss/securities/SBER.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close() //nolint:errcheck,gosec
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected 502, got %d", res.StatusCode)
+	}
+}
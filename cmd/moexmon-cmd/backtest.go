@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+// Source of the historical candles, implemented by MoexRequester and
+// the local CSV file
+// ----------------------------------------------------------------
+type candleSource interface {
+	FetchCandleRange(ctx context.Context, asset Asset, interval int, from time.Time, till time.Time) ([]Candle, error)
+}
+
+// ----------------------------------------------------------------
+// Backtest of the watchlist rules over [From, Till). Interval is the
+// candle interval the price rules are replayed on, the indicator
+// rules are replayed on their own candle interval.
+// ----------------------------------------------------------------
+type backtestConfig struct {
+	From     time.Time
+	Till     time.Time
+	Interval int
+}
+
+// Default candle interval of the backtest
+const defaultBacktestInterval = 10
+
+// ----------------------------------------------------------------
+// Firing of the rule during the backtest
+// ----------------------------------------------------------------
+type backtestFiring struct {
+	Time  time.Time `json:"time"`
+	Price float64   `json:"price"`
+	Value float64   `json:"value"` // value of the condition compared with the target
+	Alert string    `json:"alert"`
+}
+
+// ----------------------------------------------------------------
+// Backtest report of the watchlist rule
+// ----------------------------------------------------------------
+type backtestResult struct {
+	WatchlistID int              `json:"watchlist_id"`
+	Ticker      string           `json:"ticker"`
+	Condition   string           `json:"condition"`
+	TargetPrice float64          `json:"target_price"`
+	Mode        string           `json:"mode"`
+	Interval    int              `json:"interval"`
+	Candles     int              `json:"candles"` // number of candles replayed
+	Firings     []backtestFiring `json:"firings"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// ----------------------------------------------------------------
+// Candles of the local CSV file by ticker. The file has a header
+// with the "ticker", "begin" (Moscow time) and "close" columns, and
+// optionally "open", "high", "low", "volume", "value" and "interval".
+// The rows without the interval match any interval.
+// ----------------------------------------------------------------
+type csvCandles struct {
+	candles   map[string][]Candle
+	intervals map[string][]int
+}
+
+// ----------------------------------------------------------------
+// Parse the optional float column, NaN if not set
+// ----------------------------------------------------------------
+func csvFloat(record []string, index int) (float64, error) {
+	if index < 0 || index >= len(record) || record[index] == "" {
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(record[index], 64)
+}
+
+// ----------------------------------------------------------------
+// Parse the candle time, either ISS "2006-01-02 15:04:05" in Moscow
+// time or RFC 3339
+// ----------------------------------------------------------------
+func csvTime(value string) (time.Time, error) {
+	if begin, err := time.ParseInLocation(time.DateTime, value, moscowTime); err == nil {
+		return begin, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ----------------------------------------------------------------
+// Load the candles of the CSV file
+// ----------------------------------------------------------------
+func loadCSVCandles(path string) (*csvCandles, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	return parseCSVCandles(f)
+}
+
+// ----------------------------------------------------------------
+func parseCSVCandles(r io.Reader) (*csvCandles, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	tickerIndex := columnIndex(header, "ticker")
+	beginIndex := columnIndex(header, "begin")
+	closeIndex := columnIndex(header, "close")
+	if tickerIndex < 0 || beginIndex < 0 || closeIndex < 0 {
+		return nil, fmt.Errorf("unexpected CSV columns: %v", header)
+	}
+	intervalIndex := columnIndex(header, "interval")
+	floatIndexes := []int{
+		columnIndex(header, "open"), closeIndex, columnIndex(header, "high"),
+		columnIndex(header, "low"), columnIndex(header, "volume"), columnIndex(header, "value"),
+	}
+
+	result := &csvCandles{candles: make(map[string][]Candle), intervals: make(map[string][]int)}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		begin, err := csvTime(record[beginIndex])
+		if err != nil {
+			return nil, fmt.Errorf("invalid begin at CSV line %d: %w", line, err)
+		}
+		values := make([]float64, len(floatIndexes))
+		for i, index := range floatIndexes {
+			if values[i], err = csvFloat(record, index); err != nil {
+				return nil, fmt.Errorf("invalid %s at CSV line %d: %w", header[index], line, err)
+			}
+		}
+		if math.IsNaN(values[1]) {
+			return nil, fmt.Errorf("missing close at CSV line %d", line)
+		}
+		interval := 0
+		if intervalIndex >= 0 && record[intervalIndex] != "" {
+			if interval, err = strconv.Atoi(record[intervalIndex]); err != nil {
+				return nil, fmt.Errorf("invalid interval at CSV line %d: %w", line, err)
+			}
+		}
+
+		ticker := record[tickerIndex]
+		result.candles[ticker] = append(result.candles[ticker], Candle{
+			Begin:  begin,
+			Open:   values[0],
+			Close:  values[1],
+			High:   values[2],
+			Low:    values[3],
+			Volume: values[4],
+			Value:  values[5],
+		})
+		result.intervals[ticker] = append(result.intervals[ticker], interval)
+	}
+	return result, nil
+}
+
+// ----------------------------------------------------------------
+// Candles of the asset with the interval beginning within [from, till)
+// in chronological order
+// ----------------------------------------------------------------
+func (source *csvCandles) FetchCandleRange(ctx context.Context, asset Asset, interval int, from time.Time, till time.Time) ([]Candle, error) {
+	var candles []Candle
+	intervals := source.intervals[asset.Ticker]
+	for i, candle := range source.candles[asset.Ticker] {
+		if (intervals[i] == 0 || intervals[i] == interval) && !candle.Begin.Before(from) && candle.Begin.Before(till) {
+			candles = append(candles, candle)
+		}
+	}
+	if len(candles) == 0 {
+		return nil, &AssetNotFoundError{Asset: asset.Ticker}
+	}
+	slices.SortStableFunc(candles, func(a, b Candle) int { return a.Begin.Compare(b.Begin) })
+	return candles, nil
+}
+
+// ----------------------------------------------------------------
+// Candle interval the rule is replayed on
+// ----------------------------------------------------------------
+func backtestInterval(item godfather.MOEXWatchlistItem, config backtestConfig) int {
+	if condition := moexConditions[item.Condition]; condition.candles != nil {
+		return item.Params.CandleInterval
+	}
+	if config.Interval == 0 {
+		return defaultBacktestInterval
+	}
+	return config.Interval
+}
+
+// ----------------------------------------------------------------
+// History needed before the backtest start: the candles of the
+// indicators or the sessions of the activity rules, and a week for
+// the previous close. Twice the history covers weekends and holidays.
+// ----------------------------------------------------------------
+func backtestWarmup(item godfather.MOEXWatchlistItem, interval int) time.Duration {
+	warmup := 7 * 24 * time.Hour
+	condition := moexConditions[item.Condition]
+	if condition.candles != nil {
+		warmup += 2 * time.Duration(condition.candles(item.Params)) * candleDuration(interval)
+	}
+	if condition.volumes != nil {
+		warmup += 2 * time.Duration(condition.volumes(item.Params)) * 24 * time.Hour
+	}
+	return warmup
+}
+
+// ----------------------------------------------------------------
+// Session state accumulated over the candles of the day
+// ----------------------------------------------------------------
+type backtestSession struct {
+	day       time.Time
+	open      float64
+	high      float64 // extremes of the candles so far
+	low       float64
+	quoteHigh float64 // extremes as of the last candle close
+	quoteLow  float64
+	volume    float64
+	value     float64
+	prevClose float64 // close of the previous session, NaN if unknown
+	lastClose float64
+}
+
+// ----------------------------------------------------------------
+// Update the session with the candle, the completed session is added
+// to the volumes history
+// ----------------------------------------------------------------
+func (session *backtestSession) update(candle Candle, volumes *[]Volume) {
+	day := moscowDay(candle.Begin)
+	if !session.day.Equal(day) {
+		if !session.day.IsZero() {
+			*volumes = append(*volumes, Volume{Date: session.day, Volume: session.volume, Value: session.value})
+			session.prevClose = session.lastClose
+		} else {
+			session.prevClose = math.NaN()
+		}
+		open := candle.Open
+		if math.IsNaN(open) {
+			open = candle.Close
+		}
+		*session = backtestSession{day: day, open: open, high: math.Inf(-1), low: math.Inf(1), prevClose: session.prevClose}
+	}
+	// The extremes not reported are the close
+	high, low := candle.High, candle.Low
+	if math.IsNaN(high) {
+		high = candle.Close
+	}
+	if math.IsNaN(low) {
+		low = candle.Close
+	}
+	// The extremes within the candle are only known after its close
+	session.quoteHigh = math.Max(session.high, candle.Close)
+	session.quoteLow = math.Min(session.low, candle.Close)
+	session.high = math.Max(session.quoteHigh, high)
+	session.low = math.Min(session.quoteLow, low)
+	session.volume += candle.Volume
+	session.value += candle.Value
+	session.lastClose = candle.Close
+}
+
+// ----------------------------------------------------------------
+// Quote of the session as of the candle close
+// ----------------------------------------------------------------
+func (session *backtestSession) quote() Quote {
+	quote := Quote{
+		Price:     session.lastClose,
+		Open:      session.open,
+		High:      session.quoteHigh,
+		Low:       session.quoteLow,
+		WAPrice:   math.NaN(),
+		ChangePct: math.NaN(),
+		VolToday:  session.volume,
+		ValToday:  session.value,
+	}
+	if session.volume > 0 && !math.IsNaN(session.value) {
+		quote.WAPrice = session.value / session.volume
+	}
+	if session.prevClose > 0 {
+		quote.ChangePct = (session.lastClose - session.prevClose) / session.prevClose * 100
+	}
+	return quote
+}
+
+// ----------------------------------------------------------------
+// Close time of the candle: the end of the interval, or the begin of
+// the next candle if it is earlier
+// ----------------------------------------------------------------
+func candleClose(candles []Candle, i int, interval int) time.Time {
+	end := candles[i].Begin.Add(candleDuration(interval))
+	if i+1 < len(candles) && candles[i+1].Begin.Before(end) {
+		return candles[i+1].Begin
+	}
+	return end
+}
+
+// ----------------------------------------------------------------
+// Replay the candles through the rule starting armed, the one-shot
+// rules stop at the first firing. The rule is evaluated at the candle
+// close, when its prices are known. The second leg of the pair rules
+// is the last close of the second candles.
+// ----------------------------------------------------------------
+func replayRule(item godfather.MOEXWatchlistItem, interval int, candles []Candle, second []Candle, from time.Time) backtestResult {
+	result := backtestResult{
+		WatchlistID: item.ID,
+		Ticker:      item.Ticker,
+		Condition:   item.Condition,
+		TargetPrice: item.TargetPrice,
+		Mode:        item.Mode,
+		Interval:    interval,
+		Firings:     []backtestFiring{},
+	}
+	item.Armed = true
+	item.LastTriggeredAt = time.Time{}
+
+	condition := moexConditions[item.Condition]
+	session := backtestSession{}
+	var volumes []Volume
+	secondIndex := 0
+	secondPrice := math.NaN()
+	for i, candle := range candles {
+		session.update(candle, &volumes)
+		for secondIndex < len(second) && !second[secondIndex].Begin.After(candle.Begin) {
+			secondPrice = second[secondIndex].Close
+			secondIndex++
+		}
+		if candle.Begin.Before(from) {
+			continue
+		}
+		result.Candles++
+
+		quote := session.quote()
+		quote.Volumes = volumes
+		if condition.candles != nil {
+			count := condition.candles(item.Params)
+			quote.Candles = map[int][]Candle{interval: candles[max(0, i+1-count) : i+1]}
+		}
+		if item.Pair.Ticker != "" {
+			quote.Second = &Quote{Price: secondPrice}
+		}
+
+		closedAt := candleClose(candles, i, interval)
+		switch evaluateRule(item, quote, closedAt) {
+		case ruleFire:
+			state, _ := conditionValue(item, quote, closedAt)
+			result.Firings = append(result.Firings, backtestFiring{
+				Time:  closedAt,
+				Price: quote.Price,
+				Value: state.value,
+				Alert: describeAlert(item, quote),
+			})
+			if item.Mode != godfather.MOEXRuleCrossing {
+				return result
+			}
+			item.Armed = false
+			item.LastTriggeredAt = closedAt
+		case ruleRearm:
+			item.Armed = true
+		case ruleIdle:
+		}
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+// Backtest the rule, the failures are reported in the result
+// ----------------------------------------------------------------
+func backtestRule(ctx context.Context, source candleSource, item godfather.MOEXWatchlistItem, config backtestConfig) backtestResult {
+	interval := backtestInterval(item, config)
+	from := config.From.Add(-backtestWarmup(item, interval))
+	candles, err := source.FetchCandleRange(ctx, assetOf(item), interval, from, config.Till)
+	var second []Candle
+	if err == nil && item.Pair.Ticker != "" {
+		second, err = source.FetchCandleRange(ctx, pairAssetOf(item), interval, from, config.Till)
+	}
+	if err != nil {
+		result := replayRule(item, interval, nil, nil, config.From)
+		result.Error = err.Error()
+		return result
+	}
+	return replayRule(item, interval, candles, second, config.From)
+}
+
+// ----------------------------------------------------------------
+// Backtest the watchlist rules one by one
+// ----------------------------------------------------------------
+func runBacktest(ctx context.Context, source candleSource, watchlist []godfather.MOEXWatchlistItem, config backtestConfig) []backtestResult {
+	results := make([]backtestResult, 0, len(watchlist))
+	for _, item := range watchlist {
+		if ctx.Err() != nil {
+			break
+		}
+		result := backtestRule(ctx, source, item, config)
+		if result.Error != "" {
+			slog.Error(fmt.Sprintf("Failed to backtest watchlist item %d for %s", item.ID, item.Ticker), "error", result.Error)
+		} else {
+			slog.Info(fmt.Sprintf("Watchlist item %d for %s would have fired %d times over %d candles",
+				item.ID, item.Ticker, len(result.Firings), result.Candles))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ----------------------------------------------------------------
+// Write the backtest report as JSON or as CSV with a row per firing
+// ----------------------------------------------------------------
+func writeBacktest(w io.Writer, format string, results []backtestResult) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"watchlist_id", "ticker", "condition", "target_price", "mode", "interval",
+			"time", "price", "value", "alert"}); err != nil {
+			return err
+		}
+		for _, result := range results {
+			for _, firing := range result.Firings {
+				if err := writer.Write([]string{
+					strconv.Itoa(result.WatchlistID), result.Ticker, result.Condition,
+					strconv.FormatFloat(result.TargetPrice, 'f', -1, 64), result.Mode, strconv.Itoa(result.Interval),
+					firing.Time.Format(time.RFC3339), strconv.FormatFloat(firing.Price, 'f', -1, 64),
+					strconv.FormatFloat(firing.Value, 'f', -1, 64), firing.Alert,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unsupported backtest format '%s', expected json or csv", format)
+	}
+}
+
+// ----------------------------------------------------------------
+// Options of the backtest mode set by the command line flags
+// ----------------------------------------------------------------
+type backtestOptions struct {
+	From     string // first day, YYYY-MM-DD
+	To       string // last day inclusive, YYYY-MM-DD, today if empty
+	Interval int
+	CSVPath  string // local candles file, ISS if empty
+	Format   string // "json" or "csv"
+	Output   string // report file, stdout if empty
+	RuleID   int    // single watchlist item to backtest, all if 0
+}
+
+// ----------------------------------------------------------------
+// Parse the backtest period in Moscow time
+// ----------------------------------------------------------------
+func (options backtestOptions) config(now time.Time) (backtestConfig, error) {
+	if options.From == "" {
+		return backtestConfig{}, errors.New("the backtest start is not set, use -from YYYY-MM-DD")
+	}
+	from, err := time.ParseInLocation(time.DateOnly, options.From, moscowTime)
+	if err != nil {
+		return backtestConfig{}, fmt.Errorf("invalid backtest start: %w", err)
+	}
+	to := moscowDay(now)
+	if options.To != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, options.To, moscowTime); err != nil {
+			return backtestConfig{}, fmt.Errorf("invalid backtest end: %w", err)
+		}
+	}
+	if to.Before(from) {
+		return backtestConfig{}, fmt.Errorf("the backtest end %s is before its start %s", options.To, options.From)
+	}
+	if options.Interval != 0 && candleDuration(options.Interval) == 0 {
+		return backtestConfig{}, fmt.Errorf("unsupported candle interval: %d", options.Interval)
+	}
+	return backtestConfig{From: from, Till: to.AddDate(0, 0, 1), Interval: options.Interval}, nil
+}
+
+// ----------------------------------------------------------------
+// Backtest the watchlist rules not archived, active or not, and
+// write the report. No alerts are published.
+// ----------------------------------------------------------------
+func startBacktest(ctx context.Context, db *godfather.Database, options backtestOptions) error {
+	config, err := options.config(time.Now())
+	if err != nil {
+		return err
+	}
+	var source candleSource = &MoexRequester{}
+	if options.CSVPath != "" {
+		if source, err = loadCSVCandles(options.CSVPath); err != nil {
+			return fmt.Errorf("failed to load candles: %w", err)
+		}
+	}
+
+	items, err := db.GetMOEXWatchlist(false)
+	if err != nil {
+		return err
+	}
+	watchlist := make([]godfather.MOEXWatchlistItem, 0, len(items))
+	for _, item := range items {
+		if item.ArchivedAt.IsZero() && (options.RuleID == 0 || item.ID == options.RuleID) {
+			watchlist = append(watchlist, item)
+		}
+	}
+	if len(watchlist) == 0 {
+		return errors.New("no watchlist items to backtest")
+	}
+	slog.Info(fmt.Sprintf("Backtesting %d watchlist items from %s to %s...", len(watchlist),
+		config.From.Format(time.DateOnly), config.Till.AddDate(0, 0, -1).Format(time.DateOnly)))
+	results := runBacktest(ctx, source, watchlist, config)
+
+	if options.Output == "" {
+		return writeBacktest(os.Stdout, options.Format, results)
+	}
+	f, err := os.Create(filepath.Clean(options.Output))
+	if err != nil {
+		return err
+	}
+	if err := writeBacktest(f, options.Format, results); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
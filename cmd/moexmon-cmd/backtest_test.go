@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+const backtestCSV = `ticker,begin,open,high,low,close,volume,interval
+SBER,2025-03-03 10:00:00,300,301,299,300,1000,10
+SBER,2025-03-03 10:10:00,300,306,300,305,1000,10
+SBER,2025-03-03 10:20:00,305,305,297,298,1000,10
+SBER,2025-03-03 10:30:00,298,309,298,308,1000,10
+SBER,2025-03-03 10:40:00,308,311,308,310,1000,10
+SBER,2025-03-03 00:00:00,300,311,297,310,5000,24
+SBERP,2025-03-03 10:00:00,,,,295,,
+SBERP,2025-03-03 10:30:00,,,,300,,
+`
+
+// ----------------------------------------------------------------
+func TestParseCSVCandles(t *testing.T) {
+	source, err := parseCSVCandles(strings.NewReader(backtestCSV))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	from := time.Date(2025, 3, 3, 0, 0, 0, 0, moscowTime)
+	candles, err := source.FetchCandleRange(context.Background(), Asset{Ticker: "SBER"}, 10, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candles) != 5 || candles[1].Close != 305 || candles[1].High != 306 || !math.IsNaN(candles[1].Value) {
+		t.Errorf("unexpected candles: %+v", candles)
+	}
+	// The candles without the interval match any interval
+	candles, err = source.FetchCandleRange(context.Background(), Asset{Ticker: "SBERP"}, 60, from, from.AddDate(0, 0, 1))
+	if err != nil || len(candles) != 2 || !math.IsNaN(candles[0].Open) {
+		t.Errorf("unexpected candles: %+v, %v", candles, err)
+	}
+	if _, err := source.FetchCandleRange(context.Background(), Asset{Ticker: "GAZP"}, 10, from, from.AddDate(0, 0, 1)); err == nil {
+		t.Error("expected error for the unknown ticker, got nil")
+	}
+
+	for _, body := range []string{"", "ticker,close\nSBER,300\n", "ticker,begin,close\nSBER,yesterday,300\n", "ticker,begin,close\nSBER,2025-03-03 10:00:00,\n"} {
+		if _, err := parseCSVCandles(strings.NewReader(body)); err == nil {
+			t.Errorf("expected error for '%s', got nil", body)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestRunBacktest(t *testing.T) {
+	source, err := parseCSVCandles(strings.NewReader(backtestCSV))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := backtestConfig{
+		From:     time.Date(2025, 3, 3, 0, 0, 0, 0, moscowTime),
+		Till:     time.Date(2025, 3, 4, 0, 0, 0, 0, moscowTime),
+		Interval: 10,
+	}
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", Condition: "above", TargetPrice: 304, Mode: godfather.MOEXRuleOneShot},
+		{ID: 2, Ticker: "SBER", Condition: "above", TargetPrice: 304, Mode: godfather.MOEXRuleCrossing, Hysteresis: 5},
+		{ID: 3, Ticker: "SBER", Condition: "new_high", Mode: godfather.MOEXRuleCrossing},
+		{ID: 4, Ticker: "SBER", Condition: "spread_above", TargetPrice: 8, Mode: godfather.MOEXRuleCrossing,
+			Pair: godfather.MOEXPairLeg{Ticker: "SBERP"}},
+		{ID: 5, Ticker: "GAZP", Condition: "above", TargetPrice: 150, Mode: godfather.MOEXRuleOneShot},
+		{ID: 6, Ticker: "SBER", Condition: "above", TargetPrice: 304, Mode: godfather.MOEXRuleOneShot,
+			ValidUntil: time.Date(2025, 3, 3, 10, 20, 0, 0, moscowTime)},
+	}
+	results := runBacktest(context.Background(), source, watchlist, config)
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(results))
+	}
+
+	firings := func(result backtestResult) []string {
+		times := make([]string, len(result.Firings))
+		for i, firing := range result.Firings {
+			times[i] = firing.Time.Format("15:04")
+		}
+		return times
+	}
+	tests := []struct {
+		expected string
+	}{
+		// The one-shot rule stops at the first firing, at the candle close
+		{"10:20"},
+		// The crossing rule is re-armed once the price leaves the band
+		{"10:20,10:40"},
+		// The close reaches the session high
+		{"10:10,10:40"},
+		// The second leg is the last close: 305-295, 298-295 and 310-300
+		{"10:20,10:50"},
+		{""},
+		// The rule expires when the 10:10 candle closes
+		{""},
+	}
+	for i, test := range tests {
+		if times := strings.Join(firings(results[i]), ","); times != test.expected {
+			t.Errorf("expected rule %d to fire at '%s', got '%s'", results[i].WatchlistID, test.expected, times)
+		}
+	}
+	if results[0].Candles != 2 || results[0].Firings[0].Price != 305 || results[0].Firings[0].Value != 305 ||
+		results[0].Firings[0].Alert != "The price for SBER is above 304.00" {
+		t.Errorf("unexpected result: %+v", results[0])
+	}
+	if results[4].Error == "" {
+		t.Errorf("expected error without the candles, got %+v", results[4])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestReplayRule_SessionStatistics(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 10, 0, 0, 0, moscowTime) }
+	candles := []Candle{
+		{Begin: day(3), Open: 100, Close: 100, High: math.NaN(), Low: math.NaN(), Volume: 10, Value: 1000},
+		{Begin: day(4), Open: 101, Close: 104, High: 104, Low: 101, Volume: 30, Value: 3100},
+	}
+	item := godfather.MOEXWatchlistItem{ID: 1, Ticker: "SBER", Condition: "change_above", TargetPrice: 3, Mode: godfather.MOEXRuleOneShot}
+	result := replayRule(item, 24, candles, nil, day(4))
+	if result.Candles != 1 || len(result.Firings) != 1 || result.Firings[0].Value != 4 {
+		t.Errorf("expected the change to the previous close, got %+v", result)
+	}
+
+	// The turnover of the previous sessions is averaged
+	item = godfather.MOEXWatchlistItem{ID: 2, Ticker: "SBER", Condition: "turnover_spike", TargetPrice: 3,
+		Mode: godfather.MOEXRuleOneShot, Params: godfather.MOEXRuleParams{LookbackDays: 1}}
+	result = replayRule(item, 24, candles, nil, day(4))
+	if len(result.Firings) != 1 || result.Firings[0].Value != 3.1 {
+		t.Errorf("expected the turnover spike, got %+v", result)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestWriteBacktest(t *testing.T) {
+	results := []backtestResult{{
+		WatchlistID: 1, Ticker: "SBER", Condition: "above", TargetPrice: 304.5, Mode: godfather.MOEXRuleOneShot, Interval: 10, Candles: 5,
+		Firings: []backtestFiring{{Time: time.Date(2025, 3, 3, 10, 10, 0, 0, moscowTime), Price: 305, Value: 305,
+			Alert: "The price for SBER is above 304.50"}},
+	}}
+
+	var buffer bytes.Buffer
+	if err := writeBacktest(&buffer, "csv", results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "watchlist_id,ticker,condition,target_price,mode,interval,time,price,value,alert\n" +
+		"1,SBER,above,304.5,oneshot,10,2025-03-03T10:10:00+03:00,305,305,The price for SBER is above 304.50\n"
+	if buffer.String() != expected {
+		t.Errorf("unexpected CSV report:\n%s", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := writeBacktest(&buffer, "json", results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded []backtestResult
+	if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil || len(decoded) != 1 || len(decoded[0].Firings) != 1 {
+		t.Errorf("unexpected JSON report: %s, %v", buffer.String(), err)
+	}
+
+	if err := writeBacktest(&buffer, "xml", results); err == nil {
+		t.Error("expected error for the unsupported format, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestBacktestOptions_Config(t *testing.T) {
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, moscowTime)
+	config, err := backtestOptions{From: "2025-03-01"}.config(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.From.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, moscowTime)) || !config.Till.Equal(time.Date(2025, 3, 6, 0, 0, 0, 0, moscowTime)) {
+		t.Errorf("unexpected period: %+v", config)
+	}
+
+	for _, options := range []backtestOptions{{}, {From: "March"}, {From: "2025-03-01", To: "2025-02-01"}, {From: "2025-03-01", Interval: 5}} {
+		if _, err := options.config(now); err == nil {
+			t.Errorf("expected error for %+v, got nil", options)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchCandleRange(t *testing.T) {
+	var requests []string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.String())
+		body := `{"candles":{"columns":["open","close","high","low","value","volume","begin","end"],"data":[]}}`
+		if req.URL.Query().Get("start") == "0" {
+			body = `{"candles":{"columns":["open","close","high","low","value","volume","begin","end"],"data":[
+				[300,301,302,299,30100,100,"2025-03-03 09:50:00","2025-03-03 09:59:59"],
+				[301,305,306,300,30500,100,"2025-03-03 10:00:00","2025-03-03 10:09:59"]]}}`
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	from := time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime)
+	candles, err := requester.FetchCandleRange(context.Background(), Asset{Ticker: "SBER", AssetType: "stock"}, 10, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 2 || !strings.Contains(requests[0], "interval=10&from=2025-03-03&till=2025-03-03&start=0") ||
+		!strings.HasSuffix(requests[1], "&start=2") {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+	// The candles before the start are cut
+	if len(candles) != 1 || candles[0].Close != 305 || candles[0].Value != 30500 {
+		t.Errorf("unexpected candles: %+v", candles)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+type moexSecurities struct {
+	Securities struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"securities"`
+}
+
+// ----------------------------------------------------------------
+// Storage of the asset catalog, implemented by godfather.Database
+// ----------------------------------------------------------------
+type assetCatalog interface {
+	UpsertMOEXAssets(assets []godfather.MOEXAsset) error
+	MarkMOEXAssetsDelisted(engine string, market string, board string, syncedBefore time.Time) (int64, error)
+}
+
+// Default interval between two catalog synchronizations
+const defaultCatalogIntervalHours = 24
+
+// ----------------------------------------------------------------
+// Get the string value from the row, empty if it is missing or null
+// ----------------------------------------------------------------
+func optionalString(row []any, index int) string {
+	if index < 0 || index >= len(row) {
+		return ""
+	}
+	value, isOk := row[index].(string)
+	if !isOk {
+		return ""
+	}
+	return value
+}
+
+// ----------------------------------------------------------------
+// Fetch the securities listed on the board
+// ----------------------------------------------------------------
+func fetchBoardSecurities(ctx context.Context, board CatalogBoardConfig, syncedAt time.Time) ([]godfather.MOEXAsset, error) {
+	requested := "SECID,SHORTNAME,SECNAME,ISIN,LOTSIZE,CURRENCYID"
+	if board.Market == fortsBoard.market {
+		requested += ",ASSETCODE"
+	}
+	url := issClient.URL("/iss/engines/%s/markets/%s/boards/%s/securities.json?iss.meta=off&iss.only=securities&securities.columns=%s",
+		board.Engine, board.Market, board.Board, requested)
+	result, err := query[moexSecurities](ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := result.Securities.Columns
+	secidIndex := columnIndex(columns, "SECID")
+	if secidIndex < 0 {
+		return nil, fmt.Errorf("unexpected securities columns for board %s: %v", board.Board, columns)
+	}
+	shortNameIndex := columnIndex(columns, "SHORTNAME")
+	nameIndex := columnIndex(columns, "SECNAME")
+	isinIndex := columnIndex(columns, "ISIN")
+	lotSizeIndex := columnIndex(columns, "LOTSIZE")
+	currencyIndex := columnIndex(columns, "CURRENCYID")
+	assetCodeIndex := columnIndex(columns, "ASSETCODE")
+	codes := make(map[string]bool)
+
+	assets := make([]godfather.MOEXAsset, 0, len(result.Securities.Data))
+	for _, row := range result.Securities.Data {
+		ticker := optionalString(row, secidIndex)
+		if ticker == "" {
+			continue
+		}
+		asset := godfather.MOEXAsset{
+			Ticker:    ticker,
+			ClassID:   board.Class,
+			Name:      optionalString(row, nameIndex),
+			ShortName: optionalString(row, shortNameIndex),
+			ISIN:      optionalString(row, isinIndex),
+			Engine:    board.Engine,
+			Market:    board.Market,
+			Board:     board.Board,
+			Currency:  optionalString(row, currencyIndex),
+			SyncedAt:  syncedAt,
+		}
+		if asset.Name == "" {
+			asset.Name = asset.ShortName
+		}
+		if lotSize := optionalFloat(row, lotSizeIndex); lotSize > 0 {
+			asset.LotSize = int(lotSize)
+		}
+		assets = append(assets, asset)
+
+		// The continuous contract is listed as long as one of its
+		// contracts is
+		code := optionalString(row, assetCodeIndex)
+		if code != "" && code != ticker && !codes[code] {
+			codes[code] = true
+			continuous := asset
+			continuous.Ticker = code
+			continuous.Name = code + " (continuous)"
+			continuous.ShortName = code
+			continuous.ISIN = ""
+			assets = append(assets, continuous)
+		}
+	}
+	return assets, nil
+}
+
+// ----------------------------------------------------------------
+// Synchronize the asset catalog with the securities listed on the
+// boards. A ticker listed on several boards is assigned to the first
+// one; the assets missing from a board are flagged as delisted.
+// ----------------------------------------------------------------
+func syncAssets(ctx context.Context, catalog assetCatalog, boards []CatalogBoardConfig) error {
+	if len(boards) == 0 {
+		return errors.New("no boards configured for the asset catalog")
+	}
+	syncedAt := time.Now()
+	seen := make(map[string]bool)
+	var assets []godfather.MOEXAsset
+	var synced []CatalogBoardConfig
+	var errs []error
+	for _, board := range boards {
+		securities, err := fetchBoardSecurities(ctx, board, syncedAt)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to fetch securities of board %s", board.Board), "error", err)
+			moexFailures.Inc()
+			errs = append(errs, fmt.Errorf("board %s: %w", board.Board, err))
+			continue
+		}
+		// Don't delist the whole board because of an empty response
+		if len(securities) == 0 {
+			slog.Warn(fmt.Sprintf("No securities listed on board %s", board.Board))
+			continue
+		}
+		for _, security := range securities {
+			if seen[security.Ticker] {
+				continue
+			}
+			seen[security.Ticker] = true
+			assets = append(assets, security)
+		}
+		synced = append(synced, board)
+	}
+
+	if len(assets) > 0 {
+		if err := catalog.UpsertMOEXAssets(assets); err != nil {
+			dbFailures.Inc()
+			return errors.Join(append(errs, err)...)
+		}
+	}
+	for _, board := range synced {
+		count, err := catalog.MarkMOEXAssetsDelisted(board.Engine, board.Market, board.Board, syncedAt)
+		if err != nil {
+			dbFailures.Inc()
+			errs = append(errs, err)
+			continue
+		}
+		if count > 0 {
+			slog.Info(fmt.Sprintf("%d assets of board %s flagged as delisted", count, board.Board))
+		}
+	}
+	slog.Info(fmt.Sprintf("MOEX asset catalog synchronized: %d assets from %d boards", len(assets), len(synced)))
+	return errors.Join(errs...)
+}
+
+// ----------------------------------------------------------------
+func startAssetSync(ctx context.Context, catalog assetCatalog, config CatalogConfig) {
+	if len(config.Boards) == 0 {
+		slog.Info("MOEX asset catalog synchronization is disabled")
+		return
+	}
+	intervalHours := config.IntervalHours
+	if intervalHours <= 0 {
+		intervalHours = defaultCatalogIntervalHours
+	}
+	slog.Info(fmt.Sprintf("Starting MOEX asset catalog synchronization every %d hours...", intervalHours))
+
+	ticker := time.NewTicker(time.Duration(intervalHours) * time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := syncAssets(ctx, catalog, config.Boards); err != nil {
+			slog.Error("Failed to synchronize MOEX asset catalog", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
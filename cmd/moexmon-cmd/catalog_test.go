@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+type mockAssetCatalog struct {
+	upserted  []godfather.MOEXAsset
+	delisted  []string
+	upsertErr error
+}
+
+func (m *mockAssetCatalog) UpsertMOEXAssets(assets []godfather.MOEXAsset) error {
+	m.upserted = append(m.upserted, assets...)
+	return m.upsertErr
+}
+
+func (m *mockAssetCatalog) MarkMOEXAssetsDelisted(engine string, market string, board string, syncedBefore time.Time) (int64, error) {
+	m.delisted = append(m.delisted, board)
+	return 1, nil
+}
+
+// ----------------------------------------------------------------
+func TestFetchBoardSecurities(t *testing.T) {
+	var requested string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.Path
+		body := `{"securities":{"columns":["SECID","SHORTNAME","SECNAME","ISIN","LOTSIZE","CURRENCYID"],"data":[
+			["SBER","Сбербанк","Сбербанк России ПАО ао","RU0009029540",10,"SUR"],
+			["NONAME","NoName",null,null,null,null],
+			[null,"Broken","Broken","",1,"SUR"]]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	syncedAt := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+	board := CatalogBoardConfig{Engine: "stock", Market: "shares", Board: "TQBR", Class: "stock"}
+	assets, err := fetchBoardSecurities(context.Background(), board, syncedAt)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if requested != "/iss/engines/stock/markets/shares/boards/TQBR/securities.json" {
+		t.Errorf("unexpected request: %s", requested)
+	}
+	if len(assets) != 2 {
+		t.Fatalf("expected 2 assets, got %d", len(assets))
+	}
+	sber := assets[0]
+	if sber.Ticker != "SBER" || sber.ISIN != "RU0009029540" || sber.LotSize != 10 || sber.Currency != "SUR" ||
+		sber.Board != "TQBR" || sber.ClassID != "stock" || !sber.SyncedAt.Equal(syncedAt) {
+		t.Errorf("unexpected asset: %+v", sber)
+	}
+	// The short name is used if the full one is missing
+	if assets[1].Name != "NoName" || assets[1].LotSize != 0 {
+		t.Errorf("unexpected asset: %+v", assets[1])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchBoardSecurities_UnexpectedColumns(t *testing.T) {
+	body := `{"securities":{"columns":["SHORTNAME"],"data":[["Сбербанк"]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	board := CatalogBoardConfig{Engine: "stock", Market: "shares", Board: "TQBR", Class: "stock"}
+	if _, err := fetchBoardSecurities(context.Background(), board, time.Now()); err == nil {
+		t.Error("expected error for unexpected columns, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSyncAssets(t *testing.T) {
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case strings.Contains(req.URL.Path, "/boards/TQBR/"):
+			body = `{"securities":{"columns":["SECID","SHORTNAME"],"data":[["SBER","Сбербанк"],["GAZP","ГАЗПРОМ ао"]]}}`
+		case strings.Contains(req.URL.Path, "/boards/SMAL/"):
+			body = `{"securities":{"columns":["SECID","SHORTNAME"],"data":[["SBER","Сбербанк"],["ODD","Odd lot"]]}}`
+		case strings.Contains(req.URL.Path, "/boards/TQTF/"):
+			body = `{"securities":{"columns":["SECID","SHORTNAME"],"data":[]}}`
+		default:
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	catalog := &mockAssetCatalog{}
+	err := syncAssets(context.Background(), catalog, []CatalogBoardConfig{
+		{Engine: "stock", Market: "shares", Board: "TQBR", Class: "stock"},
+		{Engine: "stock", Market: "shares", Board: "SMAL", Class: "stock"},
+		{Engine: "stock", Market: "shares", Board: "TQTF", Class: "stock"},
+		{Engine: "stock", Market: "bonds", Board: "TQOB", Class: "bond"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "TQOB") {
+		t.Errorf("expected error for board TQOB, got %v", err)
+	}
+
+	// SBER is kept on the first board it is listed on
+	if len(catalog.upserted) != 3 {
+		t.Fatalf("expected 3 assets upserted, got %+v", catalog.upserted)
+	}
+	for _, asset := range catalog.upserted {
+		if asset.Ticker == "SBER" && asset.Board != "TQBR" {
+			t.Errorf("expected SBER on TQBR, got %s", asset.Board)
+		}
+	}
+	// Neither the empty nor the failed board is delisted
+	if len(catalog.delisted) != 2 || catalog.delisted[0] != "TQBR" || catalog.delisted[1] != "SMAL" {
+		t.Errorf("unexpected delisted boards: %v", catalog.delisted)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSyncAssets_Errors(t *testing.T) {
+	if err := syncAssets(context.Background(), &mockAssetCatalog{}, nil); err == nil {
+		t.Error("expected error without boards, got nil")
+	}
+
+	body := `{"securities":{"columns":["SECID"],"data":[["SBER"]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	catalog := &mockAssetCatalog{upsertErr: errors.New("insert failed")}
+	err := syncAssets(context.Background(), catalog, []CatalogBoardConfig{{Engine: "stock", Market: "shares", Board: "TQBR", Class: "stock"}})
+	if err == nil {
+		t.Error("expected error for failed upsert, got nil")
+	}
+	// Nothing is delisted if the assets were not stored
+	if len(catalog.delisted) != 0 {
+		t.Errorf("unexpected delisted boards: %v", catalog.delisted)
+	}
+}
@@ -6,9 +6,126 @@ import (
 	"path/filepath"
 )
 
+// ----------------------------------------------------------------
+// Session windows of the ISS engine, "HH:MM-HH:MM" in Moscow time or
+// "off", the defaults of the engine are used if not set
+// ----------------------------------------------------------------
+type SessionConfig struct {
+	MainSession    string `json:"main_session"`
+	EveningSession string `json:"evening_session"`
+	WeekendSession string `json:"weekend_session"`
+}
+
+// ----------------------------------------------------------------
+// MOEX trading schedule. The top-level session windows apply to the
+// stock market, the other engines are set in the engines section.
+// The windows are used when ISS reports no trading hours.
+// ----------------------------------------------------------------
+type ScheduleConfig struct {
+	HolidaysFile   string                   `json:"holidays_file"`
+	MainSession    string                   `json:"main_session"`
+	EveningSession string                   `json:"evening_session"`
+	WeekendSession string                   `json:"weekend_session"`
+	Engines        map[string]SessionConfig `json:"engines"`
+}
+
+// ----------------------------------------------------------------
+// ISS board to synchronize the asset catalog from, the class is
+// assigned to the new assets of the board
+// ----------------------------------------------------------------
+type CatalogBoardConfig struct {
+	Engine string `json:"engine"`
+	Market string `json:"market"`
+	Board  string `json:"board"`
+	Class  string `json:"class"`
+}
+
+// ----------------------------------------------------------------
+// MOEX asset catalog synchronization, disabled if no boards are set
+// ----------------------------------------------------------------
+type CatalogConfig struct {
+	IntervalHours int                  `json:"interval_hours"`
+	Boards        []CatalogBoardConfig `json:"boards"`
+}
+
+// ----------------------------------------------------------------
+// MOEX price history maintenance, the retention is set in days per
+// interval ("raw", "1m", "1h", "1d"), 0 keeps the history forever
+// ----------------------------------------------------------------
+type HistoryConfig struct {
+	MaintenanceMinutes int            `json:"maintenance_minutes"`
+	RetentionDays      map[string]int `json:"retention_days"`
+}
+
+// ----------------------------------------------------------------
+func (config HistoryConfig) maintenanceMinutes() int {
+	if config.MaintenanceMinutes <= 0 {
+		return defaultHistoryMaintenanceMinutes
+	}
+	return config.MaintenanceMinutes
+}
+
+// ----------------------------------------------------------------
+// ISS client: base URL of ISS (e.g. the local simulator), timeout of
+// a single request, attempts of a query with the jittered exponential
+// backoff between them, and the circuit breaker opened after the
+// consecutive failed queries. The defaults are used if not set.
+// ----------------------------------------------------------------
+type ISSConfig struct {
+	BaseURL                string `json:"base_url"`
+	TimeoutSeconds         int    `json:"timeout_seconds"`
+	MaxAttempts            int    `json:"max_attempts"`
+	BackoffMilliseconds    int    `json:"backoff_ms"`
+	MaxBackoffSeconds      int    `json:"max_backoff_seconds"`
+	BreakerThreshold       int    `json:"breaker_threshold"`
+	BreakerCooldownSeconds int    `json:"breaker_cooldown_seconds"`
+}
+
+// ----------------------------------------------------------------
+// Limits of the live quotes stream, the defaults are used if not set
+// ----------------------------------------------------------------
+type QuotesConfig struct {
+	MaxAgeSeconds int   `json:"max_age_seconds"`
+	MaxBytes      int64 `json:"max_bytes"`
+}
+
+// ----------------------------------------------------------------
+// Corporate events synchronization: dividends of the stocks, coupons
+// and offers of the bonds within the horizon are pulled from ISS by
+// the workers. The defaults are used if not set.
+// ----------------------------------------------------------------
+type EventsConfig struct {
+	IntervalHours int `json:"interval_hours"`
+	HorizonDays   int `json:"horizon_days"`
+	Workers       int `json:"workers"`
+}
+
+// ----------------------------------------------------------------
+// Market data provider selected by the exchange ("moex", the default)
+// and the source of its quotes: "iss" (the default) or "replay" of the
+// .csv or .jsonl file or directory set by the path, optionally looped
+// ----------------------------------------------------------------
+type ProviderConfig struct {
+	Exchange string `json:"exchange"`
+	Source   string `json:"source"`
+	Path     string `json:"path"`
+	Loop     bool   `json:"loop"`
+}
+
+// ----------------------------------------------------------------
+// Leader election between the replicas: only the holder of the
+// Postgres advisory lock monitors the watchlist, the lock is checked
+// every interval. The defaults are used if not set.
+// ----------------------------------------------------------------
+type LeaderConfig struct {
+	LockKey              int64 `json:"lock_key"`
+	CheckIntervalSeconds int   `json:"check_interval_seconds"`
+}
+
 // ----------------------------------------------------------------
 type Config struct {
 	CheckIntervalSeconds int `json:"check_interval_seconds"`
+	Workers              int `json:"workers"`
 	Prometheus           struct {
 		Port int    `json:"port"`
 		URL  string `json:"url"`
@@ -26,6 +143,14 @@ type Config struct {
 		User string `json:"user"`
 		Pass string `json:"pass"`
 	} `json:"nats"`
+	Schedule ScheduleConfig `json:"schedule"`
+	Catalog  CatalogConfig  `json:"catalog"`
+	History  HistoryConfig  `json:"history"`
+	ISS      ISSConfig      `json:"iss"`
+	Quotes   QuotesConfig   `json:"quotes"`
+	Events   EventsConfig   `json:"events"`
+	Provider ProviderConfig `json:"provider"`
+	Leader   LeaderConfig   `json:"leader"`
 }
 
 // ----------------------------------------------------------------
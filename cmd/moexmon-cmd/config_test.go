@@ -16,6 +16,7 @@ func TestParseConfig_ValidFile(t *testing.T) {
 
 	configContent := `{
 		"check_interval_seconds": 10,
+		"workers": 8,
 		"prometheus": {
 			"port": 9090,
 			"url": "http://localhost:9090"
@@ -30,6 +31,17 @@ func TestParseConfig_ValidFile(t *testing.T) {
 		"nats": {
 			"host": "localhost",
 			"port": 4222
+		},
+		"schedule": {
+			"holidays_file": "holidays.json",
+			"evening_session": "off"
+		},
+		"history": {
+			"retention_days": {"raw": 3, "1d": 0}
+		},
+		"leader": {
+			"lock_key": 42,
+			"check_interval_seconds": 2
 		}
 	}`
 	if _, err := tmpFile.Write([]byte(configContent)); err != nil {
@@ -41,8 +53,8 @@ func TestParseConfig_ValidFile(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ParseConfig failed: %v", err)
 	}
-	if cfg.CheckIntervalSeconds != 10 {
-		t.Errorf("expected CheckIntervalSeconds=10, got %d", cfg.CheckIntervalSeconds)
+	if cfg.CheckIntervalSeconds != 10 || cfg.Workers != 8 {
+		t.Errorf("expected CheckIntervalSeconds=10 and Workers=8, got %d and %d", cfg.CheckIntervalSeconds, cfg.Workers)
 	}
 	if cfg.Prometheus.Port != 9090 || cfg.Prometheus.URL != "http://localhost:9090" {
 		t.Errorf("unexpected Prometheus config: %+v", cfg.Prometheus)
@@ -55,6 +67,16 @@ func TestParseConfig_ValidFile(t *testing.T) {
 	if cfg.NATS.Host != "localhost" || cfg.NATS.Port != 4222 {
 		t.Errorf("unexpected NATS config: %+v", cfg.NATS)
 	}
+	if cfg.Schedule.HolidaysFile != "holidays.json" || cfg.Schedule.EveningSession != "off" || cfg.Schedule.MainSession != "" {
+		t.Errorf("unexpected Schedule config: %+v", cfg.Schedule)
+	}
+	if cfg.History.maintenanceMinutes() != defaultHistoryMaintenanceMinutes || historyRetention(cfg.History, "raw") != 3 ||
+		historyRetention(cfg.History, "1m") != 30 || historyRetention(cfg.History, "1d") != 0 {
+		t.Errorf("unexpected History config: %+v", cfg.History)
+	}
+	if cfg.Leader.LockKey != 42 || cfg.Leader.CheckIntervalSeconds != 2 {
+		t.Errorf("unexpected Leader config: %+v", cfg.Leader)
+	}
 }
 
 // ----------------------------------------------------------------
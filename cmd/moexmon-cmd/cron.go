@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// Cron-like schedule of the watchlist rule: minute, hour, day of
+// month, month and day of week, evaluated in Moscow time. Each field
+// is "*" or a list of values and ranges with an optional step, e.g.
+// "0-59 10 * * 1-5" is the first hour of the main session.
+// ----------------------------------------------------------------
+type cronSchedule struct {
+	minutes    uint64
+	hours      uint64
+	days       uint64
+	months     uint64
+	weekdays   uint64
+	anyDay     bool // the day of month is "*"
+	anyWeekday bool // the day of week is "*"
+}
+
+// ----------------------------------------------------------------
+// Bounds of the cron field values
+// ----------------------------------------------------------------
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // both 0 and 7 are Sunday
+}
+
+// ----------------------------------------------------------------
+// Parse the value of the cron field
+// ----------------------------------------------------------------
+func parseCronValue(field cronField, value string) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < field.min || number > field.max {
+		return 0, fmt.Errorf("invalid %s '%s', expected %d-%d", field.name, value, field.min, field.max)
+	}
+	return number, nil
+}
+
+// ----------------------------------------------------------------
+// Parse the cron field to the bit set of the matching values
+// ----------------------------------------------------------------
+func parseCronField(field cronField, spec string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid %s step '%s'", field.name, stepSpec)
+			}
+		}
+
+		from, to := field.min, field.max
+		if rangeSpec != "*" {
+			first, last, isRange := strings.Cut(rangeSpec, "-")
+			var err error
+			if from, err = parseCronValue(field, first); err != nil {
+				return 0, err
+			}
+			to = from
+			if isRange {
+				if to, err = parseCronValue(field, last); err != nil {
+					return 0, err
+				}
+				if to < from {
+					return 0, fmt.Errorf("invalid %s range '%s'", field.name, rangeSpec)
+				}
+			} else if stepped {
+				// "5/15" is "5-59/15"
+				to = field.max
+			}
+		}
+		for value := from; value <= to; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// ----------------------------------------------------------------
+// Parse the five-field cron schedule
+// ----------------------------------------------------------------
+func parseCron(spec string) (*cronSchedule, error) {
+	specs := strings.Fields(spec)
+	if len(specs) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule '%s': expected %d fields", spec, len(cronFields))
+	}
+	bits := make([]uint64, len(cronFields))
+	for i, field := range cronFields {
+		var err error
+		if bits[i], err = parseCronField(field, specs[i]); err != nil {
+			return nil, fmt.Errorf("invalid schedule '%s': %w", spec, err)
+		}
+	}
+	// Sunday is both 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		minutes:    bits[0],
+		hours:      bits[1],
+		days:       bits[2],
+		months:     bits[3],
+		weekdays:   bits[4],
+		anyDay:     strings.HasPrefix(specs[2], "*"),
+		anyWeekday: strings.HasPrefix(specs[4], "*"),
+	}, nil
+}
+
+// ----------------------------------------------------------------
+// Check whether the minute of the time matches the schedule. As in
+// cron, if both the day of month and the day of week are restricted,
+// either of them matches the day.
+// ----------------------------------------------------------------
+func (schedule *cronSchedule) matches(now time.Time) bool {
+	now = now.In(moscowTime)
+	if schedule.minutes&(1<<now.Minute()) == 0 || schedule.hours&(1<<now.Hour()) == 0 ||
+		schedule.months&(1<<int(now.Month())) == 0 {
+		return false
+	}
+	day := schedule.days&(1<<now.Day()) != 0
+	weekday := schedule.weekdays&(1<<int(now.Weekday())) != 0
+	if schedule.anyDay || schedule.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
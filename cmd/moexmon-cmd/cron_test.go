@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+func TestParseCron_Matches(t *testing.T) {
+	// 2025-03-03 is Monday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2025, 3, day, hour, minute, 0, 0, moscowTime)
+	}
+	tests := []struct {
+		spec     string
+		now      time.Time
+		expected bool
+	}{
+		{"* * * * *", at(3, 3, 17), true},
+		{"0-59 10 * * 1-5", at(3, 10, 0), true},
+		{"0-59 10 * * 1-5", at(3, 10, 59), true},
+		{"0-59 10 * * 1-5", at(3, 11, 0), false},
+		{"0-59 10 * * 1-5", at(8, 10, 30), false},
+		{"*/15 * * * *", at(3, 12, 45), true},
+		{"*/15 * * * *", at(3, 12, 46), false},
+		{"5/20 * * * *", at(3, 12, 25), true},
+		{"0,30 18-23 * * *", at(3, 19, 30), true},
+		// Sunday is both 0 and 7
+		{"* * * * 7", at(9, 12, 0), true},
+		{"* * * * 0", at(9, 12, 0), true},
+		// Either restricted day matches
+		{"* * 1 * 1", at(3, 12, 0), true},
+		{"* * 1 * 1", at(1, 12, 0), true},
+		{"* * 1 * 1", at(4, 12, 0), false},
+		{"* * * 4 *", at(3, 12, 0), false},
+		// The time is converted to Moscow
+		{"0 10 * * *", time.Date(2025, 3, 3, 7, 0, 0, 0, time.UTC), true},
+	}
+	for _, test := range tests {
+		schedule, err := parseCron(test.spec)
+		if err != nil {
+			t.Fatalf("unexpected error for '%s': %v", test.spec, err)
+		}
+		if result := schedule.matches(test.now); result != test.expected {
+			t.Errorf("expected %v for '%s' at %s, got %v", test.expected, test.spec, test.now.Format(time.RFC3339), result)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "10-5 * * * *", "*/0 * * * *", "a * * * *", "1,,2 * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("expected error for '%s', got nil", spec)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type moexTable struct {
+	Columns []string `json:"columns"`
+	Data    [][]any  `json:"data"`
+}
+
+type moexDividends struct {
+	Dividends moexTable `json:"dividends"`
+}
+
+type moexBondization struct {
+	Coupons moexTable `json:"coupons"`
+	Offers  moexTable `json:"offers"`
+}
+
+// ----------------------------------------------------------------
+// Storage of the corporate events and their notices, implemented by
+// godfather.Database
+// ----------------------------------------------------------------
+type eventStore interface {
+	GetListedMOEXAssets(classes []string) ([]godfather.MOEXAsset, error)
+	UpsertMOEXCorporateEvents(events []godfather.MOEXCorporateEvent) error
+	DeleteMOEXCorporateEventsBefore(day time.Time) (int64, error)
+	GetPendingMOEXEventNotices(day time.Time) ([]godfather.MOEXEventNotice, error)
+	AddMOEXEventNotice(subscriptionID int, eventID int) error
+}
+
+// Defaults of the corporate events synchronization
+const (
+	defaultEventsIntervalHours = 24
+	defaultEventsHorizonDays   = 90
+	defaultEventsWorkers       = 4
+)
+
+// ----------------------------------------------------------------
+func (config EventsConfig) intervalHours() int {
+	if config.IntervalHours <= 0 {
+		return defaultEventsIntervalHours
+	}
+	return config.IntervalHours
+}
+
+// ----------------------------------------------------------------
+func (config EventsConfig) horizonDays() int {
+	if config.HorizonDays <= 0 {
+		return defaultEventsHorizonDays
+	}
+	return config.HorizonDays
+}
+
+// ----------------------------------------------------------------
+func (config EventsConfig) workers() int {
+	if config.Workers <= 0 {
+		return defaultEventsWorkers
+	}
+	return config.Workers
+}
+
+// ----------------------------------------------------------------
+// Start of the day in Moscow time
+// ----------------------------------------------------------------
+func moscowDay(now time.Time) time.Time {
+	now = now.In(moscowTime)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, moscowTime)
+}
+
+// ----------------------------------------------------------------
+// The calendar day of the date stored without the time zone
+// ----------------------------------------------------------------
+func moscowDayOf(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, moscowTime)
+}
+
+// ----------------------------------------------------------------
+// Parse the events of the table with the date within [from, till],
+// the rows without the date are skipped
+// ----------------------------------------------------------------
+func parseEvents(table moexTable, ticker string, kind string, dateColumn string, valueColumn string,
+	currencyColumn string, from time.Time, till time.Time) ([]godfather.MOEXCorporateEvent, error) {
+	dateIndex := columnIndex(table.Columns, dateColumn)
+	if dateIndex < 0 {
+		return nil, fmt.Errorf("unexpected %s columns for %s: %v", kind, ticker, table.Columns)
+	}
+	valueIndex := columnIndex(table.Columns, valueColumn)
+	currencyIndex := columnIndex(table.Columns, currencyColumn)
+
+	var events []godfather.MOEXCorporateEvent
+	for _, row := range table.Data {
+		date, err := time.ParseInLocation(time.DateOnly, optionalString(row, dateIndex), moscowTime)
+		if err != nil || date.Before(from) || date.After(till) {
+			continue
+		}
+		events = append(events, godfather.MOEXCorporateEvent{
+			Ticker:   ticker,
+			Kind:     kind,
+			Date:     date,
+			Value:    optionalFloat(row, valueIndex),
+			Currency: optionalString(row, currencyIndex),
+		})
+	}
+	return events, nil
+}
+
+// ----------------------------------------------------------------
+// Fetch the dividends of the stock with the registry close date
+// within [from, till]
+// ----------------------------------------------------------------
+func fetchDividends(ctx context.Context, ticker string, from time.Time, till time.Time) ([]godfather.MOEXCorporateEvent, error) {
+	url := issClient.URL("/iss/securities/%s/dividends.json?iss.meta=off", ticker)
+	result, err := query[moexDividends](ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return parseEvents(result.Dividends, ticker, godfather.MOEXDividend, "registryclosedate", "value", "currencyid", from, till)
+}
+
+// ----------------------------------------------------------------
+// Fetch the coupons and the offers of the bond within [from, till]
+// ----------------------------------------------------------------
+func fetchBondization(ctx context.Context, ticker string, from time.Time, till time.Time) ([]godfather.MOEXCorporateEvent, error) {
+	url := issClient.URL("/iss/securities/%s/bondization.json?iss.meta=off&iss.only=coupons,offers&limit=unlimited&from=%s&till=%s",
+		ticker, from.Format(time.DateOnly), till.Format(time.DateOnly))
+	result, err := query[moexBondization](ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	coupons, err := parseEvents(result.Coupons, ticker, godfather.MOEXCoupon, "coupondate", "value", "faceunit", from, till)
+	if err != nil {
+		return nil, err
+	}
+	// The offers may be absent for the bond
+	if len(result.Offers.Columns) == 0 {
+		return coupons, nil
+	}
+	offers, err := parseEvents(result.Offers, ticker, godfather.MOEXOffer, "offerdate", "price", "faceunit", from, till)
+	if err != nil {
+		return nil, err
+	}
+	return append(coupons, offers...), nil
+}
+
+// ----------------------------------------------------------------
+// Fetch the corporate events of the asset depending on its class
+// ----------------------------------------------------------------
+func fetchCorporateEvents(ctx context.Context, asset godfather.MOEXAsset, from time.Time, till time.Time) ([]godfather.MOEXCorporateEvent, error) {
+	switch asset.ClassID {
+	case "stock":
+		return fetchDividends(ctx, asset.Ticker, from, till)
+	case "bond":
+		return fetchBondization(ctx, asset.Ticker, from, till)
+	default:
+		return nil, nil
+	}
+}
+
+// ----------------------------------------------------------------
+// Synchronize the corporate events of the listed stocks and bonds
+// within the horizon and drop the past ones
+// ----------------------------------------------------------------
+func syncCorporateEvents(ctx context.Context, store eventStore, config EventsConfig, now time.Time) error {
+	assets, err := store.GetListedMOEXAssets([]string{"stock", "bond"})
+	if err != nil {
+		dbFailures.Inc()
+		return err
+	}
+	from := moscowDay(now)
+	till := from.AddDate(0, 0, config.horizonDays())
+
+	var mutex sync.Mutex
+	var events []godfather.MOEXCorporateEvent
+	failed := 0
+	skipped := runPool(ctx, config.workers(), assets, func(asset godfather.MOEXAsset) {
+		found, err := fetchCorporateEvents(ctx, asset, from, till)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to fetch corporate events of %s", asset.Ticker), "error", err)
+			moexFailures.Inc()
+			failed++
+			return
+		}
+		events = append(events, found...)
+	})
+	if skipped > 0 {
+		slog.Warn(fmt.Sprintf("Corporate events synchronization interrupted, %d assets skipped", skipped))
+	}
+
+	var errs []error
+	if len(events) > 0 {
+		if err := store.UpsertMOEXCorporateEvents(events); err != nil {
+			dbFailures.Inc()
+			errs = append(errs, err)
+		}
+	}
+	count, err := store.DeleteMOEXCorporateEventsBefore(from)
+	if err != nil {
+		dbFailures.Inc()
+		errs = append(errs, err)
+	} else if count > 0 {
+		slog.Debug(fmt.Sprintf("%d past MOEX corporate events deleted", count))
+	}
+	if failed > 0 {
+		errs = append(errs, fmt.Errorf("failed to fetch corporate events of %d assets", failed))
+	}
+	slog.Info(fmt.Sprintf("MOEX corporate events synchronized: %d events of %d assets", len(events), len(assets)-failed-skipped))
+	return errors.Join(errs...)
+}
+
+// ----------------------------------------------------------------
+// Human readable description of the upcoming event for alerts
+// ----------------------------------------------------------------
+func describeCorporateEvent(event godfather.MOEXCorporateEvent, today time.Time) string {
+	date := event.Date.Format(time.DateOnly)
+	days := int(math.Round(moscowDayOf(event.Date).Sub(today).Hours() / 24))
+	when := fmt.Sprintf("in %d days", days)
+	switch days {
+	case 0:
+		when = "today"
+	case 1:
+		when = "tomorrow"
+	}
+
+	value := ""
+	if !math.IsNaN(event.Value) {
+		value = fmt.Sprintf(" of %.2f %s", event.Value, event.Currency)
+		if event.Kind == godfather.MOEXOffer {
+			value = fmt.Sprintf(" at %.2f%%", event.Value)
+		}
+	}
+	switch event.Kind {
+	case godfather.MOEXDividend:
+		return fmt.Sprintf("%s dividend%s: the registry closes on %s (%s)", event.Ticker, value, date, when)
+	case godfather.MOEXCoupon:
+		return fmt.Sprintf("%s coupon%s is paid on %s (%s)", event.Ticker, value, date, when)
+	default:
+		return fmt.Sprintf("%s offer%s is due on %s (%s)", event.Ticker, value, date, when)
+	}
+}
+
+// ----------------------------------------------------------------
+// Publish the alerts of the events entering the subscriptions' notice
+// period, each event is notified once per subscription
+// ----------------------------------------------------------------
+func notifyCorporateEvents(store eventStore, publisher alertPublisher, now time.Time) error {
+	today := moscowDay(now)
+	notices, err := store.GetPendingMOEXEventNotices(today)
+	if err != nil {
+		dbFailures.Inc()
+		return err
+	}
+	for _, notice := range notices {
+		text := describeCorporateEvent(notice.Event, today)
+		data, err := msgpack.Marshal(godfather.AlertMessage{
+			Subject:        text,
+			NotificationId: notice.NotificationID,
+		})
+		if err != nil {
+			slog.Error("Failed to marshal alert message", "error", err)
+			alertFailures.Inc()
+			continue
+		}
+		if err := publisher.Publish("alerts.MOEX", data); err != nil {
+			slog.Error("Failed to publish alert", "error", err)
+			alertFailures.Inc()
+			continue
+		}
+		alertsPublished.Inc()
+		slog.Debug("Alert published", "message", text, "subscription_id", notice.SubscriptionID)
+
+		if err := store.AddMOEXEventNotice(notice.SubscriptionID, notice.Event.ID); err != nil {
+			slog.Error("Failed to record event notice", "error", err)
+			dbFailures.Inc()
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+func startEventSync(ctx context.Context, store eventStore, publisher alertPublisher, config EventsConfig) {
+	intervalHours := config.intervalHours()
+	slog.Info(fmt.Sprintf("Starting MOEX corporate events synchronization every %d hours...", intervalHours))
+
+	ticker := time.NewTicker(time.Duration(intervalHours) * time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := syncCorporateEvents(ctx, store, config, time.Now()); err != nil {
+			slog.Error("Failed to synchronize MOEX corporate events", "error", err)
+		}
+		if err := notifyCorporateEvents(store, publisher, time.Now()); err != nil {
+			slog.Error("Failed to notify MOEX corporate events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ----------------------------------------------------------------
+type mockEventStore struct {
+	assets   []godfather.MOEXAsset
+	upserted []godfather.MOEXCorporateEvent
+	deleted  time.Time
+	notices  []godfather.MOEXEventNotice
+	noticed  []int
+}
+
+func (m *mockEventStore) GetListedMOEXAssets(classes []string) ([]godfather.MOEXAsset, error) {
+	return m.assets, nil
+}
+
+func (m *mockEventStore) UpsertMOEXCorporateEvents(events []godfather.MOEXCorporateEvent) error {
+	m.upserted = append(m.upserted, events...)
+	return nil
+}
+
+func (m *mockEventStore) DeleteMOEXCorporateEventsBefore(day time.Time) (int64, error) {
+	m.deleted = day
+	return 0, nil
+}
+
+func (m *mockEventStore) GetPendingMOEXEventNotices(day time.Time) ([]godfather.MOEXEventNotice, error) {
+	return m.notices, nil
+}
+
+func (m *mockEventStore) AddMOEXEventNotice(subscriptionID int, eventID int) error {
+	m.noticed = append(m.noticed, subscriptionID)
+	return nil
+}
+
+// ----------------------------------------------------------------
+func mockCorporateEventsISS(t *testing.T) *[]string {
+	var requests []string
+	var mutex sync.Mutex
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mutex.Lock()
+		requests = append(requests, req.URL.String())
+		mutex.Unlock()
+		var body string
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/securities/SBER/dividends.json"):
+			body = `{"dividends":{"columns":["secid","isin","registryclosedate","value","currencyid"],"data":[
+				["SBER","RU0009029540","2024-07-11",33.3,"RUB"],
+				["SBER","RU0009029540","2025-07-18",34.84,"RUB"]]}}`
+		case strings.HasSuffix(req.URL.Path, "/securities/SU26238RMFS4/bondization.json"):
+			body = `{"coupons":{"columns":["isin","coupondate","value","faceunit"],"data":[
+				["RU000A1038V6","2025-06-04",35.4,"SUR"],
+				["RU000A1038V6",null,null,"SUR"]]},
+				"offers":{"columns":["isin","offerdate","price","faceunit"],"data":[["RU000A1038V6","2025-06-20",100,"SUR"]]}}`
+		default:
+			t.Errorf("unexpected request: %s", req.URL.String())
+			body = `{}`
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+	return &requests
+}
+
+// ----------------------------------------------------------------
+func TestSyncCorporateEvents(t *testing.T) {
+	requests := mockCorporateEventsISS(t)
+	store := &mockEventStore{assets: []godfather.MOEXAsset{
+		{Ticker: "SBER", ClassID: "stock"},
+		{Ticker: "SU26238RMFS4", ClassID: "bond"},
+		{Ticker: "USD000UTSTOM", ClassID: "currency"},
+	}}
+
+	now := time.Date(2025, 5, 20, 9, 0, 0, 0, time.UTC)
+	if err := syncCorporateEvents(context.Background(), store, EventsConfig{}, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("expected 2 requests, got %v", *requests)
+	}
+	for _, request := range *requests {
+		if strings.Contains(request, "bondization") && !strings.Contains(request, "from=2025-05-20&till=2025-08-18") {
+			t.Errorf("unexpected bondization request: %s", request)
+		}
+	}
+	if !store.deleted.Equal(time.Date(2025, 5, 20, 0, 0, 0, 0, moscowTime)) {
+		t.Errorf("unexpected deletion day: %v", store.deleted)
+	}
+
+	// The past dividend and the coupon without the date are skipped
+	kinds := make(map[string]godfather.MOEXCorporateEvent)
+	for _, event := range store.upserted {
+		kinds[event.Kind] = event
+	}
+	if len(store.upserted) != 3 || len(kinds) != 3 {
+		t.Fatalf("expected a dividend, a coupon and an offer, got %+v", store.upserted)
+	}
+	dividend := kinds[godfather.MOEXDividend]
+	if dividend.Ticker != "SBER" || dividend.Value != 34.84 || dividend.Currency != "RUB" ||
+		dividend.Date.Format(time.DateOnly) != "2025-07-18" {
+		t.Errorf("unexpected dividend: %+v", dividend)
+	}
+	if offer := kinds[godfather.MOEXOffer]; offer.Value != 100 || offer.Date.Format(time.DateOnly) != "2025-06-20" {
+		t.Errorf("unexpected offer: %+v", offer)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchDividends_UnexpectedColumns(t *testing.T) {
+	body := `{"dividends":{"columns":["secid","value"],"data":[["SBER",34.84]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	from := time.Date(2025, 5, 20, 0, 0, 0, 0, moscowTime)
+	if _, err := fetchDividends(context.Background(), "SBER", from, from.AddDate(0, 0, 90)); err == nil {
+		t.Error("expected error for unexpected columns, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeCorporateEvent(t *testing.T) {
+	today := time.Date(2025, 7, 15, 0, 0, 0, 0, moscowTime)
+	tests := []struct {
+		event    godfather.MOEXCorporateEvent
+		expected string
+	}{
+		{godfather.MOEXCorporateEvent{Ticker: "SBER", Kind: godfather.MOEXDividend, Value: 34.84, Currency: "RUB",
+			Date: time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)},
+			"SBER dividend of 34.84 RUB: the registry closes on 2025-07-18 (in 3 days)"},
+		{godfather.MOEXCorporateEvent{Ticker: "SU26238RMFS4", Kind: godfather.MOEXCoupon, Value: 35.4, Currency: "SUR",
+			Date: time.Date(2025, 7, 16, 0, 0, 0, 0, time.UTC)},
+			"SU26238RMFS4 coupon of 35.40 SUR is paid on 2025-07-16 (tomorrow)"},
+		{godfather.MOEXCorporateEvent{Ticker: "SU26238RMFS4", Kind: godfather.MOEXOffer, Value: 100,
+			Date: time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)},
+			"SU26238RMFS4 offer at 100.00% is due on 2025-07-15 (today)"},
+		{godfather.MOEXCorporateEvent{Ticker: "GAZP", Kind: godfather.MOEXDividend, Value: math.NaN(),
+			Date: time.Date(2025, 7, 20, 0, 0, 0, 0, time.UTC)},
+			"GAZP dividend: the registry closes on 2025-07-20 (in 5 days)"},
+	}
+	for _, test := range tests {
+		if text := describeCorporateEvent(test.event, today); text != test.expected {
+			t.Errorf("expected '%s', got '%s'", test.expected, text)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestNotifyCorporateEvents(t *testing.T) {
+	event := godfather.MOEXCorporateEvent{ID: 10, Ticker: "SBER", Kind: godfather.MOEXDividend, Value: 34.84,
+		Currency: "RUB", Date: time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)}
+	store := &mockEventStore{notices: []godfather.MOEXEventNotice{{SubscriptionID: 1, NotificationID: 2, Event: event}}}
+	publisher := &mockQuotePublisher{}
+
+	if err := notifyCorporateEvents(store, publisher, time.Date(2025, 7, 15, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var alert godfather.AlertMessage
+	if err := msgpack.Unmarshal(publisher.messages["alerts.MOEX"], &alert); err != nil {
+		t.Fatalf("failed to unmarshal alert: %v", err)
+	}
+	if alert.NotificationId != 2 || !strings.HasPrefix(alert.Subject, "SBER dividend") {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+	if len(store.noticed) != 1 || store.noticed[0] != 1 {
+		t.Errorf("expected the notice recorded, got %v", store.noticed)
+	}
+
+	// The notice is not recorded if the alert is not published
+	store.noticed = nil
+	publisher = &mockQuotePublisher{err: io.ErrClosedPipe}
+	if err := notifyCorporateEvents(store, publisher, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.noticed) != 0 {
+		t.Errorf("unexpected notices: %v", store.noticed)
+	}
+}
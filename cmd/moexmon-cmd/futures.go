@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// Futures contract listed on FORTS
+// ----------------------------------------------------------------
+type moexContract struct {
+	secid         string    // SECID of the contract, e.g. SiZ5
+	assetCode     string    // ASSETCODE of the continuous contract, e.g. Si
+	lastTradeDate time.Time // LASTTRADEDATE, expiration of the contract
+}
+
+// ----------------------------------------------------------------
+// Futures data of the quote, only set for the futures
+// ----------------------------------------------------------------
+type MoexFutures struct {
+	Contract         string    // SECID of the traded contract, the front month for the continuous codes
+	OpenInterest     float64   // OPENPOSITION, open contracts
+	PrevOpenInterest float64   // PREVOPENPOSITION, open contracts at the previous session close
+	LastTradeDate    time.Time // LASTTRADEDATE, expiration of the contract
+}
+
+// Board of the FORTS futures
+var fortsBoard = moexBoard{engine: "futures", market: "forts", board: "RFUD"}
+
+// ----------------------------------------------------------------
+// Fetch the contracts listed on FORTS
+// ----------------------------------------------------------------
+func fetchContracts(ctx context.Context) ([]moexContract, error) {
+	url := issClient.URL("/iss/engines/%s/markets/%s/boards/%s/securities.json?iss.meta=off&iss.only=securities&securities.columns=SECID,ASSETCODE,LASTTRADEDATE",
+		fortsBoard.engine, fortsBoard.market, fortsBoard.board)
+	result, err := query[moexSecurities](ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := result.Securities.Columns
+	secidIndex := columnIndex(columns, "SECID")
+	assetCodeIndex := columnIndex(columns, "ASSETCODE")
+	lastTradeIndex := columnIndex(columns, "LASTTRADEDATE")
+	if secidIndex < 0 || assetCodeIndex < 0 || lastTradeIndex < 0 {
+		return nil, fmt.Errorf("unexpected futures columns: %v", columns)
+	}
+	contracts := make([]moexContract, 0, len(result.Securities.Data))
+	for _, row := range result.Securities.Data {
+		contract := moexContract{
+			secid:         optionalString(row, secidIndex),
+			assetCode:     optionalString(row, assetCodeIndex),
+			lastTradeDate: optionalDate(row, lastTradeIndex),
+		}
+		if contract.secid != "" {
+			contracts = append(contracts, contract)
+		}
+	}
+	return contracts, nil
+}
+
+// ----------------------------------------------------------------
+// Front month of the continuous contract: the listed contract of the
+// asset code expiring first, not before today
+// ----------------------------------------------------------------
+func frontContract(contracts []moexContract, code string, today time.Time) (moexContract, bool) {
+	var front moexContract
+	found := false
+	for _, contract := range contracts {
+		// The contract itself is watched
+		if contract.secid == code {
+			return contract, true
+		}
+		if contract.assetCode != code || contract.lastTradeDate.Before(today) {
+			continue
+		}
+		if !found || contract.lastTradeDate.Before(front.lastTradeDate) {
+			front, found = contract, true
+		}
+	}
+	return front, found
+}
+
+// ----------------------------------------------------------------
+// Resolve the futures code to the traded contract. The contracts are
+// reloaded once a day, so the continuous codes roll to the next
+// contract once the front one expires.
+// ----------------------------------------------------------------
+func (requester *MoexRequester) resolveContract(ctx context.Context, code string) (moexContract, error) {
+	requester.mutex.Lock()
+	defer requester.mutex.Unlock()
+
+	today := moscowDay(time.Now())
+	if !requester.contractsDay.Equal(today) {
+		contracts, err := fetchContracts(ctx)
+		if err != nil {
+			return moexContract{}, err
+		}
+		requester.contracts = contracts
+		requester.contractsDay = today
+	}
+
+	front, found := frontContract(requester.contracts, code, today)
+	if !found {
+		return moexContract{}, &AssetNotFoundError{Asset: code}
+	}
+	if requester.fronts == nil {
+		requester.fronts = make(map[string]string)
+	}
+	if previous := requester.fronts[code]; previous != "" && previous != front.secid {
+		slog.Info(fmt.Sprintf("Rolled %s from %s to %s", code, previous, front.secid))
+	}
+	requester.fronts[code] = front.secid
+	return front, nil
+}
+
+// ----------------------------------------------------------------
+// Board and ISS security of the asset, the futures codes are resolved
+// to the traded contract
+// ----------------------------------------------------------------
+func (requester *MoexRequester) resolveSecurity(ctx context.Context, asset Asset) (moexBoard, string, error) {
+	board, err := resolveBoard(asset)
+	if err != nil {
+		return board, "", err
+	}
+	if board.market != fortsBoard.market {
+		return board, asset.Ticker, nil
+	}
+	contract, err := requester.resolveContract(ctx, asset.Ticker)
+	if err != nil {
+		return board, "", err
+	}
+	return board, contract.secid, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+func TestFrontContract(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 0, 0, 0, 0, moscowTime) }
+	contracts := []moexContract{
+		{secid: "SiH6", assetCode: "Si", lastTradeDate: time.Date(2026, 3, 19, 0, 0, 0, 0, moscowTime)},
+		{secid: "SiZ5", assetCode: "Si", lastTradeDate: day(12, 18)},
+		{secid: "SiU5", assetCode: "Si", lastTradeDate: day(9, 18)},
+		{secid: "BRX5", assetCode: "BR", lastTradeDate: day(10, 31)},
+	}
+	tests := []struct {
+		code     string
+		today    time.Time
+		expected string
+	}{
+		{"Si", day(9, 1), "SiU5"},
+		// The contract is traded until its last trading day
+		{"Si", day(9, 18), "SiU5"},
+		{"Si", day(9, 19), "SiZ5"},
+		{"Si", day(12, 19), "SiH6"},
+		// The contract is watched by itself
+		{"SiU5", day(9, 1), "SiU5"},
+		{"BR", day(11, 1), ""},
+		{"RTS", day(9, 1), ""},
+	}
+	for _, test := range tests {
+		front, found := frontContract(contracts, test.code, test.today)
+		if found != (test.expected != "") || front.secid != test.expected {
+			t.Errorf("expected '%s' for %s on %s, got '%s'", test.expected, test.code, test.today.Format(time.DateOnly), front.secid)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_Futures(t *testing.T) {
+	expiration := moscowDay(time.Now()).AddDate(0, 1, 0).Format(time.DateOnly)
+	var requests []string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.String())
+		var body string
+		if req.URL.Query().Get("iss.only") == "securities" {
+			body = `{"securities":{"columns":["SECID","ASSETCODE","LASTTRADEDATE"],"data":[
+				["SiZ5","Si","` + expiration + `"],["SiU5","Si","2020-09-18"]]}}`
+		} else {
+			body = `{"marketdata":{"columns":["SECID","LAST","OPENPOSITION"],"data":[["SiZ5",92100,1500000]]},
+				"securities":{"columns":["SECID","PREVOPENPOSITION","LASTTRADEDATE"],"data":[["SiZ5",1200000,"` + expiration + `"]]}}`
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "Si", AssetType: "futures"},
+		{Ticker: "SiZ5", AssetType: "futures"},
+	})
+	if len(requests) != 2 || !strings.Contains(requests[1], "/engines/futures/markets/forts/boards/RFUD/") ||
+		!strings.HasSuffix(requests[1], "&securities=SiZ5") {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+	// The continuous code and the contract share the quote
+	for _, ticker := range []string{"Si", "SiZ5"} {
+		quote := quotes[ticker]
+		if quote.Err != nil || quote.Price != 92100 || quote.Futures == nil {
+			t.Fatalf("unexpected %s quote: %+v", ticker, quote)
+		}
+		if quote.Futures.Contract != "SiZ5" || quote.Futures.OpenInterest != 1500000 || quote.Futures.PrevOpenInterest != 1200000 ||
+			quote.Futures.LastTradeDate.Format(time.DateOnly) != expiration {
+			t.Errorf("unexpected %s futures data: %+v", ticker, quote.Futures)
+		}
+	}
+
+	// The contracts are loaded once a day
+	requester.FetchPrices(context.Background(), []Asset{{Ticker: "Si", AssetType: "futures"}})
+	if len(requests) != 3 {
+		t.Errorf("expected the contracts to be cached, got %v", requests)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchBoardSecurities_ContinuousFutures(t *testing.T) {
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"securities":{"columns":["SECID","SHORTNAME","SECNAME","ISIN","LOTSIZE","CURRENCYID","ASSETCODE"],"data":[
+			["SiZ5","Si-12.25","Фьючерсный контракт Si-12.25",null,1,null,"Si"],
+			["SiH6","Si-3.26","Фьючерсный контракт Si-3.26",null,1,null,"Si"]]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+	board := CatalogBoardConfig{Engine: "futures", Market: "forts", Board: "RFUD", Class: "futures"}
+	assets, err := fetchBoardSecurities(context.Background(), board, time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tickers := make([]string, len(assets))
+	for i, asset := range assets {
+		tickers[i] = asset.Ticker
+	}
+	if strings.Join(tickers, ",") != "SiZ5,Si,SiH6" {
+		t.Fatalf("expected the contracts and the continuous code, got %v", tickers)
+	}
+	if assets[1].ClassID != "futures" || assets[1].Board != "RFUD" || assets[1].Name != "Si (continuous)" {
+		t.Errorf("unexpected continuous contract: %+v", assets[1])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_FuturesRules(t *testing.T) {
+	expiration := moscowDay(time.Now()).AddDate(0, 0, 5)
+	quote := Quote{Price: 92100, Futures: &MoexFutures{Contract: "SiZ5", OpenInterest: 1500000,
+		PrevOpenInterest: 1200000, LastTradeDate: expiration}}
+	// The roll lags and ISS still reports the expired contract
+	expired := Quote{Price: 92100, Futures: &MoexFutures{Contract: "SiU5", LastTradeDate: moscowDay(time.Now()).AddDate(0, 0, -2)}}
+	tests := []struct {
+		condition string
+		target    float64
+		quote     Quote
+		expected  bool
+	}{
+		{"oi_above", 1000000, quote, true},
+		{"oi_below", 1000000, quote, false},
+		{"oi_change_above", 20, quote, true},
+		{"oi_change_above", 30, quote, false},
+		{"oi_change_below", -10, quote, false},
+		{"expiration_within", 7, quote, true},
+		{"expiration_within", 3, quote, false},
+		{"expiration_within", 5, quote, true},
+		{"expiration_within", 7, expired, false},
+		// The futures data is required
+		{"oi_above", 0, Quote{Price: 92100}, false},
+		{"expiration_within", 7, Quote{Price: 92100}, false},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "Si", Condition: test.condition, TargetPrice: test.target}
+		if result := conditionMatch(item, test.quote, time.Now()); result != test.expected {
+			t.Errorf("expected %v for %s %.2f, got %v", test.expected, test.condition, test.target, result)
+		}
+	}
+
+	item := godfather.MOEXWatchlistItem{Ticker: "Si", Condition: "expiration_within", TargetPrice: 7}
+	crossing := item
+	crossing.Mode = godfather.MOEXRuleCrossing
+	crossing.Armed = true
+	if action := evaluateRule(crossing, expired, time.Now()); action != ruleIdle {
+		t.Errorf("expected the expired contract not to fire again, got %v", action)
+	}
+	expected := "The expiration of Si is within 7 days (SiZ5, " + expiration.Format(time.DateOnly) + ")"
+	if text := describeAlert(item, quote); text != expected {
+		t.Errorf("expected '%s', got '%s'", expected, text)
+	}
+}
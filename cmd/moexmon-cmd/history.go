@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+// Storage of the price history, implemented by godfather.Database
+// ----------------------------------------------------------------
+type quoteStore interface {
+	AddMOEXQuotes(quotes []godfather.MOEXQuote) error
+	EnsureMOEXQuotesPartition(day time.Time) error
+	DropMOEXQuotesBefore(day time.Time) (int64, error)
+	RollupMOEXQuotes(interval string, since time.Time) (int64, error)
+	DeleteMOEXQuoteBarsBefore(interval string, before time.Time) (int64, error)
+}
+
+// Default interval between two maintenances of the price history
+const defaultHistoryMaintenanceMinutes = 5
+
+// Default retention of the price history in days, 0 keeps forever
+var defaultHistoryRetentionDays = map[string]int{
+	godfather.MOEXQuoteRaw:    7,
+	godfather.MOEXQuoteMinute: 30,
+	godfather.MOEXQuoteHour:   365,
+	godfather.MOEXQuoteDay:    0,
+}
+
+// Truncation unit of each rollup interval
+var historyRollupUnits = []struct {
+	interval string
+	unit     time.Duration
+}{
+	{godfather.MOEXQuoteMinute, time.Minute},
+	{godfather.MOEXQuoteHour, time.Hour},
+	{godfather.MOEXQuoteDay, 24 * time.Hour},
+}
+
+// ----------------------------------------------------------------
+// Store the successfully fetched prices of the snapshot
+// ----------------------------------------------------------------
+func recordQuotes(store quoteStore, snapshot map[string]Quote, now time.Time) {
+	quotes := make([]godfather.MOEXQuote, 0, len(snapshot))
+	for ticker, quote := range snapshot {
+		if quote.Err != nil || math.IsNaN(quote.Price) {
+			continue
+		}
+		quotes = append(quotes, godfather.MOEXQuote{
+			Ticker:    ticker,
+			Timestamp: now,
+			Price:     quote.Price,
+			VolToday:  quote.VolToday,
+		})
+	}
+	if len(quotes) == 0 {
+		return
+	}
+	// Keep the inserts in a stable order to avoid deadlocks
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].Ticker < quotes[j].Ticker })
+
+	if err := store.AddMOEXQuotes(quotes); err != nil {
+		slog.Error("Failed to store MOEX quotes", "error", err)
+		dbFailures.Inc()
+		return
+	}
+	slog.Debug(fmt.Sprintf("Stored %d MOEX quotes", len(quotes)))
+}
+
+// ----------------------------------------------------------------
+// Retention of the interval in days, 0 keeps forever
+// ----------------------------------------------------------------
+func historyRetention(config HistoryConfig, interval string) int {
+	if days, found := config.RetentionDays[interval]; found {
+		return days
+	}
+	return defaultHistoryRetentionDays[interval]
+}
+
+// ----------------------------------------------------------------
+// Prepare the partitions of the coming days, roll up the recent
+// quotes and apply the retention policy
+// ----------------------------------------------------------------
+func maintainHistory(store quoteStore, config HistoryConfig, now time.Time) error {
+	var errs []error
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for day := -1; day <= 2; day++ {
+		if err := store.EnsureMOEXQuotesPartition(today.AddDate(0, 0, day)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// The last two buckets are recomputed, the rollups of the source
+	// interval must be complete before the next one
+	every := time.Duration(config.maintenanceMinutes()) * time.Minute
+	for _, rollup := range historyRollupUnits {
+		since := now.Add(-2 * max(rollup.unit, every))
+		count, err := store.RollupMOEXQuotes(rollup.interval, since)
+		if err != nil {
+			// Don't delete the history that is not rolled up yet
+			return errors.Join(append(errs, err)...)
+		}
+		slog.Debug(fmt.Sprintf("Rolled up %d MOEX quote bars of %s", count, rollup.interval))
+	}
+
+	if days := historyRetention(config, godfather.MOEXQuoteRaw); days > 0 {
+		dropped, err := store.DropMOEXQuotesBefore(today.AddDate(0, 0, -days))
+		if err != nil {
+			errs = append(errs, err)
+		} else if dropped > 0 {
+			slog.Info(fmt.Sprintf("Dropped %d MOEX quotes partitions", dropped))
+		}
+	}
+	for _, rollup := range historyRollupUnits {
+		days := historyRetention(config, rollup.interval)
+		if days <= 0 {
+			continue
+		}
+		count, err := store.DeleteMOEXQuoteBarsBefore(rollup.interval, today.AddDate(0, 0, -days))
+		if err != nil {
+			errs = append(errs, err)
+		} else if count > 0 {
+			slog.Info(fmt.Sprintf("Deleted %d MOEX quote bars of %s", count, rollup.interval))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ----------------------------------------------------------------
+func startHistoryMaintenance(ctx context.Context, store quoteStore, config HistoryConfig) {
+	minutes := config.maintenanceMinutes()
+	slog.Info(fmt.Sprintf("Starting MOEX price history maintenance every %d minutes...", minutes))
+
+	ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := maintainHistory(store, config, time.Now()); err != nil {
+			slog.Error("Failed to maintain MOEX price history", "error", err)
+			dbFailures.Inc()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+type mockQuoteStore struct {
+	quotes     []godfather.MOEXQuote
+	partitions []time.Time
+	rollups    []string
+	rollupErr  error
+	droppedAt  []time.Time
+	deleted    map[string]time.Time
+}
+
+func (m *mockQuoteStore) AddMOEXQuotes(quotes []godfather.MOEXQuote) error {
+	m.quotes = append(m.quotes, quotes...)
+	return nil
+}
+
+func (m *mockQuoteStore) EnsureMOEXQuotesPartition(day time.Time) error {
+	m.partitions = append(m.partitions, day)
+	return nil
+}
+
+func (m *mockQuoteStore) DropMOEXQuotesBefore(day time.Time) (int64, error) {
+	m.droppedAt = append(m.droppedAt, day)
+	return 1, nil
+}
+
+func (m *mockQuoteStore) RollupMOEXQuotes(interval string, since time.Time) (int64, error) {
+	if m.rollupErr != nil {
+		return 0, m.rollupErr
+	}
+	m.rollups = append(m.rollups, interval)
+	return 1, nil
+}
+
+func (m *mockQuoteStore) DeleteMOEXQuoteBarsBefore(interval string, before time.Time) (int64, error) {
+	if m.deleted == nil {
+		m.deleted = make(map[string]time.Time)
+	}
+	m.deleted[interval] = before
+	return 1, nil
+}
+
+// ----------------------------------------------------------------
+func TestRecordQuotes(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	store := &mockQuoteStore{}
+	recordQuotes(store, map[string]Quote{
+		"SBER": {Price: 310.5, VolToday: 1000},
+		"GAZP": {Price: 150, VolToday: math.NaN()},
+		"YNDX": {Err: &AssetNotFoundError{Asset: "YNDX"}},
+		"LKOH": {Price: math.NaN()},
+	}, now)
+
+	if len(store.quotes) != 2 {
+		t.Fatalf("expected 2 quotes stored, got %+v", store.quotes)
+	}
+	if store.quotes[0].Ticker != "GAZP" || store.quotes[1].Ticker != "SBER" {
+		t.Errorf("expected quotes ordered by ticker, got %+v", store.quotes)
+	}
+	if !store.quotes[1].Timestamp.Equal(now) || store.quotes[1].Price != 310.5 || store.quotes[1].VolToday != 1000 {
+		t.Errorf("unexpected quote: %+v", store.quotes[1])
+	}
+
+	// Nothing is stored without prices
+	store = &mockQuoteStore{}
+	recordQuotes(store, map[string]Quote{"YNDX": {Err: errors.New("timeout")}}, now)
+	if len(store.quotes) != 0 {
+		t.Errorf("unexpected quotes stored: %+v", store.quotes)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMaintainHistory(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 30, 0, 0, time.UTC)
+	today := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	store := &mockQuoteStore{}
+	config := HistoryConfig{RetentionDays: map[string]int{godfather.MOEXQuoteMinute: 10}}
+	if err := maintainHistory(store, config, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.partitions) != 4 || !store.partitions[0].Equal(today.AddDate(0, 0, -1)) ||
+		!store.partitions[3].Equal(today.AddDate(0, 0, 2)) {
+		t.Errorf("unexpected partitions: %v", store.partitions)
+	}
+	if len(store.rollups) != 3 || store.rollups[0] != godfather.MOEXQuoteMinute ||
+		store.rollups[1] != godfather.MOEXQuoteHour || store.rollups[2] != godfather.MOEXQuoteDay {
+		t.Errorf("unexpected rollups order: %v", store.rollups)
+	}
+	// The default retention of the raw quotes is a week
+	if len(store.droppedAt) != 1 || !store.droppedAt[0].Equal(today.AddDate(0, 0, -7)) {
+		t.Errorf("unexpected dropped partitions: %v", store.droppedAt)
+	}
+	if before := store.deleted[godfather.MOEXQuoteMinute]; !before.Equal(today.AddDate(0, 0, -10)) {
+		t.Errorf("unexpected retention of 1m bars: %s", before)
+	}
+	if before := store.deleted[godfather.MOEXQuoteHour]; !before.Equal(today.AddDate(0, 0, -365)) {
+		t.Errorf("unexpected retention of 1h bars: %s", before)
+	}
+	// The daily bars are kept forever by default
+	if _, found := store.deleted[godfather.MOEXQuoteDay]; found {
+		t.Error("unexpected deletion of 1d bars")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMaintainHistory_RollupError(t *testing.T) {
+	store := &mockQuoteStore{rollupErr: errors.New("rollup failed")}
+	err := maintainHistory(store, HistoryConfig{}, time.Now())
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	// Nothing is deleted before it is rolled up
+	if len(store.droppedAt) != 0 || len(store.deleted) != 0 {
+		t.Errorf("unexpected retention applied: %v, %v", store.droppedAt, store.deleted)
+	}
+}
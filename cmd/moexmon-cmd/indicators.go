@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+)
+
+// All the indicators below take the values in chronological order and
+// return a series of the same length, NaN where there is not enough data
+
+// ----------------------------------------------------------------
+// Simple moving average
+// ----------------------------------------------------------------
+func sma(values []float64, period int) []float64 {
+	result := nanSeries(len(values))
+	if period <= 0 {
+		return result
+	}
+	sum := 0.0
+	for i, value := range values {
+		sum += value
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			result[i] = sum / float64(period)
+		}
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+// Exponential moving average seeded with the SMA of the first period
+// ----------------------------------------------------------------
+func ema(values []float64, period int) []float64 {
+	result := nanSeries(len(values))
+	if period <= 0 || len(values) < period {
+		return result
+	}
+	k := 2.0 / float64(period+1)
+	result[period-1] = sma(values[:period], period)[period-1]
+	for i := period; i < len(values); i++ {
+		result[i] = values[i]*k + result[i-1]*(1-k)
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+// Relative strength index with Wilder's smoothing
+// ----------------------------------------------------------------
+func rsi(values []float64, period int) []float64 {
+	result := nanSeries(len(values))
+	if period <= 0 || len(values) <= period {
+		return result
+	}
+
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	result[period] = rsiValue(gain, loss)
+
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		currentGain, currentLoss := 0.0, 0.0
+		if change > 0 {
+			currentGain = change
+		} else {
+			currentLoss = -change
+		}
+		gain = (gain*float64(period-1) + currentGain) / float64(period)
+		loss = (loss*float64(period-1) + currentLoss) / float64(period)
+		result[i] = rsiValue(gain, loss)
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+func rsiValue(gain float64, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// ----------------------------------------------------------------
+// Bollinger bands: SMA and the bands width standard deviations away
+// ----------------------------------------------------------------
+func bollinger(values []float64, period int, width float64) (middle []float64, upper []float64, lower []float64) {
+	middle = sma(values, period)
+	upper = nanSeries(len(values))
+	lower = nanSeries(len(values))
+	for i := range values {
+		if math.IsNaN(middle[i]) {
+			continue
+		}
+		variance := 0.0
+		for _, value := range values[i-period+1 : i+1] {
+			variance += (value - middle[i]) * (value - middle[i])
+		}
+		deviation := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + width*deviation
+		lower[i] = middle[i] - width*deviation
+	}
+	return middle, upper, lower
+}
+
+// ----------------------------------------------------------------
+func nanSeries(length int) []float64 {
+	result := make([]float64, length)
+	for i := range result {
+		result[i] = math.NaN()
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// ----------------------------------------------------------------
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// ----------------------------------------------------------------
+func TestSMA(t *testing.T) {
+	result := sma([]float64{1, 2, 3, 4, 5}, 3)
+	if !math.IsNaN(result[0]) || !math.IsNaN(result[1]) {
+		t.Errorf("Expected NaN before the period is filled, got %v", result)
+	}
+	expected := []float64{2, 3, 4}
+	for i, value := range expected {
+		if !almostEqual(result[i+2], value) {
+			t.Errorf("Expected %v at %d, got %v", value, i+2, result[i+2])
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSMA_InvalidPeriod(t *testing.T) {
+	for _, value := range sma([]float64{1, 2, 3}, 0) {
+		if !math.IsNaN(value) {
+			t.Errorf("Expected NaN for the zero period, got %v", value)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEMA(t *testing.T) {
+	result := ema([]float64{1, 2, 3, 4, 5}, 3)
+	// Seeded with SMA(3) = 2, then k = 0.5
+	expected := []float64{2, 3, 4}
+	for i, value := range expected {
+		if !almostEqual(result[i+2], value) {
+			t.Errorf("Expected %v at %d, got %v", value, i+2, result[i+2])
+		}
+	}
+	if !math.IsNaN(ema([]float64{1, 2}, 3)[1]) {
+		t.Error("Expected NaN when there is not enough data")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestRSI(t *testing.T) {
+	// Rising only: RSI is 100
+	rising := rsi([]float64{1, 2, 3, 4, 5, 6}, 3)
+	if !almostEqual(rising[5], 100) {
+		t.Errorf("Expected RSI 100 for rising prices, got %v", rising[5])
+	}
+	// Falling only: RSI is 0
+	falling := rsi([]float64{6, 5, 4, 3, 2, 1}, 3)
+	if !almostEqual(falling[5], 0) {
+		t.Errorf("Expected RSI 0 for falling prices, got %v", falling[5])
+	}
+	// Flat: RSI is 50
+	flat := rsi([]float64{3, 3, 3, 3}, 3)
+	if !almostEqual(flat[3], 50) {
+		t.Errorf("Expected RSI 50 for flat prices, got %v", flat[3])
+	}
+	// Equal gains and losses: RSI is 50
+	mixed := rsi([]float64{1, 2, 1}, 2)
+	if !almostEqual(mixed[2], 50) {
+		t.Errorf("Expected RSI 50, got %v", mixed[2])
+	}
+	if !math.IsNaN(mixed[1]) {
+		t.Errorf("Expected NaN before the period is filled, got %v", mixed[1])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestBollinger(t *testing.T) {
+	middle, upper, lower := bollinger([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	// Mean 5, population standard deviation 2
+	if !almostEqual(middle[7], 5) || !almostEqual(upper[7], 9) || !almostEqual(lower[7], 1) {
+		t.Errorf("Unexpected bands: %v %v %v", middle[7], upper[7], lower[7])
+	}
+	if !math.IsNaN(upper[6]) || !math.IsNaN(lower[6]) {
+		t.Errorf("Expected NaN before the period is filled")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the ISS client configuration
+const (
+	defaultISSBaseURL                = "https://iss.moex.com"
+	defaultISSTimeoutSeconds         = 10
+	defaultISSMaxAttempts            = 4
+	defaultISSBackoffMilliseconds    = 500
+	defaultISSMaxBackoffSeconds      = 30
+	defaultISSBreakerThreshold       = 5
+	defaultISSBreakerCooldownSeconds = 60
+)
+
+// ----------------------------------------------------------------
+// Circuit breaker state, exposed as the moexmon_iss_breaker_state
+// gauge
+// ----------------------------------------------------------------
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // ISS is queried
+	breakerOpen                         // ISS is down, the queries fail fast
+	breakerHalfOpen                     // a single trial query is allowed
+)
+
+// ----------------------------------------------------------------
+func (state breakerState) String() string {
+	switch state {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Returned without querying ISS while the circuit breaker is open
+var ErrISSUnavailable = errors.New("ISS is unavailable, circuit breaker is open")
+
+// ----------------------------------------------------------------
+// Unexpected HTTP status of the ISS response
+// ----------------------------------------------------------------
+type ISSStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // requested by 429 and 503 responses
+}
+
+func (e *ISSStatusError) Error() string {
+	return fmt.Sprintf("unexpected ISS response status %d", e.StatusCode)
+}
+
+// ----------------------------------------------------------------
+// Temporary failures worth a retry: network errors, 5xx and 429
+// ----------------------------------------------------------------
+func retryable(err error) bool {
+	var statusErr *ISSStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return !errors.Is(err, ErrISSUnavailable)
+}
+
+// ----------------------------------------------------------------
+// Parse the Retry-After header: delay in seconds or HTTP date
+// ----------------------------------------------------------------
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// ----------------------------------------------------------------
+// ISS HTTP client with timeouts, retries with jittered exponential
+// backoff and a circuit breaker shared by all the queries
+// ----------------------------------------------------------------
+type ISSClient struct {
+	http        *http.Client
+	baseURL     string // scheme and host of ISS, without the trailing slash
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	threshold   int
+	cooldown    time.Duration
+
+	mutex     sync.Mutex
+	state     breakerState
+	failures  int       // consecutive failed queries
+	openUntil time.Time // end of the pause while the breaker is open
+	trial     bool      // trial query in flight while half-open
+}
+
+// ----------------------------------------------------------------
+func NewISSClient(config ISSConfig) *ISSClient {
+	withDefault := func(value int, fallback int) int {
+		if value <= 0 {
+			return fallback
+		}
+		return value
+	}
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultISSBaseURL
+	}
+	client := &ISSClient{
+		http:        &http.Client{Timeout: time.Duration(withDefault(config.TimeoutSeconds, defaultISSTimeoutSeconds)) * time.Second},
+		baseURL:     baseURL,
+		maxAttempts: withDefault(config.MaxAttempts, defaultISSMaxAttempts),
+		backoff:     time.Duration(withDefault(config.BackoffMilliseconds, defaultISSBackoffMilliseconds)) * time.Millisecond,
+		maxBackoff:  time.Duration(withDefault(config.MaxBackoffSeconds, defaultISSMaxBackoffSeconds)) * time.Second,
+		threshold:   withDefault(config.BreakerThreshold, defaultISSBreakerThreshold),
+		cooldown:    time.Duration(withDefault(config.BreakerCooldownSeconds, defaultISSBreakerCooldownSeconds)) * time.Second,
+	}
+	client.report()
+	return client
+}
+
+// ----------------------------------------------------------------
+// URL of the ISS endpoint, the path is formatted with the arguments
+// ----------------------------------------------------------------
+func (client *ISSClient) URL(format string, args ...any) string {
+	return client.baseURL + fmt.Sprintf(format, args...)
+}
+
+// ----------------------------------------------------------------
+// Export the breaker state, must be called with the mutex held or
+// before the client is shared
+// ----------------------------------------------------------------
+func (client *ISSClient) report() {
+	issBreakerState.Set(float64(client.state))
+	issConsecutiveFailures.Set(float64(client.failures))
+}
+
+// ----------------------------------------------------------------
+// Check if ISS may be queried now, reserving the trial query if the
+// breaker is half-open
+// ----------------------------------------------------------------
+func (client *ISSClient) acquire(now time.Time) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.state == breakerOpen && !now.Before(client.openUntil) {
+		client.state = breakerHalfOpen
+		client.trial = false
+		slog.Info("ISS circuit breaker is half-open, probing ISS")
+		client.report()
+	}
+	switch client.state {
+	case breakerOpen:
+		return ErrISSUnavailable
+	case breakerHalfOpen:
+		if client.trial {
+			return ErrISSUnavailable
+		}
+		client.trial = true
+	case breakerClosed:
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Record the outcome of the query, pause is the delay requested by
+// ISS before the next query
+// ----------------------------------------------------------------
+func (client *ISSClient) release(err error, pause time.Duration, now time.Time) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	defer client.report()
+
+	client.trial = false
+	if err == nil || !retryable(err) {
+		if client.state != breakerClosed {
+			slog.Info("ISS circuit breaker is closed, ISS is available")
+		}
+		client.state = breakerClosed
+		client.failures = 0
+		return
+	}
+
+	client.failures++
+	if client.state == breakerHalfOpen || client.failures >= client.threshold || pause > client.maxBackoff {
+		client.state = breakerOpen
+		client.openUntil = now.Add(max(client.cooldown, pause))
+		slog.Warn(fmt.Sprintf("ISS circuit breaker is open until %s after %d failed queries", client.openUntil.Format(time.RFC3339), client.failures), "error", err)
+	}
+}
+
+// ----------------------------------------------------------------
+// Check if the breaker lets the queries through, polling is paused
+// otherwise
+// ----------------------------------------------------------------
+func (client *ISSClient) Available(now time.Time) bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.state != breakerOpen || !now.Before(client.openUntil)
+}
+
+// ----------------------------------------------------------------
+// Delay before the next attempt: the one requested by ISS, or the
+// exponential backoff with full jitter
+// ----------------------------------------------------------------
+func (client *ISSClient) delay(attempt int, err error) time.Duration {
+	var statusErr *ISSStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter
+	}
+	backoff := min(client.backoff<<min(attempt, 16), client.maxBackoff)
+	return rand.N(backoff) + 1
+}
+
+// ----------------------------------------------------------------
+// Single attempt of the query
+// ----------------------------------------------------------------
+func (client *ISSClient) do(req *http.Request) ([]byte, error) {
+	res, err := client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close() // nolint:errcheck,gosec
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil, &ISSStatusError{
+			StatusCode: res.StatusCode,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return io.ReadAll(res.Body)
+}
+
+// ----------------------------------------------------------------
+// Forget the trial query cancelled by the caller
+// ----------------------------------------------------------------
+func (client *ISSClient) cancel() {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.trial = false
+}
+
+// ----------------------------------------------------------------
+// Query ISS, retrying the temporary failures
+// ----------------------------------------------------------------
+func (client *ISSClient) Get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.acquire(time.Now()); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, err := client.do(req)
+		if ctx.Err() != nil {
+			client.cancel()
+			return nil, ctx.Err()
+		}
+		if err == nil || !retryable(err) {
+			client.release(err, 0, time.Now())
+			return body, err
+		}
+
+		pause := client.delay(attempt, err)
+		// Let the breaker hold the queries if ISS asked for a longer pause
+		if attempt == client.maxAttempts-1 || pause > client.maxBackoff {
+			client.release(err, pause, time.Now())
+			return nil, err
+		}
+		slog.Debug(fmt.Sprintf("Retrying ISS query in %s", pause), "error", err)
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			client.cancel()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// Replace the shared ISS client with a single attempt one using the
+// transport
+// ----------------------------------------------------------------
+func mockISS(transport http.RoundTripper) {
+	issClient = NewISSClient(ISSConfig{MaxAttempts: 1})
+	issClient.http = &http.Client{Transport: transport}
+}
+
+// ----------------------------------------------------------------
+func testISSClient(config ISSConfig, transport http.RoundTripper) *ISSClient {
+	client := NewISSClient(config)
+	client.http.Transport = transport
+	return client
+}
+
+// ----------------------------------------------------------------
+func statusResponse(status int, header http.Header) *http.Response {
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(bytes.NewBufferString(`{}`))}
+}
+
+// ----------------------------------------------------------------
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-5", 0},
+		{"Mon, 03 Mar 2025 12:01:00 GMT", time.Minute},
+		{"Mon, 03 Mar 2025 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		if delay := parseRetryAfter(test.value, now); delay != test.expected {
+			t.Errorf("expected %s for %q, got %s", test.expected, test.value, delay)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_URL(t *testing.T) {
+	path := "/iss/engines/%s.json?iss.meta=off"
+	if url := NewISSClient(ISSConfig{}).URL(path, "stock"); url != "https://iss.moex.com/iss/engines/stock.json?iss.meta=off" {
+		t.Errorf("unexpected default URL: %s", url)
+	}
+	if url := NewISSClient(ISSConfig{BaseURL: "http://iss-sim:8080/"}).URL(path, "stock"); url != "http://iss-sim:8080/iss/engines/stock.json?iss.meta=off" {
+		t.Errorf("unexpected simulator URL: %s", url)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_RetryServerErrors(t *testing.T) {
+	calls := 0
+	client := testISSClient(ISSConfig{BackoffMilliseconds: 1}, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		switch calls {
+		case 1:
+			return statusResponse(http.StatusBadGateway, nil), nil
+		case 2:
+			return nil, errors.New("connection reset")
+		default:
+			return statusResponse(http.StatusOK, nil), nil
+		}
+	}))
+
+	body, err := client.Get(context.Background(), "https://iss.moex.com/iss/engines.json")
+	if err != nil || string(body) != "{}" {
+		t.Fatalf("expected the response after retries, got %q, %v", body, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if client.state != breakerClosed || client.failures != 0 {
+		t.Errorf("expected the closed breaker, got %s with %d failures", client.state, client.failures)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_ClientErrors(t *testing.T) {
+	calls := 0
+	client := testISSClient(ISSConfig{BackoffMilliseconds: 1, BreakerThreshold: 1}, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return statusResponse(http.StatusNotFound, nil), nil
+	}))
+
+	_, err := client.Get(context.Background(), "https://iss.moex.com/iss/unknown.json")
+	var statusErr *ISSStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the status error, got %v", err)
+	}
+	// Neither retried nor counted as ISS failure
+	if calls != 1 || client.state != breakerClosed {
+		t.Errorf("unexpected %d attempts with %s breaker", calls, client.state)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_Timeout(t *testing.T) {
+	calls := 0
+	client := testISSClient(ISSConfig{MaxAttempts: 2, BackoffMilliseconds: 1}, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}))
+	client.http.Timeout = 10 * time.Millisecond
+
+	if _, err := client.Get(context.Background(), "https://iss.moex.com/iss/engines.json"); err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+	if calls != 2 || client.failures != 1 {
+		t.Errorf("expected the timeouts to be retried, got %d attempts and %d failures", calls, client.failures)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_RetryAfter(t *testing.T) {
+	calls := 0
+	client := testISSClient(ISSConfig{MaxBackoffSeconds: 1}, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return statusResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"0"}}), nil
+		}
+		return statusResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"120"}}), nil
+	}))
+
+	start := time.Now()
+	if _, err := client.Get(context.Background(), "https://iss.moex.com/iss/engines.json"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	// The long pause is not waited for, the breaker holds the queries instead
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+	if client.state != breakerOpen || client.openUntil.Before(start.Add(2*time.Minute)) {
+		t.Errorf("expected the breaker open for 2 minutes, got %s until %s", client.state, client.openUntil)
+	}
+	if client.Available(time.Now()) {
+		t.Error("expected polling to be paused")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_Breaker(t *testing.T) {
+	healthy := false
+	calls := 0
+	client := testISSClient(ISSConfig{MaxAttempts: 1, BreakerThreshold: 2}, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if healthy {
+			return statusResponse(http.StatusOK, nil), nil
+		}
+		return statusResponse(http.StatusServiceUnavailable, nil), nil
+	}))
+	ctx := context.Background()
+	url := "https://iss.moex.com/iss/engines.json"
+
+	for range 2 {
+		if _, err := client.Get(ctx, url); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+	if client.state != breakerOpen {
+		t.Fatalf("expected the breaker to open after 2 failures, got %s", client.state)
+	}
+	if _, err := client.Get(ctx, url); !errors.Is(err, ErrISSUnavailable) || calls != 2 {
+		t.Errorf("expected the query to fail fast, got %v after %d attempts", err, calls)
+	}
+
+	// A failed trial query opens the breaker again
+	client.openUntil = time.Now()
+	if _, err := client.Get(ctx, url); err == nil || errors.Is(err, ErrISSUnavailable) {
+		t.Errorf("expected the trial query to fail, got %v", err)
+	}
+	if client.state != breakerOpen || calls != 3 {
+		t.Errorf("expected the breaker open after the trial, got %s after %d attempts", client.state, calls)
+	}
+
+	// A successful trial query closes the breaker
+	healthy = true
+	client.openUntil = time.Now()
+	if _, err := client.Get(ctx, url); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.state != breakerClosed || client.failures != 0 {
+		t.Errorf("expected the closed breaker, got %s with %d failures", client.state, client.failures)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_HalfOpenSingleTrial(t *testing.T) {
+	client := NewISSClient(ISSConfig{})
+	client.state = breakerOpen
+	client.openUntil = time.Now()
+
+	if err := client.acquire(time.Now()); err != nil {
+		t.Fatalf("expected the trial query to be allowed, got %v", err)
+	}
+	if err := client.acquire(time.Now()); !errors.Is(err, ErrISSUnavailable) {
+		t.Errorf("expected a single trial query, got %v", err)
+	}
+	// The cancelled trial doesn't change the state
+	client.cancel()
+	if err := client.acquire(time.Now()); err != nil || client.state != breakerHalfOpen {
+		t.Errorf("expected a new trial query, got %v in %s state", err, client.state)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestISSClient_Cancelled(t *testing.T) {
+	client := testISSClient(ISSConfig{BreakerThreshold: 1}, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return statusResponse(http.StatusServiceUnavailable, nil), nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.Get(ctx, "https://iss.moex.com/iss/engines.json"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation error, got %v", err)
+	}
+	if client.state != breakerClosed || client.failures != 0 {
+		t.Errorf("expected the cancellation not to count, got %s with %d failures", client.state, client.failures)
+	}
+}
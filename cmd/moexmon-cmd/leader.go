@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Defaults of the leader election
+const (
+	defaultLeaderLockKey              = 0x6d6f65786d6f6e // "moexmon"
+	defaultLeaderCheckIntervalSeconds = 5
+)
+
+var leaderState = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "moexmon_leader",
+		Help: "Leadership of the replica: 0 - standby, 1 - leader monitoring the watchlist",
+	},
+)
+
+// ----------------------------------------------------------------
+// Lock held by the leader, implemented by godfather.AdvisoryLock
+// ----------------------------------------------------------------
+type leaderLock interface {
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// ----------------------------------------------------------------
+// Take the lock without waiting, nil if another replica holds it
+// ----------------------------------------------------------------
+type leaderLocker func(ctx context.Context, key int64) (leaderLock, error)
+
+// ----------------------------------------------------------------
+// Locker of the Postgres advisory lock
+// ----------------------------------------------------------------
+func advisoryLocker(db *godfather.Database) leaderLocker {
+	return func(ctx context.Context, key int64) (leaderLock, error) {
+		lock, err := db.TryAdvisoryLock(ctx, key)
+		if lock == nil {
+			return nil, err
+		}
+		return lock, nil
+	}
+}
+
+// ----------------------------------------------------------------
+// Leader election between the moexmon replicas. The standby replicas
+// try to take the lock every interval, so the failover takes at most
+// an interval once the lock of the leader is released.
+// ----------------------------------------------------------------
+type LeaderElector struct {
+	locker   leaderLocker
+	key      int64
+	interval time.Duration
+	identity string
+}
+
+// ----------------------------------------------------------------
+func NewLeaderElector(locker leaderLocker, config LeaderConfig) *LeaderElector {
+	key := config.LockKey
+	if key == 0 {
+		key = defaultLeaderLockKey
+	}
+	seconds := config.CheckIntervalSeconds
+	if seconds <= 0 {
+		seconds = defaultLeaderCheckIntervalSeconds
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &LeaderElector{
+		locker:   locker,
+		key:      key,
+		interval: time.Duration(seconds) * time.Second,
+		identity: fmt.Sprintf("%s/%d", hostname, os.Getpid()),
+	}
+}
+
+// ----------------------------------------------------------------
+// Run the leader routines while the replica holds the lock, blocks
+// until the context is done. The context of the routines is
+// cancelled once the leadership is lost.
+// ----------------------------------------------------------------
+func (elector *LeaderElector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	slog.Info(fmt.Sprintf("Replica %s is standing by for the leadership, checked every %s", elector.identity, elector.interval))
+	leaderState.Set(0)
+
+	ticker := time.NewTicker(elector.interval)
+	defer ticker.Stop()
+	for {
+		lock, err := elector.locker(ctx, elector.key)
+		if err != nil {
+			slog.Error("Failed to take the leader lock", "error", err)
+			dbFailures.Inc()
+		} else if lock != nil {
+			elector.lead(ctx, lock, lead)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Run the leader routines until the lock is lost, the routines
+// return or the context is done, then release the lock
+// ----------------------------------------------------------------
+func (elector *LeaderElector) lead(ctx context.Context, lock leaderLock, lead func(ctx context.Context)) {
+	slog.Info(fmt.Sprintf("Replica %s is the leader now", elector.identity))
+	leaderState.Set(1)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(elector.interval)
+	defer ticker.Stop()
+	for leading := true; leading; {
+		select {
+		case <-ctx.Done():
+			leading = false
+		case <-done:
+			slog.Warn("Leader routines stopped unexpectedly")
+			leading = false
+		case <-ticker.C:
+			if err := lock.Check(ctx); err != nil {
+				slog.Error("Lost the leadership", "error", err)
+				dbFailures.Inc()
+				leading = false
+			}
+		}
+	}
+	cancel()
+	<-done
+
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), elector.interval)
+	defer cancelRelease()
+	if err := lock.Release(releaseCtx); err != nil {
+		slog.Warn("Failed to release the leader lock", "error", err)
+	}
+	leaderState.Set(0)
+	slog.Info(fmt.Sprintf("Replica %s stepped down from the leadership", elector.identity))
+}
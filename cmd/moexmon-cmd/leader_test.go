@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+type mockLeaderLock struct {
+	lost     atomic.Bool
+	released atomic.Bool
+}
+
+func (lock *mockLeaderLock) Check(ctx context.Context) error {
+	if lock.lost.Load() {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (lock *mockLeaderLock) Release(ctx context.Context) error {
+	lock.released.Store(true)
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Locker handing out the locks in order, nil once they run out
+// ----------------------------------------------------------------
+func mockLocker(locks ...leaderLock) (leaderLocker, *atomic.Int32) {
+	var mutex sync.Mutex
+	var attempts atomic.Int32
+	return func(ctx context.Context, key int64) (leaderLock, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts.Add(1)
+		if len(locks) == 0 {
+			return nil, nil
+		}
+		lock := locks[0]
+		locks = locks[1:]
+		if lock == nil {
+			return nil, errors.New("database is down")
+		}
+		return lock, nil
+	}, &attempts
+}
+
+// ----------------------------------------------------------------
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestNewLeaderElector(t *testing.T) {
+	elector := NewLeaderElector(nil, LeaderConfig{})
+	if elector.key != defaultLeaderLockKey || elector.interval != defaultLeaderCheckIntervalSeconds*time.Second || elector.identity == "" {
+		t.Errorf("unexpected defaults: %+v", elector)
+	}
+	elector = NewLeaderElector(nil, LeaderConfig{LockKey: 42, CheckIntervalSeconds: 1})
+	if elector.key != 42 || elector.interval != time.Second {
+		t.Errorf("unexpected elector: %+v", elector)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestLeaderElector_Failover(t *testing.T) {
+	first, second := &mockLeaderLock{}, &mockLeaderLock{}
+	// The first attempt fails, the lock is taken on the second one
+	locker, attempts := mockLocker(nil, first, second)
+	elector := &LeaderElector{locker: locker, key: 1, interval: 5 * time.Millisecond, identity: "test"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var terms atomic.Int32
+	var leading atomic.Bool
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		elector.Run(ctx, func(ctx context.Context) {
+			terms.Add(1)
+			leading.Store(true)
+			<-ctx.Done()
+			leading.Store(false)
+		})
+	}()
+
+	waitFor(t, "the first term", func() bool { return leading.Load() && terms.Load() == 1 })
+	if attempts.Load() != 2 {
+		t.Errorf("expected the lock on the second attempt, got %d", attempts.Load())
+	}
+
+	// The routines are stopped once the lock is lost, then the lock
+	// is taken again
+	first.lost.Store(true)
+	waitFor(t, "the second term", func() bool { return leading.Load() && terms.Load() == 2 })
+	if !first.released.Load() {
+		t.Error("expected the lost lock to be released")
+	}
+
+	cancel()
+	<-stopped
+	if leading.Load() || !second.released.Load() {
+		t.Errorf("expected the leader to step down on shutdown, leading %v", leading.Load())
+	}
+}
+
+// ----------------------------------------------------------------
+func TestLeaderElector_Standby(t *testing.T) {
+	locker, attempts := mockLocker()
+	elector := &LeaderElector{locker: locker, key: 1, interval: time.Millisecond, identity: "test"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var led atomic.Bool
+	go elector.Run(ctx, func(ctx context.Context) { led.Store(true) })
+
+	waitFor(t, "the retries", func() bool { return attempts.Load() >= 3 })
+	if led.Load() {
+		t.Error("expected the standby replica not to lead")
+	}
+}
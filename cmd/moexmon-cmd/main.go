@@ -4,10 +4,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
@@ -40,6 +45,50 @@ var alertFailures = prometheus.NewCounter(
 		Help: "Number of failures when publishing alerts to NATS",
 	},
 )
+var sessionState = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "moexmon_session_state",
+		Help: "Current MOEX trading session by ISS engine: 0 - closed, 1 - main, 2 - evening, 3 - weekend",
+	},
+	[]string{"engine"},
+)
+var quotesPublished = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "moexmon_quotes_published",
+		Help: "Number of live quotes published to NATS",
+	},
+)
+var quoteFailures = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "moexmon_quote_failures",
+		Help: "Number of failures when publishing live quotes to NATS",
+	},
+)
+var ticksSkipped = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "moexmon_ticks_skipped",
+		Help: "Number of ticks skipped because the previous one was still running",
+	},
+)
+var tickDuration = prometheus.NewHistogram(
+	prometheus.HistogramOpts{
+		Name:    "moexmon_tick_duration_seconds",
+		Help:    "Duration of the watchlist check ticks",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	},
+)
+var issBreakerState = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "moexmon_iss_breaker_state",
+		Help: "State of the ISS circuit breaker: 0 - closed, 1 - open, 2 - half-open",
+	},
+)
+var issConsecutiveFailures = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "moexmon_iss_consecutive_failures",
+		Help: "Number of consecutive failed ISS queries",
+	},
+)
 
 // ----------------------------------------------------------------
 func startMetrics(ctx context.Context, url string, port int) {
@@ -53,38 +102,328 @@ func startMetrics(ctx context.Context, url string, port int) {
 	server.RegisterCounter(moexFailures)
 	server.RegisterCounter(alertsPublished)
 	server.RegisterCounter(alertFailures)
+	server.RegisterCounter(quotesPublished)
+	server.RegisterCounter(quoteFailures)
+	server.RegisterCounter(ticksSkipped)
+	server.RegisterHistogram(tickDuration)
+	server.RegisterGaugeVec(sessionState)
+	server.RegisterGauge(issBreakerState)
+	server.RegisterGauge(issConsecutiveFailures)
+	server.RegisterGauge(leaderState)
 
 	<-ctx.Done()
 	_ = server.Stop()
 }
 
 // ----------------------------------------------------------------
-func conditionMatch(ctx context.Context, item godfather.MOEXWatchlistItem, moex MoexQuery) bool {
-	price, err := moex.FetchPrice(ctx, item.Ticker, item.AssetClass)
-	if err != nil {
-		if _, ok := err.(*AssetNotFoundError); ok {
-			slog.Warn(fmt.Sprintf("Asset %s not found on MOEX", item.Ticker))
+func assetOf(item godfather.MOEXWatchlistItem) Asset {
+	return Asset{
+		Ticker:    item.Ticker,
+		AssetType: item.AssetClass,
+		Engine:    item.Engine,
+		Market:    item.Market,
+		Board:     item.Board,
+	}
+}
+
+// ----------------------------------------------------------------
+// Second leg of the pair rule
+// ----------------------------------------------------------------
+func pairAssetOf(item godfather.MOEXWatchlistItem) Asset {
+	return Asset{
+		Ticker:    item.Pair.Ticker,
+		AssetType: item.Pair.AssetClass,
+		Engine:    item.Pair.Engine,
+		Market:    item.Pair.Market,
+		Board:     item.Pair.Board,
+	}
+}
+
+// ----------------------------------------------------------------
+// Detect the primary board of the assets added since the last tick,
+// including the second legs of the pair rules, returns the detected
+// assets to be stored. The asset type defaults are used if the
+// detection fails. The catalog synchronization stores the board of
+// its assets, the gateway has no endpoint creating the watchlist items
+// or the assets, so the assets added outside of the catalog get their
+// board here, on the first tick after the item is added.
+// ----------------------------------------------------------------
+func detectBoards(ctx context.Context, moex QuoteProvider, watchlist []godfather.MOEXWatchlistItem) map[string]Asset {
+	detected := make(map[string]Asset)
+	failed := make(map[string]bool)
+	detect := func(asset Asset) (Asset, bool) {
+		// The futures codes are resolved to the contracts on FORTS
+		if asset.Ticker == "" || asset.Board != "" || asset.AssetType == "futures" || failed[asset.Ticker] {
+			return asset, false
+		}
+		if known, seen := detected[asset.Ticker]; seen {
+			return known, true
+		}
+		known, err := moex.DetectBoard(ctx, asset.Ticker)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Failed to detect the board of %s, using the %s default", asset.Ticker, asset.AssetType), "error", err)
+			moexFailures.Inc()
+			failed[asset.Ticker] = true
+			return asset, false
+		}
+		slog.Info(fmt.Sprintf("Detected board %s/%s/%s for %s", known.Engine, known.Market, known.Board, asset.Ticker))
+		detected[asset.Ticker] = known
+		return known, true
+	}
+
+	for i, item := range watchlist {
+		if asset, ok := detect(assetOf(item)); ok {
+			watchlist[i].Engine = asset.Engine
+			watchlist[i].Market = asset.Market
+			watchlist[i].Board = asset.Board
+		}
+		if asset, ok := detect(pairAssetOf(item)); ok {
+			watchlist[i].Pair.Engine = asset.Engine
+			watchlist[i].Pair.Market = asset.Market
+			watchlist[i].Pair.Board = asset.Board
+		}
+	}
+	return detected
+}
+
+// ----------------------------------------------------------------
+func storeBoards(db tickStore, detected map[string]Asset) {
+	for ticker, asset := range detected {
+		if err := db.SetMOEXAssetBoard(ticker, asset.Engine, asset.Market, asset.Board); err != nil {
+			slog.Error("Failed to store the asset board", "error", err)
+			dbFailures.Inc()
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Fetch the prices for all the watchlist items, including the second
+// legs of the pair rules, and the extra assets in one batch
+// ----------------------------------------------------------------
+func fetchSnapshot(ctx context.Context, moex QuoteProvider, watchlist []godfather.MOEXWatchlistItem, extra []Asset) map[string]Quote {
+	assets := make([]Asset, 0, len(watchlist)+len(extra))
+	seen := make(map[string]bool, len(watchlist)+len(extra))
+	add := func(asset Asset) {
+		if asset.Ticker == "" || seen[asset.Ticker] {
+			return
+		}
+		seen[asset.Ticker] = true
+		assets = append(assets, asset)
+	}
+	for _, item := range watchlist {
+		add(assetOf(item))
+		add(pairAssetOf(item))
+	}
+	for _, asset := range extra {
+		add(asset)
+	}
+	return moex.FetchPrices(ctx, assets)
+}
+
+// ----------------------------------------------------------------
+// Fetch the candles history needed by the indicator rules
+// ----------------------------------------------------------------
+func attachCandles(ctx context.Context, moex QuoteProvider, watchlist []godfather.MOEXWatchlistItem, snapshot map[string]Quote, workers int) {
+	type candlesKey struct {
+		asset    Asset
+		interval int
+	}
+	type candlesJob struct {
+		key   candlesKey
+		count int
+	}
+	required := make(map[candlesKey]int)
+	for _, item := range watchlist {
+		condition, known := moexConditions[item.Condition]
+		if !known || condition.candles == nil {
+			continue
+		}
+		count := condition.candles(item.Params)
+		if count == 0 {
+			slog.Warn(fmt.Sprintf("Missing indicator parameters for watchlist item %d", item.ID))
+			continue
+		}
+		key := candlesKey{asset: assetOf(item), interval: item.Params.CandleInterval}
+		required[key] = max(required[key], count)
+	}
+
+	var jobs []candlesJob
+	for key, count := range required {
+		if quote, found := snapshot[key.asset.Ticker]; found && quote.Err == nil {
+			jobs = append(jobs, candlesJob{key: key, count: count})
+		}
+	}
+
+	var mutex sync.Mutex
+	runPool(ctx, workers, jobs, func(job candlesJob) {
+		candles, err := moex.FetchCandles(ctx, job.key.asset, job.key.interval, job.count)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to fetch candles for %s: %s", job.key.asset.Ticker, err.Error()))
+			moexFailures.Inc()
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		quote := snapshot[job.key.asset.Ticker]
+		if quote.Candles == nil {
+			quote.Candles = make(map[int][]Candle)
+		}
+		quote.Candles[job.key.interval] = candles
+		snapshot[job.key.asset.Ticker] = quote
+	})
+}
+
+// ----------------------------------------------------------------
+// Fetch the volumes history needed by the activity rules
+// ----------------------------------------------------------------
+func attachVolumes(ctx context.Context, moex QuoteProvider, watchlist []godfather.MOEXWatchlistItem, snapshot map[string]Quote, workers int) {
+	type volumesJob struct {
+		asset Asset
+		days  int
+	}
+	required := make(map[Asset]int)
+	for _, item := range watchlist {
+		condition, known := moexConditions[item.Condition]
+		if !known || condition.volumes == nil {
+			continue
+		}
+		asset := assetOf(item)
+		required[asset] = max(required[asset], condition.volumes(item.Params))
+	}
+
+	var jobs []volumesJob
+	for asset, days := range required {
+		if quote, found := snapshot[asset.Ticker]; found && quote.Err == nil {
+			jobs = append(jobs, volumesJob{asset: asset, days: days})
+		}
+	}
+
+	var mutex sync.Mutex
+	runPool(ctx, workers, jobs, func(job volumesJob) {
+		volumes, err := moex.FetchVolumes(ctx, job.asset, job.days)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to fetch volumes for %s: %s", job.asset.Ticker, err.Error()))
+			moexFailures.Inc()
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		quote := snapshot[job.asset.Ticker]
+		quote.Volumes = volumes
+		snapshot[job.asset.Ticker] = quote
+	})
+}
+
+// ----------------------------------------------------------------
+// Look up the ticker's quote in the snapshot, logging fetch failures
+// ----------------------------------------------------------------
+func lookupTicker(ticker string, snapshot map[string]Quote) (Quote, bool) {
+	quote, found := snapshot[ticker]
+	if !found {
+		quote.Err = &AssetNotFoundError{Asset: ticker}
+	}
+	if quote.Err != nil {
+		if _, ok := quote.Err.(*AssetNotFoundError); ok {
+			slog.Warn(fmt.Sprintf("Asset %s not found on MOEX", ticker))
 		} else {
-			slog.Error(fmt.Sprintf("Failed to fetch price for %s: %s", item.Ticker, err.Error()))
+			slog.Error(fmt.Sprintf("Failed to fetch price for %s: %s", ticker, quote.Err.Error()))
 			moexFailures.Inc()
 		}
-		return false
+		return quote, false
 	}
-	slog.Debug(fmt.Sprintf("Current price for %s: %.2f", item.Ticker, price))
-	switch item.Condition {
-	case "above":
-		return price > item.TargetPrice
-	case "below":
-		return price < item.TargetPrice
-	default:
-		return false
+	slog.Debug(fmt.Sprintf("Current price for %s: %.2f", ticker, quote.Price))
+	return quote, true
+}
+
+// ----------------------------------------------------------------
+// Look up the item's quote in the snapshot, with the second leg's
+// quote attached for the pair rules
+// ----------------------------------------------------------------
+func lookupQuote(item godfather.MOEXWatchlistItem, snapshot map[string]Quote) (Quote, bool) {
+	quote, ok := lookupTicker(item.Ticker, snapshot)
+	if !ok || item.Pair.Ticker == "" {
+		return quote, ok
+	}
+	second, ok := lookupTicker(item.Pair.Ticker, snapshot)
+	if !ok {
+		return quote, false
+	}
+	quote.Second = &second
+	return quote, true
+}
+
+// ----------------------------------------------------------------
+// Storage of the expired watchlist items, implemented by
+// godfather.Database
+// ----------------------------------------------------------------
+type watchlistArchiver interface {
+	ArchiveMOEXWatchlistItem(id int, archivedAt time.Time) error
+}
+
+// ----------------------------------------------------------------
+// Storage of the watchlist, the portfolios and the quotes checked in
+// the tick, implemented by godfather.Database
+// ----------------------------------------------------------------
+type tickStore interface {
+	watchlistArchiver
+	portfolioStore
+	quoteStore
+	GetMOEXWatchlist(activeOnly bool) ([]godfather.MOEXWatchlistItem, error)
+	GetMonitoredPortfolios() ([]godfather.Portfolio, error)
+	SetMOEXAssetBoard(ticker string, engine string, market string, board string) error
+	SetMOEXWatchlistItemActiveStatus(id int, active bool) error
+	SetMOEXWatchlistItemTriggerState(id int, armed bool, lastTriggeredAt time.Time) error
+	AddMOEXAlert(alert *godfather.MOEXAlert) error
+}
+
+// ----------------------------------------------------------------
+// Archive the watchlist items past their validity period notifying
+// the owners, returns the items still valid. The item is archived
+// only once the owner is notified, so a failed notice is retried on
+// the next tick.
+// ----------------------------------------------------------------
+func archiveExpired(store watchlistArchiver, publisher alertPublisher, watchlist []godfather.MOEXWatchlistItem, now time.Time) []godfather.MOEXWatchlistItem {
+	valid := make([]godfather.MOEXWatchlistItem, 0, len(watchlist))
+	for _, item := range watchlist {
+		if item.ValidUntil.IsZero() || now.Before(item.ValidUntil) {
+			valid = append(valid, item)
+			continue
+		}
+
+		text := fmt.Sprintf("The rule expired on %s and was archived: %s",
+			item.ValidUntil.In(moscowTime).Format("2006-01-02 15:04 MST"), describeCondition(item))
+		data, err := msgpack.Marshal(godfather.AlertMessage{
+			Subject:        text,
+			NotificationId: item.NotificationID,
+			WatchlistId:    item.ID,
+		})
+		if err != nil {
+			slog.Error("Failed to marshal alert message", "error", err)
+			alertFailures.Inc()
+			continue
+		}
+		if err := publisher.Publish("alerts.MOEX", data); err != nil {
+			slog.Error("Failed to publish alert", "error", err)
+			alertFailures.Inc()
+			continue
+		}
+		alertsPublished.Inc()
+		slog.Debug("Alert published", "message", text, "watchlist_id", item.ID)
+
+		if err := store.ArchiveMOEXWatchlistItem(item.ID, now); err != nil {
+			slog.Error("Failed to archive watchlist item", "error", err)
+			dbFailures.Inc()
+			continue
+		}
+		slog.Info(fmt.Sprintf("Watchlist item %d for %s expired and was archived", item.ID, item.Ticker))
 	}
+	return valid
 }
 
 // ----------------------------------------------------------------
-func deactivateWatchlistItem(db *godfather.Database, ticker string) {
-	slog.Debug(fmt.Sprintf("Condition met for %s, deactivating watchlist item", ticker))
-	err := db.SetMOEXWatchlistItemActiveStatus(ticker, false)
+func deactivateWatchlistItem(db tickStore, item godfather.MOEXWatchlistItem) {
+	slog.Debug(fmt.Sprintf("Condition met for %s, deactivating watchlist item %d", item.Ticker, item.ID))
+	err := db.SetMOEXWatchlistItemActiveStatus(item.ID, false)
 	if err != nil {
 		slog.Error("Failed to deactivate watchlist item", "error", err)
 		dbFailures.Inc()
@@ -92,54 +431,372 @@ func deactivateWatchlistItem(db *godfather.Database, ticker string) {
 }
 
 // ----------------------------------------------------------------
-func sendAlert(item godfather.MOEXWatchlistItem, mb *godfather.MessageBus) {
-	alertText := fmt.Sprintf("The price for %s is %s %.2f", item.Ticker, item.Condition, item.TargetPrice)
+func disarmWatchlistItem(db tickStore, item godfather.MOEXWatchlistItem, triggeredAt time.Time) {
+	slog.Debug(fmt.Sprintf("Condition met for %s, disarming watchlist item %d", item.Ticker, item.ID))
+	err := db.SetMOEXWatchlistItemTriggerState(item.ID, false, triggeredAt)
+	if err != nil {
+		slog.Error("Failed to disarm watchlist item", "error", err)
+		dbFailures.Inc()
+	}
+}
+
+// ----------------------------------------------------------------
+func rearmWatchlistItem(db tickStore, item godfather.MOEXWatchlistItem) {
+	slog.Debug(fmt.Sprintf("Price for %s moved back past the hysteresis band, re-arming watchlist item %d", item.Ticker, item.ID))
+	err := db.SetMOEXWatchlistItemTriggerState(item.ID, true, item.LastTriggeredAt)
+	if err != nil {
+		slog.Error("Failed to re-arm watchlist item", "error", err)
+		dbFailures.Inc()
+	}
+}
+
+// ----------------------------------------------------------------
+// Alert text, reporting the prices of both legs for the pair rules
+// and the date (with the contract for the futures) for the reminders
+// ----------------------------------------------------------------
+func describeAlert(item godfather.MOEXWatchlistItem, quote Quote) string {
+	text := describeCondition(item)
+	if quote.Second != nil {
+		text += fmt.Sprintf(" (%s %.2f, %s %.2f)", item.Ticker, quote.Price, item.Pair.Ticker, quote.Second.Price)
+	}
+	if condition := moexConditions[item.Condition]; condition.date != nil && !condition.date(quote).IsZero() {
+		date := condition.date(quote).Format(time.DateOnly)
+		if quote.Futures != nil {
+			text += fmt.Sprintf(" (%s, %s)", quote.Futures.Contract, date)
+		} else {
+			text += fmt.Sprintf(" (%s)", date)
+		}
+	}
+	return text
+}
+
+// ----------------------------------------------------------------
+// Publish the alert to NATS, returns true if the alert was published
+// ----------------------------------------------------------------
+func sendAlert(item godfather.MOEXWatchlistItem, quote Quote, mb alertPublisher) bool {
+	alertText := describeAlert(item, quote)
 	alert := godfather.AlertMessage{
 		Subject:        alertText,
 		NotificationId: item.NotificationID,
+		WatchlistId:    item.ID,
 	}
 	data, err := msgpack.Marshal(alert)
 	if err != nil {
 		slog.Error("Failed to marshal alert message", "error", err)
 		alertFailures.Inc()
-		return
+		return false
 	}
 	err = mb.Publish("alerts.MOEX", data)
 	if err != nil {
 		alertFailures.Inc()
 		slog.Error("Failed to publish alert", "error", err)
-	} else {
-		alertsPublished.Inc()
-		slog.Debug("Alert published", "message", alertText)
+		return false
+	}
+	alertsPublished.Inc()
+	slog.Debug("Alert published", "message", alertText, "watchlist_id", item.ID)
+	return true
+}
+
+// ----------------------------------------------------------------
+func recordAlert(db tickStore, item godfather.MOEXWatchlistItem, quote Quote, published bool) {
+	alert := &godfather.MOEXAlert{
+		WatchlistID: item.ID,
+		Price:       quote.Price,
+		TargetPrice: item.TargetPrice,
+		Condition:   item.Condition,
+		Published:   published,
+	}
+	if quote.Second != nil {
+		alert.SecondTicker = item.Pair.Ticker
+		alert.SecondPrice = quote.Second.Price
+	}
+	err := db.AddMOEXAlert(alert)
+	if err != nil {
+		slog.Error("Failed to record alert", "error", err)
+		dbFailures.Inc()
+	}
+}
+
+// ----------------------------------------------------------------
+// Evaluation of the watchlist item in the tick
+// ----------------------------------------------------------------
+type tickResult struct {
+	item   godfather.MOEXWatchlistItem
+	quote  Quote
+	quoted bool // false if the price is not available
+	action ruleAction
+}
+
+// ----------------------------------------------------------------
+// Evaluate the watchlist item and apply the action unless it is a
+// dry run
+// ----------------------------------------------------------------
+func processWatchlistItem(db tickStore, mb alertPublisher, item godfather.MOEXWatchlistItem, snapshot map[string]Quote, now time.Time, dryRun bool) tickResult {
+	result := tickResult{item: item}
+	result.quote, result.quoted = lookupQuote(item, snapshot)
+	if !result.quoted {
+		return result
+	}
+	result.action = evaluateRule(item, result.quote, now)
+	if dryRun {
+		return result
+	}
+
+	switch result.action {
+	case ruleFire:
+		if item.Mode == godfather.MOEXRuleCrossing {
+			disarmWatchlistItem(db, item, now)
+		} else {
+			deactivateWatchlistItem(db, item)
+		}
+		published := sendAlert(item, result.quote, mb)
+		recordAlert(db, item, result.quote, published)
+	case ruleRearm:
+		rearmWatchlistItem(db, item)
+	case ruleIdle:
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+// ISS engines the watchlist items are traded on, including the second
+// legs of the pair rules
+// ----------------------------------------------------------------
+func watchlistEngines(watchlist []godfather.MOEXWatchlistItem) []string {
+	seen := make(map[string]bool)
+	var engines []string
+	for _, item := range watchlist {
+		assets := []Asset{assetOf(item)}
+		if item.Pair.Ticker != "" {
+			assets = append(assets, pairAssetOf(item))
+		}
+		for _, asset := range assets {
+			board, err := resolveBoard(asset)
+			if err != nil || seen[board.engine] {
+				continue
+			}
+			seen[board.engine] = true
+			engines = append(engines, board.engine)
+		}
 	}
+	return engines
 }
 
 // ----------------------------------------------------------------
-func startMonitoring(ctx context.Context, moex MoexQuery, db *godfather.Database, mb *godfather.MessageBus, interval_sec int) {
-	slog.Info(fmt.Sprintf("Starting MOEX monitoring, check interval is %d seconds...", interval_sec))
+// Keep the watchlist items traded at the given moment, the pair rules
+// need both legs in session
+// ----------------------------------------------------------------
+func tradingItems(schedules *TradingSchedules, watchlist []godfather.MOEXWatchlistItem, now time.Time) []godfather.MOEXWatchlistItem {
+	trading := func(asset Asset) bool {
+		board, err := resolveBoard(asset)
+		return err != nil || schedules.Session(board.engine, now) != sessionClosed
+	}
+	var items []godfather.MOEXWatchlistItem
+	for _, item := range watchlist {
+		if !trading(assetOf(item)) || (item.Pair.Ticker != "" && !trading(pairAssetOf(item))) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// ----------------------------------------------------------------
+// Block until any engine of the watchlist is open, returns false if
+// the context is done. The schedules are reloaded from ISS once the
+// engines close.
+// ----------------------------------------------------------------
+func waitForSession(ctx context.Context, schedules *TradingSchedules) bool {
+	reloaded := false
+	for {
+		now := time.Now()
+		open := false
+		for engine, session := range schedules.Sessions(now) {
+			sessionState.WithLabelValues(engine).Set(float64(session))
+			open = open || session != sessionClosed
+		}
+		if open {
+			return true
+		}
+		if !reloaded {
+			reloaded = true
+			schedules.Reload(ctx, now)
+			continue
+		}
+
+		next, found := schedules.NextOpen(now)
+		if !found {
+			next = now.Add(24 * time.Hour)
+		}
+		slog.Info(fmt.Sprintf("MOEX is closed, sleeping until %s", next.Format(time.RFC3339)))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
 
-	ticker := time.NewTicker(time.Duration(interval_sec) * time.Second)
+// ----------------------------------------------------------------
+// Check the active watchlist items and the portfolio rules once. The
+// dry run only evaluates the watchlist items: nothing is stored to
+// the database or published to NATS. The items out of the trading
+// session of their engine are skipped if the schedules are set.
+// ----------------------------------------------------------------
+func runTick(ctx context.Context, moex QuoteProvider, db tickStore, mb alertPublisher, schedules *TradingSchedules, workers int, dryRun bool) ([]tickResult, error) {
+	watchlist, err := db.GetMOEXWatchlist(true)
+	if err != nil {
+		dbFailures.Inc()
+		return nil, fmt.Errorf("failed to retrieve MOEX watchlist: %w", err)
+	}
+	slog.Debug(fmt.Sprintf("MOEX watchlist retrieved %d active items", len(watchlist)))
+	var portfolios []godfather.Portfolio
+	if !dryRun {
+		watchlist = archiveExpired(db, mb, watchlist, providerNow(moex))
+		portfolios, err = db.GetMonitoredPortfolios()
+		if err != nil {
+			slog.Error("Failed to retrieve portfolios", "error", err)
+			dbFailures.Inc()
+		}
+	}
+	if len(watchlist) == 0 && len(portfolios) == 0 {
+		return nil, nil
+	}
+	detected := detectBoards(ctx, moex, watchlist)
+	if !dryRun {
+		storeBoards(db, detected)
+	}
+	if schedules != nil {
+		schedules.Track(ctx, watchlistEngines(watchlist))
+		watchlist = tradingItems(schedules, watchlist, providerNow(moex))
+	}
+	snapshot := fetchSnapshot(ctx, moex, watchlist, portfolioAssets(portfolios))
+	attachCandles(ctx, moex, watchlist, snapshot, workers)
+	attachVolumes(ctx, moex, watchlist, snapshot, workers)
+	now := providerNow(moex)
+	// The replayed quotes are neither stored to the history nor
+	// published to the live quotes stream
+	if !dryRun && !isReplay(moex) {
+		recordQuotes(db, snapshot, now)
+		publishQuotes(mb, watchlist, snapshot, now)
+	}
+
+	// The results are kept in the watchlist order
+	results := make([]tickResult, len(watchlist))
+	positions := make([]int, len(watchlist))
+	for i, item := range watchlist {
+		results[i] = tickResult{item: item}
+		positions[i] = i
+	}
+	skipped := runPool(ctx, workers, positions, func(i int) {
+		results[i] = processWatchlistItem(db, mb, watchlist[i], snapshot, now, dryRun)
+	})
+	if skipped > 0 {
+		slog.Warn(fmt.Sprintf("Tick deadline exceeded, %d watchlist items not checked", skipped))
+	}
+	if !dryRun {
+		checkPortfolios(db, mb, portfolios, snapshot, now)
+	}
+	return results, nil
+}
+
+// ----------------------------------------------------------------
+// Print the table of the evaluated watchlist items
+// ----------------------------------------------------------------
+func printTick(w io.Writer, results []tickResult) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(table, "ID\tTICKER\tPRICE\tCONDITION\tTARGET\tWOULD FIRE"); err != nil {
+		return err
+	}
+	for _, result := range results {
+		price := "n/a"
+		if result.quoted {
+			price = strconv.FormatFloat(result.quote.Price, 'f', -1, 64)
+		}
+		fire := "no"
+		switch result.action {
+		case ruleFire:
+			fire = "yes"
+		case ruleRearm:
+			fire = "re-arm"
+		case ruleIdle:
+		}
+		if _, err := fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%.2f\t%s\n", result.item.ID, result.item.Ticker, price,
+			result.item.Condition, result.item.TargetPrice, fire); err != nil {
+			return err
+		}
+	}
+	return table.Flush()
+}
+
+// ----------------------------------------------------------------
+// Run a single tick and print the evaluated watchlist items
+// ----------------------------------------------------------------
+func runOnce(ctx context.Context, moex QuoteProvider, db tickStore, mb alertPublisher, workers int, dryRun bool) error {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	results, err := runTick(ctx, moex, db, mb, nil, workers, dryRun)
+	if err != nil {
+		return err
+	}
+	return printTick(os.Stdout, results)
+}
+
+// ----------------------------------------------------------------
+// Run a tick every interval, each one bounded by the interval. The
+// tick is skipped if the previous one is still running, the ticks wait
+// for the trading session if the schedules are set.
+// ----------------------------------------------------------------
+func startMonitoring(ctx context.Context, moex QuoteProvider, db *godfather.Database, mb *godfather.MessageBus, schedules *TradingSchedules, interval_sec int, workers int) {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	slog.Info(fmt.Sprintf("Starting MOEX monitoring, check interval is %d seconds, %d workers...", interval_sec, workers))
+
+	interval := time.Duration(interval_sec) * time.Second
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var running atomic.Bool
+	var wg sync.WaitGroup
+	defer wg.Wait()
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("Monitoring stopped due to context cancellation")
 			return
 		case <-ticker.C:
-			watchlist, err := db.GetMOEXWatchlist(true)
-			if err != nil {
-				slog.Error("Failed to retrieve MOEX watchlist", "error", err)
-				dbFailures.Inc()
+			if running.Load() {
+				slog.Warn("Previous tick is still running, skipping the tick")
+				ticksSkipped.Inc()
 				continue
 			}
-			slog.Debug(fmt.Sprintf("MOEX watchlist retrieved %d active items", len(watchlist)))
-			for _, watchlistItem := range watchlist {
-				if conditionMatch(ctx, watchlistItem, moex) {
-					deactivateWatchlistItem(db, watchlistItem.Ticker)
-					sendAlert(watchlistItem, mb)
-				}
+			if schedules != nil && !waitForSession(ctx, schedules) {
+				slog.Info("Monitoring stopped due to context cancellation")
+				return
+			}
+			if !isReplay(moex) && !issClient.Available(time.Now()) {
+				slog.Debug("ISS circuit breaker is open, polling is paused")
+				continue
 			}
+
+			running.Store(true)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer running.Store(false)
+				tickCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+
+				start := time.Now()
+				if _, err := runTick(tickCtx, moex, db, mb, schedules, workers, false); err != nil {
+					slog.Error("Failed to run the tick", "error", err)
+				}
+				tickDuration.Observe(time.Since(start).Seconds())
+			}()
 		}
 	}
 }
@@ -149,10 +806,26 @@ func main() {
 	var configPath string
 	var verbose bool
 	var help bool
+	var syncAssetsOnly bool
+	var backtest bool
+	var once bool
+	var dryRun bool
+	var backtestFlags backtestOptions
 
 	flag.StringVar(&configPath, "c", "moexmon.json", "path to config file")
 	flag.BoolVar(&verbose, "v", false, "verbose logging")
 	flag.BoolVar(&help, "h", false, "show help")
+	flag.BoolVar(&syncAssetsOnly, "sync-assets", false, "synchronize the MOEX asset catalog and exit")
+	flag.BoolVar(&once, "once", false, "check the active watchlist once, print the results and exit")
+	flag.BoolVar(&dryRun, "dry-run", false, "with -once, don't update the watchlist and don't publish to NATS")
+	flag.BoolVar(&backtest, "backtest", false, "replay the historical candles through the watchlist rules and exit")
+	flag.StringVar(&backtestFlags.From, "from", "", "first day of the backtest, YYYY-MM-DD")
+	flag.StringVar(&backtestFlags.To, "to", "", "last day of the backtest, YYYY-MM-DD, today if not set")
+	flag.IntVar(&backtestFlags.Interval, "interval", defaultBacktestInterval, "candle interval of the backtest: 1, 10, 60 or 24")
+	flag.StringVar(&backtestFlags.CSVPath, "csv", "", "replay the candles of the CSV file instead of ISS")
+	flag.StringVar(&backtestFlags.Format, "format", "json", "backtest report format: json or csv")
+	flag.StringVar(&backtestFlags.Output, "o", "", "backtest report file, stdout if not set")
+	flag.IntVar(&backtestFlags.RuleID, "rule", 0, "backtest the single watchlist item")
 	flag.Parse()
 
 	if help {
@@ -161,6 +834,10 @@ func main() {
 	}
 
 	logger := godfather.SetupLogger(verbose)
+	if dryRun && !once {
+		logger.Error("The -dry-run flag requires -once")
+		os.Exit(1)
+	}
 
 	config, err := ParseConfig(configPath)
 	if err != nil {
@@ -176,6 +853,9 @@ func main() {
 	)
 	defer stop() // Release signal resources when main exits
 
+	// Configure the ISS client before the first query
+	issClient = NewISSClient(config.ISS)
+
 	// Initialize the database connection
 	db, err := godfather.InitDBFromEnv()
 	if err != nil {
@@ -188,6 +868,43 @@ func main() {
 		}
 	}()
 
+	// One-shot synchronization of the asset catalog
+	if syncAssetsOnly {
+		if err := syncAssets(ctx, db, config.Catalog.Boards); err != nil {
+			logger.Error("Failed to synchronize MOEX asset catalog", "error", err)
+			_ = db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Backtest of the watchlist rules, no alerts are published
+	if backtest {
+		if err := startBacktest(ctx, db, backtestFlags); err != nil {
+			logger.Error("Failed to backtest MOEX watchlist", "error", err)
+			_ = db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Create the market data provider
+	provider, err := newProvider(config.Provider)
+	if err != nil {
+		logger.Error("Failed to initialize market data provider", "error", err)
+		return
+	}
+
+	// One-shot evaluation of the watchlist without NATS
+	if once && dryRun {
+		if err := runOnce(ctx, provider, db, nil, config.Workers, true); err != nil {
+			logger.Error("Failed to check MOEX watchlist", "error", err)
+			_ = db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize the message bus (NATS)
 	mb, err := godfather.NewMessageBus(config.NATS.Host, config.NATS.Port, config.NATS.User)
 	if err != nil {
@@ -203,14 +920,89 @@ func main() {
 		return
 	}
 
-	// Create a new MOEX requester
-	moexRequester := newMoexRequester()
+	// Create the live quotes stream
+	quotesMaxAge := config.Quotes.MaxAgeSeconds
+	if quotesMaxAge <= 0 {
+		quotesMaxAge = defaultQuotesMaxAgeSeconds
+	}
+	quotesMaxBytes := config.Quotes.MaxBytes
+	if quotesMaxBytes <= 0 {
+		quotesMaxBytes = defaultQuotesMaxBytes
+	}
+	err = mb.CreateLimitsStream(quotesStream, quotesSubjects, time.Duration(quotesMaxAge)*time.Second, quotesMaxBytes)
+	if err != nil {
+		logger.Error("Failed to create stream for quotes", "error", err)
+		return
+	}
+
+	// One-shot check of the watchlist, e.g. from cron
+	if once {
+		if err := runOnce(ctx, provider, db, mb, config.Workers, false); err != nil {
+			logger.Error("Failed to check MOEX watchlist", "error", err)
+			mb.Close()
+			_ = db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Load the MOEX trading schedules of the engines in the watchlist,
+	// the replay follows the time of its quotes
+	var schedules *TradingSchedules
+	if !isReplay(provider) {
+		schedules, err = NewTradingSchedules(config.Schedule)
+		if err != nil {
+			logger.Error("Failed to initialize MOEX trading schedule", "error", err)
+			return
+		}
+		schedules.CheckHolidayFile(time.Now())
+		engines := []string{"stock"}
+		if watchlist, err := db.GetMOEXWatchlist(true); err != nil {
+			logger.Warn("Failed to retrieve MOEX watchlist, loading the stock market schedule only", "error", err)
+		} else {
+			engines = append(engines, watchlistEngines(watchlist)...)
+		}
+		schedules.Reload(ctx, time.Now())
+		schedules.Track(ctx, engines)
+	}
 
-	// Start the routines
+	// Start the routines, the ones writing to the database or
+	// publishing to NATS run on the leader replica only. The catalog
+	// and the corporate events are not synchronized from ISS for the
+	// replay.
 	go startMetrics(ctx, config.Prometheus.URL, config.Prometheus.Port)
-	go startMonitoring(ctx, moexRequester, db, mb, config.CheckIntervalSeconds)
+	routines := []func(context.Context){
+		func(ctx context.Context) {
+			startMonitoring(ctx, provider, db, mb, schedules, config.CheckIntervalSeconds, config.Workers)
+		},
+		func(ctx context.Context) { startHistoryMaintenance(ctx, db, config.History) },
+	}
+	if !isReplay(provider) {
+		routines = append(routines,
+			func(ctx context.Context) { startAssetSync(ctx, db, config.Catalog) },
+			func(ctx context.Context) { startEventSync(ctx, db, mb, config.Events) })
+	}
+	leadRoutines := func(ctx context.Context) {
+		var wg sync.WaitGroup
+		for _, routine := range routines {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				routine(ctx)
+			}()
+		}
+		wg.Wait()
+	}
+	elector := NewLeaderElector(advisoryLocker(db), config.Leader)
+	elected := make(chan struct{})
+	go func() {
+		defer close(elected)
+		elector.Run(ctx, leadRoutines)
+	}()
 
-	// Wait for the signal to stop
+	// Wait for the signal to stop, the leader releases the lock
+	// before the database is closed
 	<-ctx.Done()
 	slog.Info("Received termination signal, shutting down...")
+	<-elected
 }
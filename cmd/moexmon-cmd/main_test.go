@@ -1,124 +1,526 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 // ----------------------------------------------------------------
-type mockMoexQuery struct {
-	price float64
-	err   error
+type mockProvider struct {
+	mutex     sync.Mutex
+	price     float64
+	err       error
+	requested []Asset
+	candles   []Candle
+	counts    []int
+	volumes   []Volume
+	days      []int
+	boards    map[string]Asset
+	detected  []string
 }
 
-func (m *mockMoexQuery) FetchPrice(ctx context.Context, ticker string, assetClass string) (float64, error) {
+func (m *mockProvider) FetchPrice(ctx context.Context, ticker string, assetClass string) (float64, error) {
 	return m.price, m.err
 }
 
-// ----------------------------------------------------------------
-func TestConditionMatch_AboveConditionMet(t *testing.T) {
-	item := godfather.MOEXWatchlistItem{
-		Ticker:      "AAPL",
-		AssetClass:  "stock",
-		Condition:   "above",
-		TargetPrice: 100.0,
+func (m *mockProvider) FetchPrices(ctx context.Context, assets []Asset) map[string]Quote {
+	m.requested = append(m.requested, assets...)
+	result := make(map[string]Quote, len(assets))
+	for _, asset := range assets {
+		result[asset.Ticker] = Quote{Price: m.price, Err: m.err}
 	}
-	moex := &mockMoexQuery{price: 150.0}
-	result := conditionMatch(context.Background(), item, moex)
-	if !result {
-		t.Errorf("Expected true for price above target")
+	return result
+}
+
+func (m *mockProvider) FetchCandles(ctx context.Context, asset Asset, interval int, count int) ([]Candle, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counts = append(m.counts, count)
+	return m.candles, m.err
+}
+
+func (m *mockProvider) DetectBoard(ctx context.Context, ticker string) (Asset, error) {
+	m.detected = append(m.detected, ticker)
+	asset, found := m.boards[ticker]
+	if !found {
+		return Asset{}, &AssetNotFoundError{Asset: ticker}
 	}
+	return asset, nil
+}
+
+func (m *mockProvider) FetchVolumes(ctx context.Context, asset Asset, days int) ([]Volume, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.days = append(m.days, days)
+	return m.volumes, m.err
 }
 
 // ----------------------------------------------------------------
-func TestConditionMatch_AboveConditionNotMet(t *testing.T) {
-	item := godfather.MOEXWatchlistItem{
-		Ticker:      "AAPL",
-		AssetClass:  "stock",
-		Condition:   "above",
-		TargetPrice: 200.0,
+type mockWatchlistArchiver struct {
+	archived []int
+	err      error
+}
+
+func (m *mockWatchlistArchiver) ArchiveMOEXWatchlistItem(id int, archivedAt time.Time) error {
+	if m.err != nil {
+		return m.err
 	}
-	moex := &mockMoexQuery{price: 150.0}
-	result := conditionMatch(context.Background(), item, moex)
-	if result {
-		t.Errorf("Expected false for price not above target")
+	m.archived = append(m.archived, id)
+	return nil
+}
+
+// ----------------------------------------------------------------
+type mockTickStore struct {
+	mockWatchlistArchiver
+	mockPortfolioStore
+	mockQuoteStore
+	watchlist []godfather.MOEXWatchlistItem
+	boards    []string
+	active    map[int]bool
+	alerts    []godfather.MOEXAlert
+}
+
+func (m *mockTickStore) GetMOEXWatchlist(activeOnly bool) ([]godfather.MOEXWatchlistItem, error) {
+	return m.watchlist, nil
+}
+
+func (m *mockTickStore) GetMonitoredPortfolios() ([]godfather.Portfolio, error) {
+	return nil, nil
+}
+
+func (m *mockTickStore) SetMOEXAssetBoard(ticker string, engine string, market string, board string) error {
+	m.boards = append(m.boards, ticker)
+	return nil
+}
+
+func (m *mockTickStore) SetMOEXWatchlistItemActiveStatus(id int, active bool) error {
+	if m.active == nil {
+		m.active = make(map[int]bool)
 	}
+	m.active[id] = active
+	return nil
+}
+
+func (m *mockTickStore) SetMOEXWatchlistItemTriggerState(id int, armed bool, lastTriggeredAt time.Time) error {
+	return nil
+}
+
+func (m *mockTickStore) AddMOEXAlert(alert *godfather.MOEXAlert) error {
+	m.alerts = append(m.alerts, *alert)
+	return nil
 }
 
 // ----------------------------------------------------------------
-func TestConditionMatch_BelowConditionMet(t *testing.T) {
-	item := godfather.MOEXWatchlistItem{
-		Ticker:      "AAPL",
-		AssetClass:  "stock",
-		Condition:   "below",
-		TargetPrice: 200.0,
+func TestFetchSnapshot_DeduplicatesTickers(t *testing.T) {
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", AssetClass: "stock", Condition: "above", TargetPrice: 300.0},
+		{ID: 2, Ticker: "SBER", AssetClass: "stock", Condition: "below", TargetPrice: 200.0},
+		{ID: 3, Ticker: "GAZP", AssetClass: "stock", Condition: "below", TargetPrice: 150.0},
 	}
-	moex := &mockMoexQuery{price: 150.0}
-	result := conditionMatch(context.Background(), item, moex)
-	if !result {
-		t.Errorf("Expected true for price below target")
+	moex := &mockProvider{price: 250.0}
+	// The portfolio holdings are fetched in the same batch
+	extra := []Asset{{Ticker: "SBER", AssetType: "stock"}, {Ticker: "LKOH", AssetType: "stock"}}
+	snapshot := fetchSnapshot(context.Background(), moex, watchlist, extra)
+	if len(moex.requested) != 3 {
+		t.Errorf("Expected 3 assets requested, got %d", len(moex.requested))
+	}
+	if len(snapshot) != 3 || snapshot["SBER"].Price != 250.0 || snapshot["LKOH"].Price != 250.0 {
+		t.Errorf("Unexpected snapshot: %+v", snapshot)
 	}
 }
 
 // ----------------------------------------------------------------
-func TestConditionMatch_BelowConditionNotMet(t *testing.T) {
+func TestLookupQuote_Success(t *testing.T) {
 	item := godfather.MOEXWatchlistItem{
 		Ticker:      "AAPL",
 		AssetClass:  "stock",
-		Condition:   "below",
+		Condition:   "above",
 		TargetPrice: 100.0,
 	}
-	moex := &mockMoexQuery{price: 150.0}
-	result := conditionMatch(context.Background(), item, moex)
-	if result {
-		t.Errorf("Expected false for price not below target")
+	snapshot := map[string]Quote{"AAPL": {Price: 150.0}}
+	quote, ok := lookupQuote(item, snapshot)
+	if !ok {
+		t.Errorf("Expected the quote to be found")
+	}
+	if quote.Price != 150.0 {
+		t.Errorf("Expected observed price 150.0, got %v", quote.Price)
 	}
 }
 
 // ----------------------------------------------------------------
-func TestConditionMatch_UnknownCondition(t *testing.T) {
+func TestLookupQuote_AssetNotFoundError(t *testing.T) {
 	item := godfather.MOEXWatchlistItem{
 		Ticker:      "AAPL",
 		AssetClass:  "stock",
-		Condition:   "unknown",
+		Condition:   "above",
 		TargetPrice: 100.0,
 	}
-	moex := &mockMoexQuery{price: 150.0}
-	result := conditionMatch(context.Background(), item, moex)
+	snapshot := map[string]Quote{"AAPL": {Err: &AssetNotFoundError{Asset: "AAPL"}}}
+	_, result := lookupQuote(item, snapshot)
 	if result {
-		t.Errorf("Expected false for unknown condition")
+		t.Errorf("Expected false when AssetNotFoundError is returned")
 	}
 }
 
 // ----------------------------------------------------------------
-func TestConditionMatch_AssetNotFoundError(t *testing.T) {
+func TestLookupQuote_OtherError(t *testing.T) {
 	item := godfather.MOEXWatchlistItem{
 		Ticker:      "AAPL",
 		AssetClass:  "stock",
 		Condition:   "above",
 		TargetPrice: 100.0,
 	}
-	moex := &mockMoexQuery{err: &AssetNotFoundError{}}
-	result := conditionMatch(context.Background(), item, moex)
+	snapshot := map[string]Quote{"AAPL": {Err: os.ErrInvalid}}
+	_, result := lookupQuote(item, snapshot)
 	if result {
-		t.Errorf("Expected false when AssetNotFoundError is returned")
+		t.Errorf("Expected false when other error is returned")
 	}
 }
 
 // ----------------------------------------------------------------
-func TestConditionMatch_OtherError(t *testing.T) {
+func TestLookupQuote_MissingFromSnapshot(t *testing.T) {
 	item := godfather.MOEXWatchlistItem{
 		Ticker:      "AAPL",
 		AssetClass:  "stock",
 		Condition:   "above",
 		TargetPrice: 100.0,
 	}
-	moex := &mockMoexQuery{err: os.ErrInvalid}
-	result := conditionMatch(context.Background(), item, moex)
+	_, result := lookupQuote(item, map[string]Quote{})
 	if result {
-		t.Errorf("Expected false when other error is returned")
+		t.Errorf("Expected false when the ticker is missing from the snapshot")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAttachCandles_OnlyForIndicatorRules(t *testing.T) {
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", AssetClass: "stock", Condition: "above", TargetPrice: 300.0},
+		{ID: 2, Ticker: "SBER", AssetClass: "stock", Condition: "sma_cross_above",
+			Params: godfather.MOEXRuleParams{CandleInterval: 24, FastPeriod: 20, SlowPeriod: 50}},
+		{ID: 3, Ticker: "SBER", AssetClass: "stock", Condition: "rsi_below", TargetPrice: 30.0,
+			Params: godfather.MOEXRuleParams{CandleInterval: 24, Period: 14}},
+		{ID: 4, Ticker: "GAZP", AssetClass: "stock", Condition: "rsi_below", TargetPrice: 30.0,
+			Params: godfather.MOEXRuleParams{CandleInterval: 24}},
+	}
+	moex := &mockProvider{price: 250.0, candles: []Candle{{Close: 250.0}}}
+	snapshot := fetchSnapshot(context.Background(), moex, watchlist, nil)
+	attachCandles(context.Background(), moex, watchlist, snapshot, 2)
+
+	// One fetch for SBER covering the longest history, none for GAZP without parameters
+	if len(moex.counts) != 1 || moex.counts[0] != 51 {
+		t.Errorf("Expected a single fetch of 51 candles, got %v", moex.counts)
+	}
+	if len(snapshot["SBER"].Candles[24]) != 1 {
+		t.Errorf("Expected candles attached to SBER, got %+v", snapshot["SBER"].Candles)
+	}
+	if snapshot["GAZP"].Candles != nil {
+		t.Errorf("Expected no candles for GAZP, got %+v", snapshot["GAZP"].Candles)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAttachVolumes_OnlyForActivityRules(t *testing.T) {
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "GAZP", AssetClass: "stock", Condition: "volume_spike", TargetPrice: 3.0},
+		{ID: 2, Ticker: "GAZP", AssetClass: "stock", Condition: "turnover_spike", TargetPrice: 2.0,
+			Params: godfather.MOEXRuleParams{LookbackDays: 30}},
+		{ID: 3, Ticker: "SBER", AssetClass: "stock", Condition: "above", TargetPrice: 300.0},
+	}
+	moex := &mockProvider{price: 150.0, volumes: []Volume{{Volume: 1000}}}
+	snapshot := fetchSnapshot(context.Background(), moex, watchlist, nil)
+	attachVolumes(context.Background(), moex, watchlist, snapshot, 2)
+
+	// One fetch for GAZP covering the longest lookback, none for SBER
+	if len(moex.days) != 1 || moex.days[0] != 30 {
+		t.Errorf("Expected a single fetch of 30 days, got %v", moex.days)
+	}
+	if len(snapshot["GAZP"].Volumes) != 1 {
+		t.Errorf("Expected volumes attached to GAZP, got %+v", snapshot["GAZP"].Volumes)
+	}
+	if snapshot["SBER"].Volumes != nil {
+		t.Errorf("Expected no volumes for SBER, got %+v", snapshot["SBER"].Volumes)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDetectBoards(t *testing.T) {
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SU26238RMFS4", AssetClass: "bond", Condition: "below", TargetPrice: 60.0},
+		{ID: 2, Ticker: "SU26238RMFS4", AssetClass: "bond", Condition: "above", TargetPrice: 70.0},
+		{ID: 3, Ticker: "SBER", AssetClass: "stock", Engine: "stock", Market: "shares", Board: "TQBR"},
+		{ID: 4, Ticker: "NOPE", AssetClass: "stock"},
+		{ID: 5, Ticker: "NOPE", AssetClass: "stock"},
+	}
+	moex := &mockProvider{boards: map[string]Asset{
+		"SU26238RMFS4": {Ticker: "SU26238RMFS4", Engine: "stock", Market: "bonds", Board: "TQOB"},
+	}}
+	detected := detectBoards(context.Background(), moex, watchlist)
+
+	// Each unknown ticker is detected once, the failed ones are not retried within the tick
+	if len(moex.detected) != 2 || moex.detected[0] != "SU26238RMFS4" || moex.detected[1] != "NOPE" {
+		t.Errorf("Unexpected detection requests: %v", moex.detected)
+	}
+	if len(detected) != 1 || detected["SU26238RMFS4"].Board != "TQOB" {
+		t.Errorf("Unexpected detected boards: %+v", detected)
+	}
+	if watchlist[0].Board != "TQOB" || watchlist[1].Board != "TQOB" || watchlist[1].Market != "bonds" {
+		t.Errorf("Expected the board to be set for all the items, got %+v", watchlist[:2])
+	}
+	if watchlist[3].Board != "" {
+		t.Errorf("Expected no board for the unknown asset, got %+v", watchlist[3])
+	}
+	if asset := assetOf(watchlist[0]); asset.Engine != "stock" || asset.AssetType != "bond" {
+		t.Errorf("Unexpected asset: %+v", asset)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestRunPool_BoundsConcurrency(t *testing.T) {
+	var active, peak, done atomic.Int32
+	items := make([]int, 20)
+	skipped := runPool(context.Background(), 3, items, func(item int) {
+		current := active.Add(1)
+		for {
+			previous := peak.Load()
+			if current <= previous || peak.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		active.Add(-1)
+		done.Add(1)
+	})
+	if skipped != 0 || done.Load() != 20 {
+		t.Errorf("expected all 20 items processed, got %d with %d skipped", done.Load(), skipped)
+	}
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent jobs, got %d", peak.Load())
+	}
+}
+
+// ----------------------------------------------------------------
+func TestRunPool_Deadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var done atomic.Int32
+	skipped := runPool(ctx, 1, []int{1, 2, 3, 4, 5}, func(item int) {
+		done.Add(1)
+		if item == 2 {
+			cancel()
+		}
+	})
+	if done.Load() != 2 || skipped != 3 {
+		t.Errorf("expected 2 items processed and 3 skipped, got %d and %d", done.Load(), skipped)
+	}
+
+	// Nothing is started once the deadline is exceeded
+	if skipped := runPool(ctx, 4, []int{1, 2}, func(item int) { done.Add(1) }); skipped != 2 || done.Load() != 2 {
+		t.Errorf("expected all items skipped, got %d skipped", skipped)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestPairRules_SecondLeg(t *testing.T) {
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", AssetClass: "stock", Engine: "stock", Market: "shares", Board: "TQBR", Condition: "above"},
+		{ID: 2, Ticker: "SBER", AssetClass: "stock", Engine: "stock", Market: "shares", Board: "TQBR", Condition: "spread_above",
+			TargetPrice: 5, Pair: godfather.MOEXPairLeg{Ticker: "SBERP", AssetClass: "stock"}},
+	}
+	moex := &mockProvider{price: 310, boards: map[string]Asset{
+		"SBERP": {Ticker: "SBERP", Engine: "stock", Market: "shares", Board: "TQBR"},
+	}}
+
+	// The second leg's board is detected and its price fetched in the same batch
+	detected := detectBoards(context.Background(), moex, watchlist)
+	if len(moex.detected) != 1 || detected["SBERP"].Board != "TQBR" || watchlist[1].Pair.Board != "TQBR" {
+		t.Errorf("Unexpected detection of the second leg: %v, %+v", moex.detected, watchlist[1].Pair)
+	}
+	snapshot := fetchSnapshot(context.Background(), moex, watchlist, nil)
+	if len(moex.requested) != 2 || moex.requested[1].Ticker != "SBERP" || moex.requested[1].Board != "TQBR" {
+		t.Errorf("Unexpected assets requested: %+v", moex.requested)
+	}
+
+	snapshot["SBERP"] = Quote{Price: 304}
+	quote, ok := lookupQuote(watchlist[1], snapshot)
+	if !ok || quote.Second == nil || quote.Second.Price != 304 {
+		t.Fatalf("Expected the second leg attached, got %+v", quote)
+	}
+	if quote, _ := lookupQuote(watchlist[0], snapshot); quote.Second != nil {
+		t.Errorf("Unexpected second leg for the single asset rule: %+v", quote)
+	}
+	if text := describeAlert(watchlist[1], quote); text != "The spread SBER - SBERP is above 5.00 (SBER 310.00, SBERP 304.00)" {
+		t.Errorf("Unexpected alert text: %s", text)
+	}
+
+	// The rule is not evaluated without the second leg
+	snapshot["SBERP"] = Quote{Err: &AssetNotFoundError{Asset: "SBERP"}}
+	if _, ok := lookupQuote(watchlist[1], snapshot); ok {
+		t.Error("Expected false without the second leg's price")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeAlert_BondReminder(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{Ticker: "RU000A1038V6", Condition: "offer_within", TargetPrice: 14}
+	quote := Quote{Price: 98.5, Bond: &MoexBond{OfferDate: time.Date(2026, 6, 1, 0, 0, 0, 0, moscowTime)}}
+	if text := describeAlert(item, quote); text != "The offer of RU000A1038V6 is within 14 days (2026-06-01)" {
+		t.Errorf("Unexpected alert text: %s", text)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestArchiveExpired(t *testing.T) {
+	now := time.Date(2025, 3, 7, 10, 0, 0, 0, moscowTime)
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", Condition: "above", TargetPrice: 300, NotificationID: 5},
+		{ID: 2, Ticker: "GAZP", Condition: "below", TargetPrice: 150, NotificationID: 6,
+			ValidUntil: time.Date(2025, 3, 7, 0, 0, 0, 0, moscowTime)},
+		{ID: 3, Ticker: "LKOH", Condition: "above", TargetPrice: 7000, ValidUntil: now.Add(time.Hour)},
+	}
+	store := &mockWatchlistArchiver{}
+	publisher := &mockQuotePublisher{}
+
+	valid := archiveExpired(store, publisher, watchlist, now)
+	if len(valid) != 2 || valid[0].ID != 1 || valid[1].ID != 3 {
+		t.Errorf("Unexpected valid items: %+v", valid)
+	}
+	if len(store.archived) != 1 || store.archived[0] != 2 {
+		t.Errorf("Expected the expired item archived, got %v", store.archived)
+	}
+	var alert godfather.AlertMessage
+	if err := msgpack.Unmarshal(publisher.messages["alerts.MOEX"], &alert); err != nil {
+		t.Fatalf("Failed to unmarshal alert: %v", err)
+	}
+	if alert.NotificationId != 6 || alert.WatchlistId != 2 ||
+		alert.Subject != "The rule expired on 2025-03-07 00:00 MSK and was archived: The price for GAZP is below 150.00" {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+
+	// The item is not archived until the owner is notified
+	store = &mockWatchlistArchiver{}
+	publisher = &mockQuotePublisher{err: errors.New("no connection")}
+	if valid := archiveExpired(store, publisher, watchlist, now); len(valid) != 2 {
+		t.Errorf("Unexpected valid items: %+v", valid)
+	}
+	if len(store.archived) != 0 {
+		t.Errorf("Unexpected archived items: %v", store.archived)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestProcessWatchlistItem_DryRun(t *testing.T) {
+	snapshot := map[string]Quote{"SBER": {Price: 310}}
+	now := time.Now()
+
+	// The database and the message bus are not touched by the dry run
+	item := godfather.MOEXWatchlistItem{ID: 1, Ticker: "SBER", Condition: "above", TargetPrice: 300, Mode: godfather.MOEXRuleOneShot}
+	result := processWatchlistItem(nil, nil, item, snapshot, now, true)
+	if !result.quoted || result.quote.Price != 310 || result.action != ruleFire {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	item = godfather.MOEXWatchlistItem{ID: 2, Ticker: "GAZP", Condition: "below", TargetPrice: 150}
+	if result := processWatchlistItem(nil, nil, item, snapshot, now, true); result.quoted || result.action != ruleIdle {
+		t.Errorf("Unexpected result without the price: %+v", result)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestPrintTick(t *testing.T) {
+	results := []tickResult{
+		{item: godfather.MOEXWatchlistItem{ID: 1, Ticker: "SBER", Condition: "above", TargetPrice: 300},
+			quote: Quote{Price: 310.5}, quoted: true, action: ruleFire},
+		{item: godfather.MOEXWatchlistItem{ID: 12, Ticker: "GAZP", Condition: "below", TargetPrice: 150}},
+		{item: godfather.MOEXWatchlistItem{ID: 3, Ticker: "LKOH", Condition: "rsi_above", TargetPrice: 70},
+			quote: Quote{Price: 7000}, quoted: true, action: ruleRearm},
+	}
+	var buffer bytes.Buffer
+	if err := printTick(&buffer, results); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "ID  TICKER  PRICE  CONDITION  TARGET  WOULD FIRE\n" +
+		"1   SBER    310.5  above      300.00  yes\n" +
+		"12  GAZP    n/a    below      150.00  no\n" +
+		"3   LKOH    7000   rsi_above  70.00   re-arm\n"
+	if buffer.String() != expected {
+		t.Errorf("Unexpected table:\n%s", buffer.String())
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingItems(t *testing.T) {
+	mockISS(&mockRoundTripper{err: io.ErrUnexpectedEOF})
+	schedules, err := NewTradingSchedules(ScheduleConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", AssetClass: "stock"},
+		{ID: 2, Ticker: "USD000UTSTOM", AssetClass: "currency"},
+		{ID: 3, Ticker: "CNYRUB_TOM", AssetClass: "currency", Pair: godfather.MOEXPairLeg{Ticker: "SBER", AssetClass: "stock"}},
+	}
+	engines := watchlistEngines(watchlist)
+	if strings.Join(engines, ",") != "stock,currency" {
+		t.Errorf("unexpected engines: %v", engines)
+	}
+	schedules.Track(context.Background(), engines)
+
+	// Only the currency market is open at 08:00
+	items := tradingItems(schedules, watchlist, moscow("2025-03-03", "08:00"))
+	if len(items) != 1 || items[0].ID != 2 {
+		t.Errorf("expected the currency item only, got %+v", items)
+	}
+	if items := tradingItems(schedules, watchlist, moscow("2025-03-03", "12:00")); len(items) != 3 {
+		t.Errorf("expected all the items, got %+v", items)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestRunTick_Replay(t *testing.T) {
+	path := writeReplayFile(t, t.TempDir(), "quotes.jsonl", `{"ticker":"SBER","time":"2025-03-03 10:00:00","price":300}
+`)
+	provider, err := newProvider(ProviderConfig{Source: "replay", Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	watchlist := []godfather.MOEXWatchlistItem{{ID: 1, Ticker: "SBER", AssetClass: "stock", Engine: "stock", Market: "shares",
+		Board: "TQBR", Condition: "above", TargetPrice: 250, Mode: godfather.MOEXRuleOneShot}}
+
+	// The live quotes are stored and published
+	store := &mockTickStore{watchlist: watchlist}
+	publisher := &mockQuotePublisher{}
+	if _, err := runTick(context.Background(), &mockProvider{price: 300}, store, publisher, nil, 1, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.quotes) != 1 || publisher.messages["quotes.MOEX.TQBR.SBER"] == nil {
+		t.Errorf("expected the live quote stored and published, got %v and %v", store.quotes, publisher.messages)
+	}
+
+	// The replayed quotes only fire the rules
+	store = &mockTickStore{watchlist: watchlist}
+	publisher = &mockQuotePublisher{}
+	results, err := runTick(context.Background(), provider, store, publisher, nil, 1, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.quotes) != 0 || len(store.partitions) != 0 {
+		t.Errorf("expected no replayed quotes stored, got %v", store.quotes)
+	}
+	for subject := range publisher.messages {
+		if strings.HasPrefix(subject, "quotes.") {
+			t.Errorf("expected no replayed quotes published, got %s", subject)
+		}
+	}
+	if len(results) != 1 || results[0].action != ruleFire || publisher.messages["alerts.MOEX"] == nil || len(store.alerts) != 1 {
+		t.Errorf("expected the rule to fire on the replayed quote, got %+v", results)
 	}
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type moexPrices struct {
@@ -14,13 +17,168 @@ type moexPrices struct {
 		Columns []string `json:"columns"`
 		Data    [][]any  `json:"data"`
 	} `json:"marketdata"`
+	// Only requested for the bonds
+	MarketdataYields struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"marketdata_yields"`
+	Securities struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"securities"`
+}
+
+// ----------------------------------------------------------------
+// Asset to be queried in a batch. The ISS engine, market and board
+// are empty if not detected yet, the asset type defaults are used
+// then.
+// ----------------------------------------------------------------
+type Asset struct {
+	Ticker    string
+	AssetType string
+	Engine    string
+	Market    string
+	Board     string
+}
+
+// ----------------------------------------------------------------
+// Result of the batch query for a single ticker. Session statistics
+// not reported by ISS (e.g. before the first trade) are NaN.
+// ----------------------------------------------------------------
+type Quote struct {
+	Price     float64 // LAST
+	Open      float64 // OPEN
+	High      float64 // HIGH
+	Low       float64 // LOW
+	WAPrice   float64 // WAPRICE, volume weighted average price
+	ChangePct float64 // LASTTOPREVPRICE, change to the previous close in %
+	VolToday  float64 // VOLTODAY, number of securities traded today
+	ValToday  float64 // VALTODAY, turnover today in the board's currency
+	Err       error
+
+	// Candles history by interval, only fetched for the indicator rules
+	Candles map[int][]Candle
+	// Daily trading volumes of the previous sessions, only fetched for the volume rules
+	Volumes []Volume
+	// Quote of the second leg, only set for the pair rules
+	Second *Quote
+	// Bond analytics, only set for the bonds. The price of the bond is
+	// in % of the face value.
+	Bond *MoexBond
+	// Futures data, only set for the futures
+	Futures *MoexFutures
+}
+
+// ----------------------------------------------------------------
+// Bond analytics from the ISS marketdata_yields and securities
+// blocks, the unknown values are NaN and the unknown dates are zero
+// ----------------------------------------------------------------
+type MoexBond struct {
+	Yield         float64   // EFFECTIVEYIELD, yield to maturity (or to the offer) in %
+	Duration      float64   // DURATION, days
+	AccruedInt    float64   // ACCRUEDINT, accrued interest in the face currency
+	FaceValue     float64   // FACEVALUE
+	CouponPercent float64   // COUPONPERCENT, annual coupon rate in % of the face value
+	MaturityDate  time.Time // MATDATE
+	OfferDate     time.Time // nearest of OFFERDATE (put) and CALLOPTIONDATE
+}
+
+// ----------------------------------------------------------------
+// Candle of the ISS candles history
+// ----------------------------------------------------------------
+type Candle struct {
+	Begin  time.Time
+	Open   float64
+	Close  float64
+	High   float64
+	Low    float64
+	Volume float64
+	Value  float64 // turnover in the board's currency, NaN if not reported
+}
+
+// ----------------------------------------------------------------
+// Trading volume of a single session from the ISS history
+// ----------------------------------------------------------------
+type Volume struct {
+	Date   time.Time
+	Volume float64 // VOLUME, number of securities traded
+	Value  float64 // VALUE, turnover in the board's currency
+}
+
+type moexHistory struct {
+	History struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"history"`
+}
+
+type moexSecurityBoards struct {
+	Boards struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"boards"`
+}
+
+type moexCandles struct {
+	Candles struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"candles"`
 }
 
-type MoexQuery interface {
+// ----------------------------------------------------------------
+// Market data provider, see providerFactories
+// ----------------------------------------------------------------
+type QuoteProvider interface {
 	FetchPrice(ctx context.Context, asset string, assetType string) (float64, error)
+	FetchPrices(ctx context.Context, assets []Asset) map[string]Quote
+	FetchCandles(ctx context.Context, asset Asset, interval int, count int) ([]Candle, error)
+	FetchVolumes(ctx context.Context, asset Asset, days int) ([]Volume, error)
+	DetectBoard(ctx context.Context, ticker string) (Asset, error)
 }
 
-type MoexRequester struct{}
+// Moscow time used by ISS, no daylight saving time since 2014
+var moscowTime = time.FixedZone("MSK", 3*60*60)
+
+// Maximum number of securities requested from ISS in a single query
+const moexBatchSize = 50
+
+// Maximum number of candles pages requested from ISS in a single fetch
+const moexMaxCandlePages = 10
+
+// Number of rows returned by ISS in a single history page
+const moexHistoryPageSize = 100
+
+// ----------------------------------------------------------------
+// Duration of the ISS candle interval, zero if it is not supported
+// ----------------------------------------------------------------
+func candleDuration(interval int) time.Duration {
+	switch interval {
+	case 1:
+		return time.Minute
+	case 10:
+		return 10 * time.Minute
+	case 60:
+		return time.Hour
+	case 24:
+		return 24 * time.Hour
+	case 7:
+		return 7 * 24 * time.Hour
+	case 31:
+		return 31 * 24 * time.Hour
+	case 4:
+		return 92 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+type MoexRequester struct {
+	mutex        sync.Mutex
+	contracts    []moexContract    // FORTS contracts listed on contractsDay
+	contractsDay time.Time         // zero until the contracts are fetched
+	fronts       map[string]string // front contracts of the continuous codes to log the rolls
+}
 
 // ----------------------------------------------------------------
 func parseJSON[T any](s []byte) (T, error) {
@@ -32,29 +190,21 @@ func parseJSON[T any](s []byte) (T, error) {
 	return r, nil
 }
 
+// ----------------------------------------------------------------
+// ISS client shared by all the queries, configured on startup
+// ----------------------------------------------------------------
+var issClient = NewISSClient(ISSConfig{})
+
 // ----------------------------------------------------------------
 func query[T any](ctx context.Context, url string) (T, error) {
 	var result T
 
 	slog.Debug(fmt.Sprintf("Query MOEX: %s", url))
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		slog.Error(fmt.Sprintf("failed to create request: %s", err.Error()))
-		return result, err
-	}
-
-	res, err := http.DefaultClient.Do(req)
+	body, err := issClient.Get(ctx, url)
 	if err != nil {
 		slog.Error(fmt.Sprintf("failed to query MOEX: %s", err.Error()))
 		return result, err
 	}
-	defer res.Body.Close() // nolint:errcheck,gosec
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		slog.Error(fmt.Sprintf("failed to read response from MOEX: %s", err.Error()))
-		return result, err
-	}
 	return parseJSON[T](body)
 }
 
@@ -68,24 +218,67 @@ func (e *AssetNotFoundError) Error() string {
 }
 
 // ----------------------------------------------------------------
-func (requester *MoexRequester) FetchPrice(ctx context.Context, asset string, assetType string) (float64, error) {
-	var market, mode string
-	switch assetType {
+type moexBoard struct {
+	engine string
+	market string
+	board  string
+}
+
+// ----------------------------------------------------------------
+// Board the asset is traded on: the detected one, or the default
+// board of the asset type
+// ----------------------------------------------------------------
+func resolveBoard(asset Asset) (moexBoard, error) {
+	if asset.Engine != "" && asset.Market != "" && asset.Board != "" {
+		return moexBoard{engine: asset.Engine, market: asset.Market, board: asset.Board}, nil
+	}
+	switch asset.AssetType {
 	case "stock":
-		market = "shares"
-		mode = "TQBR"
+		return moexBoard{engine: "stock", market: "shares", board: "TQBR"}, nil
 	case "bond":
-		market = "bonds"
-		mode = "TQCB"
+		return moexBoard{engine: "stock", market: "bonds", board: "TQCB"}, nil
 	case "currency":
-		market = "currency"
-		mode = "CETS"
+		return moexBoard{engine: "currency", market: "selt", board: "CETS"}, nil
+	case "futures":
+		return fortsBoard, nil
 	default:
-		return 0, fmt.Errorf("unsupported asset type: %s", assetType)
+		return moexBoard{}, fmt.Errorf("unsupported asset type: %s", asset.AssetType)
+	}
+}
+
+// ----------------------------------------------------------------
+func columnIndex(columns []string, name string) int {
+	for i, column := range columns {
+		if column == name {
+			return i
+		}
 	}
+	return -1
+}
 
-	url := fmt.Sprintf("https://iss.moex.com/iss/engines/stock/markets/%s/boards/%s/securities/%s.json?iss.meta=off&iss.only=marketdata&marketdata.columns=LAST",
-		market, mode, asset)
+// ----------------------------------------------------------------
+// Get the numeric value from the row, NaN if it is missing or null
+// ----------------------------------------------------------------
+func optionalFloat(row []any, index int) float64 {
+	if index < 0 || index >= len(row) {
+		return math.NaN()
+	}
+	value, isOk := row[index].(float64)
+	if !isOk {
+		return math.NaN()
+	}
+	return value
+}
+
+// ----------------------------------------------------------------
+func (requester *MoexRequester) FetchPrice(ctx context.Context, asset string, assetType string) (float64, error) {
+	board, security, err := requester.resolveSecurity(ctx, Asset{Ticker: asset, AssetType: assetType})
+	if err != nil {
+		return 0, err
+	}
+
+	url := issClient.URL("/iss/engines/%s/markets/%s/boards/%s/securities/%s.json?iss.meta=off&iss.only=marketdata&marketdata.columns=LAST",
+		board.engine, board.market, board.board, security)
 	prices, err := query[moexPrices](ctx, url)
 	if err != nil {
 		return 0, err
@@ -104,6 +297,468 @@ func (requester *MoexRequester) FetchPrice(ctx context.Context, asset string, as
 }
 
 // ----------------------------------------------------------------
-func newMoexRequester() MoexQuery {
+// Fetch the prices of several assets, one ISS query per board. The
+// quotes of the futures codes are the ones of the traded contracts.
+// ----------------------------------------------------------------
+func (requester *MoexRequester) FetchPrices(ctx context.Context, assets []Asset) map[string]Quote {
+	results := make(map[string]Quote, len(assets))
+	groups := make(map[moexBoard][]string)
+	// Tickers quoted by the ISS security, several futures codes may
+	// resolve to the same contract
+	names := make(map[string][]string)
+	for _, asset := range assets {
+		if _, seen := results[asset.Ticker]; seen {
+			continue
+		}
+		board, security, err := requester.resolveSecurity(ctx, asset)
+		if err != nil {
+			results[asset.Ticker] = Quote{Err: err}
+			continue
+		}
+		// Placeholder until the price is fetched
+		results[asset.Ticker] = Quote{Err: &AssetNotFoundError{Asset: asset.Ticker}}
+		if _, seen := names[security]; !seen {
+			groups[board] = append(groups[board], security)
+		}
+		names[security] = append(names[security], asset.Ticker)
+	}
+
+	for board, securities := range groups {
+		for start := 0; start < len(securities); start += moexBatchSize {
+			end := min(start+moexBatchSize, len(securities))
+			requester.fetchBoardPrices(ctx, board, securities[start:end], names, results)
+		}
+	}
+	return results
+}
+
+// ----------------------------------------------------------------
+func (requester *MoexRequester) fetchBoardPrices(ctx context.Context, board moexBoard, securities []string, names map[string][]string, results map[string]Quote) {
+	set := func(security string, quote Quote) {
+		for _, ticker := range names[security] {
+			results[ticker] = quote
+		}
+	}
+	blocks := "marketdata"
+	columns := "SECID,LAST,OPEN,HIGH,LOW,WAPRICE,LASTTOPREVPRICE,VOLTODAY,VALTODAY"
+	switch board.market {
+	case "bonds":
+		blocks = "marketdata,marketdata_yields,securities&marketdata_yields.columns=SECID,EFFECTIVEYIELD,DURATION" +
+			"&securities.columns=SECID,FACEVALUE,ACCRUEDINT,COUPONPERCENT,MATDATE,OFFERDATE,CALLOPTIONDATE"
+	case fortsBoard.market:
+		blocks = "marketdata,securities&securities.columns=SECID,PREVOPENPOSITION,LASTTRADEDATE"
+		columns += ",OPENPOSITION"
+	}
+	url := issClient.URL("/iss/engines/%s/markets/%s/boards/%s/securities.json?iss.meta=off&iss.only=%s&marketdata.columns=%s&securities=%s",
+		board.engine, board.market, board.board, blocks, columns, strings.Join(securities, ","))
+	prices, err := query[moexPrices](ctx, url)
+	if err != nil {
+		for _, security := range securities {
+			set(security, Quote{Err: err})
+		}
+		return
+	}
+
+	secidIndex := columnIndex(prices.Marketdata.Columns, "SECID")
+	lastIndex := columnIndex(prices.Marketdata.Columns, "LAST")
+	if secidIndex < 0 || lastIndex < 0 {
+		err := fmt.Errorf("unexpected marketdata columns for board %s: %v", board.board, prices.Marketdata.Columns)
+		for _, security := range securities {
+			set(security, Quote{Err: err})
+		}
+		return
+	}
+
+	for _, row := range prices.Marketdata.Data {
+		if len(row) <= secidIndex || len(row) <= lastIndex {
+			continue
+		}
+		security, isOk := row[secidIndex].(string)
+		if !isOk {
+			continue
+		}
+		if _, requested := names[security]; !requested {
+			continue
+		}
+		price, isOk := row[lastIndex].(float64)
+		if !isOk {
+			set(security, Quote{Err: fmt.Errorf("invalid price data type for asset %s", security)})
+			continue
+		}
+		set(security, Quote{
+			Price:     price,
+			Open:      optionalFloat(row, columnIndex(prices.Marketdata.Columns, "OPEN")),
+			High:      optionalFloat(row, columnIndex(prices.Marketdata.Columns, "HIGH")),
+			Low:       optionalFloat(row, columnIndex(prices.Marketdata.Columns, "LOW")),
+			WAPrice:   optionalFloat(row, columnIndex(prices.Marketdata.Columns, "WAPRICE")),
+			ChangePct: optionalFloat(row, columnIndex(prices.Marketdata.Columns, "LASTTOPREVPRICE")),
+			VolToday:  optionalFloat(row, columnIndex(prices.Marketdata.Columns, "VOLTODAY")),
+			ValToday:  optionalFloat(row, columnIndex(prices.Marketdata.Columns, "VALTODAY")),
+		})
+	}
+	switch board.market {
+	case "bonds":
+		attachBonds(prices, names, results)
+	case fortsBoard.market:
+		attachFutures(prices, names, results)
+	}
+}
+
+// ----------------------------------------------------------------
+// Get the date from the row, zero if it is missing or not set
+// ("0000-00-00" in ISS)
+// ----------------------------------------------------------------
+func optionalDate(row []any, index int) time.Time {
+	date, err := time.ParseInLocation(time.DateOnly, optionalString(row, index), moscowTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// ----------------------------------------------------------------
+// Attach the bond analytics to the fetched quotes
+// ----------------------------------------------------------------
+func attachBonds(prices moexPrices, names map[string][]string, results map[string]Quote) {
+	bonds := make(map[string]*MoexBond)
+	bondOf := func(security string) *MoexBond {
+		if !quoted(security, names, results) {
+			return nil
+		}
+		if bonds[security] == nil {
+			bonds[security] = &MoexBond{Yield: math.NaN(), Duration: math.NaN(), AccruedInt: math.NaN(),
+				FaceValue: math.NaN(), CouponPercent: math.NaN()}
+		}
+		return bonds[security]
+	}
+
+	columns := prices.MarketdataYields.Columns
+	secidIndex := columnIndex(columns, "SECID")
+	for _, row := range prices.MarketdataYields.Data {
+		if bond := bondOf(optionalString(row, secidIndex)); bond != nil {
+			bond.Yield = optionalFloat(row, columnIndex(columns, "EFFECTIVEYIELD"))
+			bond.Duration = optionalFloat(row, columnIndex(columns, "DURATION"))
+		}
+	}
+
+	columns = prices.Securities.Columns
+	secidIndex = columnIndex(columns, "SECID")
+	for _, row := range prices.Securities.Data {
+		bond := bondOf(optionalString(row, secidIndex))
+		if bond == nil {
+			continue
+		}
+		bond.FaceValue = optionalFloat(row, columnIndex(columns, "FACEVALUE"))
+		bond.AccruedInt = optionalFloat(row, columnIndex(columns, "ACCRUEDINT"))
+		bond.CouponPercent = optionalFloat(row, columnIndex(columns, "COUPONPERCENT"))
+		bond.MaturityDate = optionalDate(row, columnIndex(columns, "MATDATE"))
+		bond.OfferDate = optionalDate(row, columnIndex(columns, "OFFERDATE"))
+		if call := optionalDate(row, columnIndex(columns, "CALLOPTIONDATE")); !call.IsZero() &&
+			(bond.OfferDate.IsZero() || call.Before(bond.OfferDate)) {
+			bond.OfferDate = call
+		}
+	}
+
+	for security, bond := range bonds {
+		for _, ticker := range names[security] {
+			quote := results[ticker]
+			quote.Bond = bond
+			results[ticker] = quote
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Check whether the price of the ISS security was fetched
+// ----------------------------------------------------------------
+func quoted(security string, names map[string][]string, results map[string]Quote) bool {
+	tickers := names[security]
+	return len(tickers) > 0 && results[tickers[0]].Err == nil
+}
+
+// ----------------------------------------------------------------
+// Attach the open interest and the expiration to the fetched quotes
+// of the futures
+// ----------------------------------------------------------------
+func attachFutures(prices moexPrices, names map[string][]string, results map[string]Quote) {
+	futures := make(map[string]*MoexFutures)
+	columns := prices.Marketdata.Columns
+	secidIndex := columnIndex(columns, "SECID")
+	for _, row := range prices.Marketdata.Data {
+		security := optionalString(row, secidIndex)
+		if !quoted(security, names, results) {
+			continue
+		}
+		futures[security] = &MoexFutures{
+			Contract:         security,
+			OpenInterest:     optionalFloat(row, columnIndex(columns, "OPENPOSITION")),
+			PrevOpenInterest: math.NaN(),
+		}
+	}
+
+	columns = prices.Securities.Columns
+	secidIndex = columnIndex(columns, "SECID")
+	for _, row := range prices.Securities.Data {
+		contract := futures[optionalString(row, secidIndex)]
+		if contract == nil {
+			continue
+		}
+		contract.PrevOpenInterest = optionalFloat(row, columnIndex(columns, "PREVOPENPOSITION"))
+		contract.LastTradeDate = optionalDate(row, columnIndex(columns, "LASTTRADEDATE"))
+	}
+
+	for security, contract := range futures {
+		for _, ticker := range names[security] {
+			quote := results[ticker]
+			quote.Futures = contract
+			results[ticker] = quote
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Fetch at most count latest candles in chronological order
+// ----------------------------------------------------------------
+func (requester *MoexRequester) FetchCandles(ctx context.Context, asset Asset, interval int, count int) ([]Candle, error) {
+	duration := candleDuration(interval)
+	if duration == 0 {
+		return nil, fmt.Errorf("unsupported candle interval: %d", interval)
+	}
+	board, security, err := requester.resolveSecurity(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Twice the requested history plus a week covers weekends and holidays
+	from := time.Now().Add(-2*time.Duration(count)*duration - 7*24*time.Hour)
+	candles := make([]Candle, 0, count)
+	for page := 0; page < moexMaxCandlePages && len(candles) < count; page++ {
+		url := issClient.URL("/iss/engines/%s/markets/%s/boards/%s/securities/%s/candles.json?iss.meta=off&iss.reverse=true&interval=%d&from=%s&start=%d",
+			board.engine, board.market, board.board, security, interval, from.Format(time.DateOnly), len(candles))
+		result, err := query[moexCandles](ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Candles.Data) == 0 {
+			break
+		}
+		parsed, err := parseCandles(result.Candles.Columns, result.Candles.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candles for asset %s: %w", asset.Ticker, err)
+		}
+		candles = append(candles, parsed...)
+	}
+	if len(candles) == 0 {
+		return nil, &AssetNotFoundError{Asset: asset.Ticker}
+	}
+	if len(candles) > count {
+		candles = candles[:count]
+	}
+	// Newest first as requested by iss.reverse
+	slices.Reverse(candles)
+	return candles, nil
+}
+
+// ----------------------------------------------------------------
+// Fetch the candles beginning within [from, till) in chronological
+// order. The continuous futures are resolved to the current front
+// contract.
+// ----------------------------------------------------------------
+func (requester *MoexRequester) FetchCandleRange(ctx context.Context, asset Asset, interval int, from time.Time, till time.Time) ([]Candle, error) {
+	if candleDuration(interval) == 0 {
+		return nil, fmt.Errorf("unsupported candle interval: %d", interval)
+	}
+	board, security, err := requester.resolveSecurity(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []Candle
+	for {
+		url := issClient.URL("/iss/engines/%s/markets/%s/boards/%s/securities/%s/candles.json?iss.meta=off&interval=%d&from=%s&till=%s&start=%d",
+			board.engine, board.market, board.board, security, interval, from.In(moscowTime).Format(time.DateOnly),
+			till.In(moscowTime).Format(time.DateOnly), len(candles))
+		result, err := query[moexCandles](ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Candles.Data) == 0 {
+			break
+		}
+		parsed, err := parseCandles(result.Candles.Columns, result.Candles.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candles for asset %s: %w", asset.Ticker, err)
+		}
+		candles = append(candles, parsed...)
+	}
+	// The ISS range is in days, cut it to the requested time
+	candles = slices.DeleteFunc(candles, func(candle Candle) bool {
+		return candle.Begin.Before(from) || !candle.Begin.Before(till)
+	})
+	if len(candles) == 0 {
+		return nil, &AssetNotFoundError{Asset: asset.Ticker}
+	}
+	return candles, nil
+}
+
+// ----------------------------------------------------------------
+func parseCandles(columns []string, data [][]any) ([]Candle, error) {
+	openIndex := columnIndex(columns, "open")
+	closeIndex := columnIndex(columns, "close")
+	highIndex := columnIndex(columns, "high")
+	lowIndex := columnIndex(columns, "low")
+	volumeIndex := columnIndex(columns, "volume")
+	valueIndex := columnIndex(columns, "value")
+	beginIndex := columnIndex(columns, "begin")
+	if closeIndex < 0 || beginIndex < 0 {
+		return nil, fmt.Errorf("unexpected candles columns: %v", columns)
+	}
+
+	candles := make([]Candle, 0, len(data))
+	for _, row := range data {
+		begin, isOk := row[beginIndex].(string)
+		if !isOk {
+			return nil, fmt.Errorf("invalid candle begin: %v", row[beginIndex])
+		}
+		beginTime, err := time.ParseInLocation(time.DateTime, begin, moscowTime)
+		if err != nil {
+			return nil, err
+		}
+		closePrice, isOk := row[closeIndex].(float64)
+		if !isOk {
+			return nil, fmt.Errorf("invalid candle close price: %v", row[closeIndex])
+		}
+		candles = append(candles, Candle{
+			Begin:  beginTime,
+			Open:   optionalFloat(row, openIndex),
+			Close:  closePrice,
+			High:   optionalFloat(row, highIndex),
+			Low:    optionalFloat(row, lowIndex),
+			Volume: optionalFloat(row, volumeIndex),
+			Value:  optionalFloat(row, valueIndex),
+		})
+	}
+	return candles, nil
+}
+
+// ----------------------------------------------------------------
+// Fetch the volumes of at most days latest sessions before today
+// in chronological order
+// ----------------------------------------------------------------
+func (requester *MoexRequester) FetchVolumes(ctx context.Context, asset Asset, days int) ([]Volume, error) {
+	board, security, err := requester.resolveSecurity(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(moscowTime)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, moscowTime)
+	// Twice the requested history plus two weeks covers weekends and holidays
+	from := today.AddDate(0, 0, -2*days-14)
+	var volumes []Volume
+	for page := 0; page < moexMaxCandlePages; page++ {
+		url := issClient.URL("/iss/history/engines/%s/markets/%s/boards/%s/securities/%s.json?iss.meta=off&iss.only=history&history.columns=TRADEDATE,VOLUME,VALUE&from=%s&start=%d",
+			board.engine, board.market, board.board, security, from.Format(time.DateOnly), page*moexHistoryPageSize)
+		result, err := query[moexHistory](ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseVolumes(result.History.Columns, result.History.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history for asset %s: %w", asset.Ticker, err)
+		}
+		volumes = append(volumes, parsed...)
+		if len(result.History.Data) < moexHistoryPageSize {
+			break
+		}
+	}
+
+	// The current session is reported by the marketdata
+	volumes = slices.DeleteFunc(volumes, func(volume Volume) bool {
+		return !volume.Date.Before(today)
+	})
+	if len(volumes) == 0 {
+		return nil, &AssetNotFoundError{Asset: asset.Ticker}
+	}
+	if len(volumes) > days {
+		volumes = volumes[len(volumes)-days:]
+	}
+	return volumes, nil
+}
+
+// ----------------------------------------------------------------
+func parseVolumes(columns []string, data [][]any) ([]Volume, error) {
+	dateIndex := columnIndex(columns, "TRADEDATE")
+	volumeIndex := columnIndex(columns, "VOLUME")
+	valueIndex := columnIndex(columns, "VALUE")
+	if dateIndex < 0 || (volumeIndex < 0 && valueIndex < 0) {
+		return nil, fmt.Errorf("unexpected history columns: %v", columns)
+	}
+
+	volumes := make([]Volume, 0, len(data))
+	for _, row := range data {
+		date, isOk := row[dateIndex].(string)
+		if !isOk {
+			return nil, fmt.Errorf("invalid trade date: %v", row[dateIndex])
+		}
+		tradeDate, err := time.ParseInLocation(time.DateOnly, date, moscowTime)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, Volume{
+			Date:   tradeDate,
+			Volume: optionalFloat(row, volumeIndex),
+			Value:  optionalFloat(row, valueIndex),
+		})
+	}
+	return volumes, nil
+}
+
+// ----------------------------------------------------------------
+// Detect the primary board of the security, falling back to the
+// first board it is traded on
+// ----------------------------------------------------------------
+func (requester *MoexRequester) DetectBoard(ctx context.Context, ticker string) (Asset, error) {
+	url := issClient.URL("/iss/securities/%s.json?iss.meta=off&iss.only=boards&boards.columns=boardid,market,engine,is_traded,is_primary",
+		ticker)
+	result, err := query[moexSecurityBoards](ctx, url)
+	if err != nil {
+		return Asset{}, err
+	}
+
+	columns := result.Boards.Columns
+	boardIndex := columnIndex(columns, "boardid")
+	marketIndex := columnIndex(columns, "market")
+	engineIndex := columnIndex(columns, "engine")
+	tradedIndex := columnIndex(columns, "is_traded")
+	primaryIndex := columnIndex(columns, "is_primary")
+	if boardIndex < 0 || marketIndex < 0 || engineIndex < 0 {
+		return Asset{}, fmt.Errorf("unexpected boards columns: %v", columns)
+	}
+
+	var traded *Asset
+	for _, row := range result.Boards.Data {
+		board, boardOk := row[boardIndex].(string)
+		market, marketOk := row[marketIndex].(string)
+		engine, engineOk := row[engineIndex].(string)
+		if !boardOk || !marketOk || !engineOk {
+			continue
+		}
+		asset := Asset{Ticker: ticker, Engine: engine, Market: market, Board: board}
+		if primaryIndex >= 0 && row[primaryIndex] == float64(1) {
+			return asset, nil
+		}
+		if traded == nil && tradedIndex >= 0 && row[tradedIndex] == float64(1) {
+			traded = &asset
+		}
+	}
+	if traded == nil {
+		return Asset{}, &AssetNotFoundError{Asset: ticker}
+	}
+	return *traded, nil
+}
+
+// ----------------------------------------------------------------
+func newMoexRequester() QuoteProvider {
 	return &MoexRequester{}
 }
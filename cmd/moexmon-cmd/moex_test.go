@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 // ----------------------------------------------------------------
@@ -66,8 +71,7 @@ func TestQuery_Success(t *testing.T) {
 		StatusCode: 200,
 		Body:       io.NopCloser(bytes.NewBufferString(body)),
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	ctx := context.Background()
 	result, err := query[moexPrices](ctx, "http://example.com")
@@ -92,8 +96,7 @@ func TestQuery_RequestError(t *testing.T) {
 // ----------------------------------------------------------------
 func TestQuery_HTTPError(t *testing.T) {
 	// Simulate HTTP client error
-	client := &http.Client{Transport: &mockRoundTripper{resp: nil, err: errors.New("network error")}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: nil, err: errors.New("network error")})
 
 	ctx := context.Background()
 	_, err := query[moexPrices](ctx, "http://example.com")
@@ -110,8 +113,7 @@ func TestQuery_ReadError(t *testing.T) {
 		StatusCode: 200,
 		Body:       r,
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	ctx := context.Background()
 	_, err := query[moexPrices](ctx, "http://example.com")
@@ -133,8 +135,7 @@ func TestFetchPrice_StockSuccess(t *testing.T) {
 		StatusCode: 200,
 		Body:       io.NopCloser(bytes.NewBufferString(body)),
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	requester := &MoexRequester{}
 	ctx := context.Background()
@@ -155,8 +156,7 @@ func TestFetchPrice_BondSuccess(t *testing.T) {
 		StatusCode: 200,
 		Body:       io.NopCloser(bytes.NewBufferString(body)),
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	requester := &MoexRequester{}
 	ctx := context.Background()
@@ -177,8 +177,7 @@ func TestFetchPrice_CurrencySuccess(t *testing.T) {
 		StatusCode: 200,
 		Body:       io.NopCloser(bytes.NewBufferString(body)),
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	requester := &MoexRequester{}
 	ctx := context.Background()
@@ -209,8 +208,7 @@ func TestFetchPrice_NoPriceData(t *testing.T) {
 		StatusCode: 200,
 		Body:       io.NopCloser(bytes.NewBufferString(body)),
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	requester := &MoexRequester{}
 	ctx := context.Background()
@@ -228,8 +226,7 @@ func TestFetchPrice_InvalidPriceType(t *testing.T) {
 		StatusCode: 200,
 		Body:       io.NopCloser(bytes.NewBufferString(body)),
 	}
-	client := &http.Client{Transport: &mockRoundTripper{resp: mockResp}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: mockResp})
 
 	requester := &MoexRequester{}
 	ctx := context.Background()
@@ -242,8 +239,7 @@ func TestFetchPrice_InvalidPriceType(t *testing.T) {
 // ----------------------------------------------------------------
 func TestFetchPrice_QueryError(t *testing.T) {
 	// Simulate HTTP client error
-	client := &http.Client{Transport: &mockRoundTripper{resp: nil, err: errors.New("network error")}}
-	http.DefaultClient = client
+	mockISS(&mockRoundTripper{resp: nil, err: errors.New("network error")})
 
 	requester := &MoexRequester{}
 	ctx := context.Background()
@@ -252,3 +248,440 @@ func TestFetchPrice_QueryError(t *testing.T) {
 		t.Error("expected error from query, got nil")
 	}
 }
+
+// ----------------------------------------------------------------
+// roundTripFunc lets a test inspect every request sent to ISS
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_GroupsByBoard(t *testing.T) {
+	var requests []string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.String())
+		var body string
+		if strings.Contains(req.URL.Path, "/boards/TQBR/") {
+			body = `{"marketdata":{"columns":["SECID","LAST"],"data":[["SBER",310.5],["GAZP",140.25]]}}`
+		} else {
+			body = `{"marketdata":{"columns":["SECID","LAST"],"data":[["USD000UTSTOM",92.1]]}}`
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "SBER", AssetType: "stock"},
+		{Ticker: "GAZP", AssetType: "stock"},
+		{Ticker: "USD000UTSTOM", AssetType: "currency"},
+	})
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d: %v", len(requests), requests)
+	}
+	for _, url := range requests {
+		if strings.Contains(url, "/boards/TQBR/") && !strings.Contains(url, "securities=SBER,GAZP") {
+			t.Errorf("expected both stocks in one request, got %s", url)
+		}
+	}
+	if quotes["SBER"].Err != nil || quotes["SBER"].Price != 310.5 {
+		t.Errorf("unexpected SBER quote: %+v", quotes["SBER"])
+	}
+	if quotes["GAZP"].Err != nil || quotes["GAZP"].Price != 140.25 {
+		t.Errorf("unexpected GAZP quote: %+v", quotes["GAZP"])
+	}
+	if quotes["USD000UTSTOM"].Err != nil || quotes["USD000UTSTOM"].Price != 92.1 {
+		t.Errorf("unexpected USD000UTSTOM quote: %+v", quotes["USD000UTSTOM"])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_BondAnalytics(t *testing.T) {
+	var requested string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.Query().Get("iss.only")
+		body := `{"marketdata":{"columns":["SECID","LAST"],"data":[["RU000A1038V6",98.5],["RU000A0JX0J2",101.2]]},
+			"marketdata_yields":{"columns":["SECID","EFFECTIVEYIELD","DURATION"],"data":[["RU000A1038V6",16.45,730]]},
+			"securities":{"columns":["SECID","FACEVALUE","ACCRUEDINT","COUPONPERCENT","MATDATE","OFFERDATE","CALLOPTIONDATE"],"data":[
+				["RU000A1038V6",1000,12.3,14.5,"2027-06-01","2026-12-01","2026-06-01"],
+				["RU000A0JX0J2",1000,null,null,"2030-01-01","0000-00-00",null]]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "RU000A1038V6", AssetType: "bond"},
+		{Ticker: "RU000A0JX0J2", AssetType: "bond"},
+	})
+	if requested != "marketdata,marketdata_yields,securities" {
+		t.Errorf("unexpected blocks requested: %s", requested)
+	}
+	bond := quotes["RU000A1038V6"].Bond
+	if bond == nil {
+		t.Fatalf("expected bond analytics, got %+v", quotes["RU000A1038V6"])
+	}
+	if bond.Yield != 16.45 || bond.Duration != 730 || bond.FaceValue != 1000 || bond.AccruedInt != 12.3 || bond.CouponPercent != 14.5 ||
+		bond.MaturityDate.Format(time.DateOnly) != "2027-06-01" || bond.OfferDate.Format(time.DateOnly) != "2026-06-01" {
+		t.Errorf("unexpected bond analytics: %+v", bond)
+	}
+	// The missing values are unknown
+	bond = quotes["RU000A0JX0J2"].Bond
+	if bond == nil || !math.IsNaN(bond.Yield) || !math.IsNaN(bond.AccruedInt) || !bond.OfferDate.IsZero() {
+		t.Errorf("unexpected bond analytics: %+v", bond)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_PerTickerErrors(t *testing.T) {
+	body := `{"marketdata":{"columns":["SECID","LAST"],"data":[["SBER",310.5],["GAZP",null]]}}`
+	mockResp := &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+	mockISS(&mockRoundTripper{resp: mockResp})
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "SBER", AssetType: "stock"},
+		{Ticker: "GAZP", AssetType: "stock"},
+		{Ticker: "NOPE", AssetType: "stock"},
+		{Ticker: "BTC", AssetType: "crypto"},
+	})
+
+	if quotes["SBER"].Err != nil || quotes["SBER"].Price != 310.5 {
+		t.Errorf("unexpected SBER quote: %+v", quotes["SBER"])
+	}
+	if quotes["GAZP"].Err == nil {
+		t.Error("expected error for missing GAZP price, got nil")
+	}
+	var notFound *AssetNotFoundError
+	if !errors.As(quotes["NOPE"].Err, &notFound) || notFound.Asset != "NOPE" {
+		t.Errorf("expected AssetNotFoundError for NOPE, got %v", quotes["NOPE"].Err)
+	}
+	if quotes["BTC"].Err == nil {
+		t.Error("expected error for unsupported asset type, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_QueryError(t *testing.T) {
+	mockISS(&mockRoundTripper{resp: nil, err: errors.New("network error")})
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "SBER", AssetType: "stock"},
+		{Ticker: "GAZP", AssetType: "stock"},
+	})
+	for _, ticker := range []string{"SBER", "GAZP"} {
+		if quotes[ticker].Err == nil {
+			t.Errorf("expected error for %s, got nil", ticker)
+		}
+		if _, ok := quotes[ticker].Err.(*AssetNotFoundError); ok {
+			t.Errorf("expected network error for %s, got AssetNotFoundError", ticker)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_SplitsLargeBatches(t *testing.T) {
+	requests := 0
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		body := `{"marketdata":{"columns":["SECID","LAST"],"data":[]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	assets := make([]Asset, 0, moexBatchSize+1)
+	for i := 0; i <= moexBatchSize; i++ {
+		assets = append(assets, Asset{Ticker: fmt.Sprintf("T%03d", i), AssetType: "stock"})
+	}
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), assets)
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+	if len(quotes) != len(assets) {
+		t.Errorf("expected %d quotes, got %d", len(assets), len(quotes))
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_SessionStatistics(t *testing.T) {
+	body := `{"marketdata":{"columns":["SECID","LAST","OPEN","HIGH","LOW","WAPRICE","LASTTOPREVPRICE"],"data":[["SBER",310.5,300.0,312.0,299.5,305.2,1.25],["GAZP",140.0,null,null,null,null,null]]}}`
+	mockResp := &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+	mockISS(&mockRoundTripper{resp: mockResp})
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "SBER", AssetType: "stock"},
+		{Ticker: "GAZP", AssetType: "stock"},
+	})
+
+	sber := quotes["SBER"]
+	if sber.Err != nil || sber.Open != 300.0 || sber.High != 312.0 || sber.Low != 299.5 || sber.WAPrice != 305.2 || sber.ChangePct != 1.25 {
+		t.Errorf("unexpected SBER quote: %+v", sber)
+	}
+	gazp := quotes["GAZP"]
+	if gazp.Err != nil || gazp.Price != 140.0 {
+		t.Errorf("unexpected GAZP quote: %+v", gazp)
+	}
+	if !math.IsNaN(gazp.Open) || !math.IsNaN(gazp.ChangePct) {
+		t.Errorf("expected NaN for missing statistics, got %+v", gazp)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchCandles_Success(t *testing.T) {
+	var requested string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		// Newest first as requested with iss.reverse
+		body := `{"candles":{"columns":["open","close","high","low","value","volume","begin","end"],"data":[
+			[103.0,104.0,105.0,102.0,1000.0,10.0,"2025-03-03 00:00:00","2025-03-03 23:59:59"],
+			[101.0,103.0,103.5,100.5,1000.0,10.0,"2025-02-28 00:00:00","2025-02-28 23:59:59"],
+			[100.0,101.0,102.0,99.0,1000.0,10.0,"2025-02-27 00:00:00","2025-02-27 23:59:59"]]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	candles, err := requester.FetchCandles(context.Background(), Asset{Ticker: "SBER", AssetType: "stock"}, 24, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(requested, "/boards/TQBR/securities/SBER/candles.json") || !strings.Contains(requested, "interval=24") {
+		t.Errorf("unexpected request: %s", requested)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+	// Chronological order, latest candles only
+	if candles[0].Close != 103.0 || candles[1].Close != 104.0 {
+		t.Errorf("unexpected candles: %+v", candles)
+	}
+	if candles[1].Begin.Day() != 3 || candles[1].Volume != 10.0 {
+		t.Errorf("unexpected last candle: %+v", candles[1])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchCandles_Paging(t *testing.T) {
+	var starts []string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		starts = append(starts, req.URL.Query().Get("start"))
+		body := `{"candles":{"columns":["close","begin"],"data":[[100.0,"2025-03-03 10:00:00"]]}}`
+		if len(starts) > 2 {
+			body = `{"candles":{"columns":["close","begin"],"data":[]}}`
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	candles, err := requester.FetchCandles(context.Background(), Asset{Ticker: "SBER", AssetType: "stock"}, 60, 5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(candles) != 2 {
+		t.Errorf("expected 2 candles, got %d", len(candles))
+	}
+	if len(starts) != 3 || starts[0] != "0" || starts[1] != "1" || starts[2] != "2" {
+		t.Errorf("unexpected pages requested: %v", starts)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchCandles_Errors(t *testing.T) {
+	requester := &MoexRequester{}
+	if _, err := requester.FetchCandles(context.Background(), Asset{Ticker: "SBER", AssetType: "stock"}, 5, 10); err == nil {
+		t.Error("expected error for unsupported interval, got nil")
+	}
+	if _, err := requester.FetchCandles(context.Background(), Asset{Ticker: "SBER", AssetType: "crypto"}, 24, 10); err == nil {
+		t.Error("expected error for unsupported asset type, got nil")
+	}
+
+	body := `{"candles":{"columns":["close","begin"],"data":[]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	_, err := requester.FetchCandles(context.Background(), Asset{Ticker: "NOPE", AssetType: "stock"}, 24, 10)
+	var notFound *AssetNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected AssetNotFoundError, got %v", err)
+	}
+
+	body = `{"candles":{"columns":["close","begin"],"data":[[100.0,"not a date"]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	if _, err := requester.FetchCandles(context.Background(), Asset{Ticker: "SBER", AssetType: "stock"}, 24, 10); err == nil {
+		t.Error("expected error for invalid candle, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchVolumes_Success(t *testing.T) {
+	var requested string
+	today := time.Now().In(moscowTime).Format(time.DateOnly)
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		body := fmt.Sprintf(`{"history":{"columns":["TRADEDATE","VOLUME","VALUE"],"data":[
+			["2025-02-26",1000.0,100000.0],
+			["2025-02-27",2000.0,200000.0],
+			["2025-02-28",3000.0,300000.0],
+			["%s",4000.0,400000.0]]}}`, today)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	volumes, err := requester.FetchVolumes(context.Background(), Asset{Ticker: "GAZP", AssetType: "stock"}, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(requested, "/history/engines/stock/markets/shares/boards/TQBR/securities/GAZP.json") {
+		t.Errorf("unexpected request: %s", requested)
+	}
+	// Today's session is excluded, only the latest days are kept
+	if len(volumes) != 2 || volumes[0].Volume != 2000.0 || volumes[1].Value != 300000.0 {
+		t.Errorf("unexpected volumes: %+v", volumes)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchVolumes_Errors(t *testing.T) {
+	requester := &MoexRequester{}
+	if _, err := requester.FetchVolumes(context.Background(), Asset{Ticker: "GAZP", AssetType: "crypto"}, 20); err == nil {
+		t.Error("expected error for unsupported asset type, got nil")
+	}
+
+	body := `{"history":{"columns":["TRADEDATE","VOLUME","VALUE"],"data":[]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	_, err := requester.FetchVolumes(context.Background(), Asset{Ticker: "NOPE", AssetType: "stock"}, 20)
+	var notFound *AssetNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected AssetNotFoundError, got %v", err)
+	}
+
+	body = `{"history":{"columns":["SECID"],"data":[["GAZP"]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	if _, err := requester.FetchVolumes(context.Background(), Asset{Ticker: "GAZP", AssetType: "stock"}, 20); err == nil {
+		t.Error("expected error for unexpected columns, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFetchPrices_DetectedBoards(t *testing.T) {
+	var requests []string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.Path)
+		body := `{"marketdata":{"columns":["SECID","LAST"],"data":[["SU26238RMFS4",61.2],["TMOS",7.1],["USD000UTSTOM",92.1]]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	quotes := requester.FetchPrices(context.Background(), []Asset{
+		{Ticker: "SU26238RMFS4", AssetType: "bond", Engine: "stock", Market: "bonds", Board: "TQOB"},
+		{Ticker: "TMOS", AssetType: "stock", Engine: "stock", Market: "shares", Board: "TQTF"},
+		{Ticker: "USD000UTSTOM", AssetType: "currency"},
+	})
+
+	slices.Sort(requests)
+	expected := []string{
+		"/iss/engines/currency/markets/selt/boards/CETS/securities.json",
+		"/iss/engines/stock/markets/bonds/boards/TQOB/securities.json",
+		"/iss/engines/stock/markets/shares/boards/TQTF/securities.json",
+	}
+	if !slices.Equal(requests, expected) {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+	if quotes["SU26238RMFS4"].Err != nil || quotes["TMOS"].Err != nil || quotes["USD000UTSTOM"].Err != nil {
+		t.Errorf("unexpected quotes: %+v", quotes)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDetectBoard_Primary(t *testing.T) {
+	var requested string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.Path
+		body := `{"boards":{"columns":["boardid","market","engine","is_traded","is_primary"],"data":[
+			["EQOB","bonds","stock",0,0],
+			["PTOB","bonds","stock",1,0],
+			["TQOB","bonds","stock",1,1]]}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	requester := &MoexRequester{}
+	asset, err := requester.DetectBoard(context.Background(), "SU26238RMFS4")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if requested != "/iss/securities/SU26238RMFS4.json" {
+		t.Errorf("unexpected request: %s", requested)
+	}
+	if asset.Engine != "stock" || asset.Market != "bonds" || asset.Board != "TQOB" || asset.Ticker != "SU26238RMFS4" {
+		t.Errorf("unexpected asset: %+v", asset)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDetectBoard_TradedFallback(t *testing.T) {
+	body := `{"boards":{"columns":["boardid","market","engine","is_traded","is_primary"],"data":[
+		["EQRP","shares","stock",0,0],
+		["SMAL","shares","stock",1,0],
+		["TQBR","shares","stock",1,0]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+
+	requester := &MoexRequester{}
+	asset, err := requester.DetectBoard(context.Background(), "SBER")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if asset.Board != "SMAL" {
+		t.Errorf("expected the first traded board, got %+v", asset)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDetectBoard_Errors(t *testing.T) {
+	requester := &MoexRequester{}
+
+	body := `{"boards":{"columns":["boardid","market","engine","is_traded","is_primary"],"data":[["EQRP","shares","stock",0,0]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	_, err := requester.DetectBoard(context.Background(), "DELISTED")
+	var notFound *AssetNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected AssetNotFoundError, got %v", err)
+	}
+
+	body = `{"boards":{"columns":["secid"],"data":[["SBER"]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	if _, err := requester.DetectBoard(context.Background(), "SBER"); err == nil {
+		t.Error("expected error for unexpected columns, got nil")
+	}
+
+	mockISS(&mockRoundTripper{err: io.ErrUnexpectedEOF})
+	if _, err := requester.DetectBoard(context.Background(), "SBER"); err == nil {
+		t.Error("expected error for failed query, got nil")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Default number of the concurrent ISS queries and checks of a tick
+const defaultWorkers = 4
+
+// ----------------------------------------------------------------
+// Run the job for each item on at most workers goroutines and wait
+// for them to finish. The items not started before the context is
+// done are skipped, returns their number.
+// ----------------------------------------------------------------
+func runPool[T any](ctx context.Context, workers int, items []T, job func(item T)) int {
+	workers = max(min(workers, len(items)), 1)
+	jobs := make(chan T)
+	var skipped atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				if ctx.Err() != nil {
+					skipped.Add(1)
+					continue
+				}
+				job(item)
+			}
+		}()
+	}
+
+dispatch:
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			skipped.Add(int64(len(items) - i))
+			break dispatch
+		case jobs <- item:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return int(skipped.Load())
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ----------------------------------------------------------------
+// Storage of the portfolio rules state, implemented by
+// godfather.Database
+// ----------------------------------------------------------------
+type portfolioStore interface {
+	SetPortfolioRuleTriggerState(id int, armed bool, lastTriggeredAt time.Time) error
+	AddPortfolioAlert(alert *godfather.PortfolioAlert) error
+}
+
+// ----------------------------------------------------------------
+// Publisher of the alerts, implemented by godfather.MessageBus
+// ----------------------------------------------------------------
+type alertPublisher interface {
+	Publish(subject string, message []byte) error
+}
+
+// ----------------------------------------------------------------
+// Mark-to-market valuation of the portfolio at the snapshot prices
+// ----------------------------------------------------------------
+type portfolioValuation struct {
+	value    float64            // holdings at the last prices
+	cost     float64            // holdings at the average prices
+	previous float64            // holdings at the previous close, NaN if unknown
+	weights  map[string]float64 // value of the holding in % of the portfolio value
+}
+
+// ----------------------------------------------------------------
+// Unrealized P&L in % of the cost
+// ----------------------------------------------------------------
+func (valuation portfolioValuation) pnlPct() (float64, bool) {
+	if !(valuation.cost > 0) {
+		return 0, false
+	}
+	return (valuation.value - valuation.cost) / valuation.cost * 100, true
+}
+
+// ----------------------------------------------------------------
+// Loss of the value today in % of the previous close, negative if
+// the portfolio gained
+// ----------------------------------------------------------------
+func (valuation portfolioValuation) drawdownPct() (float64, bool) {
+	if !(valuation.previous > 0) {
+		return 0, false
+	}
+	return (valuation.previous - valuation.value) / valuation.previous * 100, true
+}
+
+// ----------------------------------------------------------------
+func holdingAsset(holding godfather.Holding) Asset {
+	return Asset{
+		Ticker:    holding.Ticker,
+		AssetType: holding.AssetClass,
+		Engine:    holding.Engine,
+		Market:    holding.Market,
+		Board:     holding.Board,
+	}
+}
+
+// ----------------------------------------------------------------
+// Assets of the portfolios' holdings to be fetched with the watchlist
+// ----------------------------------------------------------------
+func portfolioAssets(portfolios []godfather.Portfolio) []Asset {
+	var assets []Asset
+	for _, portfolio := range portfolios {
+		for _, holding := range portfolio.Holdings {
+			assets = append(assets, holdingAsset(holding))
+		}
+	}
+	return assets
+}
+
+// ----------------------------------------------------------------
+// Value the portfolio, all the holdings must be priced and held in
+// the portfolio's currency
+// ----------------------------------------------------------------
+func valuePortfolio(portfolio godfather.Portfolio, snapshot map[string]Quote) (portfolioValuation, bool) {
+	valuation := portfolioValuation{weights: make(map[string]float64, len(portfolio.Holdings))}
+	if len(portfolio.Holdings) == 0 {
+		return valuation, false
+	}
+	values := make(map[string]float64, len(portfolio.Holdings))
+	for _, holding := range portfolio.Holdings {
+		if holding.Currency != portfolio.Currency {
+			slog.Warn(fmt.Sprintf("Holding %s of portfolio %s is in %s, not in %s, the portfolio is not valued",
+				holding.Ticker, portfolio.Name, holding.Currency, portfolio.Currency))
+			return valuation, false
+		}
+		quote, found := snapshot[holding.Ticker]
+		// The bonds are valued in money, not in % of the face value
+		price, priced := lastPrice(quote)
+		if !found || quote.Err != nil || !priced || math.IsNaN(price) {
+			slog.Debug(fmt.Sprintf("No price for %s, portfolio %s is not valued", holding.Ticker, portfolio.Name))
+			return valuation, false
+		}
+		value := holding.Quantity * price
+		values[holding.Ticker] += value
+		valuation.value += value
+		valuation.cost += holding.Quantity * holding.AveragePrice
+		// The previous close is derived from the change to it
+		valuation.previous += value / (1 + quote.ChangePct/100)
+	}
+	if valuation.value > 0 {
+		for ticker, value := range values {
+			valuation.weights[ticker] = value / valuation.value * 100
+		}
+	}
+	return valuation, true
+}
+
+// ----------------------------------------------------------------
+// Value of the portfolio rule, the ticker of the heaviest holding for
+// the weight rules without the ticker
+// ----------------------------------------------------------------
+func portfolioRuleValue(rule godfather.PortfolioRule, valuation portfolioValuation) (float64, string, bool) {
+	switch rule.Condition {
+	case godfather.PortfolioDrawdownAbove:
+		drawdown, ok := valuation.drawdownPct()
+		return drawdown, "", ok
+	case godfather.PortfolioPnLAbove, godfather.PortfolioPnLBelow:
+		pnl, ok := valuation.pnlPct()
+		return pnl, "", ok
+	case godfather.PortfolioWeightAbove:
+		if rule.Ticker != "" {
+			return valuation.weights[rule.Ticker], rule.Ticker, valuation.value > 0
+		}
+		heaviest, weight := "", 0.0
+		for ticker, value := range valuation.weights {
+			if value > weight || (value == weight && ticker < heaviest) {
+				heaviest, weight = ticker, value
+			}
+		}
+		return weight, heaviest, heaviest != ""
+	default:
+		slog.Warn(fmt.Sprintf("Unknown condition '%s' for portfolio rule %d", rule.Condition, rule.ID))
+		return 0, "", false
+	}
+}
+
+// ----------------------------------------------------------------
+// Decide what to do with the portfolio rule: it fires once when the
+// condition is met and re-arms once the condition is not met anymore
+// ----------------------------------------------------------------
+func evaluatePortfolioRule(rule godfather.PortfolioRule, valuation portfolioValuation) ruleAction {
+	value, _, ok := portfolioRuleValue(rule, valuation)
+	if !ok {
+		return ruleIdle
+	}
+	met := value > rule.Threshold
+	if rule.Condition == godfather.PortfolioPnLBelow {
+		met = value < rule.Threshold
+	}
+	switch {
+	case met && rule.Armed:
+		return ruleFire
+	case !met && !rule.Armed:
+		return ruleRearm
+	default:
+		return ruleIdle
+	}
+}
+
+// ----------------------------------------------------------------
+// Human readable description of the fired portfolio rule for alerts
+// ----------------------------------------------------------------
+func describePortfolioRule(portfolio godfather.Portfolio, rule godfather.PortfolioRule, valuation portfolioValuation) string {
+	value, ticker, _ := portfolioRuleValue(rule, valuation)
+	total := fmt.Sprintf("value %.2f %s", valuation.value, portfolio.Currency)
+	switch rule.Condition {
+	case godfather.PortfolioDrawdownAbove:
+		return fmt.Sprintf("Portfolio %s lost %.2f%% today, above %.2f%% (%s)", portfolio.Name, value, rule.Threshold, total)
+	case godfather.PortfolioPnLAbove, godfather.PortfolioPnLBelow:
+		direction := "above"
+		if rule.Condition == godfather.PortfolioPnLBelow {
+			direction = "below"
+		}
+		return fmt.Sprintf("Unrealized P&L of portfolio %s is %.2f%% (%.2f %s), %s %.2f%% (%s)", portfolio.Name, value,
+			valuation.value-valuation.cost, portfolio.Currency, direction, rule.Threshold, total)
+	default:
+		return fmt.Sprintf("%s is %.2f%% of portfolio %s, above %.2f%% (%s)", ticker, value, portfolio.Name, rule.Threshold, total)
+	}
+}
+
+// ----------------------------------------------------------------
+// Publish the portfolio alert to NATS, returns true if the alert was
+// published
+// ----------------------------------------------------------------
+func sendPortfolioAlert(publisher alertPublisher, portfolio godfather.Portfolio, text string) bool {
+	if portfolio.NotificationID == 0 {
+		slog.Warn(fmt.Sprintf("No notification configured for portfolio %s, alert is not sent", portfolio.Name), "message", text)
+		return false
+	}
+	data, err := msgpack.Marshal(godfather.AlertMessage{
+		Subject:        text,
+		NotificationId: portfolio.NotificationID,
+		PortfolioId:    portfolio.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to marshal alert message", "error", err)
+		alertFailures.Inc()
+		return false
+	}
+	if err := publisher.Publish("alerts.MOEX", data); err != nil {
+		slog.Error("Failed to publish alert", "error", err)
+		alertFailures.Inc()
+		return false
+	}
+	alertsPublished.Inc()
+	slog.Debug("Alert published", "message", text, "portfolio_id", portfolio.ID)
+	return true
+}
+
+// ----------------------------------------------------------------
+// Publish the alert of the fired rule, then disarm and record it. The
+// rule stays armed if the alert is not published, so it is retried on
+// the next tick; the rules of the portfolios without the notification
+// are disarmed anyway.
+// ----------------------------------------------------------------
+func firePortfolioRule(store portfolioStore, publisher alertPublisher, portfolio godfather.Portfolio, rule godfather.PortfolioRule,
+	valuation portfolioValuation, now time.Time) {
+	published := sendPortfolioAlert(publisher, portfolio, describePortfolioRule(portfolio, rule, valuation))
+	if !published && portfolio.NotificationID != 0 {
+		return
+	}
+	if err := store.SetPortfolioRuleTriggerState(rule.ID, false, now); err != nil {
+		slog.Error("Failed to disarm portfolio rule", "error", err)
+		dbFailures.Inc()
+	}
+
+	value, ticker, _ := portfolioRuleValue(rule, valuation)
+	err := store.AddPortfolioAlert(&godfather.PortfolioAlert{
+		RuleID:         rule.ID,
+		Condition:      rule.Condition,
+		Threshold:      rule.Threshold,
+		Value:          value,
+		PortfolioValue: valuation.value,
+		Ticker:         ticker,
+		Published:      published,
+		Timestamp:      now,
+	})
+	if err != nil {
+		slog.Error("Failed to record portfolio alert", "error", err)
+		dbFailures.Inc()
+	}
+}
+
+// ----------------------------------------------------------------
+// Value the portfolios and evaluate their rules
+// ----------------------------------------------------------------
+func checkPortfolios(store portfolioStore, publisher alertPublisher, portfolios []godfather.Portfolio, snapshot map[string]Quote, now time.Time) {
+	for _, portfolio := range portfolios {
+		valuation, ok := valuePortfolio(portfolio, snapshot)
+		if !ok {
+			continue
+		}
+		slog.Debug(fmt.Sprintf("Portfolio %s is valued at %.2f %s, cost %.2f", portfolio.Name, valuation.value,
+			portfolio.Currency, valuation.cost))
+
+		for _, rule := range portfolio.Rules {
+			switch evaluatePortfolioRule(rule, valuation) {
+			case ruleFire:
+				firePortfolioRule(store, publisher, portfolio, rule, valuation, now)
+			case ruleRearm:
+				slog.Debug(fmt.Sprintf("Condition of portfolio rule %d is not met anymore, re-arming", rule.ID))
+				if err := store.SetPortfolioRuleTriggerState(rule.ID, true, rule.LastTriggeredAt); err != nil {
+					slog.Error("Failed to re-arm portfolio rule", "error", err)
+					dbFailures.Inc()
+				}
+			case ruleIdle:
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ----------------------------------------------------------------
+type mockPortfolioStore struct {
+	states map[int]bool
+	alerts []godfather.PortfolioAlert
+}
+
+func (m *mockPortfolioStore) SetPortfolioRuleTriggerState(id int, armed bool, lastTriggeredAt time.Time) error {
+	if m.states == nil {
+		m.states = make(map[int]bool)
+	}
+	m.states[id] = armed
+	return nil
+}
+
+func (m *mockPortfolioStore) AddPortfolioAlert(alert *godfather.PortfolioAlert) error {
+	m.alerts = append(m.alerts, *alert)
+	return nil
+}
+
+// ----------------------------------------------------------------
+func testPortfolio(rules ...godfather.PortfolioRule) godfather.Portfolio {
+	return godfather.Portfolio{
+		ID:             7,
+		Name:           "Main",
+		Currency:       "RUB",
+		NotificationID: 2,
+		Holdings: []godfather.Holding{
+			{Ticker: "SBER", Quantity: 100, AveragePrice: 250, Currency: "RUB", AssetClass: "stock"},
+			{Ticker: "GAZP", Quantity: 100, AveragePrice: 200, Currency: "RUB", AssetClass: "stock"},
+		},
+		Rules: rules,
+	}
+}
+
+// ----------------------------------------------------------------
+func TestValuePortfolio(t *testing.T) {
+	// SBER fell 10% today, GAZP is unchanged
+	snapshot := map[string]Quote{
+		"SBER": {Price: 270, ChangePct: -10},
+		"GAZP": {Price: 180, ChangePct: 0},
+	}
+	valuation, ok := valuePortfolio(testPortfolio(), snapshot)
+	if !ok {
+		t.Fatal("expected the portfolio to be valued")
+	}
+	if valuation.value != 45000 || valuation.cost != 45000 || math.Abs(valuation.previous-48000) > 1e-6 {
+		t.Errorf("unexpected valuation: %+v", valuation)
+	}
+	if drawdown, ok := valuation.drawdownPct(); !ok || math.Abs(drawdown-6.25) > 1e-9 {
+		t.Errorf("expected 6.25%% drawdown, got %f", drawdown)
+	}
+	if pnl, ok := valuation.pnlPct(); !ok || pnl != 0 {
+		t.Errorf("expected zero P&L, got %f", pnl)
+	}
+	if valuation.weights["SBER"] != 60 || valuation.weights["GAZP"] != 40 {
+		t.Errorf("unexpected weights: %v", valuation.weights)
+	}
+
+	// The change to the previous close is unknown
+	snapshot["GAZP"] = Quote{Price: 180, ChangePct: math.NaN()}
+	valuation, _ = valuePortfolio(testPortfolio(), snapshot)
+	if _, ok := valuation.drawdownPct(); ok {
+		t.Error("expected no drawdown without the previous close")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestValuePortfolio_Incomplete(t *testing.T) {
+	// A holding is not priced
+	snapshot := map[string]Quote{
+		"SBER": {Price: 270},
+		"GAZP": {Err: &AssetNotFoundError{Asset: "GAZP"}},
+	}
+	if _, ok := valuePortfolio(testPortfolio(), snapshot); ok {
+		t.Error("expected no valuation without all the prices")
+	}
+	// A holding is in another currency
+	portfolio := testPortfolio()
+	portfolio.Holdings[1].Currency = "USD"
+	snapshot["GAZP"] = Quote{Price: 180}
+	if _, ok := valuePortfolio(portfolio, snapshot); ok {
+		t.Error("expected no valuation with a foreign currency holding")
+	}
+	// A bond is valued in money
+	portfolio = testPortfolio()
+	portfolio.Holdings[1] = godfather.Holding{Ticker: "RU000A1038V6", Quantity: 10, AveragePrice: 990, Currency: "RUB", AssetClass: "bond"}
+	snapshot["RU000A1038V6"] = Quote{Price: 98.5, ChangePct: 0, Bond: &MoexBond{FaceValue: 1000}}
+	if valuation, ok := valuePortfolio(portfolio, snapshot); !ok || valuation.value != 27000+9850 {
+		t.Errorf("unexpected valuation with a bond: %+v", valuation)
+	}
+	if _, ok := valuePortfolio(godfather.Portfolio{Name: "Empty"}, snapshot); ok {
+		t.Error("expected no valuation without holdings")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluatePortfolioRule(t *testing.T) {
+	valuation := portfolioValuation{value: 45000, cost: 40000, previous: 48000,
+		weights: map[string]float64{"SBER": 60, "GAZP": 40}}
+	tests := []struct {
+		rule     godfather.PortfolioRule
+		expected ruleAction
+	}{
+		{godfather.PortfolioRule{Condition: godfather.PortfolioDrawdownAbove, Threshold: 5, Armed: true}, ruleFire},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioDrawdownAbove, Threshold: 5}, ruleIdle},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioDrawdownAbove, Threshold: 10}, ruleRearm},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioPnLAbove, Threshold: 10, Armed: true}, ruleFire},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioPnLBelow, Threshold: -5, Armed: true}, ruleIdle},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioWeightAbove, Threshold: 50, Armed: true}, ruleFire},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioWeightAbove, Threshold: 50, Ticker: "GAZP", Armed: true}, ruleIdle},
+		// The holding is not in the portfolio
+		{godfather.PortfolioRule{Condition: godfather.PortfolioWeightAbove, Threshold: 50, Ticker: "LKOH"}, ruleRearm},
+		{godfather.PortfolioRule{Condition: "unknown", Armed: true}, ruleIdle},
+	}
+	for _, test := range tests {
+		if action := evaluatePortfolioRule(test.rule, valuation); action != test.expected {
+			t.Errorf("expected %v for %+v, got %v", test.expected, test.rule, action)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribePortfolioRule(t *testing.T) {
+	portfolio := testPortfolio()
+	valuation := portfolioValuation{value: 45000, cost: 40000, previous: 48000,
+		weights: map[string]float64{"SBER": 60, "GAZP": 40}}
+	tests := []struct {
+		rule     godfather.PortfolioRule
+		expected string
+	}{
+		{godfather.PortfolioRule{Condition: godfather.PortfolioDrawdownAbove, Threshold: 5},
+			"Portfolio Main lost 6.25% today, above 5.00% (value 45000.00 RUB)"},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioPnLAbove, Threshold: 10},
+			"Unrealized P&L of portfolio Main is 12.50% (5000.00 RUB), above 10.00% (value 45000.00 RUB)"},
+		{godfather.PortfolioRule{Condition: godfather.PortfolioWeightAbove, Threshold: 50},
+			"SBER is 60.00% of portfolio Main, above 50.00% (value 45000.00 RUB)"},
+	}
+	for _, test := range tests {
+		if text := describePortfolioRule(portfolio, test.rule, valuation); text != test.expected {
+			t.Errorf("expected '%s', got '%s'", test.expected, text)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestCheckPortfolios(t *testing.T) {
+	portfolio := testPortfolio(
+		godfather.PortfolioRule{ID: 1, Condition: godfather.PortfolioDrawdownAbove, Threshold: 5, Armed: true},
+		godfather.PortfolioRule{ID: 2, Condition: godfather.PortfolioWeightAbove, Threshold: 70, Armed: false},
+		godfather.PortfolioRule{ID: 3, Condition: godfather.PortfolioPnLBelow, Threshold: -50, Armed: true},
+	)
+	snapshot := map[string]Quote{
+		"SBER": {Price: 270, ChangePct: -10},
+		"GAZP": {Price: 180, ChangePct: 0},
+	}
+	store := &mockPortfolioStore{}
+	publisher := &mockQuotePublisher{}
+	checkPortfolios(store, publisher, []godfather.Portfolio{portfolio}, snapshot, time.Now())
+
+	if len(store.states) != 2 || store.states[1] || !store.states[2] {
+		t.Errorf("expected rule 1 disarmed and rule 2 re-armed, got %v", store.states)
+	}
+	var alert godfather.AlertMessage
+	if err := msgpack.Unmarshal(publisher.messages["alerts.MOEX"], &alert); err != nil {
+		t.Fatalf("failed to unmarshal alert: %v", err)
+	}
+	if alert.PortfolioId != 7 || alert.NotificationId != 2 || alert.WatchlistId != 0 {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+	if len(store.alerts) != 1 || store.alerts[0].RuleID != 1 || !store.alerts[0].Published || store.alerts[0].Value <= 5 {
+		t.Errorf("expected the firing of rule 1 recorded, got %+v", store.alerts)
+	}
+
+	// The rule stays armed until the alert is published
+	store = &mockPortfolioStore{}
+	publisher = &mockQuotePublisher{err: errors.New("no connection")}
+	checkPortfolios(store, publisher, []godfather.Portfolio{portfolio}, snapshot, time.Now())
+	if _, disarmed := store.states[1]; disarmed || len(store.alerts) != 0 {
+		t.Errorf("expected rule 1 armed, got %v and %+v", store.states, store.alerts)
+	}
+
+	// No alert is sent without the notification, the firing is recorded
+	portfolio.NotificationID = 0
+	store = &mockPortfolioStore{}
+	publisher = &mockQuotePublisher{}
+	checkPortfolios(store, publisher, []godfather.Portfolio{portfolio}, snapshot, time.Now())
+	if len(publisher.messages) != 0 {
+		t.Errorf("unexpected alerts: %v", publisher.messages)
+	}
+	if store.states[1] || len(store.alerts) != 1 || store.alerts[0].Published {
+		t.Errorf("expected rule 1 disarmed and recorded, got %v and %+v", store.states, store.alerts)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Exchange of the default market data provider
+const defaultProviderExchange = "moex"
+
+// Source of the quotes replayed from the local files
+const replaySource = "replay"
+
+// ----------------------------------------------------------------
+// Clock of the provider replaying the recorded quotes. The trading
+// schedule, the availability and the reference data of the exchange
+// don't apply to such a provider.
+// ----------------------------------------------------------------
+type providerClock interface {
+	Now() time.Time
+}
+
+// ----------------------------------------------------------------
+// Current time of the provider: the time of the replayed quotes or the
+// wall clock
+// ----------------------------------------------------------------
+func providerNow(provider QuoteProvider) time.Time {
+	if clock, isOk := provider.(providerClock); isOk {
+		return clock.Now()
+	}
+	return time.Now()
+}
+
+// ----------------------------------------------------------------
+func isReplay(provider QuoteProvider) bool {
+	_, isOk := provider.(providerClock)
+	return isOk
+}
+
+// ----------------------------------------------------------------
+// Constructor of the market data provider
+// ----------------------------------------------------------------
+type providerFactory func(config ProviderConfig) (QuoteProvider, error)
+
+// Market data providers by exchange, a new exchange only needs its
+// provider registered here
+var providerFactories = map[string]providerFactory{
+	"moex": newMoexProvider,
+}
+
+// ----------------------------------------------------------------
+// MOEX quotes from ISS (the default) or replayed from the local files
+// ----------------------------------------------------------------
+func newMoexProvider(config ProviderConfig) (QuoteProvider, error) {
+	switch config.Source {
+	case "", "iss":
+		return newMoexRequester(), nil
+	case replaySource:
+		return newReplayProvider(config)
+	default:
+		return nil, fmt.Errorf("unknown MOEX quotes source '%s', expected iss or %s", config.Source, replaySource)
+	}
+}
+
+// ----------------------------------------------------------------
+// Create the market data provider of the configured exchange
+// ----------------------------------------------------------------
+func newProvider(config ProviderConfig) (QuoteProvider, error) {
+	exchange := config.Exchange
+	if exchange == "" {
+		exchange = defaultProviderExchange
+	}
+	factory, found := providerFactories[exchange]
+	if !found {
+		exchanges := make([]string, 0, len(providerFactories))
+		for known := range providerFactories {
+			exchanges = append(exchanges, known)
+		}
+		slices.Sort(exchanges)
+		return nil, fmt.Errorf("unknown market data provider '%s', expected one of %s", exchange, strings.Join(exchanges, ", "))
+	}
+	return factory(config)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ----------------------------------------------------------------
+// Publisher of the live quotes, implemented by godfather.MessageBus
+// ----------------------------------------------------------------
+type quotePublisher interface {
+	Publish(subject string, message []byte) error
+}
+
+// Stream of the live quotes
+const (
+	quotesStream   = "quotes"
+	quotesSubjects = "quotes.MOEX.>"
+)
+
+// Default limits of the live quotes stream
+const (
+	defaultQuotesMaxAgeSeconds = 300
+	defaultQuotesMaxBytes      = 64 * 1024 * 1024
+)
+
+// Characters not allowed in a NATS subject token
+var subjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+// ----------------------------------------------------------------
+// Subject of the asset's live quotes: quotes.MOEX.<board>.<ticker>
+// ----------------------------------------------------------------
+func quoteSubject(board string, ticker string) string {
+	return fmt.Sprintf("quotes.MOEX.%s.%s", subjectReplacer.Replace(board), subjectReplacer.Replace(ticker))
+}
+
+// ----------------------------------------------------------------
+// Publish the successfully fetched quotes of the snapshot, including
+// the second legs of the pair rules
+// ----------------------------------------------------------------
+func publishQuotes(publisher quotePublisher, watchlist []godfather.MOEXWatchlistItem, snapshot map[string]Quote, now time.Time) {
+	published := make(map[string]bool, len(snapshot))
+	for _, item := range watchlist {
+		publishQuote(publisher, assetOf(item), snapshot, published, now)
+		if item.Pair.Ticker != "" {
+			publishQuote(publisher, pairAssetOf(item), snapshot, published, now)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Publish the asset's quote unless it is already published
+// ----------------------------------------------------------------
+func publishQuote(publisher quotePublisher, asset Asset, snapshot map[string]Quote, published map[string]bool, now time.Time) {
+	quote, found := snapshot[asset.Ticker]
+	if !found || quote.Err != nil || published[asset.Ticker] {
+		return
+	}
+	published[asset.Ticker] = true
+
+	board, err := resolveBoard(asset)
+	if err != nil {
+		return
+	}
+	data, err := msgpack.Marshal(godfather.QuoteMessage{
+		Ticker:    asset.Ticker,
+		Engine:    board.engine,
+		Market:    board.market,
+		Board:     board.board,
+		Timestamp: now,
+		Price:     quote.Price,
+		Open:      quote.Open,
+		High:      quote.High,
+		Low:       quote.Low,
+		WAPrice:   quote.WAPrice,
+		ChangePct: quote.ChangePct,
+		VolToday:  quote.VolToday,
+		ValToday:  quote.ValToday,
+	})
+	if err != nil {
+		slog.Error("Failed to marshal quote message", "error", err)
+		quoteFailures.Inc()
+		return
+	}
+	if err := publisher.Publish(quoteSubject(board.board, asset.Ticker), data); err != nil {
+		slog.Error(fmt.Sprintf("Failed to publish quote for %s", asset.Ticker), "error", err)
+		quoteFailures.Inc()
+		return
+	}
+	quotesPublished.Inc()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ----------------------------------------------------------------
+type mockQuotePublisher struct {
+	messages map[string][]byte
+	err      error
+}
+
+func (m *mockQuotePublisher) Publish(subject string, message []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.messages == nil {
+		m.messages = make(map[string][]byte)
+	}
+	m.messages[subject] = message
+	return nil
+}
+
+// ----------------------------------------------------------------
+func TestQuoteSubject(t *testing.T) {
+	if subject := quoteSubject("TQBR", "SBER"); subject != "quotes.MOEX.TQBR.SBER" {
+		t.Errorf("unexpected subject: %s", subject)
+	}
+	if subject := quoteSubject("TQBR", "A.B*C>"); subject != "quotes.MOEX.TQBR.A_B_C_" {
+		t.Errorf("unexpected sanitized subject: %s", subject)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestPublishQuotes(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	watchlist := []godfather.MOEXWatchlistItem{
+		{ID: 1, Ticker: "SBER", AssetClass: "stock", Engine: "stock", Market: "shares", Board: "TQBR"},
+		{ID: 2, Ticker: "SBER", AssetClass: "stock", Engine: "stock", Market: "shares", Board: "TQBR"},
+		{ID: 3, Ticker: "SU26238RMFS4", AssetClass: "bond"},
+		{ID: 4, Ticker: "NOPE", AssetClass: "stock"},
+	}
+	snapshot := map[string]Quote{
+		"SBER":         {Price: 310.5, Open: 305, VolToday: 1000, ValToday: math.NaN()},
+		"SU26238RMFS4": {Price: 61.2},
+		"NOPE":         {Err: &AssetNotFoundError{Asset: "NOPE"}},
+	}
+	publisher := &mockQuotePublisher{}
+	publishQuotes(publisher, watchlist, snapshot, now)
+
+	if len(publisher.messages) != 2 {
+		t.Fatalf("expected 2 quotes published, got %d", len(publisher.messages))
+	}
+	// The default board of the asset type is used until detected
+	if _, found := publisher.messages["quotes.MOEX.TQCB.SU26238RMFS4"]; !found {
+		t.Errorf("expected the bond quote on the default board, got %v", publisher.messages)
+	}
+
+	var message godfather.QuoteMessage
+	if err := msgpack.Unmarshal(publisher.messages["quotes.MOEX.TQBR.SBER"], &message); err != nil {
+		t.Fatalf("failed to unmarshal quote: %v", err)
+	}
+	if message.Ticker != "SBER" || message.Board != "TQBR" || message.Market != "shares" || message.Price != 310.5 ||
+		message.Open != 305 || message.VolToday != 1000 || !math.IsNaN(message.ValToday) || !message.Timestamp.Equal(now) {
+		t.Errorf("unexpected quote message: %+v", message)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestPublishQuotes_Failure(t *testing.T) {
+	watchlist := []godfather.MOEXWatchlistItem{{ID: 1, Ticker: "SBER", AssetClass: "stock"}}
+	publisher := &mockQuotePublisher{err: errors.New("no responders")}
+	// The failures are only counted
+	publishQuotes(publisher, watchlist, map[string]Quote{"SBER": {Price: 310.5}}, time.Now())
+	if len(publisher.messages) != 0 {
+		t.Errorf("unexpected messages: %v", publisher.messages)
+	}
+}
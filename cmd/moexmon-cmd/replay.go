@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// Quote of the replay file. The session statistics not set are NaN,
+// the board is only used to answer the board detection.
+// ----------------------------------------------------------------
+type replayQuote struct {
+	time  time.Time
+	quote Quote
+	asset Asset
+}
+
+// ----------------------------------------------------------------
+// JSONL record of the replay file, the CSV file has the same columns
+// ----------------------------------------------------------------
+type replayRecord struct {
+	Ticker    string   `json:"ticker"`
+	Time      string   `json:"time"`
+	Price     *float64 `json:"price"`
+	Open      *float64 `json:"open"`
+	High      *float64 `json:"high"`
+	Low       *float64 `json:"low"`
+	WAPrice   *float64 `json:"waprice"`
+	ChangePct *float64 `json:"change_pct"`
+	VolToday  *float64 `json:"vol_today"`
+	ValToday  *float64 `json:"val_today"`
+	Engine    string   `json:"engine"`
+	Market    string   `json:"market"`
+	Board     string   `json:"board"`
+}
+
+// ----------------------------------------------------------------
+// Market data provider replaying the quotes of the local files: each
+// batch query moves to the next timestamp of the files and reports
+// the last quotes known at it. At the end the replay stays at the
+// last timestamp or starts over if looped.
+// ----------------------------------------------------------------
+type ReplayProvider struct {
+	mutex  sync.Mutex
+	quotes map[string][]replayQuote // by ticker in chronological order
+	frames []time.Time              // distinct timestamps of the quotes
+	frame  int                      // current timestamp, -1 before the first batch query
+	loop   bool
+}
+
+// ----------------------------------------------------------------
+func optionalValue(value *float64) float64 {
+	if value == nil {
+		return math.NaN()
+	}
+	return *value
+}
+
+// ----------------------------------------------------------------
+// Convert the record to the quote
+// ----------------------------------------------------------------
+func (record replayRecord) parse() (replayQuote, error) {
+	if record.Ticker == "" || record.Price == nil {
+		return replayQuote{}, errors.New("the ticker and the price are required")
+	}
+	at, err := csvTime(record.Time)
+	if err != nil {
+		return replayQuote{}, fmt.Errorf("invalid time: %w", err)
+	}
+	return replayQuote{
+		time: at,
+		quote: Quote{
+			Price:     *record.Price,
+			Open:      optionalValue(record.Open),
+			High:      optionalValue(record.High),
+			Low:       optionalValue(record.Low),
+			WAPrice:   optionalValue(record.WAPrice),
+			ChangePct: optionalValue(record.ChangePct),
+			VolToday:  optionalValue(record.VolToday),
+			ValToday:  optionalValue(record.ValToday),
+		},
+		asset: Asset{Ticker: record.Ticker, Engine: record.Engine, Market: record.Market, Board: record.Board},
+	}, nil
+}
+
+// ----------------------------------------------------------------
+// Read the records of the JSONL file
+// ----------------------------------------------------------------
+func readReplayJSONL(r io.Reader) ([]replayRecord, error) {
+	var records []replayRecord
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record replayRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid record at line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// ----------------------------------------------------------------
+// Read the records of the CSV file with a header
+// ----------------------------------------------------------------
+func readReplayCSV(r io.Reader) ([]replayRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	text := func(row []string, column string) string {
+		if index := columnIndex(header, column); index >= 0 {
+			return row[index]
+		}
+		return ""
+	}
+
+	var records []replayRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		record := replayRecord{
+			Ticker: text(row, "ticker"),
+			Time:   text(row, "time"),
+			Engine: text(row, "engine"),
+			Market: text(row, "market"),
+			Board:  text(row, "board"),
+		}
+		numbers := []struct {
+			column string
+			field  **float64
+		}{
+			{"price", &record.Price}, {"open", &record.Open}, {"high", &record.High}, {"low", &record.Low},
+			{"waprice", &record.WAPrice}, {"change_pct", &record.ChangePct},
+			{"vol_today", &record.VolToday}, {"val_today", &record.ValToday},
+		}
+		for _, number := range numbers {
+			value, err := csvFloat(row, columnIndex(header, number.column))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s at CSV line %d: %w", number.column, line, err)
+			}
+			if !math.IsNaN(value) {
+				*number.field = &value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ----------------------------------------------------------------
+// Load the quotes of the .csv or .jsonl file
+// ----------------------------------------------------------------
+func loadReplayFile(path string) ([]replayQuote, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var records []replayRecord
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		records, err = readReplayCSV(f)
+	case ".jsonl":
+		records, err = readReplayJSONL(f)
+	default:
+		return nil, fmt.Errorf("unsupported replay file %s, expected .csv or .jsonl", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	quotes := make([]replayQuote, 0, len(records))
+	for i, record := range records {
+		quote, err := record.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid record %d of %s: %w", i+1, path, err)
+		}
+		quotes = append(quotes, quote)
+	}
+	return quotes, nil
+}
+
+// ----------------------------------------------------------------
+// Create the provider replaying the file or the .csv and .jsonl files
+// of the directory
+// ----------------------------------------------------------------
+func newReplayProvider(config ProviderConfig) (QuoteProvider, error) {
+	if config.Path == "" {
+		return nil, errors.New("the replay path is not set")
+	}
+	info, err := os.Stat(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{config.Path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if !entry.IsDir() && (ext == ".csv" || ext == ".jsonl") {
+				files = append(files, filepath.Join(config.Path, entry.Name()))
+			}
+		}
+	}
+
+	var quotes []replayQuote
+	for _, file := range files {
+		loaded, err := loadReplayFile(file)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, loaded...)
+	}
+	if len(quotes) == 0 {
+		return nil, fmt.Errorf("no quotes to replay in %s", config.Path)
+	}
+	return newReplay(quotes, config.Loop), nil
+}
+
+// ----------------------------------------------------------------
+func newReplay(quotes []replayQuote, loop bool) *ReplayProvider {
+	slices.SortStableFunc(quotes, func(a, b replayQuote) int { return a.time.Compare(b.time) })
+	provider := &ReplayProvider{quotes: make(map[string][]replayQuote), frame: -1, loop: loop}
+	for _, quote := range quotes {
+		provider.quotes[quote.asset.Ticker] = append(provider.quotes[quote.asset.Ticker], quote)
+		if len(provider.frames) == 0 || !provider.frames[len(provider.frames)-1].Equal(quote.time) {
+			provider.frames = append(provider.frames, quote.time)
+		}
+	}
+	return provider
+}
+
+// ----------------------------------------------------------------
+// Time of the current frame, the first one before the first batch
+// query. Must be called with the mutex held.
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) now() time.Time {
+	return provider.frames[max(provider.frame, 0)]
+}
+
+// ----------------------------------------------------------------
+// Time of the replayed quotes
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) Now() time.Time {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	return provider.now()
+}
+
+// ----------------------------------------------------------------
+// Quotes of the ticker up to the current frame. Must be called with
+// the mutex held.
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) history(ticker string) []replayQuote {
+	quotes := provider.quotes[ticker]
+	now := provider.now()
+	return quotes[:sort.Search(len(quotes), func(i int) bool { return quotes[i].time.After(now) })]
+}
+
+// ----------------------------------------------------------------
+// Last quote of the ticker at the current frame. Must be called with
+// the mutex held.
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) latest(ticker string) (replayQuote, error) {
+	history := provider.history(ticker)
+	if len(history) == 0 {
+		return replayQuote{}, &AssetNotFoundError{Asset: ticker}
+	}
+	return history[len(history)-1], nil
+}
+
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) FetchPrice(ctx context.Context, ticker string, assetClass string) (float64, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	quote, err := provider.latest(ticker)
+	return quote.quote.Price, err
+}
+
+// ----------------------------------------------------------------
+// Move to the next frame and report the quotes known at it
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) FetchPrices(ctx context.Context, assets []Asset) map[string]Quote {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.frame++
+	if provider.frame >= len(provider.frames) {
+		if provider.loop {
+			provider.frame = 0
+		} else {
+			provider.frame = len(provider.frames) - 1
+		}
+	}
+
+	results := make(map[string]Quote, len(assets))
+	for _, asset := range assets {
+		quote, err := provider.latest(asset.Ticker)
+		if err != nil {
+			results[asset.Ticker] = Quote{Price: math.NaN(), Err: err}
+			continue
+		}
+		results[asset.Ticker] = quote.quote
+	}
+	return results
+}
+
+// ----------------------------------------------------------------
+// Start of the candle the time belongs to in Moscow time
+// ----------------------------------------------------------------
+func candleBegin(at time.Time, interval int) time.Time {
+	day := moscowDay(at)
+	switch interval {
+	case 24:
+		return day
+	case 7:
+		// The weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case 31:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, moscowTime)
+	case 4:
+		return time.Date(day.Year(), day.Month()-(day.Month()-1)%3, 1, 0, 0, 0, 0, moscowTime)
+	default:
+		return day.Add(at.Sub(day).Truncate(candleDuration(interval)))
+	}
+}
+
+// ----------------------------------------------------------------
+// Candles built from the replayed prices up to the current frame
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) FetchCandles(ctx context.Context, asset Asset, interval int, count int) ([]Candle, error) {
+	if candleDuration(interval) == 0 {
+		return nil, fmt.Errorf("unsupported candle interval: %d", interval)
+	}
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	var candles []Candle
+	for _, quote := range provider.history(asset.Ticker) {
+		price := quote.quote.Price
+		begin := candleBegin(quote.time, interval)
+		if len(candles) == 0 || !candles[len(candles)-1].Begin.Equal(begin) {
+			candles = append(candles, Candle{Begin: begin, Open: price, Close: price, High: price, Low: price,
+				Volume: math.NaN(), Value: math.NaN()})
+			continue
+		}
+		candle := &candles[len(candles)-1]
+		candle.Close = price
+		candle.High = math.Max(candle.High, price)
+		candle.Low = math.Min(candle.Low, price)
+	}
+	if len(candles) == 0 {
+		return nil, &AssetNotFoundError{Asset: asset.Ticker}
+	}
+	return candles[max(0, len(candles)-count):], nil
+}
+
+// ----------------------------------------------------------------
+// Volumes of the previous replayed sessions: the last volume and
+// turnover reported on each day
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) FetchVolumes(ctx context.Context, asset Asset, days int) ([]Volume, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	today := moscowDay(provider.now())
+	var volumes []Volume
+	for _, quote := range provider.history(asset.Ticker) {
+		day := moscowDay(quote.time)
+		if !day.Before(today) || math.IsNaN(quote.quote.VolToday) {
+			continue
+		}
+		volume := Volume{Date: day, Volume: quote.quote.VolToday, Value: quote.quote.ValToday}
+		if len(volumes) > 0 && volumes[len(volumes)-1].Date.Equal(day) {
+			volumes[len(volumes)-1] = volume
+		} else {
+			volumes = append(volumes, volume)
+		}
+	}
+	if len(volumes) == 0 {
+		return nil, &AssetNotFoundError{Asset: asset.Ticker}
+	}
+	return volumes[max(0, len(volumes)-days):], nil
+}
+
+// ----------------------------------------------------------------
+// Board of the ticker set in the replay files
+// ----------------------------------------------------------------
+func (provider *ReplayProvider) DetectBoard(ctx context.Context, ticker string) (Asset, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	for _, quote := range provider.quotes[ticker] {
+		if quote.asset.Board != "" {
+			return quote.asset, nil
+		}
+	}
+	return Asset{}, &AssetNotFoundError{Asset: ticker}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+func writeReplayFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// ----------------------------------------------------------------
+func TestNewProvider(t *testing.T) {
+	provider, err := newProvider(ProviderConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := provider.(*MoexRequester); !ok {
+		t.Errorf("expected the MOEX provider by default, got %T", provider)
+	}
+	if _, err := newProvider(ProviderConfig{Exchange: "nyse"}); err == nil {
+		t.Error("expected error for the unknown exchange, got nil")
+	}
+	if _, err := newProvider(ProviderConfig{Exchange: "moex", Source: "quik"}); err == nil {
+		t.Error("expected error for the unknown source, got nil")
+	}
+	if _, err := newProvider(ProviderConfig{Source: "replay"}); err == nil {
+		t.Error("expected error without the replay path, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestReplayProvider_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeReplayFile(t, dir, "2025-03-03.csv", `ticker,time,price,vol_today,board,market,engine
+SBER,2025-03-03 10:00:00,300,1000,TQBR,shares,stock
+GAZP,2025-03-03 10:00:00,150,,,,
+SBER,2025-03-03 10:01:00,305,3000,,,
+`)
+	writeReplayFile(t, dir, "2025-03-04.jsonl", `{"ticker":"SBER","time":"2025-03-04T10:00:00+03:00","price":310,"open":309,"vol_today":500}
+
+{"ticker":"GAZP","time":"2025-03-04T10:00:00+03:00","price":155}
+`)
+	writeReplayFile(t, dir, "README.md", "ignored")
+
+	provider, err := newProvider(ProviderConfig{Source: "replay", Path: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	assets := []Asset{{Ticker: "SBER"}, {Ticker: "GAZP"}, {Ticker: "LKOH"}}
+	prices := func() []float64 {
+		quotes := provider.FetchPrices(ctx, assets)
+		if quotes["LKOH"].Err == nil {
+			t.Errorf("expected error for the unknown ticker, got %+v", quotes["LKOH"])
+		}
+		return []float64{quotes["SBER"].Price, quotes["GAZP"].Price}
+	}
+
+	// Each batch moves to the next timestamp, the last one is kept
+	expected := [][]float64{{300, 150}, {305, 150}, {310, 155}, {310, 155}}
+	for i, want := range expected {
+		if got := prices(); got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("frame %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	quote := provider.FetchPrices(ctx, assets[:1])["SBER"]
+	if quote.Open != 309 || quote.VolToday != 500 || !math.IsNaN(quote.High) {
+		t.Errorf("unexpected quote: %+v", quote)
+	}
+	if price, err := provider.FetchPrice(ctx, "GAZP", "stock"); err != nil || price != 155 {
+		t.Errorf("unexpected price: %v, %v", price, err)
+	}
+
+	candles, err := provider.FetchCandles(ctx, Asset{Ticker: "SBER"}, 24, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candles) != 2 || candles[0].Open != 300 || candles[0].Close != 305 || candles[0].High != 305 || candles[1].Close != 310 {
+		t.Errorf("unexpected candles: %+v", candles)
+	}
+	volumes, err := provider.FetchVolumes(ctx, Asset{Ticker: "SBER"}, 5)
+	if err != nil || len(volumes) != 1 || volumes[0].Volume != 3000 {
+		t.Errorf("unexpected volumes: %+v, %v", volumes, err)
+	}
+
+	board, err := provider.DetectBoard(ctx, "SBER")
+	if err != nil || board.Board != "TQBR" || board.Market != "shares" || board.Engine != "stock" {
+		t.Errorf("unexpected board: %+v, %v", board, err)
+	}
+	if _, err := provider.DetectBoard(ctx, "GAZP"); err == nil {
+		t.Error("expected error without the board, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestReplayProvider_Loop(t *testing.T) {
+	path := writeReplayFile(t, t.TempDir(), "quotes.jsonl", `{"ticker":"SBER","time":"2025-03-03 10:00:00","price":300}
+{"ticker":"SBER","time":"2025-03-03 10:01:00","price":301}
+`)
+	provider, err := newProvider(ProviderConfig{Source: "replay", Path: path, Loop: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []float64
+	for range 3 {
+		got = append(got, provider.FetchPrices(context.Background(), []Asset{{Ticker: "SBER"}})["SBER"].Price)
+	}
+	if got[0] != 300 || got[1] != 301 || got[2] != 300 {
+		t.Errorf("expected the replay to start over, got %v", got)
+	}
+
+	// The replay runs on the time of its quotes
+	if now := providerNow(provider); !isReplay(provider) || !now.Equal(time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime)) {
+		t.Errorf("expected the time of the replayed quotes, got %s", now)
+	}
+	if now := providerNow(&MoexRequester{}); isReplay(&MoexRequester{}) || time.Since(now) > time.Minute {
+		t.Errorf("expected the wall clock, got %s", now)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestReplayProvider_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"noprice.jsonl": `{"ticker":"SBER","time":"2025-03-03 10:00:00"}`,
+		"badtime.csv":   "ticker,time,price\nSBER,today,300\n",
+		"badprice.csv":  "ticker,time,price\nSBER,2025-03-03 10:00:00,cheap\n",
+		"quotes.txt":    "SBER 300",
+		"empty.csv":     "ticker,time,price\n",
+	} {
+		path := writeReplayFile(t, dir, name, content)
+		if _, err := newProvider(ProviderConfig{Source: "replay", Path: path}); err == nil {
+			t.Errorf("expected error for %s, got nil", name)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestCandleBegin(t *testing.T) {
+	// 2025-03-05 is Wednesday
+	at := time.Date(2025, 3, 5, 10, 17, 0, 0, moscowTime)
+	tests := []struct {
+		interval int
+		expected string
+	}{
+		{1, "2025-03-05 10:17"},
+		{10, "2025-03-05 10:10"},
+		{60, "2025-03-05 10:00"},
+		{24, "2025-03-05 00:00"},
+		{7, "2025-03-03 00:00"},
+		{31, "2025-03-01 00:00"},
+		{4, "2025-01-01 00:00"},
+	}
+	for _, test := range tests {
+		if begin := candleBegin(at, test.interval).Format("2006-01-02 15:04"); begin != test.expected {
+			t.Errorf("expected %s for interval %d, got %s", test.expected, test.interval, begin)
+		}
+	}
+}
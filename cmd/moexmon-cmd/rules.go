@@ -0,0 +1,612 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+// Action to take on the watchlist item after the evaluation
+// ----------------------------------------------------------------
+type ruleAction int
+
+const (
+	ruleIdle  ruleAction = iota // nothing to do
+	ruleFire                    // the condition is met, send the alert
+	ruleRearm                   // the crossing rule may fire again
+)
+
+// ----------------------------------------------------------------
+// Watchlist rule condition: the value computed from the quote is
+// compared with the item's target (or zero for session extremes,
+// indicator crossings and bands)
+// ----------------------------------------------------------------
+type moexCondition struct {
+	above       bool   // fire when the value is above the threshold
+	session     bool   // fire when the price reaches the session extreme
+	cross       bool   // fire when the value crosses zero since the previous candle
+	band        bool   // fire when the price leaves the band
+	pair        string // operator joining the legs of the pair rules, empty otherwise
+	description string // human readable name of the value
+	unit        string
+	value       func(quote Quote) (float64, bool)
+
+	// Indicator conditions: the current and previous values computed from
+	// the candles, and the number of candles needed to compute them
+	indicator func(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool)
+	candles   func(params godfather.MOEXRuleParams) int
+	// Activity conditions: the number of previous sessions needed to
+	// compute the average volume
+	volumes func(params godfather.MOEXRuleParams) int
+	// Reminder conditions: the date reminded of, zero if unknown, the
+	// value is the number of days left to it
+	date func(quote Quote) time.Time
+}
+
+// ----------------------------------------------------------------
+func (condition moexCondition) zeroThreshold() bool {
+	return condition.session || condition.cross || condition.band
+}
+
+// ----------------------------------------------------------------
+// Price in money, the bonds are quoted in % of the face value
+// ----------------------------------------------------------------
+func lastPrice(quote Quote) (float64, bool) {
+	if quote.Bond == nil {
+		return quote.Price, true
+	}
+	if !(quote.Bond.FaceValue > 0) {
+		return 0, false
+	}
+	return quote.Price * quote.Bond.FaceValue / 100, true
+}
+
+// ----------------------------------------------------------------
+func dailyChange(quote Quote) (float64, bool) {
+	return quote.ChangePct, !math.IsNaN(quote.ChangePct)
+}
+
+// ----------------------------------------------------------------
+func changeFromOpen(quote Quote) (float64, bool) {
+	// Written this way to reject NaN as well
+	if !(quote.Open > 0) {
+		return 0, false
+	}
+	return (quote.Price - quote.Open) / quote.Open * 100, true
+}
+
+// ----------------------------------------------------------------
+func distanceFromHigh(quote Quote) (float64, bool) {
+	if !(quote.High > 0) {
+		return 0, false
+	}
+	return quote.Price - quote.High, true
+}
+
+// ----------------------------------------------------------------
+func distanceFromLow(quote Quote) (float64, bool) {
+	if !(quote.Low > 0) {
+		return 0, false
+	}
+	return quote.Price - quote.Low, true
+}
+
+// ----------------------------------------------------------------
+func distanceFromVWAP(quote Quote) (float64, bool) {
+	if !(quote.WAPrice > 0) {
+		return 0, false
+	}
+	return (quote.Price - quote.WAPrice) / quote.WAPrice * 100, true
+}
+
+// ----------------------------------------------------------------
+func candleCloses(item godfather.MOEXWatchlistItem, quote Quote) []float64 {
+	candles := quote.Candles[item.Params.CandleInterval]
+	closes := make([]float64, len(candles))
+	for i, candle := range candles {
+		closes[i] = candle.Close
+	}
+	return closes
+}
+
+// ----------------------------------------------------------------
+// The last and the previous values of the series, if both are known
+// ----------------------------------------------------------------
+func lastTwo(series []float64) (float64, float64, bool) {
+	n := len(series)
+	if n < 2 || math.IsNaN(series[n-1]) || math.IsNaN(series[n-2]) {
+		return 0, 0, false
+	}
+	return series[n-1], series[n-2], true
+}
+
+// ----------------------------------------------------------------
+func spread(fast []float64, slow []float64) []float64 {
+	result := make([]float64, len(fast))
+	for i := range fast {
+		result[i] = fast[i] - slow[i]
+	}
+	return result
+}
+
+// ----------------------------------------------------------------
+func smaSpread(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	closes := candleCloses(item, quote)
+	return lastTwo(spread(sma(closes, item.Params.FastPeriod), sma(closes, item.Params.SlowPeriod)))
+}
+
+// ----------------------------------------------------------------
+func emaSpread(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	closes := candleCloses(item, quote)
+	return lastTwo(spread(ema(closes, item.Params.FastPeriod), ema(closes, item.Params.SlowPeriod)))
+}
+
+// ----------------------------------------------------------------
+func rsiIndicator(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	return lastTwo(rsi(candleCloses(item, quote), item.Params.Period))
+}
+
+// ----------------------------------------------------------------
+func bollingerWidth(params godfather.MOEXRuleParams) float64 {
+	if params.BandWidth > 0 {
+		return params.BandWidth
+	}
+	return 2
+}
+
+// ----------------------------------------------------------------
+func aboveUpperBand(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	_, upper, _ := bollinger(candleCloses(item, quote), item.Params.Period, bollingerWidth(item.Params))
+	band, previous, ok := lastTwo(upper)
+	return quote.Price - band, previous, ok
+}
+
+// ----------------------------------------------------------------
+func belowLowerBand(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	_, _, lower := bollinger(candleCloses(item, quote), item.Params.Period, bollingerWidth(item.Params))
+	band, previous, ok := lastTwo(lower)
+	return quote.Price - band, previous, ok
+}
+
+// ----------------------------------------------------------------
+func maCrossCandles(params godfather.MOEXRuleParams) int {
+	if params.FastPeriod <= 0 || params.SlowPeriod <= 0 {
+		return 0
+	}
+	return max(params.FastPeriod, params.SlowPeriod) + 1
+}
+
+// ----------------------------------------------------------------
+// EMA and RSI need a longer history to converge
+// ----------------------------------------------------------------
+func emaCrossCandles(params godfather.MOEXRuleParams) int {
+	return 3 * maCrossCandles(params)
+}
+
+// ----------------------------------------------------------------
+func rsiCandles(params godfather.MOEXRuleParams) int {
+	if params.Period <= 0 {
+		return 0
+	}
+	return 3*params.Period + 1
+}
+
+// ----------------------------------------------------------------
+func bollingerCandles(params godfather.MOEXRuleParams) int {
+	if params.Period <= 0 {
+		return 0
+	}
+	return params.Period + 1
+}
+
+// ----------------------------------------------------------------
+func lookbackDays(params godfather.MOEXRuleParams) int {
+	if params.LookbackDays > 0 {
+		return params.LookbackDays
+	}
+	return 20
+}
+
+// ----------------------------------------------------------------
+// Ratio of today's activity to the average over the lookback days
+// ----------------------------------------------------------------
+func activityRatio(item godfather.MOEXWatchlistItem, quote Quote, today float64, daily func(Volume) float64) (float64, float64, bool) {
+	days := lookbackDays(item.Params)
+	if len(quote.Volumes) < days || math.IsNaN(today) {
+		return 0, 0, false
+	}
+	sum := 0.0
+	for _, volume := range quote.Volumes[len(quote.Volumes)-days:] {
+		sum += daily(volume)
+	}
+	// Written this way to reject NaN as well
+	if !(sum > 0) {
+		return 0, 0, false
+	}
+	return today / (sum / float64(days)), 0, true
+}
+
+// ----------------------------------------------------------------
+func volumeSpike(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	return activityRatio(item, quote, quote.VolToday, func(volume Volume) float64 { return volume.Volume })
+}
+
+// ----------------------------------------------------------------
+func turnoverSpike(item godfather.MOEXWatchlistItem, quote Quote) (float64, float64, bool) {
+	return activityRatio(item, quote, quote.ValToday, func(volume Volume) float64 { return volume.Value })
+}
+
+// ----------------------------------------------------------------
+// The prices of both legs of the pair rule, if both are known
+// ----------------------------------------------------------------
+func pairPrices(quote Quote) (float64, float64, bool) {
+	if quote.Second == nil || math.IsNaN(quote.Price) || math.IsNaN(quote.Second.Price) {
+		return 0, 0, false
+	}
+	return quote.Price, quote.Second.Price, true
+}
+
+// ----------------------------------------------------------------
+func pairSpread(quote Quote) (float64, bool) {
+	first, second, ok := pairPrices(quote)
+	return first - second, ok
+}
+
+// ----------------------------------------------------------------
+func pairRatio(quote Quote) (float64, bool) {
+	first, second, ok := pairPrices(quote)
+	if !ok || second <= 0 {
+		return 0, false
+	}
+	return first / second, true
+}
+
+// ----------------------------------------------------------------
+// Spread in percent of the second leg's price
+// ----------------------------------------------------------------
+func pairSpreadPct(quote Quote) (float64, bool) {
+	first, second, ok := pairPrices(quote)
+	if !ok || second <= 0 {
+		return 0, false
+	}
+	return (first - second) / second * 100, true
+}
+
+// ----------------------------------------------------------------
+func bondPricePct(quote Quote) (float64, bool) {
+	return quote.Price, quote.Bond != nil
+}
+
+// ----------------------------------------------------------------
+func bondYield(quote Quote) (float64, bool) {
+	if quote.Bond == nil {
+		return 0, false
+	}
+	return quote.Bond.Yield, !math.IsNaN(quote.Bond.Yield)
+}
+
+// ----------------------------------------------------------------
+// Annual coupon in % of the bond's price
+// ----------------------------------------------------------------
+func currentYield(quote Quote) (float64, bool) {
+	if quote.Bond == nil || math.IsNaN(quote.Bond.CouponPercent) || !(quote.Price > 0) {
+		return 0, false
+	}
+	return quote.Bond.CouponPercent / quote.Price * 100, true
+}
+
+// ----------------------------------------------------------------
+func accruedInterest(quote Quote) (float64, bool) {
+	if quote.Bond == nil {
+		return 0, false
+	}
+	return quote.Bond.AccruedInt, !math.IsNaN(quote.Bond.AccruedInt)
+}
+
+// ----------------------------------------------------------------
+// Duration in years, ISS reports it in days
+// ----------------------------------------------------------------
+func bondDuration(quote Quote) (float64, bool) {
+	if quote.Bond == nil || math.IsNaN(quote.Bond.Duration) {
+		return 0, false
+	}
+	return quote.Bond.Duration / 365, true
+}
+
+// ----------------------------------------------------------------
+func maturityDate(quote Quote) time.Time {
+	if quote.Bond == nil {
+		return time.Time{}
+	}
+	return quote.Bond.MaturityDate
+}
+
+// ----------------------------------------------------------------
+func offerDate(quote Quote) time.Time {
+	if quote.Bond == nil {
+		return time.Time{}
+	}
+	return quote.Bond.OfferDate
+}
+
+// ----------------------------------------------------------------
+func expirationDate(quote Quote) time.Time {
+	if quote.Futures == nil {
+		return time.Time{}
+	}
+	return quote.Futures.LastTradeDate
+}
+
+// ----------------------------------------------------------------
+// Number of days from today to the date, if it is set and not past
+// ----------------------------------------------------------------
+func daysUntil(date time.Time, now time.Time) (float64, bool) {
+	if date.IsZero() {
+		return 0, false
+	}
+	now = now.In(moscowTime)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, moscowTime)
+	days := math.Round(date.Sub(today).Hours() / 24)
+	if days < 0 {
+		return 0, false
+	}
+	return days, true
+}
+
+// ----------------------------------------------------------------
+func openInterest(quote Quote) (float64, bool) {
+	if quote.Futures == nil {
+		return 0, false
+	}
+	return quote.Futures.OpenInterest, !math.IsNaN(quote.Futures.OpenInterest)
+}
+
+// ----------------------------------------------------------------
+// Change of the open interest since the previous session in %
+// ----------------------------------------------------------------
+func openInterestChange(quote Quote) (float64, bool) {
+	if quote.Futures == nil || math.IsNaN(quote.Futures.OpenInterest) || !(quote.Futures.PrevOpenInterest > 0) {
+		return 0, false
+	}
+	return (quote.Futures.OpenInterest - quote.Futures.PrevOpenInterest) / quote.Futures.PrevOpenInterest * 100, true
+}
+
+// Supported conditions, must be kept in sync with moex_watchlist_condition_check
+var moexConditions = map[string]moexCondition{
+	"above":               {above: true, description: "price", value: lastPrice},
+	"below":               {above: false, description: "price", value: lastPrice},
+	"change_above":        {above: true, description: "daily change", unit: "%", value: dailyChange},
+	"change_below":        {above: false, description: "daily change", unit: "%", value: dailyChange},
+	"open_change_above":   {above: true, description: "change from open", unit: "%", value: changeFromOpen},
+	"open_change_below":   {above: false, description: "change from open", unit: "%", value: changeFromOpen},
+	"new_high":            {above: true, session: true, description: "session high", value: distanceFromHigh},
+	"new_low":             {above: false, session: true, description: "session low", value: distanceFromLow},
+	"vwap_above":          {above: true, description: "distance from VWAP", unit: "%", value: distanceFromVWAP},
+	"vwap_below":          {above: false, description: "distance from VWAP", unit: "%", value: distanceFromVWAP},
+	"sma_cross_above":     {above: true, cross: true, description: "SMA", indicator: smaSpread, candles: maCrossCandles},
+	"sma_cross_below":     {above: false, cross: true, description: "SMA", indicator: smaSpread, candles: maCrossCandles},
+	"ema_cross_above":     {above: true, cross: true, description: "EMA", indicator: emaSpread, candles: emaCrossCandles},
+	"ema_cross_below":     {above: false, cross: true, description: "EMA", indicator: emaSpread, candles: emaCrossCandles},
+	"rsi_above":           {above: true, description: "RSI", indicator: rsiIndicator, candles: rsiCandles},
+	"rsi_below":           {above: false, description: "RSI", indicator: rsiIndicator, candles: rsiCandles},
+	"bollinger_above":     {above: true, band: true, description: "upper Bollinger band", indicator: aboveUpperBand, candles: bollingerCandles},
+	"bollinger_below":     {above: false, band: true, description: "lower Bollinger band", indicator: belowLowerBand, candles: bollingerCandles},
+	"volume_spike":        {above: true, description: "volume", indicator: volumeSpike, volumes: lookbackDays},
+	"turnover_spike":      {above: true, description: "turnover", indicator: turnoverSpike, volumes: lookbackDays},
+	"spread_above":        {above: true, pair: "-", description: "spread", value: pairSpread},
+	"spread_below":        {above: false, pair: "-", description: "spread", value: pairSpread},
+	"ratio_above":         {above: true, pair: "/", description: "ratio", value: pairRatio},
+	"ratio_below":         {above: false, pair: "/", description: "ratio", value: pairRatio},
+	"spread_pct_above":    {above: true, pair: "-", description: "spread", unit: "%", value: pairSpreadPct},
+	"spread_pct_below":    {above: false, pair: "-", description: "spread", unit: "%", value: pairSpreadPct},
+	"price_pct_above":     {above: true, description: "price", unit: "% of the face value", value: bondPricePct},
+	"price_pct_below":     {above: false, description: "price", unit: "% of the face value", value: bondPricePct},
+	"yield_above":         {above: true, description: "yield to maturity", unit: "%", value: bondYield},
+	"yield_below":         {above: false, description: "yield to maturity", unit: "%", value: bondYield},
+	"current_yield_above": {above: true, description: "current yield", unit: "%", value: currentYield},
+	"current_yield_below": {above: false, description: "current yield", unit: "%", value: currentYield},
+	"accrued_above":       {above: true, description: "accrued interest", value: accruedInterest},
+	"accrued_below":       {above: false, description: "accrued interest", value: accruedInterest},
+	"duration_above":      {above: true, description: "duration", unit: " years", value: bondDuration},
+	"duration_below":      {above: false, description: "duration", unit: " years", value: bondDuration},
+	"maturity_within":     {above: false, description: "maturity", date: maturityDate},
+	"offer_within":        {above: false, description: "offer", date: offerDate},
+	"oi_above":            {above: true, description: "open interest", value: openInterest},
+	"oi_below":            {above: false, description: "open interest", value: openInterest},
+	"oi_change_above":     {above: true, description: "open interest change", unit: "%", value: openInterestChange},
+	"oi_change_below":     {above: false, description: "open interest change", unit: "%", value: openInterestChange},
+	"expiration_within":   {above: false, description: "expiration", date: expirationDate},
+}
+
+// ----------------------------------------------------------------
+// State of the item's condition given the current quote
+// ----------------------------------------------------------------
+type conditionState struct {
+	condition moexCondition
+	value     float64
+	previous  float64 // only for the indicator conditions
+	threshold float64
+}
+
+// ----------------------------------------------------------------
+// The date conditions count the days from now, the evaluation time
+// ----------------------------------------------------------------
+func conditionValue(item godfather.MOEXWatchlistItem, quote Quote, now time.Time) (conditionState, bool) {
+	state := conditionState{threshold: item.TargetPrice}
+	condition, known := moexConditions[item.Condition]
+	if !known {
+		slog.Warn(fmt.Sprintf("Unknown condition '%s' for watchlist item %d", item.Condition, item.ID))
+		return state, false
+	}
+	state.condition = condition
+
+	var ok bool
+	switch {
+	case condition.indicator != nil:
+		state.value, state.previous, ok = condition.indicator(item, quote)
+	case condition.date != nil:
+		state.value, ok = daysUntil(condition.date(quote), now)
+	default:
+		state.value, ok = condition.value(quote)
+	}
+	if !ok {
+		slog.Debug(fmt.Sprintf("No %s available for %s", condition.description, item.Ticker))
+		return state, false
+	}
+	if condition.zeroThreshold() {
+		state.threshold = 0
+	}
+	return state, true
+}
+
+// ----------------------------------------------------------------
+func conditionMatch(item godfather.MOEXWatchlistItem, quote Quote, now time.Time) bool {
+	state, ok := conditionValue(item, quote, now)
+	switch {
+	case !ok:
+		return false
+	case state.condition.cross && state.condition.above:
+		return state.value > 0 && state.previous <= 0
+	case state.condition.cross:
+		return state.value < 0 && state.previous >= 0
+	case state.condition.session && state.condition.above:
+		return state.value >= state.threshold
+	case state.condition.session || state.condition.date != nil:
+		return state.value <= state.threshold
+	case state.condition.above:
+		return state.value > state.threshold
+	default:
+		return state.value < state.threshold
+	}
+}
+
+// ----------------------------------------------------------------
+// Check whether the value moved back past the hysteresis band
+// ----------------------------------------------------------------
+func rearmMatch(item godfather.MOEXWatchlistItem, quote Quote, now time.Time) bool {
+	state, ok := conditionValue(item, quote, now)
+	switch {
+	case !ok:
+		return false
+	case state.condition.above:
+		return state.value < state.threshold-item.Hysteresis
+	default:
+		return state.value > state.threshold+item.Hysteresis
+	}
+}
+
+// ----------------------------------------------------------------
+// Human readable description of the item's condition for alerts
+// ----------------------------------------------------------------
+func describeCondition(item godfather.MOEXWatchlistItem) string {
+	condition, known := moexConditions[item.Condition]
+	direction := "below"
+	if condition.above {
+		direction = "above"
+	}
+	switch {
+	case !known:
+		return fmt.Sprintf("The price for %s is %s %.2f", item.Ticker, item.Condition, item.TargetPrice)
+	case condition.date != nil:
+		return fmt.Sprintf("The %s of %s is within %.0f days", condition.description, item.Ticker, item.TargetPrice)
+	case condition.pair != "":
+		return fmt.Sprintf("The %s %s %s %s is %s %.2f%s", condition.description, item.Ticker, condition.pair, item.Pair.Ticker,
+			direction, item.TargetPrice, condition.unit)
+	case condition.session:
+		return fmt.Sprintf("%s reached a new %s", item.Ticker, condition.description)
+	case condition.cross:
+		return fmt.Sprintf("The %s(%d) for %s crossed %s %s(%d)", condition.description, item.Params.FastPeriod,
+			item.Ticker, direction, condition.description, item.Params.SlowPeriod)
+	case condition.band:
+		return fmt.Sprintf("The price for %s is %s the %s", item.Ticker, direction, condition.description)
+	case condition.volumes != nil:
+		return fmt.Sprintf("The %s today for %s is above %.2fx the %d-day average", condition.description, item.Ticker,
+			item.TargetPrice, lookbackDays(item.Params))
+	case condition.indicator != nil:
+		return fmt.Sprintf("The %s(%d) for %s is %s %.2f", condition.description, item.Params.Period, item.Ticker, direction, item.TargetPrice)
+	default:
+		return fmt.Sprintf("The %s for %s is %s %.2f%s", condition.description, item.Ticker, direction, item.TargetPrice, condition.unit)
+	}
+}
+
+// ----------------------------------------------------------------
+// Check whether the rule's deadline (Moscow time of day) has passed
+// ----------------------------------------------------------------
+func pastDeadline(item godfather.MOEXWatchlistItem, now time.Time) bool {
+	if item.Params.Deadline == 0 {
+		return false
+	}
+	now = now.In(moscowTime)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, moscowTime)
+	return now.Sub(midnight) >= item.Params.Deadline
+}
+
+// ----------------------------------------------------------------
+// Check whether the rule is within its validity period
+// ----------------------------------------------------------------
+func withinValidity(item godfather.MOEXWatchlistItem, now time.Time) bool {
+	if !item.ValidFrom.IsZero() && now.Before(item.ValidFrom) {
+		return false
+	}
+	return item.ValidUntil.IsZero() || now.Before(item.ValidUntil)
+}
+
+// ----------------------------------------------------------------
+// Check whether the rule's schedule allows it to fire now, the rule
+// with an invalid schedule never fires
+// ----------------------------------------------------------------
+func onSchedule(item godfather.MOEXWatchlistItem, now time.Time) bool {
+	if item.Schedule == "" {
+		return true
+	}
+	schedule, err := parseCron(item.Schedule)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Watchlist item %d for %s has an invalid schedule", item.ID, item.Ticker), "error", err)
+		return false
+	}
+	return schedule.matches(now)
+}
+
+// ----------------------------------------------------------------
+// Decide what to do with the watchlist item given the current quote.
+// One-shot rules fire whenever the condition is met; crossing rules
+// fire only when armed and out of the cooldown, and are re-armed
+// once the price leaves the hysteresis band around the target.
+// No rule fires after its deadline, out of its validity period or
+// its schedule.
+// ----------------------------------------------------------------
+func evaluateRule(item godfather.MOEXWatchlistItem, quote Quote, now time.Time) ruleAction {
+	crossing := item.Mode == godfather.MOEXRuleCrossing
+	if crossing && !item.Armed {
+		if rearmMatch(item, quote, now) {
+			return ruleRearm
+		}
+		return ruleIdle
+	}
+
+	if !conditionMatch(item, quote, now) {
+		return ruleIdle
+	}
+	if pastDeadline(item, now) {
+		slog.Debug(fmt.Sprintf("Watchlist item %d for %s is past its deadline", item.ID, item.Ticker))
+		return ruleIdle
+	}
+	if !withinValidity(item, now) {
+		slog.Debug(fmt.Sprintf("Watchlist item %d for %s is out of its validity period", item.ID, item.Ticker))
+		return ruleIdle
+	}
+	if !onSchedule(item, now) {
+		slog.Debug(fmt.Sprintf("Watchlist item %d for %s is out of its schedule", item.ID, item.Ticker))
+		return ruleIdle
+	}
+	if crossing && !item.LastTriggeredAt.IsZero() && now.Sub(item.LastTriggeredAt) < item.Cooldown {
+		slog.Debug(fmt.Sprintf("Watchlist item %d for %s is in cooldown until %s", item.ID, item.Ticker,
+			item.LastTriggeredAt.Add(item.Cooldown).Format(time.RFC3339)))
+		return ruleIdle
+	}
+	return ruleFire
+}
@@ -0,0 +1,692 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/godfather/internal/godfather"
+)
+
+// ----------------------------------------------------------------
+func TestConditionMatch_AboveConditionMet(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "AAPL",
+		AssetClass:  "stock",
+		Condition:   "above",
+		TargetPrice: 100.0,
+	}
+	result := conditionMatch(item, Quote{Price: 150.0}, time.Now())
+	if !result {
+		t.Errorf("Expected true for price above target")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_AboveConditionNotMet(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "AAPL",
+		AssetClass:  "stock",
+		Condition:   "above",
+		TargetPrice: 200.0,
+	}
+	result := conditionMatch(item, Quote{Price: 150.0}, time.Now())
+	if result {
+		t.Errorf("Expected false for price not above target")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_BelowConditionMet(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "AAPL",
+		AssetClass:  "stock",
+		Condition:   "below",
+		TargetPrice: 200.0,
+	}
+	result := conditionMatch(item, Quote{Price: 150.0}, time.Now())
+	if !result {
+		t.Errorf("Expected true for price below target")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_BelowConditionNotMet(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "AAPL",
+		AssetClass:  "stock",
+		Condition:   "below",
+		TargetPrice: 100.0,
+	}
+	result := conditionMatch(item, Quote{Price: 150.0}, time.Now())
+	if result {
+		t.Errorf("Expected false for price not below target")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_UnknownCondition(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "AAPL",
+		AssetClass:  "stock",
+		Condition:   "unknown",
+		TargetPrice: 100.0,
+	}
+	result := conditionMatch(item, Quote{Price: 150.0}, time.Now())
+	if result {
+		t.Errorf("Expected false for unknown condition")
+	}
+}
+
+// ----------------------------------------------------------------
+func crossingItem() godfather.MOEXWatchlistItem {
+	return godfather.MOEXWatchlistItem{
+		ID:          1,
+		Ticker:      "SBER",
+		AssetClass:  "stock",
+		Condition:   "above",
+		TargetPrice: 300.0,
+		Active:      true,
+		Mode:        godfather.MOEXRuleCrossing,
+		Hysteresis:  5.0,
+		Cooldown:    time.Hour,
+		Armed:       true,
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_OneShotFires(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: "above", TargetPrice: 300.0, Mode: godfather.MOEXRuleOneShot}
+	if action := evaluateRule(item, Quote{Price: 310.0}, time.Now()); action != ruleFire {
+		t.Errorf("Expected ruleFire, got %v", action)
+	}
+	if action := evaluateRule(item, Quote{Price: 290.0}, time.Now()); action != ruleIdle {
+		t.Errorf("Expected ruleIdle, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_CrossingArmedFires(t *testing.T) {
+	item := crossingItem()
+	if action := evaluateRule(item, Quote{Price: 301.0}, time.Now()); action != ruleFire {
+		t.Errorf("Expected ruleFire, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_CrossingDisarmedDoesNotFire(t *testing.T) {
+	item := crossingItem()
+	item.Armed = false
+	item.LastTriggeredAt = time.Now().Add(-2 * time.Hour)
+	if action := evaluateRule(item, Quote{Price: 320.0}, time.Now()); action != ruleIdle {
+		t.Errorf("Expected ruleIdle, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_CrossingRearmsOutsideHysteresis(t *testing.T) {
+	item := crossingItem()
+	item.Armed = false
+
+	// Inside the hysteresis band: stays disarmed
+	if action := evaluateRule(item, Quote{Price: 296.0}, time.Now()); action != ruleIdle {
+		t.Errorf("Expected ruleIdle inside the band, got %v", action)
+	}
+	// Past the band: re-armed
+	if action := evaluateRule(item, Quote{Price: 294.0}, time.Now()); action != ruleRearm {
+		t.Errorf("Expected ruleRearm past the band, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_CrossingBelowRearm(t *testing.T) {
+	item := crossingItem()
+	item.Condition = "below"
+	item.Armed = false
+	if action := evaluateRule(item, Quote{Price: 304.0}, time.Now()); action != ruleIdle {
+		t.Errorf("Expected ruleIdle inside the band, got %v", action)
+	}
+	if action := evaluateRule(item, Quote{Price: 306.0}, time.Now()); action != ruleRearm {
+		t.Errorf("Expected ruleRearm past the band, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_CrossingCooldown(t *testing.T) {
+	now := time.Now()
+	item := crossingItem()
+	item.LastTriggeredAt = now.Add(-30 * time.Minute)
+	if action := evaluateRule(item, Quote{Price: 310.0}, now); action != ruleIdle {
+		t.Errorf("Expected ruleIdle during cooldown, got %v", action)
+	}
+
+	item.LastTriggeredAt = now.Add(-61 * time.Minute)
+	if action := evaluateRule(item, Quote{Price: 310.0}, now); action != ruleFire {
+		t.Errorf("Expected ruleFire after cooldown, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func sessionQuote() Quote {
+	return Quote{
+		Price:     105.0,
+		Open:      100.0,
+		High:      105.0,
+		Low:       98.0,
+		WAPrice:   102.0,
+		ChangePct: 3.5,
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_SessionStatistics(t *testing.T) {
+	tests := []struct {
+		condition string
+		target    float64
+		expected  bool
+	}{
+		{"change_above", 3.0, true},
+		{"change_above", 4.0, false},
+		{"change_below", -1.0, false},
+		{"change_below", 4.0, true},
+		{"open_change_above", 4.9, true},
+		{"open_change_above", 5.1, false},
+		{"open_change_below", 5.1, true},
+		{"new_high", 0, true},
+		{"new_low", 0, false},
+		{"vwap_above", 2.9, true},
+		{"vwap_above", 3.0, false},
+		{"vwap_below", 0, false},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: test.condition, TargetPrice: test.target}
+		if result := conditionMatch(item, sessionQuote(), time.Now()); result != test.expected {
+			t.Errorf("%s %.2f: expected %t, got %t", test.condition, test.target, test.expected, result)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_MissingStatistics(t *testing.T) {
+	quote := Quote{Price: 105.0, Open: math.NaN(), High: math.NaN(), Low: math.NaN(), WAPrice: math.NaN(), ChangePct: math.NaN()}
+	for _, condition := range []string{"change_above", "change_below", "open_change_above", "new_high", "new_low", "vwap_below"} {
+		item := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: condition, TargetPrice: 1000.0}
+		if conditionMatch(item, quote, time.Now()) {
+			t.Errorf("%s: expected false when the statistic is not available", condition)
+		}
+		if rearmMatch(item, quote, time.Now()) {
+			t.Errorf("%s: expected no re-arm when the statistic is not available", condition)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_NewHighRearm(t *testing.T) {
+	item := crossingItem()
+	item.Condition = "new_high"
+	item.Hysteresis = 2.0
+	item.Armed = false
+
+	quote := sessionQuote()
+	quote.Price = 104.0
+	if action := evaluateRule(item, quote, time.Now()); action != ruleIdle {
+		t.Errorf("Expected ruleIdle close to the high, got %v", action)
+	}
+	quote.Price = 102.5
+	if action := evaluateRule(item, quote, time.Now()); action != ruleRearm {
+		t.Errorf("Expected ruleRearm past the band, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeCondition(t *testing.T) {
+	tests := []struct {
+		item     godfather.MOEXWatchlistItem
+		expected string
+	}{
+		{godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: "above", TargetPrice: 300}, "The price for SBER is above 300.00"},
+		{godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: "change_below", TargetPrice: -5}, "The daily change for SBER is below -5.00%"},
+		{godfather.MOEXWatchlistItem{Ticker: "GAZP", Condition: "new_low"}, "GAZP reached a new session low"},
+	}
+	for _, test := range tests {
+		if text := describeCondition(test.item); text != test.expected {
+			t.Errorf("expected '%s', got '%s'", test.expected, text)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func candlesQuote(price float64, closes ...float64) Quote {
+	candles := make([]Candle, len(closes))
+	for i, value := range closes {
+		candles[i] = Candle{Close: value}
+	}
+	return Quote{Price: price, Candles: map[int][]Candle{24: candles}}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_SMACross(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:    "SBER",
+		Condition: "sma_cross_above",
+		Params:    godfather.MOEXRuleParams{CandleInterval: 24, FastPeriod: 2, SlowPeriod: 3},
+	}
+	// Fast SMA goes from below the slow one to above it on the last candle
+	crossing := candlesQuote(10, 10, 9, 8, 12)
+	if !conditionMatch(item, crossing, time.Now()) {
+		t.Error("Expected the fast SMA to cross above the slow one")
+	}
+	// Fast SMA already above the slow one: no crossing
+	above := candlesQuote(10, 8, 9, 10, 11)
+	if conditionMatch(item, above, time.Now()) {
+		t.Error("Expected no crossing when the fast SMA was already above")
+	}
+
+	item.Condition = "sma_cross_below"
+	if conditionMatch(item, crossing, time.Now()) {
+		t.Error("Expected no crossing below")
+	}
+	if !conditionMatch(item, candlesQuote(10, 8, 9, 10, 6), time.Now()) {
+		t.Error("Expected the fast SMA to cross below the slow one")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_EMACross(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:    "SBER",
+		Condition: "ema_cross_above",
+		Params:    godfather.MOEXRuleParams{CandleInterval: 24, FastPeriod: 2, SlowPeriod: 3},
+	}
+	if !conditionMatch(item, candlesQuote(10, 10, 9, 8, 7, 12), time.Now()) {
+		t.Error("Expected the fast EMA to cross above the slow one")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_RSI(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "SBER",
+		Condition:   "rsi_below",
+		TargetPrice: 30,
+		Params:      godfather.MOEXRuleParams{CandleInterval: 24, Period: 3},
+	}
+	falling := candlesQuote(5, 10, 9, 8, 7, 6, 5)
+	if !conditionMatch(item, falling, time.Now()) {
+		t.Error("Expected RSI below 30 for falling prices")
+	}
+	item.Condition = "rsi_above"
+	item.TargetPrice = 70
+	if conditionMatch(item, falling, time.Now()) {
+		t.Error("Expected RSI not above 70 for falling prices")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_Bollinger(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:    "SBER",
+		Condition: "bollinger_above",
+		Params:    godfather.MOEXRuleParams{CandleInterval: 24, Period: 8},
+	}
+	// Bands are 1..9 on the last candle
+	quote := candlesQuote(9.5, 5, 2, 4, 4, 4, 5, 5, 7, 9)
+	if !conditionMatch(item, quote, time.Now()) {
+		t.Error("Expected the price above the upper band")
+	}
+	quote.Price = 8.5
+	if conditionMatch(item, quote, time.Now()) {
+		t.Error("Expected the price inside the bands")
+	}
+
+	item.Condition = "bollinger_below"
+	quote.Price = 0.5
+	if !conditionMatch(item, quote, time.Now()) {
+		t.Error("Expected the price below the lower band")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_IndicatorWithoutCandles(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "SBER",
+		Condition:   "rsi_below",
+		TargetPrice: 30,
+		Params:      godfather.MOEXRuleParams{CandleInterval: 24, Period: 14},
+	}
+	if conditionMatch(item, Quote{Price: 100}, time.Now()) {
+		t.Error("Expected false without candles")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeCondition_Indicators(t *testing.T) {
+	cross := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: "sma_cross_above",
+		Params: godfather.MOEXRuleParams{FastPeriod: 20, SlowPeriod: 50}}
+	if text := describeCondition(cross); text != "The SMA(20) for SBER crossed above SMA(50)" {
+		t.Errorf("unexpected description: %s", text)
+	}
+	rsiItem := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: "rsi_below", TargetPrice: 30,
+		Params: godfather.MOEXRuleParams{Period: 14}}
+	if text := describeCondition(rsiItem); text != "The RSI(14) for SBER is below 30.00" {
+		t.Errorf("unexpected description: %s", text)
+	}
+}
+
+// ----------------------------------------------------------------
+func volumesQuote(volToday float64, valToday float64, days int) Quote {
+	volumes := make([]Volume, days)
+	for i := range volumes {
+		volumes[i] = Volume{Volume: 1000, Value: 100000}
+	}
+	return Quote{Price: 150, VolToday: volToday, ValToday: valToday, Volumes: volumes}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_VolumeSpike(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "GAZP",
+		Condition:   "volume_spike",
+		TargetPrice: 3,
+		Params:      godfather.MOEXRuleParams{LookbackDays: 5},
+	}
+	if !conditionMatch(item, volumesQuote(3500, 0, 5), time.Now()) {
+		t.Error("Expected the volume spike to be detected")
+	}
+	if conditionMatch(item, volumesQuote(2500, 0, 5), time.Now()) {
+		t.Error("Expected no spike below 3x the average")
+	}
+	// Not enough history for the lookback
+	if conditionMatch(item, volumesQuote(3500, 0, 4), time.Now()) {
+		t.Error("Expected false without the full history")
+	}
+	if conditionMatch(item, volumesQuote(math.NaN(), 0, 5), time.Now()) {
+		t.Error("Expected false without today's volume")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_TurnoverSpike(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "GAZP",
+		Condition:   "turnover_spike",
+		TargetPrice: 2,
+	}
+	// Default lookback of 20 days
+	if !conditionMatch(item, volumesQuote(0, 250000, 20), time.Now()) {
+		t.Error("Expected the turnover spike to be detected")
+	}
+	if conditionMatch(item, volumesQuote(0, 250000, 19), time.Now()) {
+		t.Error("Expected false without the full history")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_Deadline(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "GAZP",
+		Condition:   "volume_spike",
+		TargetPrice: 3,
+		Mode:        godfather.MOEXRuleOneShot,
+		Params:      godfather.MOEXRuleParams{LookbackDays: 5, Deadline: 12 * time.Hour},
+	}
+	quote := volumesQuote(3500, 0, 5)
+
+	morning := time.Date(2025, 3, 3, 11, 59, 0, 0, moscowTime)
+	if action := evaluateRule(item, quote, morning); action != ruleFire {
+		t.Errorf("Expected the rule to fire before the deadline, got %v", action)
+	}
+	// 12:00 in Moscow is 09:00 UTC
+	noon := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	if action := evaluateRule(item, quote, noon); action != ruleIdle {
+		t.Errorf("Expected no firing after the deadline, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_Validity(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "SBER",
+		Condition:   "above",
+		TargetPrice: 100,
+		Mode:        godfather.MOEXRuleOneShot,
+		ValidFrom:   time.Date(2025, 3, 3, 0, 0, 0, 0, moscowTime),
+		ValidUntil:  time.Date(2025, 3, 7, 0, 0, 0, 0, moscowTime),
+	}
+	quote := Quote{Price: 150}
+	tests := []struct {
+		now      time.Time
+		expected ruleAction
+	}{
+		{time.Date(2025, 3, 2, 23, 59, 0, 0, moscowTime), ruleIdle},
+		{time.Date(2025, 3, 3, 0, 0, 0, 0, moscowTime), ruleFire},
+		{time.Date(2025, 3, 6, 23, 59, 0, 0, moscowTime), ruleFire},
+		{time.Date(2025, 3, 7, 0, 0, 0, 0, moscowTime), ruleIdle},
+	}
+	for _, test := range tests {
+		if action := evaluateRule(item, quote, test.now); action != test.expected {
+			t.Errorf("Expected %v at %s, got %v", test.expected, test.now.Format(time.RFC3339), action)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_Schedule(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "SBER",
+		Condition:   "above",
+		TargetPrice: 100,
+		Mode:        godfather.MOEXRuleCrossing,
+		Armed:       true,
+		Hysteresis:  5,
+		Schedule:    "0-59 10 * * 1-5",
+	}
+	quote := Quote{Price: 150}
+
+	if action := evaluateRule(item, quote, time.Date(2025, 3, 3, 10, 30, 0, 0, moscowTime)); action != ruleFire {
+		t.Errorf("Expected the rule to fire on schedule, got %v", action)
+	}
+	if action := evaluateRule(item, quote, time.Date(2025, 3, 3, 11, 0, 0, 0, moscowTime)); action != ruleIdle {
+		t.Errorf("Expected no firing out of the schedule, got %v", action)
+	}
+	// The crossing rule is re-armed out of the schedule
+	item.Armed = false
+	if action := evaluateRule(item, Quote{Price: 90}, time.Date(2025, 3, 3, 11, 0, 0, 0, moscowTime)); action != ruleRearm {
+		t.Errorf("Expected the rule re-armed out of the schedule, got %v", action)
+	}
+	// The rule with an invalid schedule never fires
+	item.Armed = true
+	item.Schedule = "first hour"
+	if action := evaluateRule(item, quote, time.Date(2025, 3, 3, 10, 30, 0, 0, moscowTime)); action != ruleIdle {
+		t.Errorf("Expected no firing with an invalid schedule, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeCondition_VolumeSpike(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{Ticker: "GAZP", Condition: "volume_spike", TargetPrice: 3}
+	if text := describeCondition(item); text != "The volume today for GAZP is above 3.00x the 20-day average" {
+		t.Errorf("unexpected description: %s", text)
+	}
+}
+
+// ----------------------------------------------------------------
+func pairQuote(first float64, second float64) Quote {
+	return Quote{Price: first, Second: &Quote{Price: second}}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_PairRules(t *testing.T) {
+	tests := []struct {
+		condition string
+		target    float64
+		quote     Quote
+		expected  bool
+	}{
+		{"spread_above", 5, pairQuote(310, 304), true},
+		{"spread_above", 5, pairQuote(310, 306), false},
+		{"spread_below", 0, pairQuote(300, 304), true},
+		{"ratio_above", 1.01, pairQuote(310, 300), true},
+		{"ratio_below", 1.01, pairQuote(310, 300), false},
+		{"spread_pct_above", 2, pairQuote(306, 300), false},
+		{"spread_pct_below", -1, pairQuote(294, 300), true},
+		// Both legs must be priced
+		{"spread_above", 5, Quote{Price: 310}, false},
+		{"ratio_above", 1, pairQuote(310, 0), false},
+		{"spread_pct_below", 0, pairQuote(294, math.NaN()), false},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: test.condition, TargetPrice: test.target,
+			Pair: godfather.MOEXPairLeg{Ticker: "SBERP"}}
+		if result := conditionMatch(item, test.quote, time.Now()); result != test.expected {
+			t.Errorf("expected %v for %s %.2f with %+v, got %v", test.expected, test.condition, test.target, test.quote, result)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestEvaluateRule_PairCrossingRearm(t *testing.T) {
+	item := godfather.MOEXWatchlistItem{
+		Ticker:      "SBER",
+		Condition:   "spread_above",
+		TargetPrice: 5,
+		Mode:        godfather.MOEXRuleCrossing,
+		Hysteresis:  1,
+		Pair:        godfather.MOEXPairLeg{Ticker: "SBERP"},
+	}
+	if action := evaluateRule(item, pairQuote(310, 305.5), time.Now()); action != ruleIdle {
+		t.Errorf("Expected no re-arming within the hysteresis band, got %v", action)
+	}
+	if action := evaluateRule(item, pairQuote(310, 306.5), time.Now()); action != ruleRearm {
+		t.Errorf("Expected the rule to re-arm once the spread narrowed, got %v", action)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeCondition_PairRules(t *testing.T) {
+	tests := []struct {
+		condition string
+		expected  string
+	}{
+		{"spread_above", "The spread SBER - SBERP is above 1.50"},
+		{"ratio_below", "The ratio SBER / SBERP is below 1.50"},
+		{"spread_pct_above", "The spread SBER - SBERP is above 1.50%"},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "SBER", Condition: test.condition, TargetPrice: 1.5,
+			Pair: godfather.MOEXPairLeg{Ticker: "SBERP"}}
+		if text := describeCondition(item); text != test.expected {
+			t.Errorf("expected '%s', got '%s'", test.expected, text)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func bondQuote(price float64) Quote {
+	today := time.Now().In(moscowTime)
+	return Quote{Price: price, Bond: &MoexBond{
+		Yield:         16.5,
+		Duration:      730,
+		AccruedInt:    12.3,
+		FaceValue:     1000,
+		CouponPercent: 14,
+		MaturityDate:  time.Date(today.Year(), today.Month(), today.Day()+40, 0, 0, 0, 0, moscowTime),
+		OfferDate:     time.Date(today.Year(), today.Month(), today.Day()+10, 0, 0, 0, 0, moscowTime),
+	}}
+}
+
+// ----------------------------------------------------------------
+func TestConditionMatch_BondRules(t *testing.T) {
+	tests := []struct {
+		condition string
+		target    float64
+		quote     Quote
+		expected  bool
+	}{
+		// The price of the bond is compared in money
+		{"above", 980, bondQuote(98.5), true},
+		{"below", 980, bondQuote(98.5), false},
+		{"above", 980, Quote{Price: 98.5, Bond: &MoexBond{FaceValue: math.NaN()}}, false},
+		{"price_pct_below", 99, bondQuote(98.5), true},
+		{"price_pct_above", 99, Quote{Price: 98.5}, false},
+		{"yield_above", 16, bondQuote(98.5), true},
+		{"yield_below", 16, bondQuote(98.5), false},
+		{"current_yield_above", 14.2, bondQuote(98.5), true},
+		{"current_yield_below", 14.2, bondQuote(100), true},
+		{"accrued_above", 10, bondQuote(98.5), true},
+		{"duration_below", 2.5, bondQuote(98.5), true},
+		{"duration_above", 2.5, bondQuote(98.5), false},
+		{"maturity_within", 30, bondQuote(98.5), false},
+		{"maturity_within", 60, bondQuote(98.5), true},
+		{"offer_within", 14, bondQuote(98.5), true},
+		// The bond analytics are required
+		{"yield_above", 16, Quote{Price: 98.5}, false},
+		{"offer_within", 14, Quote{Price: 98.5, Bond: &MoexBond{}}, false},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "RU000A1038V6", Condition: test.condition, TargetPrice: test.target}
+		if result := conditionMatch(item, test.quote, time.Now()); result != test.expected {
+			t.Errorf("expected %v for %s %.2f, got %v", test.expected, test.condition, test.target, result)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// The days to the dates are counted from the evaluation time, so the
+// backtest sees the reminders as they were at the time
+// ----------------------------------------------------------------
+func TestEvaluateRule_DateRulesAtFixedDate(t *testing.T) {
+	quote := Quote{Price: 98.5,
+		Bond: &MoexBond{
+			MaturityDate: time.Date(2025, 4, 1, 0, 0, 0, 0, moscowTime),
+			OfferDate:    time.Date(2025, 3, 14, 0, 0, 0, 0, moscowTime),
+		},
+		Futures: &MoexFutures{Contract: "SiH5", LastTradeDate: time.Date(2025, 3, 20, 0, 0, 0, 0, moscowTime)},
+	}
+	tests := []struct {
+		condition string
+		target    float64
+		now       time.Time
+		expected  ruleAction
+	}{
+		{"maturity_within", 30, time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime), ruleFire},
+		{"maturity_within", 30, time.Date(2025, 2, 20, 10, 0, 0, 0, moscowTime), ruleIdle},
+		{"offer_within", 14, time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime), ruleFire},
+		{"offer_within", 7, time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime), ruleIdle},
+		// The date exactly the target days away is within them
+		{"maturity_within", 29, time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime), ruleFire},
+		{"offer_within", 11, time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime), ruleFire},
+		// The past dates never match
+		{"maturity_within", 30, time.Date(2025, 4, 2, 10, 0, 0, 0, moscowTime), ruleIdle},
+		{"offer_within", 14, time.Date(2025, 3, 20, 10, 0, 0, 0, moscowTime), ruleIdle},
+		{"expiration_within", 7, time.Date(2025, 3, 14, 23, 30, 0, 0, moscowTime), ruleFire},
+		{"expiration_within", 7, time.Date(2025, 3, 3, 10, 0, 0, 0, moscowTime), ruleIdle},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "RU000A1038V6", Condition: test.condition, TargetPrice: test.target}
+		if action := evaluateRule(item, quote, test.now); action != test.expected {
+			t.Errorf("expected %v for %s %.0f at %s, got %v", test.expected, test.condition, test.target,
+				test.now.Format(time.DateTime), action)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDescribeCondition_BondRules(t *testing.T) {
+	tests := []struct {
+		condition string
+		expected  string
+	}{
+		{"yield_above", "The yield to maturity for RU000A1038V6 is above 30.00%"},
+		{"price_pct_below", "The price for RU000A1038V6 is below 30.00% of the face value"},
+		{"duration_above", "The duration for RU000A1038V6 is above 30.00 years"},
+		{"maturity_within", "The maturity of RU000A1038V6 is within 30 days"},
+		{"offer_within", "The offer of RU000A1038V6 is within 30 days"},
+	}
+	for _, test := range tests {
+		item := godfather.MOEXWatchlistItem{Ticker: "RU000A1038V6", Condition: test.condition, TargetPrice: 30}
+		if text := describeCondition(item); text != test.expected {
+			t.Errorf("expected '%s', got '%s'", test.expected, text)
+		}
+	}
+}
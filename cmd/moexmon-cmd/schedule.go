@@ -0,0 +1,580 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------
+// Trading session state, exposed as the moexmon_session_state gauge
+// ----------------------------------------------------------------
+type tradingSession int
+
+const (
+	sessionClosed  tradingSession = iota // no trading
+	sessionMain                          // main session of a working day
+	sessionEvening                       // evening session of a working day
+	sessionWeekend                       // weekend session
+)
+
+// ----------------------------------------------------------------
+func (session tradingSession) String() string {
+	switch session {
+	case sessionMain:
+		return "main"
+	case sessionEvening:
+		return "evening"
+	case sessionWeekend:
+		return "weekend"
+	default:
+		return "closed"
+	}
+}
+
+// ----------------------------------------------------------------
+// Session window as offsets from the Moscow midnight, empty if the
+// session is disabled
+// ----------------------------------------------------------------
+type sessionWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// ----------------------------------------------------------------
+func (window sessionWindow) empty() bool {
+	return window.end <= window.start
+}
+
+// ----------------------------------------------------------------
+func (window sessionWindow) contains(offset time.Duration) bool {
+	return offset >= window.start && offset < window.end
+}
+
+// ----------------------------------------------------------------
+// Parse the "HH:MM-HH:MM" window, "off" disables the session
+// ----------------------------------------------------------------
+func parseSessionWindow(value string) (sessionWindow, error) {
+	if value == "off" {
+		return sessionWindow{}, nil
+	}
+	start, end, found := strings.Cut(value, "-")
+	if !found {
+		return sessionWindow{}, fmt.Errorf("invalid session window: %s", value)
+	}
+	startTime, err := time.Parse("15:04", strings.TrimSpace(start))
+	if err != nil {
+		return sessionWindow{}, fmt.Errorf("invalid session start %s: %w", start, err)
+	}
+	endTime, err := time.Parse("15:04", strings.TrimSpace(end))
+	if err != nil {
+		return sessionWindow{}, fmt.Errorf("invalid session end %s: %w", end, err)
+	}
+	window := sessionWindow{
+		start: time.Duration(startTime.Hour())*time.Hour + time.Duration(startTime.Minute())*time.Minute,
+		end:   time.Duration(endTime.Hour())*time.Hour + time.Duration(endTime.Minute())*time.Minute,
+	}
+	if window.empty() {
+		return sessionWindow{}, fmt.Errorf("session window %s ends before it starts", value)
+	}
+	return window, nil
+}
+
+// ----------------------------------------------------------------
+// Kind of the trading day
+// ----------------------------------------------------------------
+type dayKind int
+
+const (
+	dayWorking dayKind = iota // main and evening sessions
+	dayWeekend                // weekend session only
+	dayHoliday                // no trading
+)
+
+// ----------------------------------------------------------------
+type daySession struct {
+	session tradingSession
+	window  sessionWindow
+}
+
+// Default session windows of the MOEX stock market, Moscow time
+const (
+	defaultMainSession    = "09:50-18:50"
+	defaultEveningSession = "19:00-23:50"
+	defaultWeekendSession = "10:00-19:00"
+)
+
+// Default session windows of the other ISS engines, the engines not
+// listed follow the stock market
+var defaultEngineSessions = map[string]SessionConfig{
+	"currency": {MainSession: "07:00-19:00", EveningSession: "19:00-23:50", WeekendSession: "off"},
+	"futures":  {MainSession: "09:00-18:50", EveningSession: "19:05-23:50", WeekendSession: "10:00-19:00"},
+}
+
+// Number of days to look ahead for the next trading session
+const scheduleLookaheadDays = 14
+
+// ----------------------------------------------------------------
+// Trading day reported by ISS
+// ----------------------------------------------------------------
+type tradingDay struct {
+	working bool
+	hours   sessionWindow // empty if ISS reports no trading hours
+}
+
+// ----------------------------------------------------------------
+// Trading schedule of the ISS engine in Moscow time. The working days
+// and their hours are loaded from the ISS engine timetable and fall
+// back to the holiday file and the configured session windows.
+// ----------------------------------------------------------------
+type TradingSchedule struct {
+	engine        string
+	main          sessionWindow
+	evening       sessionWindow
+	weekend       sessionWindow
+	weekdays      map[time.Weekday]tradingDay // days of the week
+	holidays      map[string]tradingDay       // exceptions by date
+	fallback      map[string]bool             // exceptions from the holiday file, true for a working day
+	fallbackUntil time.Time                   // end of the last year of the holiday file
+}
+
+// ----------------------------------------------------------------
+// Holiday file: non-trading dates and working weekends, YYYY-MM-DD
+// ----------------------------------------------------------------
+type holidayFile struct {
+	Holidays []string `json:"holidays"`
+	Workdays []string `json:"workdays"`
+}
+
+type moexEngineSchedule struct {
+	Timetable struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"timetable"`
+	Dailytable struct {
+		Columns []string `json:"columns"`
+		Data    [][]any  `json:"data"`
+	} `json:"dailytable"`
+}
+
+// ----------------------------------------------------------------
+// Session windows of the engine: the configured ones, then the
+// defaults of the engine, then the stock market ones
+// ----------------------------------------------------------------
+func engineSessions(config ScheduleConfig, engine string) SessionConfig {
+	sessions := SessionConfig{
+		MainSession:    config.MainSession,
+		EveningSession: config.EveningSession,
+		WeekendSession: config.WeekendSession,
+	}
+	if defaults, found := defaultEngineSessions[engine]; found {
+		sessions = defaults
+	}
+	if configured, found := config.Engines[engine]; found {
+		sessions.MainSession = cmp.Or(configured.MainSession, sessions.MainSession)
+		sessions.EveningSession = cmp.Or(configured.EveningSession, sessions.EveningSession)
+		sessions.WeekendSession = cmp.Or(configured.WeekendSession, sessions.WeekendSession)
+	}
+	sessions.MainSession = cmp.Or(sessions.MainSession, defaultMainSession)
+	sessions.EveningSession = cmp.Or(sessions.EveningSession, defaultEveningSession)
+	sessions.WeekendSession = cmp.Or(sessions.WeekendSession, defaultWeekendSession)
+	return sessions
+}
+
+// ----------------------------------------------------------------
+func NewTradingSchedule(config ScheduleConfig, engine string) (*TradingSchedule, error) {
+	working := tradingDay{working: true}
+	schedule := &TradingSchedule{
+		engine: engine,
+		weekdays: map[time.Weekday]tradingDay{
+			time.Monday: working, time.Tuesday: working, time.Wednesday: working, time.Thursday: working, time.Friday: working,
+		},
+		holidays: make(map[string]tradingDay),
+		fallback: make(map[string]bool),
+	}
+
+	sessions := engineSessions(config, engine)
+	windows := []struct {
+		value  string
+		window *sessionWindow
+	}{
+		{sessions.MainSession, &schedule.main},
+		{sessions.EveningSession, &schedule.evening},
+		{sessions.WeekendSession, &schedule.weekend},
+	}
+	for _, window := range windows {
+		parsed, err := parseSessionWindow(window.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s engine schedule: %w", engine, err)
+		}
+		*window.window = parsed
+	}
+
+	if config.HolidaysFile != "" {
+		if err := schedule.loadHolidayFile(config.HolidaysFile); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) loadHolidayFile(path string) error {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to read holiday file: %w", err)
+	}
+	var holidays holidayFile
+	if err := json.Unmarshal(content, &holidays); err != nil {
+		return fmt.Errorf("failed to parse holiday file: %w", err)
+	}
+
+	for _, dates := range []struct {
+		values  []string
+		working bool
+	}{{holidays.Holidays, false}, {holidays.Workdays, true}} {
+		for _, date := range dates.values {
+			day, err := time.ParseInLocation(time.DateOnly, date, moscowTime)
+			if err != nil {
+				return fmt.Errorf("invalid date %s in holiday file: %w", date, err)
+			}
+			schedule.fallback[date] = dates.working
+			if end := time.Date(day.Year()+1, 1, 1, 0, 0, 0, 0, moscowTime); end.After(schedule.fallbackUntil) {
+				schedule.fallbackUntil = end
+			}
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Check whether the holiday file covers the moment, the file lists
+// the holidays of whole years
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) holidaysCover(moment time.Time) bool {
+	return moment.Before(schedule.fallbackUntil)
+}
+
+// ----------------------------------------------------------------
+// Trading hours of the ISS timetable row, empty if not reported
+// ----------------------------------------------------------------
+func parseTradingHours(row []any, startIndex int, stopIndex int) sessionWindow {
+	if startIndex < 0 || stopIndex < 0 {
+		return sessionWindow{}
+	}
+	var offsets [2]time.Duration
+	for i, index := range []int{startIndex, stopIndex} {
+		value, isOk := row[index].(string)
+		if !isOk {
+			return sessionWindow{}
+		}
+		clock, err := time.Parse(time.TimeOnly, value)
+		if err != nil {
+			return sessionWindow{}
+		}
+		offsets[i] = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute +
+			time.Duration(clock.Second())*time.Second
+	}
+	window := sessionWindow{start: offsets[0], end: offsets[1]}
+	if window.empty() {
+		return sessionWindow{}
+	}
+	return window
+}
+
+// ----------------------------------------------------------------
+// Load the working days and their trading hours from the ISS engine
+// schedule. The holiday file is kept for the dates not reported by
+// ISS.
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) Load(ctx context.Context) error {
+	engine := schedule.engine
+	url := issClient.URL("/iss/engines/%s.json?iss.meta=off&iss.only=timetable,dailytable", engine)
+	result, err := query[moexEngineSchedule](ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to query %s engine schedule: %w", engine, err)
+	}
+
+	columns := result.Timetable.Columns
+	weekdayIndex := columnIndex(columns, "week_day")
+	workIndex := columnIndex(columns, "is_work_day")
+	startIndex := columnIndex(columns, "start_time")
+	stopIndex := columnIndex(columns, "stop_time")
+	if weekdayIndex < 0 || workIndex < 0 || len(result.Timetable.Data) == 0 {
+		return fmt.Errorf("unexpected %s engine timetable: %v", engine, columns)
+	}
+	weekdays := make(map[time.Weekday]tradingDay, len(result.Timetable.Data))
+	for _, row := range result.Timetable.Data {
+		day, isOk := row[weekdayIndex].(float64)
+		if !isOk || day < 1 || day > 7 {
+			return fmt.Errorf("invalid week day in %s engine timetable: %v", engine, row[weekdayIndex])
+		}
+		// ISS counts the days from Monday (1) to Sunday (7)
+		weekdays[time.Weekday(int(day)%7)] = tradingDay{
+			working: row[workIndex] == float64(1),
+			hours:   parseTradingHours(row, startIndex, stopIndex),
+		}
+	}
+
+	columns = result.Dailytable.Columns
+	dateIndex := columnIndex(columns, "date")
+	dailyWorkIndex := columnIndex(columns, "is_work_day")
+	startIndex = columnIndex(columns, "start_time")
+	stopIndex = columnIndex(columns, "stop_time")
+	holidays := make(map[string]tradingDay, len(result.Dailytable.Data))
+	if dateIndex >= 0 && dailyWorkIndex >= 0 {
+		for _, row := range result.Dailytable.Data {
+			date, isOk := row[dateIndex].(string)
+			if !isOk {
+				continue
+			}
+			holidays[date] = tradingDay{
+				working: row[dailyWorkIndex] == float64(1),
+				hours:   parseTradingHours(row, startIndex, stopIndex),
+			}
+		}
+	}
+
+	schedule.weekdays = weekdays
+	schedule.holidays = holidays
+	slog.Debug(fmt.Sprintf("Loaded %s engine schedule with %d exceptions", engine, len(holidays)))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Kind of the day and its trading hours reported by ISS, empty if the
+// session windows apply
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) dayKind(day time.Time) (dayKind, sessionWindow) {
+	date := day.Format(time.DateOnly)
+	reported, found := schedule.holidays[date]
+	if !found {
+		working, inFile := schedule.fallback[date]
+		if inFile && !working {
+			return dayHoliday, sessionWindow{}
+		}
+		if inFile {
+			return dayWorking, sessionWindow{}
+		}
+		reported = schedule.weekdays[day.Weekday()]
+		if !reported.working {
+			return dayWeekend, reported.hours
+		}
+		return dayWorking, reported.hours
+	}
+	switch {
+	case reported.working:
+		return dayWorking, reported.hours
+	case reported.hours.empty():
+		return dayHoliday, sessionWindow{}
+	default:
+		return dayWeekend, reported.hours
+	}
+}
+
+// ----------------------------------------------------------------
+// Sessions of the day: the trading hours reported by ISS split at the
+// start of the evening session, or the session windows
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) sessions(kind dayKind, hours sessionWindow) []daySession {
+	switch {
+	case kind == dayHoliday:
+		return nil
+	case kind == dayWeekend && hours.empty():
+		return []daySession{{sessionWeekend, schedule.weekend}}
+	case kind == dayWeekend:
+		return []daySession{{sessionWeekend, hours}}
+	case hours.empty():
+		return []daySession{{sessionMain, schedule.main}, {sessionEvening, schedule.evening}}
+	case schedule.evening.empty() || !hours.contains(schedule.evening.start):
+		return []daySession{{sessionMain, hours}}
+	default:
+		return []daySession{
+			{sessionMain, sessionWindow{start: hours.start, end: schedule.evening.start}},
+			{sessionEvening, sessionWindow{start: schedule.evening.start, end: hours.end}},
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func moscowMidnight(moment time.Time) time.Time {
+	moment = moment.In(moscowTime)
+	return time.Date(moment.Year(), moment.Month(), moment.Day(), 0, 0, 0, 0, moscowTime)
+}
+
+// ----------------------------------------------------------------
+// Trading session at the given moment
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) Session(now time.Time) tradingSession {
+	midnight := moscowMidnight(now)
+	offset := now.Sub(midnight)
+	for _, session := range schedule.sessions(schedule.dayKind(midnight)) {
+		if session.window.contains(offset) {
+			return session.session
+		}
+	}
+	return sessionClosed
+}
+
+// ----------------------------------------------------------------
+// Start of the next trading session after the given moment
+// ----------------------------------------------------------------
+func (schedule *TradingSchedule) NextOpen(now time.Time) (time.Time, bool) {
+	midnight := moscowMidnight(now)
+	for day := 0; day <= scheduleLookaheadDays; day++ {
+		date := midnight.AddDate(0, 0, day)
+		for _, session := range schedule.sessions(schedule.dayKind(date)) {
+			if session.window.empty() {
+				continue
+			}
+			start := date.Add(session.window.start)
+			if start.After(now) {
+				return start, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// ----------------------------------------------------------------
+// Trading schedules of the ISS engines used by the watchlist, created
+// and loaded from ISS on the first use
+// ----------------------------------------------------------------
+type TradingSchedules struct {
+	config  ScheduleConfig
+	mutex   sync.Mutex
+	engines map[string]*TradingSchedule
+}
+
+// ----------------------------------------------------------------
+func NewTradingSchedules(config ScheduleConfig) (*TradingSchedules, error) {
+	schedules := &TradingSchedules{config: config, engines: make(map[string]*TradingSchedule)}
+	// The configuration is validated by the stock market schedule
+	schedule, err := NewTradingSchedule(config, "stock")
+	if err != nil {
+		return nil, err
+	}
+	schedules.engines["stock"] = schedule
+	for engine := range config.Engines {
+		if _, err := NewTradingSchedule(config, engine); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// ----------------------------------------------------------------
+// Load the schedule from ISS, the holiday file is used on failure
+// ----------------------------------------------------------------
+func loadSchedule(ctx context.Context, schedule *TradingSchedule, now time.Time) {
+	err := schedule.Load(ctx)
+	if err == nil {
+		return
+	}
+	moexFailures.Inc()
+	if schedule.fallbackUntil.IsZero() || schedule.holidaysCover(now) {
+		slog.Warn(fmt.Sprintf("Failed to load MOEX %s engine schedule, using the holiday file", schedule.engine), "error", err)
+		return
+	}
+	slog.Warn(fmt.Sprintf("Failed to load MOEX %s engine schedule, the holiday file covers the dates before %s only",
+		schedule.engine, schedule.fallbackUntil.Format(time.DateOnly)), "error", err)
+}
+
+// ----------------------------------------------------------------
+// Add the schedules of the engines, the new ones are loaded from ISS
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) Track(ctx context.Context, engines []string) {
+	for _, engine := range engines {
+		schedules.mutex.Lock()
+		_, found := schedules.engines[engine]
+		schedules.mutex.Unlock()
+		if found {
+			continue
+		}
+		schedule, err := NewTradingSchedule(schedules.config, engine)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to initialize MOEX %s engine schedule", engine), "error", err)
+			continue
+		}
+		loadSchedule(ctx, schedule, time.Now())
+		schedules.mutex.Lock()
+		schedules.engines[engine] = schedule
+		schedules.mutex.Unlock()
+	}
+}
+
+// ----------------------------------------------------------------
+// Reload the schedules from ISS
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) Reload(ctx context.Context, now time.Time) {
+	for _, schedule := range schedules.list() {
+		loadSchedule(ctx, schedule, now)
+	}
+}
+
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) list() []*TradingSchedule {
+	schedules.mutex.Lock()
+	defer schedules.mutex.Unlock()
+	list := make([]*TradingSchedule, 0, len(schedules.engines))
+	for _, schedule := range schedules.engines {
+		list = append(list, schedule)
+	}
+	return list
+}
+
+// ----------------------------------------------------------------
+// Trading session of the engine at the given moment, the engines not
+// tracked follow the stock market
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) Session(engine string, now time.Time) tradingSession {
+	schedules.mutex.Lock()
+	schedule, found := schedules.engines[engine]
+	if !found {
+		schedule = schedules.engines["stock"]
+	}
+	schedules.mutex.Unlock()
+	return schedule.Session(now)
+}
+
+// ----------------------------------------------------------------
+// Trading sessions of the tracked engines at the given moment
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) Sessions(now time.Time) map[string]tradingSession {
+	sessions := make(map[string]tradingSession)
+	for _, schedule := range schedules.list() {
+		sessions[schedule.engine] = schedule.Session(now)
+	}
+	return sessions
+}
+
+// ----------------------------------------------------------------
+// Start of the next trading session of any tracked engine
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) NextOpen(now time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, schedule := range schedules.list() {
+		if start, found := schedule.NextOpen(now); found && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// ----------------------------------------------------------------
+// Warn at startup if the holiday file no longer covers the date
+// ----------------------------------------------------------------
+func (schedules *TradingSchedules) CheckHolidayFile(now time.Time) {
+	schedule := schedules.list()[0]
+	if !schedule.fallbackUntil.IsZero() && !schedule.holidaysCover(now) {
+		slog.Warn(fmt.Sprintf("MOEX holiday file %s covers the dates before %s only, the holidays are taken from ISS",
+			schedules.config.HolidaysFile, schedule.fallbackUntil.Format(time.DateOnly)))
+	}
+}
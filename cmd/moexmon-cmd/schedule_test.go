@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ----------------------------------------------------------------
+func moscow(date string, clock string) time.Time {
+	moment, err := time.ParseInLocation(time.DateTime, date+" "+clock+":00", moscowTime)
+	if err != nil {
+		panic(err)
+	}
+	return moment
+}
+
+// ----------------------------------------------------------------
+func TestParseSessionWindow(t *testing.T) {
+	window, err := parseSessionWindow("09:50-18:50")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if window.start != 9*time.Hour+50*time.Minute || window.end != 18*time.Hour+50*time.Minute {
+		t.Errorf("unexpected window: %+v", window)
+	}
+
+	window, err = parseSessionWindow("off")
+	if err != nil || !window.empty() {
+		t.Errorf("expected the disabled session, got %+v, %v", window, err)
+	}
+
+	for _, value := range []string{"", "09:50", "9-18", "18:50-09:50"} {
+		if _, err := parseSessionWindow(value); err == nil {
+			t.Errorf("expected error for %q, got nil", value)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_Session(t *testing.T) {
+	schedule, err := NewTradingSchedule(ScheduleConfig{}, "stock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		moment   time.Time
+		expected tradingSession
+	}{
+		{moscow("2025-03-03", "09:00"), sessionClosed}, // Monday before the open
+		{moscow("2025-03-03", "09:50"), sessionMain},
+		{moscow("2025-03-03", "18:55"), sessionClosed}, // clearing
+		{moscow("2025-03-03", "19:30"), sessionEvening},
+		{moscow("2025-03-03", "23:55"), sessionClosed},
+		{moscow("2025-03-01", "12:00"), sessionWeekend}, // Saturday
+		{moscow("2025-03-01", "20:00"), sessionClosed},
+		// 09:00 UTC is 12:00 in Moscow
+		{time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), sessionMain},
+	}
+	for _, test := range tests {
+		if session := schedule.Session(test.moment); session != test.expected {
+			t.Errorf("expected %s session at %s, got %s", test.expected, test.moment, session)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_HolidayFile(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "holidays-*.json")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name()) //nolint:errcheck
+	if _, err := tmpFile.WriteString(`{"holidays": ["2025-03-10", "2025-03-08"], "workdays": ["2025-03-09"]}`); err != nil {
+		t.Fatalf("failed to write to temp file: %v", err)
+	}
+	tmpFile.Close() //nolint:gosec,errcheck
+
+	schedule, err := NewTradingSchedule(ScheduleConfig{HolidaysFile: tmpFile.Name(), EveningSession: "off"}, "stock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if session := schedule.Session(moscow("2025-03-10", "12:00")); session != sessionClosed {
+		t.Errorf("expected no trading on a holiday Monday, got %s", session)
+	}
+	if session := schedule.Session(moscow("2025-03-08", "12:00")); session != sessionClosed {
+		t.Errorf("expected no weekend session on a holiday Saturday, got %s", session)
+	}
+	if session := schedule.Session(moscow("2025-03-09", "12:00")); session != sessionMain {
+		t.Errorf("expected the main session on a working Sunday, got %s", session)
+	}
+	if session := schedule.Session(moscow("2025-03-11", "20:00")); session != sessionClosed {
+		t.Errorf("expected no evening session, got %s", session)
+	}
+	// The file covers the whole years of its dates
+	if !schedule.holidaysCover(moscow("2025-12-31", "23:00")) || schedule.holidaysCover(moscow("2026-01-01", "00:00")) {
+		t.Errorf("unexpected end of the holiday file: %s", schedule.fallbackUntil)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_InvalidConfig(t *testing.T) {
+	if _, err := NewTradingSchedule(ScheduleConfig{MainSession: "whenever"}, "stock"); err == nil {
+		t.Error("expected error for invalid session window, got nil")
+	}
+	if _, err := NewTradingSchedule(ScheduleConfig{HolidaysFile: "nonexistent-file.json"}, "stock"); err == nil {
+		t.Error("expected error for nonexistent holiday file, got nil")
+	}
+
+	tmpFile, err := os.CreateTemp("", "holidays-invalid-*.json")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name()) //nolint:errcheck
+	if _, err := tmpFile.WriteString(`{"holidays": ["10.03.2025"]}`); err != nil {
+		t.Fatalf("failed to write to temp file: %v", err)
+	}
+	tmpFile.Close() //nolint:gosec,errcheck
+	if _, err := NewTradingSchedule(ScheduleConfig{HolidaysFile: tmpFile.Name()}, "stock"); err == nil {
+		t.Error("expected error for invalid holiday date, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_NextOpen(t *testing.T) {
+	schedule, err := NewTradingSchedule(ScheduleConfig{WeekendSession: "off"}, "stock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Friday night: the next session is the Monday main one
+	next, found := schedule.NextOpen(moscow("2025-02-28", "23:55"))
+	if !found || !next.Equal(moscow("2025-03-03", "09:50")) {
+		t.Errorf("unexpected next open: %s", next)
+	}
+	// Clearing between the sessions
+	next, found = schedule.NextOpen(moscow("2025-03-03", "18:55"))
+	if !found || !next.Equal(moscow("2025-03-03", "19:00")) {
+		t.Errorf("unexpected next open: %s", next)
+	}
+
+	closed, err := NewTradingSchedule(ScheduleConfig{MainSession: "off", EveningSession: "off", WeekendSession: "off"}, "stock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, found := closed.NextOpen(moscow("2025-03-03", "12:00")); found {
+		t.Error("expected no next open without sessions")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_Load(t *testing.T) {
+	var requested string
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		body := `{
+			"timetable": {"columns": ["week_day", "is_work_day", "start_time", "stop_time"], "data": [
+				[1, 1, "06:50:00", "23:50:00"], [2, 1, "06:50:00", "23:50:00"], [3, 1, "06:50:00", "23:50:00"],
+				[4, 1, "06:50:00", "23:50:00"], [5, 1, "06:50:00", "23:50:00"], [6, 0, "00:00:00", "00:00:00"],
+				[7, 0, "00:00:00", "00:00:00"]]},
+			"dailytable": {"columns": ["date", "is_work_day", "start_time", "stop_time"], "data": [
+				["2025-03-10", 0, "00:00:00", "00:00:00"],
+				["2025-03-15", 1, "06:50:00", "23:50:00"]]}
+		}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	schedule, err := NewTradingSchedule(ScheduleConfig{}, "stock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Fallback for the dates not reported by ISS
+	schedule.fallback["2025-03-11"] = false
+
+	if err := schedule.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requested != "https://iss.moex.com/iss/engines/stock.json?iss.meta=off&iss.only=timetable,dailytable" {
+		t.Errorf("unexpected request: %s", requested)
+	}
+
+	tests := []struct {
+		moment   time.Time
+		expected tradingSession
+	}{
+		{moscow("2025-03-10", "12:00"), sessionClosed},  // holiday reported by ISS
+		{moscow("2025-03-11", "12:00"), sessionClosed},  // holiday from the file
+		{moscow("2025-03-12", "12:00"), sessionMain},    // regular working day
+		{moscow("2025-03-12", "07:00"), sessionMain},    // trading hours reported by ISS
+		{moscow("2025-03-12", "19:30"), sessionEvening}, // split at the evening session
+		{moscow("2025-03-12", "23:55"), sessionClosed},
+		{moscow("2025-03-15", "12:00"), sessionMain},    // working Saturday
+		{moscow("2025-03-16", "12:00"), sessionWeekend}, // regular Sunday
+	}
+	for _, test := range tests {
+		if session := schedule.Session(test.moment); session != test.expected {
+			t.Errorf("expected %s session at %s, got %s", test.expected, test.moment, session)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_LoadErrors(t *testing.T) {
+	schedule, err := NewTradingSchedule(ScheduleConfig{}, "stock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockISS(&mockRoundTripper{err: io.ErrUnexpectedEOF})
+	if err := schedule.Load(context.Background()); err == nil {
+		t.Error("expected error for failed query, got nil")
+	}
+
+	body := `{"timetable": {"columns": ["week_day", "is_work_day"], "data": [[9, 1]]}}`
+	mockISS(&mockRoundTripper{resp: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}})
+	if err := schedule.Load(context.Background()); err == nil {
+		t.Error("expected error for invalid week day, got nil")
+	}
+
+	// The default working days are kept after a failure
+	if session := schedule.Session(moscow("2025-03-03", "12:00")); session != sessionMain {
+		t.Errorf("expected the main session, got %s", session)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_Engines(t *testing.T) {
+	config := ScheduleConfig{
+		MainSession: "10:00-18:40",
+		Engines:     map[string]SessionConfig{"futures": {WeekendSession: "off"}},
+	}
+	tests := []struct {
+		engine   string
+		moment   time.Time
+		expected tradingSession
+	}{
+		{"stock", moscow("2025-03-03", "09:55"), sessionClosed}, // configured main session
+		{"currency", moscow("2025-03-03", "07:30"), sessionMain},
+		{"currency", moscow("2025-03-01", "12:00"), sessionClosed}, // no weekend session
+		{"futures", moscow("2025-03-03", "09:05"), sessionMain},
+		{"futures", moscow("2025-03-03", "19:02"), sessionClosed}, // clearing
+		{"futures", moscow("2025-03-01", "12:00"), sessionClosed}, // weekend session disabled
+	}
+	for _, test := range tests {
+		schedule, err := NewTradingSchedule(config, test.engine)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if session := schedule.Session(test.moment); session != test.expected {
+			t.Errorf("expected %s session of %s engine at %s, got %s", test.expected, test.engine, test.moment, session)
+		}
+	}
+
+	config.Engines["currency"] = SessionConfig{MainSession: "whenever"}
+	if _, err := NewTradingSchedules(config); err == nil {
+		t.Error("expected error for invalid engine session window, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedules(t *testing.T) {
+	mockISS(&mockRoundTripper{err: io.ErrUnexpectedEOF})
+	schedules, err := NewTradingSchedules(ScheduleConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schedules.Track(context.Background(), []string{"stock", "currency"})
+
+	// The currency market opens before the stock one
+	now := moscow("2025-03-03", "08:00")
+	sessions := schedules.Sessions(now)
+	if len(sessions) != 2 || sessions["stock"] != sessionClosed || sessions["currency"] != sessionMain {
+		t.Errorf("unexpected sessions: %v", sessions)
+	}
+	// The engines not tracked follow the stock market
+	if session := schedules.Session("futures", now); session != sessionClosed {
+		t.Errorf("expected the stock market session, got %s", session)
+	}
+	next, found := schedules.NextOpen(moscow("2025-03-03", "23:55"))
+	if !found || !next.Equal(moscow("2025-03-04", "07:00")) {
+		t.Errorf("unexpected next open: %s", next)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTradingSchedule_LoadSimulator(t *testing.T) {
+	// The ISS simulator trades around the clock every day
+	mockISS(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rows := make([]string, 7)
+		for day := range rows {
+			rows[day] = fmt.Sprintf(`[%d, 1, "00:00:00", "23:59:59"]`, day+1)
+		}
+		body := `{"timetable": {"columns": ["week_day", "is_work_day", "start_time", "stop_time"], "data": [` +
+			strings.Join(rows, ",") + `]}, "dailytable": {"columns": ["date", "is_work_day"], "data": []}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}))
+
+	schedule, err := NewTradingSchedule(ScheduleConfig{}, "futures")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := schedule.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, moment := range []time.Time{moscow("2025-03-01", "03:00"), moscow("2025-03-03", "19:02"), moscow("2025-03-03", "23:55")} {
+		if session := schedule.Session(moment); session == sessionClosed {
+			t.Errorf("expected the session at %s, got %s", moment, session)
+		}
+	}
+}
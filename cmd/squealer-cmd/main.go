@@ -74,7 +74,13 @@ func handleNotifications(ctx context.Context, db *godfather.Database, mb *godfat
 			return
 		}
 
-		slog.Debug(fmt.Sprintf("Received alert %s for notification ID %d", alert.Subject, alert.NotificationId))
+		if alert.PortfolioId != 0 {
+			slog.Debug(fmt.Sprintf("Received alert %s for notification ID %d (portfolio %d)",
+				alert.Subject, alert.NotificationId, alert.PortfolioId))
+		} else {
+			slog.Debug(fmt.Sprintf("Received alert %s for notification ID %d (watchlist item %d)",
+				alert.Subject, alert.NotificationId, alert.WatchlistId))
+		}
 
 		// Read the notification from the database
 		notification, err := db.GetNotificationByID(alert.NotificationId)
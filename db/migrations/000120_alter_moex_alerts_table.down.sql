@@ -0,0 +1,10 @@
+REVOKE ALL PRIVILEGES ON SEQUENCE moex_alerts_id_seq FROM moexmon;
+REVOKE ALL PRIVILEGES ON moex_alerts FROM moexmon;
+
+DROP INDEX IF EXISTS moex_alerts_timestamp_idx;
+
+ALTER TABLE moex_alerts
+    DROP COLUMN IF EXISTS price,
+    DROP COLUMN IF EXISTS target_price,
+    DROP COLUMN IF EXISTS condition,
+    DROP COLUMN IF EXISTS published;
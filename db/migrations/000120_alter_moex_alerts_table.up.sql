@@ -0,0 +1,10 @@
+ALTER TABLE moex_alerts
+    ADD COLUMN IF NOT EXISTS price NUMERIC,
+    ADD COLUMN IF NOT EXISTS target_price NUMERIC,
+    ADD COLUMN IF NOT EXISTS condition VARCHAR,
+    ADD COLUMN IF NOT EXISTS published BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS moex_alerts_timestamp_idx ON moex_alerts (timestamp);
+
+GRANT SELECT, INSERT ON moex_alerts TO moexmon;
+GRANT USAGE ON SEQUENCE moex_alerts_id_seq TO moexmon;
@@ -0,0 +1,6 @@
+ALTER TABLE moex_watchlist
+    DROP COLUMN IF EXISTS mode,
+    DROP COLUMN IF EXISTS hysteresis,
+    DROP COLUMN IF EXISTS cooldown_seconds,
+    DROP COLUMN IF EXISTS is_armed,
+    DROP COLUMN IF EXISTS last_triggered_at;
@@ -0,0 +1,6 @@
+ALTER TABLE moex_watchlist
+    ADD COLUMN IF NOT EXISTS mode VARCHAR NOT NULL DEFAULT 'oneshot' CHECK (mode IN ('oneshot', 'crossing')),
+    ADD COLUMN IF NOT EXISTS hysteresis NUMERIC NOT NULL DEFAULT 0 CHECK (hysteresis >= 0),
+    ADD COLUMN IF NOT EXISTS cooldown_seconds INTEGER NOT NULL DEFAULT 0 CHECK (cooldown_seconds >= 0),
+    ADD COLUMN IF NOT EXISTS is_armed BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS last_triggered_at TIMESTAMP;
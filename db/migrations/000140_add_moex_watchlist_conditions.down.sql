@@ -0,0 +1,6 @@
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_target_check,
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check;
+
+ALTER TABLE moex_watchlist
+    ALTER COLUMN target_price TYPE MONEY USING target_price::money;
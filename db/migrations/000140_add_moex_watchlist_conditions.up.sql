@@ -0,0 +1,14 @@
+ALTER TABLE moex_watchlist
+    ALTER COLUMN target_price TYPE NUMERIC USING target_price::numeric;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below'
+    )),
+    ADD CONSTRAINT moex_watchlist_target_check CHECK (
+        target_price IS NOT NULL OR condition IN ('new_high', 'new_low')
+    );
@@ -0,0 +1,31 @@
+DELETE FROM moex_alerts USING moex_watchlist
+    WHERE moex_alerts.watchlist_id = moex_watchlist.id AND moex_watchlist.condition IN (
+        'sma_cross_above', 'sma_cross_below',
+        'ema_cross_above', 'ema_cross_below',
+        'rsi_above', 'rsi_below',
+        'bollinger_above', 'bollinger_below'
+    );
+DELETE FROM moex_watchlist WHERE condition IN (
+    'sma_cross_above', 'sma_cross_below',
+    'ema_cross_above', 'ema_cross_below',
+    'rsi_above', 'rsi_below',
+    'bollinger_above', 'bollinger_below'
+);
+
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check,
+    DROP CONSTRAINT IF EXISTS moex_watchlist_target_check;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below'
+    )),
+    ADD CONSTRAINT moex_watchlist_target_check CHECK (
+        target_price IS NOT NULL OR condition IN ('new_high', 'new_low')
+    );
+
+DROP TABLE IF EXISTS moex_watchlist_params;
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS moex_watchlist_params (
+    watchlist_id BIGINT PRIMARY KEY REFERENCES moex_watchlist ON DELETE CASCADE,
+    candle_interval INTEGER NOT NULL DEFAULT 24 CHECK (candle_interval IN (1, 10, 60, 24, 7, 31, 4)),
+    period INTEGER CHECK (period > 0),
+    fast_period INTEGER CHECK (fast_period > 0),
+    slow_period INTEGER CHECK (slow_period > 0),
+    band_width NUMERIC CHECK (band_width > 0),
+    CONSTRAINT moex_watchlist_params_periods_check CHECK (fast_period IS NULL OR slow_period IS NULL OR fast_period < slow_period)
+);
+
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check,
+    DROP CONSTRAINT IF EXISTS moex_watchlist_target_check;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below',
+        'sma_cross_above', 'sma_cross_below',
+        'ema_cross_above', 'ema_cross_below',
+        'rsi_above', 'rsi_below',
+        'bollinger_above', 'bollinger_below'
+    )),
+    ADD CONSTRAINT moex_watchlist_target_check CHECK (
+        target_price IS NOT NULL OR condition IN (
+            'new_high', 'new_low',
+            'sma_cross_above', 'sma_cross_below',
+            'ema_cross_above', 'ema_cross_below',
+            'bollinger_above', 'bollinger_below'
+        )
+    );
+
+GRANT SELECT ON moex_watchlist_params TO moexmon;
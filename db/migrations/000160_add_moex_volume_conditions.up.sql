@@ -0,0 +1,20 @@
+ALTER TABLE moex_watchlist_params
+    ADD COLUMN IF NOT EXISTS lookback_days INTEGER CHECK (lookback_days > 0),
+    ADD COLUMN IF NOT EXISTS deadline TIME;
+
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below',
+        'sma_cross_above', 'sma_cross_below',
+        'ema_cross_above', 'ema_cross_below',
+        'rsi_above', 'rsi_below',
+        'bollinger_above', 'bollinger_below',
+        'volume_spike', 'turnover_spike'
+    ));
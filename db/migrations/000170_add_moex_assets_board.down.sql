@@ -0,0 +1,7 @@
+REVOKE UPDATE (engine, market, board) ON moex_assets FROM moexmon;
+
+ALTER TABLE moex_assets
+    DROP CONSTRAINT IF EXISTS moex_assets_board_check,
+    DROP COLUMN IF EXISTS board,
+    DROP COLUMN IF EXISTS market,
+    DROP COLUMN IF EXISTS engine;
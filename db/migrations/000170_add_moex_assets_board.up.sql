@@ -0,0 +1,10 @@
+ALTER TABLE moex_assets
+    ADD COLUMN IF NOT EXISTS engine VARCHAR,
+    ADD COLUMN IF NOT EXISTS market VARCHAR,
+    ADD COLUMN IF NOT EXISTS board VARCHAR,
+    ADD CONSTRAINT moex_assets_board_check CHECK (
+        (engine IS NULL AND market IS NULL AND board IS NULL) OR
+        (engine IS NOT NULL AND market IS NOT NULL AND board IS NOT NULL)
+    );
+
+GRANT UPDATE (engine, market, board) ON moex_assets TO moexmon;
@@ -0,0 +1,13 @@
+REVOKE INSERT, UPDATE ON moex_assets FROM moexmon;
+GRANT UPDATE (engine, market, board) ON moex_assets TO moexmon;
+
+DROP INDEX IF EXISTS moex_assets_board_idx;
+DROP INDEX IF EXISTS moex_assets_isin_idx;
+
+ALTER TABLE moex_assets
+    DROP COLUMN IF EXISTS synced_at,
+    DROP COLUMN IF EXISTS is_delisted,
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS lot_size,
+    DROP COLUMN IF EXISTS isin,
+    DROP COLUMN IF EXISTS short_name;
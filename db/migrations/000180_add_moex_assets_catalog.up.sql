@@ -0,0 +1,13 @@
+ALTER TABLE moex_assets
+    ADD COLUMN IF NOT EXISTS short_name VARCHAR,
+    ADD COLUMN IF NOT EXISTS isin VARCHAR,
+    ADD COLUMN IF NOT EXISTS lot_size INTEGER,
+    ADD COLUMN IF NOT EXISTS currency VARCHAR,
+    ADD COLUMN IF NOT EXISTS is_delisted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS synced_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS moex_assets_isin_idx ON moex_assets (isin);
+CREATE INDEX IF NOT EXISTS moex_assets_board_idx ON moex_assets (engine, market, board);
+
+REVOKE UPDATE (engine, market, board) ON moex_assets FROM moexmon;
+GRANT INSERT, UPDATE ON moex_assets TO moexmon;
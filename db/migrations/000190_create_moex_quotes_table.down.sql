@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS moex_quotes_drop_partitions(DATE);
+DROP FUNCTION IF EXISTS moex_quotes_create_partition(DATE);
+DROP TABLE IF EXISTS moex_quote_bars;
+DROP TABLE IF EXISTS moex_quotes;
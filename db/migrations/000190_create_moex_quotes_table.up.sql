@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS moex_quotes (
+    ticker VARCHAR NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    price NUMERIC NOT NULL,
+    vol_today NUMERIC,
+    PRIMARY KEY (ticker, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE IF NOT EXISTS moex_quote_bars (
+    ticker VARCHAR NOT NULL,
+    interval VARCHAR NOT NULL CHECK (interval IN ('1m', '1h', '1d')),
+    bucket TIMESTAMP NOT NULL,
+    open NUMERIC NOT NULL,
+    high NUMERIC NOT NULL,
+    low NUMERIC NOT NULL,
+    close NUMERIC NOT NULL,
+    PRIMARY KEY (ticker, interval, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS moex_quote_bars_bucket_idx ON moex_quote_bars (interval, bucket);
+
+-- Daily partitions are named moex_quotes_YYYYMMDD
+CREATE OR REPLACE FUNCTION moex_quotes_create_partition(day DATE) RETURNS VOID
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = public AS $$
+BEGIN
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF moex_quotes FOR VALUES FROM (%L) TO (%L)',
+        'moex_quotes_' || to_char(day, 'YYYYMMDD'), day, day + 1);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION moex_quotes_drop_partitions(before DATE) RETURNS INTEGER
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = public AS $$
+DECLARE
+    partition_name TEXT;
+    dropped INTEGER := 0;
+BEGIN
+    FOR partition_name IN
+        SELECT child.relname FROM pg_inherits
+            INNER JOIN pg_class child ON pg_inherits.inhrelid = child.oid
+            INNER JOIN pg_class parent ON pg_inherits.inhparent = parent.oid
+        WHERE parent.relname = 'moex_quotes' AND child.relname < 'moex_quotes_' || to_char(before, 'YYYYMMDD')
+    LOOP
+        EXECUTE format('DROP TABLE IF EXISTS %I', partition_name);
+        dropped := dropped + 1;
+    END LOOP;
+    RETURN dropped;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION moex_quotes_create_partition(DATE), moex_quotes_drop_partitions(DATE) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION moex_quotes_create_partition(DATE), moex_quotes_drop_partitions(DATE) TO moexmon;
+GRANT SELECT, INSERT ON moex_quotes TO moexmon;
+GRANT SELECT, INSERT, UPDATE, DELETE ON moex_quote_bars TO moexmon;
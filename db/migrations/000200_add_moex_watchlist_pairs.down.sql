@@ -0,0 +1,27 @@
+DELETE FROM moex_alerts USING moex_watchlist
+    WHERE moex_alerts.watchlist_id = moex_watchlist.id AND moex_watchlist.second_ticker_id IS NOT NULL;
+DELETE FROM moex_watchlist WHERE second_ticker_id IS NOT NULL;
+
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_pair_check,
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below',
+        'sma_cross_above', 'sma_cross_below',
+        'ema_cross_above', 'ema_cross_below',
+        'rsi_above', 'rsi_below',
+        'bollinger_above', 'bollinger_below',
+        'volume_spike', 'turnover_spike'
+    ));
+
+ALTER TABLE moex_alerts
+    DROP COLUMN IF EXISTS second_price;
+
+ALTER TABLE moex_watchlist
+    DROP COLUMN IF EXISTS second_ticker_id;
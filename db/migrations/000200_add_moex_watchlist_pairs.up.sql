@@ -0,0 +1,33 @@
+ALTER TABLE moex_watchlist
+    ADD COLUMN IF NOT EXISTS second_ticker_id VARCHAR REFERENCES moex_assets;
+
+ALTER TABLE moex_alerts
+    ADD COLUMN IF NOT EXISTS second_price NUMERIC;
+
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below',
+        'sma_cross_above', 'sma_cross_below',
+        'ema_cross_above', 'ema_cross_below',
+        'rsi_above', 'rsi_below',
+        'bollinger_above', 'bollinger_below',
+        'volume_spike', 'turnover_spike',
+        'spread_above', 'spread_below',
+        'ratio_above', 'ratio_below',
+        'spread_pct_above', 'spread_pct_below'
+    )),
+    -- Pair rules reference the second leg, the others must not
+    ADD CONSTRAINT moex_watchlist_pair_check CHECK (
+        (second_ticker_id IS NOT NULL) = (condition IN (
+            'spread_above', 'spread_below',
+            'ratio_above', 'ratio_below',
+            'spread_pct_above', 'spread_pct_below'
+        ))
+    );
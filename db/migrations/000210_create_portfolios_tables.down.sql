@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS portfolio_rules;
+DROP TABLE IF EXISTS holdings;
+DROP TABLE IF EXISTS portfolios;
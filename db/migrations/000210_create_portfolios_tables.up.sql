@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS portfolios (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR NOT NULL UNIQUE,
+    -- Currency the portfolio is valued in
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    notification_id INTEGER REFERENCES notifications,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS holdings (
+    id BIGSERIAL PRIMARY KEY,
+    portfolio_id BIGINT NOT NULL REFERENCES portfolios ON DELETE CASCADE,
+    ticker_id VARCHAR NOT NULL REFERENCES moex_assets,
+    quantity NUMERIC NOT NULL CHECK (quantity > 0),
+    average_price NUMERIC NOT NULL CHECK (average_price >= 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    UNIQUE (portfolio_id, ticker_id)
+);
+
+-- Portfolio level alert rules, fire once when the condition is met and
+-- re-arm when it is not met anymore
+CREATE TABLE IF NOT EXISTS portfolio_rules (
+    id BIGSERIAL PRIMARY KEY,
+    portfolio_id BIGINT NOT NULL REFERENCES portfolios ON DELETE CASCADE,
+    condition VARCHAR NOT NULL CHECK (condition IN (
+        'drawdown_above',
+        'pnl_above', 'pnl_below',
+        'weight_above'
+    )),
+    threshold NUMERIC NOT NULL,
+    -- Holding of the weight rule, any holding if null
+    ticker_id VARCHAR REFERENCES moex_assets,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    is_armed BOOLEAN NOT NULL DEFAULT TRUE,
+    last_triggered_at TIMESTAMP,
+    CONSTRAINT portfolio_rules_ticker_check CHECK (ticker_id IS NULL OR condition = 'weight_above')
+);
+
+CREATE INDEX IF NOT EXISTS holdings_portfolio_id_idx ON holdings (portfolio_id);
+CREATE INDEX IF NOT EXISTS portfolio_rules_portfolio_id_idx ON portfolio_rules (portfolio_id);
+
+GRANT SELECT ON portfolios, holdings, portfolio_rules TO moexmon;
+GRANT UPDATE (is_armed, last_triggered_at) ON portfolio_rules TO moexmon;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS moex_event_notices;
+DROP TABLE IF EXISTS moex_event_subscriptions;
+DROP TABLE IF EXISTS moex_corporate_events;
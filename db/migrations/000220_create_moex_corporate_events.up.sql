@@ -0,0 +1,40 @@
+-- Upcoming dividends, coupons and offers of the assets: the date is the
+-- registry close date of the dividend, the coupon or the offer date
+CREATE TABLE IF NOT EXISTS moex_corporate_events (
+    id BIGSERIAL PRIMARY KEY,
+    ticker_id VARCHAR NOT NULL REFERENCES moex_assets ON DELETE CASCADE,
+    kind VARCHAR NOT NULL CHECK (kind IN ('dividend', 'coupon', 'offer')),
+    event_date DATE NOT NULL,
+    value NUMERIC,
+    currency VARCHAR,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (ticker_id, kind, event_date)
+);
+
+CREATE INDEX IF NOT EXISTS moex_corporate_events_date_idx ON moex_corporate_events (event_date);
+
+-- Subscription of the notification to the events of the asset, or of
+-- all the assets if the ticker is not set
+CREATE TABLE IF NOT EXISTS moex_event_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    notification_id INTEGER NOT NULL REFERENCES notifications ON DELETE CASCADE,
+    ticker_id VARCHAR REFERENCES moex_assets ON DELETE CASCADE,
+    days_before INTEGER NOT NULL DEFAULT 3 CHECK (days_before >= 0 AND days_before <= 90),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS moex_event_subscriptions_unique_idx
+    ON moex_event_subscriptions (notification_id, COALESCE(ticker_id, ''));
+
+-- Events already notified to the subscriptions
+CREATE TABLE IF NOT EXISTS moex_event_notices (
+    subscription_id BIGINT NOT NULL REFERENCES moex_event_subscriptions ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES moex_corporate_events ON DELETE CASCADE,
+    sent_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscription_id, event_id)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON moex_corporate_events TO moexmon;
+GRANT USAGE ON SEQUENCE moex_corporate_events_id_seq TO moexmon;
+GRANT SELECT ON moex_event_subscriptions TO moexmon;
+GRANT SELECT, INSERT ON moex_event_notices TO moexmon;
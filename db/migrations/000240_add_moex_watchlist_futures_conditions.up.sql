@@ -0,0 +1,28 @@
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_condition_check;
+
+ALTER TABLE moex_watchlist
+    ADD CONSTRAINT moex_watchlist_condition_check CHECK (condition IN (
+        'above', 'below',
+        'change_above', 'change_below',
+        'open_change_above', 'open_change_below',
+        'new_high', 'new_low',
+        'vwap_above', 'vwap_below',
+        'sma_cross_above', 'sma_cross_below',
+        'ema_cross_above', 'ema_cross_below',
+        'rsi_above', 'rsi_below',
+        'bollinger_above', 'bollinger_below',
+        'volume_spike', 'turnover_spike',
+        'spread_above', 'spread_below',
+        'ratio_above', 'ratio_below',
+        'spread_pct_above', 'spread_pct_below',
+        'price_pct_above', 'price_pct_below',
+        'yield_above', 'yield_below',
+        'current_yield_above', 'current_yield_below',
+        'accrued_above', 'accrued_below',
+        'duration_above', 'duration_below',
+        'maturity_within', 'offer_within',
+        'oi_above', 'oi_below',
+        'oi_change_above', 'oi_change_below',
+        'expiration_within'
+    ));
@@ -0,0 +1,6 @@
+ALTER TABLE moex_watchlist
+    DROP CONSTRAINT IF EXISTS moex_watchlist_validity_check,
+    DROP COLUMN IF EXISTS valid_from,
+    DROP COLUMN IF EXISTS valid_until,
+    DROP COLUMN IF EXISTS schedule,
+    DROP COLUMN IF EXISTS archived_at;
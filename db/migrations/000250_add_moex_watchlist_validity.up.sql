@@ -0,0 +1,7 @@
+ALTER TABLE moex_watchlist
+    ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS valid_until TIMESTAMPTZ,
+    -- Cron-like schedule in Moscow time the rule may fire on, always if not set
+    ADD COLUMN IF NOT EXISTS schedule VARCHAR,
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ,
+    ADD CONSTRAINT moex_watchlist_validity_check CHECK (valid_from IS NULL OR valid_until IS NULL OR valid_from < valid_until);
@@ -0,0 +1,20 @@
+-- Fired portfolio rules, the counterpart of moex_alerts
+CREATE TABLE IF NOT EXISTS portfolio_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id BIGINT NOT NULL REFERENCES portfolio_rules ON DELETE CASCADE,
+    timestamp TIMESTAMP NOT NULL,
+    condition VARCHAR NOT NULL,
+    threshold NUMERIC NOT NULL,
+    -- Value of the rule and of the portfolio when the rule fired
+    value NUMERIC NOT NULL,
+    portfolio_value NUMERIC NOT NULL,
+    -- Holding of the weight rule
+    ticker_id VARCHAR,
+    published BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS portfolio_alerts_rule_id_idx ON portfolio_alerts (rule_id);
+CREATE INDEX IF NOT EXISTS portfolio_alerts_timestamp_idx ON portfolio_alerts (timestamp);
+
+GRANT SELECT, INSERT ON portfolio_alerts TO moexmon;
+GRANT USAGE ON SEQUENCE portfolio_alerts_id_seq TO moexmon;
@@ -1,10 +1,13 @@
 package godfather
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -26,12 +29,268 @@ type Database struct {
 // MOEX watchlist item
 // ----------------------------------------------------------------
 type MOEXWatchlistItem struct {
-	Ticker         string
-	AssetClass     string
+	ID              int
+	Ticker          string
+	AssetClass      string
+	Engine          string // ISS engine, market and board of the asset, empty if not detected yet
+	Market          string
+	Board           string
+	NotificationID  int
+	TargetPrice     float64
+	Condition       string
+	Active          bool
+	Mode            string        // "oneshot" (deactivated once fired) or "crossing" (re-armed)
+	Hysteresis      float64       // distance from the target to re-arm a crossing rule
+	Cooldown        time.Duration // minimal interval between two firings of a crossing rule
+	Armed           bool
+	LastTriggeredAt time.Time // zero if the rule never fired
+	Params          MOEXRuleParams
+	Pair            MOEXPairLeg // second leg of the pair rules, empty otherwise
+	ValidFrom       time.Time   // zero if the rule is valid since its creation
+	ValidUntil      time.Time   // zero if the rule never expires
+	Schedule        string      // cron-like schedule (Moscow time) the rule may fire on, empty if always
+	ArchivedAt      time.Time   // zero unless the rule expired and was archived
+}
+
+// ----------------------------------------------------------------
+// Second leg of the MOEX pair rule, compared with the item's asset
+// ----------------------------------------------------------------
+type MOEXPairLeg struct {
+	Ticker     string
+	AssetClass string
+	Engine     string
+	Market     string
+	Board      string
+}
+
+// ----------------------------------------------------------------
+// Parameters of the MOEX watchlist rule (zero if not set)
+// ----------------------------------------------------------------
+type MOEXRuleParams struct {
+	CandleInterval int // ISS candle interval: 1, 10, 60, 24 (day), 7 (week), 31 (month), 4 (quarter)
+	Period         int
+	FastPeriod     int
+	SlowPeriod     int
+	BandWidth      float64       // Bollinger bands width in standard deviations
+	LookbackDays   int           // number of trading days to average the volume over
+	Deadline       time.Duration // time of day (Moscow) after which the rule can't fire
+}
+
+// MOEX watchlist rule modes
+const (
+	MOEXRuleOneShot  = "oneshot"
+	MOEXRuleCrossing = "crossing"
+)
+
+const moexWatchlistQuery = "SELECT moex_watchlist.id, moex_assets.ticker, moex_assets.class_id, COALESCE(moex_assets.engine, ''), COALESCE(moex_assets.market, ''), COALESCE(moex_assets.board, ''), moex_watchlist.notification_id, COALESCE(moex_watchlist.target_price, 0), moex_watchlist.condition, moex_watchlist.is_active, moex_watchlist.mode, moex_watchlist.hysteresis, moex_watchlist.cooldown_seconds, moex_watchlist.is_armed, moex_watchlist.last_triggered_at, " +
+	"COALESCE(moex_watchlist_params.candle_interval, 24), COALESCE(moex_watchlist_params.period, 0), COALESCE(moex_watchlist_params.fast_period, 0), COALESCE(moex_watchlist_params.slow_period, 0), COALESCE(moex_watchlist_params.band_width, 0), " +
+	"COALESCE(moex_watchlist_params.lookback_days, 0), COALESCE(EXTRACT(EPOCH FROM moex_watchlist_params.deadline)::INTEGER, 0), " +
+	"COALESCE(second_assets.ticker, ''), COALESCE(second_assets.class_id, ''), COALESCE(second_assets.engine, ''), COALESCE(second_assets.market, ''), COALESCE(second_assets.board, ''), " +
+	"moex_watchlist.valid_from, moex_watchlist.valid_until, COALESCE(moex_watchlist.schedule, ''), moex_watchlist.archived_at " +
+	"FROM moex_watchlist INNER JOIN moex_assets ON moex_watchlist.ticker_id = moex_assets.ticker LEFT JOIN moex_watchlist_params ON moex_watchlist_params.watchlist_id = moex_watchlist.id " +
+	"LEFT JOIN moex_assets AS second_assets ON moex_watchlist.second_ticker_id = second_assets.ticker"
+
+// ----------------------------------------------------------------
+// MOEX asset of the catalog
+// ----------------------------------------------------------------
+type MOEXAsset struct {
+	Ticker    string    `json:"ticker"`
+	ClassID   string    `json:"class_id"`
+	Name      string    `json:"name"`
+	ShortName string    `json:"short_name"`
+	ISIN      string    `json:"isin"`
+	Engine    string    `json:"engine"`
+	Market    string    `json:"market"`
+	Board     string    `json:"board"`
+	LotSize   int       `json:"lot_size"`
+	Currency  string    `json:"currency"`
+	Delisted  bool      `json:"delisted"`
+	SyncedAt  time.Time `json:"synced_at"` // zero if the asset was added by hand
+}
+
+const moexAssetsQuery = "SELECT ticker, class_id, name, COALESCE(short_name, ''), COALESCE(isin, ''), COALESCE(engine, ''), COALESCE(market, ''), COALESCE(board, ''), " +
+	"COALESCE(lot_size, 0), COALESCE(currency, ''), is_delisted, synced_at FROM moex_assets"
+
+// ----------------------------------------------------------------
+// Polled MOEX price of the asset
+// ----------------------------------------------------------------
+type MOEXQuote struct {
+	Ticker    string
+	Timestamp time.Time
+	Price     float64
+	VolToday  float64 // NaN if not reported
+}
+
+// ----------------------------------------------------------------
+// OHLC bar of the MOEX price history
+// ----------------------------------------------------------------
+type MOEXQuoteBar struct {
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+}
+
+// MOEX price history intervals: the polled quotes and the rollups
+const (
+	MOEXQuoteRaw    = "raw"
+	MOEXQuoteMinute = "1m"
+	MOEXQuoteHour   = "1h"
+	MOEXQuoteDay    = "1d"
+)
+
+// Rollups in the order they must be computed: the truncation unit
+// and the source interval of each one
+var moexQuoteRollups = []struct {
+	interval string
+	unit     string
+	source   string
+}{
+	{MOEXQuoteMinute, "minute", MOEXQuoteRaw},
+	{MOEXQuoteHour, "hour", MOEXQuoteMinute},
+	{MOEXQuoteDay, "day", MOEXQuoteHour},
+}
+
+// ----------------------------------------------------------------
+// Triggered MOEX alert
+// ----------------------------------------------------------------
+type MOEXAlert struct {
+	ID           int       `json:"id"`
+	WatchlistID  int       `json:"watchlist_id"`
+	Ticker       string    `json:"ticker"`
+	Price        float64   `json:"price"`
+	TargetPrice  float64   `json:"target_price"`
+	Condition    string    `json:"condition"`
+	Published    bool      `json:"published"`
+	Timestamp    time.Time `json:"timestamp"`
+	SecondTicker string    `json:"second_ticker,omitempty"` // second leg of the pair rules, empty otherwise
+	SecondPrice  float64   `json:"second_price,omitempty"`
+}
+
+// ----------------------------------------------------------------
+// Filter for the triggered MOEX alerts (zero values are ignored)
+// ----------------------------------------------------------------
+type MOEXAlertFilter struct {
+	Ticker string
+	From   time.Time
+	To     time.Time
+}
+
+// ----------------------------------------------------------------
+// Upcoming dividend, coupon or offer of the MOEX asset
+// ----------------------------------------------------------------
+type MOEXCorporateEvent struct {
+	ID       int
+	Ticker   string
+	Kind     string
+	Date     time.Time // registry close date of the dividend, the coupon or the offer date
+	Value    float64   // NaN if unknown, in % of the face value for the offers
+	Currency string
+}
+
+// MOEX corporate event kinds
+const (
+	MOEXDividend = "dividend"
+	MOEXCoupon   = "coupon"
+	MOEXOffer    = "offer"
+)
+
+// ----------------------------------------------------------------
+// Subscription of the notification to the corporate events
+// ----------------------------------------------------------------
+type MOEXEventSubscription struct {
+	ID             int    `json:"id"`
+	NotificationID int    `json:"notification_id"`
+	Ticker         string `json:"ticker,omitempty"` // all the assets if empty
+	DaysBefore     int    `json:"days_before"`
+}
+
+// ----------------------------------------------------------------
+// Corporate event due to be notified to the subscription
+// ----------------------------------------------------------------
+type MOEXEventNotice struct {
+	SubscriptionID int
 	NotificationID int
-	TargetPrice    float64
-	Condition      string
-	Active         bool
+	Event          MOEXCorporateEvent
+}
+
+// ----------------------------------------------------------------
+// Portfolio of the MOEX holdings
+// ----------------------------------------------------------------
+type Portfolio struct {
+	ID             int             `json:"id"`
+	Name           string          `json:"name"`
+	Currency       string          `json:"currency"`        // currency the portfolio is valued in
+	NotificationID int             `json:"notification_id"` // zero if not set
+	Holdings       []Holding       `json:"holdings"`
+	Rules          []PortfolioRule `json:"rules"`
+}
+
+// ----------------------------------------------------------------
+// Position of the portfolio
+// ----------------------------------------------------------------
+type Holding struct {
+	ID           int     `json:"id"`
+	PortfolioID  int     `json:"portfolio_id"`
+	Ticker       string  `json:"ticker"`
+	Quantity     float64 `json:"quantity"`
+	AveragePrice float64 `json:"average_price"`
+	Currency     string  `json:"currency"`
+	// The asset's class and ISS board, only loaded for monitoring
+	AssetClass string `json:"-"`
+	Engine     string `json:"-"`
+	Market     string `json:"-"`
+	Board      string `json:"-"`
+}
+
+// ----------------------------------------------------------------
+// Portfolio level alert rule
+// ----------------------------------------------------------------
+type PortfolioRule struct {
+	ID              int       `json:"id"`
+	PortfolioID     int       `json:"portfolio_id"`
+	Condition       string    `json:"condition"`
+	Threshold       float64   `json:"threshold"`
+	Ticker          string    `json:"ticker,omitempty"` // holding of the weight rule, any holding if empty
+	Active          bool      `json:"active"`
+	Armed           bool      `json:"armed"`
+	LastTriggeredAt time.Time `json:"last_triggered_at,omitzero"` // zero if the rule never fired
+}
+
+// ----------------------------------------------------------------
+// Fired portfolio rule
+// ----------------------------------------------------------------
+type PortfolioAlert struct {
+	ID             int       `json:"id"`
+	RuleID         int       `json:"rule_id"`
+	Condition      string    `json:"condition"`
+	Threshold      float64   `json:"threshold"`
+	Value          float64   `json:"value"`
+	PortfolioValue float64   `json:"portfolio_value"`
+	Ticker         string    `json:"ticker,omitempty"` // holding of the weight rule
+	Published      bool      `json:"published"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// Portfolio rule conditions, must be kept in sync with portfolio_rules_condition_check
+const (
+	PortfolioDrawdownAbove = "drawdown_above" // loss of the value today, in % of the previous close
+	PortfolioPnLAbove      = "pnl_above"      // unrealized P&L, in % of the cost
+	PortfolioPnLBelow      = "pnl_below"
+	PortfolioWeightAbove   = "weight_above" // value of the holding, in % of the portfolio value
+)
+
+// ----------------------------------------------------------------
+// Portfolio, holding or rule not found error
+// ----------------------------------------------------------------
+type PortfolioNotFound struct {
+	Entity string
+	ID     int
+}
+
+func (e *PortfolioNotFound) Error() string {
+	return fmt.Sprintf("%s not found: %d", e.Entity, e.ID)
 }
 
 // ----------------------------------------------------------------
@@ -148,6 +407,59 @@ func (db *Database) Close() error {
 	return nil
 }
 
+// ----------------------------------------------------------------
+// Session-level Postgres advisory lock. It is held on a dedicated
+// connection and released by Postgres if the connection is lost.
+// ----------------------------------------------------------------
+type AdvisoryLock struct {
+	key  int64
+	conn *sql.Conn
+}
+
+// ----------------------------------------------------------------
+// Try to take the advisory lock without waiting, nil if another
+// session holds it
+// ----------------------------------------------------------------
+func (db *Database) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	conn, err := db.handle.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock %d: %w", key, err)
+	}
+	if !locked {
+		return nil, conn.Close()
+	}
+	return &AdvisoryLock{key: key, conn: conn}, nil
+}
+
+// ----------------------------------------------------------------
+// Check that the connection holding the lock is still alive
+// ----------------------------------------------------------------
+func (lock *AdvisoryLock) Check(ctx context.Context) error {
+	if err := lock.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("advisory lock %d is lost: %w", lock.key, err)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Release the lock and return the connection to the pool
+// ----------------------------------------------------------------
+func (lock *AdvisoryLock) Release(ctx context.Context) error {
+	_, err := lock.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lock.key)
+	if closeErr := lock.conn.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release advisory lock %d: %w", lock.key, err)
+	}
+	return nil
+}
+
 // ----------------------------------------------------------------
 // User management
 // ----------------------------------------------------------------
@@ -258,10 +570,10 @@ func (db *Database) GetMOEXWatchlist(activeOnly bool) ([]MOEXWatchlistItem, erro
 	var err error
 	if activeOnly {
 		slog.Debug("Retrieving active MOEX watchlist items")
-		rows, err = db.handle.Query("SELECT moex_assets.ticker, moex_assets.class_id, moex_watchlist.notification_id, moex_watchlist.target_price::numeric, moex_watchlist.condition, moex_watchlist.is_active FROM moex_watchlist INNER JOIN moex_assets ON moex_watchlist.ticker_id = moex_assets.ticker WHERE moex_watchlist.is_active = true")
+		rows, err = db.handle.Query(moexWatchlistQuery + " WHERE moex_watchlist.is_active = true")
 	} else {
 		slog.Debug("Retrieving all MOEX watchlist items")
-		rows, err = db.handle.Query("SELECT moex_assets.ticker, moex_assets.class_id, moex_watchlist.notification_id, moex_watchlist.target_price::numeric, moex_watchlist.condition, moex_watchlist.is_active FROM moex_watchlist INNER JOIN moex_assets ON moex_watchlist.ticker_id = moex_assets.ticker")
+		rows, err = db.handle.Query(moexWatchlistQuery)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query MOEX watchlist: %w", err)
@@ -275,22 +587,833 @@ func (db *Database) GetMOEXWatchlist(activeOnly bool) ([]MOEXWatchlistItem, erro
 	var watchlist []MOEXWatchlistItem
 	for rows.Next() {
 		var item MOEXWatchlistItem
-		if err := rows.Scan(&item.Ticker, &item.AssetClass, &item.NotificationID, &item.TargetPrice, &item.Condition, &item.Active); err != nil {
+		var cooldownSeconds, deadlineSeconds int
+		var lastTriggeredAt, validFrom, validUntil, archivedAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.Ticker, &item.AssetClass, &item.Engine, &item.Market, &item.Board, &item.NotificationID, &item.TargetPrice, &item.Condition, &item.Active,
+			&item.Mode, &item.Hysteresis, &cooldownSeconds, &item.Armed, &lastTriggeredAt,
+			&item.Params.CandleInterval, &item.Params.Period, &item.Params.FastPeriod, &item.Params.SlowPeriod, &item.Params.BandWidth,
+			&item.Params.LookbackDays, &deadlineSeconds,
+			&item.Pair.Ticker, &item.Pair.AssetClass, &item.Pair.Engine, &item.Pair.Market, &item.Pair.Board,
+			&validFrom, &validUntil, &item.Schedule, &archivedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
+		item.Cooldown = time.Duration(cooldownSeconds) * time.Second
+		item.Params.Deadline = time.Duration(deadlineSeconds) * time.Second
+		item.LastTriggeredAt = lastTriggeredAt.Time
+		item.ValidFrom = validFrom.Time
+		item.ValidUntil = validUntil.Time
+		item.ArchivedAt = archivedAt.Time
 		watchlist = append(watchlist, item)
 	}
 	return watchlist, nil
 }
 
 // ----------------------------------------------------------------
-func (db *Database) SetMOEXWatchlistItemActiveStatus(ticker string, active bool) error {
-	query := "UPDATE moex_watchlist SET is_active = $1 WHERE ticker_id = $2"
-	_, err := db.handle.Exec(query, active, ticker)
+func (db *Database) SetMOEXWatchlistItemActiveStatus(id int, active bool) error {
+	query := "UPDATE moex_watchlist SET is_active = $1 WHERE id = $2"
+	_, err := db.handle.Exec(query, active, id)
 	if err != nil {
 		return fmt.Errorf("failed to update MOEX watchlist item active status: %w", err)
 	}
-	log.Debug(fmt.Sprintf("MOEX watchlist item %s active status set to %t", ticker, active))
+	log.Debug(fmt.Sprintf("MOEX watchlist item %d active status set to %t", id, active))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Persist the trigger state of the crossing MOEX watchlist item
+// ----------------------------------------------------------------
+func (db *Database) SetMOEXWatchlistItemTriggerState(id int, armed bool, lastTriggeredAt time.Time) error {
+	var triggeredAt sql.NullTime
+	if !lastTriggeredAt.IsZero() {
+		triggeredAt = sql.NullTime{Time: lastTriggeredAt, Valid: true}
+	}
+	query := "UPDATE moex_watchlist SET is_armed = $1, last_triggered_at = $2 WHERE id = $3"
+	_, err := db.handle.Exec(query, armed, triggeredAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update MOEX watchlist item trigger state: %w", err)
+	}
+	log.Debug(fmt.Sprintf("MOEX watchlist item %d armed status set to %t", id, armed))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Deactivate the expired MOEX watchlist item and mark it archived
+// ----------------------------------------------------------------
+func (db *Database) ArchiveMOEXWatchlistItem(id int, archivedAt time.Time) error {
+	query := "UPDATE moex_watchlist SET is_active = false, archived_at = $1 WHERE id = $2"
+	_, err := db.handle.Exec(query, archivedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to archive MOEX watchlist item: %w", err)
+	}
+	log.Debug(fmt.Sprintf("MOEX watchlist item %d archived", id))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Store the ISS engine, market and board the asset is traded on
+// ----------------------------------------------------------------
+func (db *Database) SetMOEXAssetBoard(ticker string, engine string, market string, board string) error {
+	query := "UPDATE moex_assets SET engine = $1, market = $2, board = $3 WHERE ticker = $4"
+	_, err := db.handle.Exec(query, engine, market, board, ticker)
+	if err != nil {
+		return fmt.Errorf("failed to update MOEX asset board: %w", err)
+	}
+	log.Debug(fmt.Sprintf("MOEX asset %s board set to %s/%s/%s", ticker, engine, market, board))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Insert or update the assets synchronized from the ISS catalog. The
+// class of the existing assets is kept.
+// ----------------------------------------------------------------
+func (db *Database) UpsertMOEXAssets(assets []MOEXAsset) error {
+	tx, err := db.handle.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.Prepare("INSERT INTO moex_assets (ticker, class_id, name, short_name, isin, engine, market, board, lot_size, currency, is_delisted, synced_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, FALSE, $11) ON CONFLICT (ticker) DO UPDATE SET name = EXCLUDED.name, short_name = EXCLUDED.short_name, " +
+		"isin = EXCLUDED.isin, engine = EXCLUDED.engine, market = EXCLUDED.market, board = EXCLUDED.board, lot_size = EXCLUDED.lot_size, " +
+		"currency = EXCLUDED.currency, is_delisted = FALSE, synced_at = EXCLUDED.synced_at")
+	if err != nil {
+		return fmt.Errorf("failed to prepare MOEX assets upsert: %w", err)
+	}
+	defer stmt.Close() //nolint:errcheck
+
+	for _, asset := range assets {
+		_, err := stmt.Exec(asset.Ticker, asset.ClassID, asset.Name, asset.ShortName, asset.ISIN, asset.Engine, asset.Market, asset.Board,
+			asset.LotSize, asset.Currency, asset.SyncedAt)
+		if err != nil {
+			return fmt.Errorf("failed to upsert MOEX asset %s: %w", asset.Ticker, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit MOEX assets: %w", err)
+	}
+	log.Debug(fmt.Sprintf("%d MOEX assets synchronized", len(assets)))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Flag the assets of the board not synchronized since the given time
+// as delisted, returns the number of the flagged assets
+// ----------------------------------------------------------------
+func (db *Database) MarkMOEXAssetsDelisted(engine string, market string, board string, syncedBefore time.Time) (int64, error) {
+	query := "UPDATE moex_assets SET is_delisted = TRUE WHERE engine = $1 AND market = $2 AND board = $3 AND is_delisted = FALSE AND (synced_at IS NULL OR synced_at < $4)"
+	result, err := db.handle.Exec(query, engine, market, board, syncedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark MOEX assets delisted: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count delisted MOEX assets: %w", err)
+	}
+	return count, nil
+}
+
+// ----------------------------------------------------------------
+// Search the assets by ticker, ISIN or name, listed assets first
+// ----------------------------------------------------------------
+func (db *Database) SearchMOEXAssets(pattern string, includeDelisted bool, limit int) ([]MOEXAsset, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(pattern)
+	query := moexAssetsQuery + " WHERE (ticker ILIKE $1 OR isin ILIKE $1 OR short_name ILIKE $1 OR name ILIKE $1)"
+	if !includeDelisted {
+		query += " AND is_delisted = FALSE"
+	}
+	query += " ORDER BY is_delisted, ticker ILIKE $2 DESC, ticker LIMIT $3"
+
+	rows, err := db.handle.Query(query, "%"+escaped+"%", escaped+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search MOEX assets: %w", err)
+	}
+	return scanMOEXAssets(rows)
+}
+
+// ----------------------------------------------------------------
+// Get the listed assets of the classes
+// ----------------------------------------------------------------
+func (db *Database) GetListedMOEXAssets(classes []string) ([]MOEXAsset, error) {
+	query := moexAssetsQuery + " WHERE is_delisted = FALSE AND class_id = ANY($1) ORDER BY ticker"
+	rows, err := db.handle.Query(query, classes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MOEX assets: %w", err)
+	}
+	return scanMOEXAssets(rows)
+}
+
+// ----------------------------------------------------------------
+func scanMOEXAssets(rows *sql.Rows) ([]MOEXAsset, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	var assets []MOEXAsset
+	for rows.Next() {
+		var asset MOEXAsset
+		var syncedAt sql.NullTime
+		if err := rows.Scan(&asset.Ticker, &asset.ClassID, &asset.Name, &asset.ShortName, &asset.ISIN, &asset.Engine, &asset.Market, &asset.Board,
+			&asset.LotSize, &asset.Currency, &asset.Delisted, &syncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		asset.SyncedAt = syncedAt.Time
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// ----------------------------------------------------------------
+// MOEX price history management
+// ----------------------------------------------------------------
+// Create the daily partition of the quotes if it doesn't exist
+// ----------------------------------------------------------------
+func (db *Database) EnsureMOEXQuotesPartition(day time.Time) error {
+	_, err := db.handle.Exec("SELECT moex_quotes_create_partition($1::date)", day.Format(time.DateOnly))
+	if err != nil {
+		return fmt.Errorf("failed to create MOEX quotes partition for %s: %w", day.Format(time.DateOnly), err)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Drop the daily partitions of the quotes before the given day,
+// returns the number of the dropped partitions
+// ----------------------------------------------------------------
+func (db *Database) DropMOEXQuotesBefore(day time.Time) (int64, error) {
+	var dropped int64
+	row := db.handle.QueryRow("SELECT moex_quotes_drop_partitions($1::date)", day.Format(time.DateOnly))
+	if err := row.Scan(&dropped); err != nil {
+		return 0, fmt.Errorf("failed to drop MOEX quotes partitions: %w", err)
+	}
+	return dropped, nil
+}
+
+// ----------------------------------------------------------------
+// Store the polled quotes
+// ----------------------------------------------------------------
+func (db *Database) AddMOEXQuotes(quotes []MOEXQuote) error {
+	tx, err := db.handle.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.Prepare("INSERT INTO moex_quotes (ticker, timestamp, price, vol_today) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING")
+	if err != nil {
+		return fmt.Errorf("failed to prepare MOEX quotes insert: %w", err)
+	}
+	defer stmt.Close() //nolint:errcheck
+
+	for _, quote := range quotes {
+		volToday := sql.NullFloat64{Float64: quote.VolToday, Valid: !math.IsNaN(quote.VolToday)}
+		if _, err := stmt.Exec(quote.Ticker, quote.Timestamp, quote.Price, volToday); err != nil {
+			return fmt.Errorf("failed to store MOEX quote for %s: %w", quote.Ticker, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit MOEX quotes: %w", err)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Recompute the bars of the interval starting from the bucket that
+// contains since, returns the number of the bars written
+// ----------------------------------------------------------------
+func (db *Database) RollupMOEXQuotes(interval string, since time.Time) (int64, error) {
+	var query string
+	for _, rollup := range moexQuoteRollups {
+		if rollup.interval != interval {
+			continue
+		}
+		if rollup.source == MOEXQuoteRaw {
+			query = fmt.Sprintf("INSERT INTO moex_quote_bars (ticker, interval, bucket, open, high, low, close) "+
+				"SELECT ticker, $1, date_trunc('%[1]s', timestamp) AS period, (array_agg(price ORDER BY timestamp))[1], MAX(price), MIN(price), (array_agg(price ORDER BY timestamp DESC))[1] "+
+				"FROM moex_quotes WHERE timestamp >= date_trunc('%[1]s', $2::timestamp) GROUP BY ticker, period", rollup.unit)
+		} else {
+			query = fmt.Sprintf("INSERT INTO moex_quote_bars (ticker, interval, bucket, open, high, low, close) "+
+				"SELECT ticker, $1, date_trunc('%[1]s', bucket) AS period, (array_agg(open ORDER BY bucket))[1], MAX(high), MIN(low), (array_agg(close ORDER BY bucket DESC))[1] "+
+				"FROM moex_quote_bars WHERE interval = '%[2]s' AND bucket >= date_trunc('%[1]s', $2::timestamp) GROUP BY ticker, period", rollup.unit, rollup.source)
+		}
+		query += " ON CONFLICT (ticker, interval, bucket) DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close"
+	}
+	if query == "" {
+		return 0, fmt.Errorf("unsupported MOEX quotes rollup interval: %s", interval)
+	}
+
+	result, err := db.handle.Exec(query, interval, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll up MOEX quotes to %s: %w", interval, err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s MOEX quote bars: %w", interval, err)
+	}
+	return count, nil
+}
+
+// ----------------------------------------------------------------
+// Delete the bars of the interval before the given time
+// ----------------------------------------------------------------
+func (db *Database) DeleteMOEXQuoteBarsBefore(interval string, before time.Time) (int64, error) {
+	result, err := db.handle.Exec("DELETE FROM moex_quote_bars WHERE interval = $1 AND bucket < $2", interval, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete %s MOEX quote bars: %w", interval, err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted %s MOEX quote bars: %w", interval, err)
+	}
+	return count, nil
+}
+
+// ----------------------------------------------------------------
+// Get the price history of the asset, oldest first. The polled
+// quotes are returned as bars with all the prices equal.
+// ----------------------------------------------------------------
+func (db *Database) GetMOEXQuoteHistory(ticker string, interval string, from time.Time, to time.Time, limit int) ([]MOEXQuoteBar, error) {
+	var rows *sql.Rows
+	var err error
+	switch interval {
+	case MOEXQuoteRaw:
+		query := "SELECT timestamp, price, price, price, price FROM moex_quotes WHERE ticker = $1 AND timestamp >= $2 AND timestamp <= $3 ORDER BY timestamp LIMIT $4"
+		rows, err = db.handle.Query(query, ticker, from, to, limit)
+	case MOEXQuoteMinute, MOEXQuoteHour, MOEXQuoteDay:
+		query := "SELECT bucket, open, high, low, close FROM moex_quote_bars WHERE ticker = $1 AND interval = $2 AND bucket >= $3 AND bucket <= $4 ORDER BY bucket LIMIT $5"
+		rows, err = db.handle.Query(query, ticker, interval, from, to, limit)
+	default:
+		return nil, fmt.Errorf("unsupported MOEX quotes interval: %s", interval)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MOEX quote history: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	var bars []MOEXQuoteBar
+	for rows.Next() {
+		var bar MOEXQuoteBar
+		if err := rows.Scan(&bar.Timestamp, &bar.Open, &bar.High, &bar.Low, &bar.Close); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
+
+// ----------------------------------------------------------------
+// MOEX alerts management
+// ----------------------------------------------------------------
+// Record the triggered MOEX alert
+// ----------------------------------------------------------------
+func (db *Database) AddMOEXAlert(alert *MOEXAlert) error {
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+	secondPrice := sql.NullFloat64{Float64: alert.SecondPrice, Valid: alert.SecondTicker != ""}
+	query := "INSERT INTO moex_alerts (watchlist_id, timestamp, price, target_price, condition, published, second_price) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	row := db.handle.QueryRow(query, alert.WatchlistID, alert.Timestamp, alert.Price, alert.TargetPrice, alert.Condition, alert.Published, secondPrice)
+	if err := row.Scan(&alert.ID); err != nil {
+		return fmt.Errorf("failed to record MOEX alert: %w", err)
+	}
+	log.Debug(fmt.Sprintf("MOEX alert %d recorded for watchlist item %d", alert.ID, alert.WatchlistID))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Get the triggered MOEX alerts matching the filter, newest first
+// ----------------------------------------------------------------
+func (db *Database) GetMOEXAlerts(filter MOEXAlertFilter) ([]MOEXAlert, error) {
+	query := "SELECT moex_alerts.id, moex_alerts.watchlist_id, moex_watchlist.ticker_id, moex_alerts.price, moex_alerts.target_price, moex_alerts.condition, moex_alerts.published, moex_alerts.timestamp, COALESCE(moex_watchlist.second_ticker_id, ''), moex_alerts.second_price FROM moex_alerts INNER JOIN moex_watchlist ON moex_alerts.watchlist_id = moex_watchlist.id"
+
+	var conditions []string
+	var args []any
+	if filter.Ticker != "" {
+		args = append(args, filter.Ticker)
+		conditions = append(conditions, fmt.Sprintf("moex_watchlist.ticker_id = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("moex_alerts.timestamp >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("moex_alerts.timestamp <= $%d", len(args)))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY moex_alerts.timestamp DESC"
+
+	rows, err := db.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MOEX alerts: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	var alerts []MOEXAlert
+	for rows.Next() {
+		var alert MOEXAlert
+		var price, targetPrice, secondPrice sql.NullFloat64
+		var condition sql.NullString
+		if err := rows.Scan(&alert.ID, &alert.WatchlistID, &alert.Ticker, &price, &targetPrice, &condition, &alert.Published, &alert.Timestamp,
+			&alert.SecondTicker, &secondPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		alert.Price = price.Float64
+		alert.SecondPrice = secondPrice.Float64
+		alert.TargetPrice = targetPrice.Float64
+		alert.Condition = condition.String
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// ----------------------------------------------------------------
+// MOEX corporate events management
+// ----------------------------------------------------------------
+// Insert or update the corporate events of the assets
+// ----------------------------------------------------------------
+func (db *Database) UpsertMOEXCorporateEvents(events []MOEXCorporateEvent) error {
+	tx, err := db.handle.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.Prepare("INSERT INTO moex_corporate_events (ticker_id, kind, event_date, value, currency, updated_at) VALUES ($1, $2, $3, $4, $5, $6) " +
+		"ON CONFLICT (ticker_id, kind, event_date) DO UPDATE SET value = EXCLUDED.value, currency = EXCLUDED.currency, updated_at = EXCLUDED.updated_at")
+	if err != nil {
+		return fmt.Errorf("failed to prepare MOEX corporate events upsert: %w", err)
+	}
+	defer stmt.Close() //nolint:errcheck
+
+	now := time.Now()
+	for _, event := range events {
+		value := sql.NullFloat64{Float64: event.Value, Valid: !math.IsNaN(event.Value)}
+		currency := sql.NullString{String: event.Currency, Valid: event.Currency != ""}
+		if _, err := stmt.Exec(event.Ticker, event.Kind, event.Date, value, currency, now); err != nil {
+			return fmt.Errorf("failed to upsert MOEX %s of %s: %w", event.Kind, event.Ticker, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit MOEX corporate events: %w", err)
+	}
+	log.Debug(fmt.Sprintf("%d MOEX corporate events synchronized", len(events)))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Delete the corporate events before the given day
+// ----------------------------------------------------------------
+func (db *Database) DeleteMOEXCorporateEventsBefore(day time.Time) (int64, error) {
+	result, err := db.handle.Exec("DELETE FROM moex_corporate_events WHERE event_date < $1", day)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete MOEX corporate events: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the number of deleted MOEX corporate events: %w", err)
+	}
+	return count, nil
+}
+
+// ----------------------------------------------------------------
+// Get the events within the subscriptions' notice period, starting
+// from the given day, which are not notified yet
+// ----------------------------------------------------------------
+func (db *Database) GetPendingMOEXEventNotices(day time.Time) ([]MOEXEventNotice, error) {
+	query := "SELECT moex_event_subscriptions.id, moex_event_subscriptions.notification_id, moex_corporate_events.id, moex_corporate_events.ticker_id, " +
+		"moex_corporate_events.kind, moex_corporate_events.event_date, moex_corporate_events.value, COALESCE(moex_corporate_events.currency, '') " +
+		"FROM moex_event_subscriptions INNER JOIN moex_corporate_events ON moex_event_subscriptions.ticker_id IS NULL OR moex_event_subscriptions.ticker_id = moex_corporate_events.ticker_id " +
+		"WHERE moex_corporate_events.event_date >= $1 AND moex_corporate_events.event_date - moex_event_subscriptions.days_before <= $1 " +
+		"AND NOT EXISTS (SELECT 1 FROM moex_event_notices WHERE moex_event_notices.subscription_id = moex_event_subscriptions.id AND moex_event_notices.event_id = moex_corporate_events.id) " +
+		"ORDER BY moex_corporate_events.event_date, moex_corporate_events.ticker_id, moex_event_subscriptions.id"
+	rows, err := db.handle.Query(query, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MOEX event notices: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	var notices []MOEXEventNotice
+	for rows.Next() {
+		var notice MOEXEventNotice
+		var value sql.NullFloat64
+		if err := rows.Scan(&notice.SubscriptionID, &notice.NotificationID, &notice.Event.ID, &notice.Event.Ticker,
+			&notice.Event.Kind, &notice.Event.Date, &value, &notice.Event.Currency); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		notice.Event.Value = math.NaN()
+		if value.Valid {
+			notice.Event.Value = value.Float64
+		}
+		notices = append(notices, notice)
+	}
+	return notices, nil
+}
+
+// ----------------------------------------------------------------
+// Record that the event was notified to the subscription
+// ----------------------------------------------------------------
+func (db *Database) AddMOEXEventNotice(subscriptionID int, eventID int) error {
+	query := "INSERT INTO moex_event_notices (subscription_id, event_id) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if _, err := db.handle.Exec(query, subscriptionID, eventID); err != nil {
+		return fmt.Errorf("failed to record MOEX event notice: %w", err)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+func (db *Database) GetMOEXEventSubscriptions() ([]MOEXEventSubscription, error) {
+	query := "SELECT id, notification_id, COALESCE(ticker_id, ''), days_before FROM moex_event_subscriptions ORDER BY id"
+	rows, err := db.handle.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MOEX event subscriptions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	var subscriptions []MOEXEventSubscription
+	for rows.Next() {
+		var subscription MOEXEventSubscription
+		if err := rows.Scan(&subscription.ID, &subscription.NotificationID, &subscription.Ticker, &subscription.DaysBefore); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+// ----------------------------------------------------------------
+func (db *Database) AddMOEXEventSubscription(subscription *MOEXEventSubscription) error {
+	ticker := sql.NullString{String: subscription.Ticker, Valid: subscription.Ticker != ""}
+	query := "INSERT INTO moex_event_subscriptions (notification_id, ticker_id, days_before) VALUES ($1, $2, $3) RETURNING id"
+	row := db.handle.QueryRow(query, subscription.NotificationID, ticker, subscription.DaysBefore)
+	if err := row.Scan(&subscription.ID); err != nil {
+		return fmt.Errorf("failed to add MOEX event subscription: %w", err)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Delete the subscription, returns false if it doesn't exist
+// ----------------------------------------------------------------
+func (db *Database) DeleteMOEXEventSubscription(id int) (bool, error) {
+	result, err := db.handle.Exec("DELETE FROM moex_event_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete MOEX event subscription: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the number of deleted MOEX event subscriptions: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ----------------------------------------------------------------
+// Portfolio management
+// ----------------------------------------------------------------
+
+// ----------------------------------------------------------------
+// Check that the statement changed a row, the entity is not found
+// otherwise
+// ----------------------------------------------------------------
+func expectAffected(result sql.Result, entity string, id int) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get the number of affected rows: %w", err)
+	}
+	if count == 0 {
+		return &PortfolioNotFound{Entity: entity, ID: id}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Create the portfolio, its holdings and rules are added separately
+// ----------------------------------------------------------------
+func (db *Database) CreatePortfolio(portfolio *Portfolio) error {
+	notificationID := sql.NullInt64{Int64: int64(portfolio.NotificationID), Valid: portfolio.NotificationID != 0}
+	query := "INSERT INTO portfolios (name, currency, notification_id) VALUES ($1, $2, $3) RETURNING id"
+	row := db.handle.QueryRow(query, portfolio.Name, portfolio.Currency, notificationID)
+	if err := row.Scan(&portfolio.ID); err != nil {
+		return fmt.Errorf("failed to create portfolio: %w", err)
+	}
+	log.Debug(fmt.Sprintf("Portfolio %d created: %s", portfolio.ID, portfolio.Name))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Get the portfolios without their holdings and rules
+// ----------------------------------------------------------------
+func (db *Database) GetPortfolios() ([]Portfolio, error) {
+	query := "SELECT id, name, currency, COALESCE(notification_id, 0) FROM portfolios ORDER BY id"
+	rows, err := db.handle.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query portfolios: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	var portfolios []Portfolio
+	for rows.Next() {
+		var portfolio Portfolio
+		if err := rows.Scan(&portfolio.ID, &portfolio.Name, &portfolio.Currency, &portfolio.NotificationID); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		portfolios = append(portfolios, portfolio)
+	}
+	return portfolios, nil
+}
+
+// ----------------------------------------------------------------
+// Get the portfolio with its holdings and rules
+// ----------------------------------------------------------------
+func (db *Database) GetPortfolio(id int) (*Portfolio, error) {
+	query := "SELECT id, name, currency, COALESCE(notification_id, 0) FROM portfolios WHERE id = $1"
+	row := db.handle.QueryRow(query, id)
+
+	var portfolio Portfolio
+	if err := row.Scan(&portfolio.ID, &portfolio.Name, &portfolio.Currency, &portfolio.NotificationID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &PortfolioNotFound{Entity: "portfolio", ID: id}
+		}
+		return nil, fmt.Errorf("failed to scan portfolio: %w", err)
+	}
+
+	holdings, err := db.getHoldings("WHERE holdings.portfolio_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := db.getPortfolioRules("WHERE portfolio_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	portfolio.Holdings = holdings[id]
+	portfolio.Rules = rules[id]
+	return &portfolio, nil
+}
+
+// ----------------------------------------------------------------
+func (db *Database) UpdatePortfolio(portfolio *Portfolio) error {
+	notificationID := sql.NullInt64{Int64: int64(portfolio.NotificationID), Valid: portfolio.NotificationID != 0}
+	query := "UPDATE portfolios SET name = $1, currency = $2, notification_id = $3, updated_at = $4 WHERE id = $5"
+	result, err := db.handle.Exec(query, portfolio.Name, portfolio.Currency, notificationID, time.Now(), portfolio.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update portfolio: %w", err)
+	}
+	return expectAffected(result, "portfolio", portfolio.ID)
+}
+
+// ----------------------------------------------------------------
+// Delete the portfolio with its holdings and rules
+// ----------------------------------------------------------------
+func (db *Database) DeletePortfolio(id int) error {
+	result, err := db.handle.Exec("DELETE FROM portfolios WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete portfolio: %w", err)
+	}
+	return expectAffected(result, "portfolio", id)
+}
+
+// ----------------------------------------------------------------
+// Get the holdings matching the filter grouped by the portfolio
+// ----------------------------------------------------------------
+func (db *Database) getHoldings(filter string, args ...any) (map[int][]Holding, error) {
+	query := "SELECT holdings.id, holdings.portfolio_id, holdings.ticker_id, holdings.quantity, holdings.average_price, holdings.currency, " +
+		"moex_assets.class_id, COALESCE(moex_assets.engine, ''), COALESCE(moex_assets.market, ''), COALESCE(moex_assets.board, '') " +
+		"FROM holdings INNER JOIN moex_assets ON holdings.ticker_id = moex_assets.ticker " + filter + " ORDER BY holdings.id"
+	rows, err := db.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	holdings := make(map[int][]Holding)
+	for rows.Next() {
+		var holding Holding
+		if err := rows.Scan(&holding.ID, &holding.PortfolioID, &holding.Ticker, &holding.Quantity, &holding.AveragePrice, &holding.Currency,
+			&holding.AssetClass, &holding.Engine, &holding.Market, &holding.Board); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		holdings[holding.PortfolioID] = append(holdings[holding.PortfolioID], holding)
+	}
+	return holdings, nil
+}
+
+// ----------------------------------------------------------------
+func (db *Database) AddHolding(holding *Holding) error {
+	query := "INSERT INTO holdings (portfolio_id, ticker_id, quantity, average_price, currency) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	row := db.handle.QueryRow(query, holding.PortfolioID, holding.Ticker, holding.Quantity, holding.AveragePrice, holding.Currency)
+	if err := row.Scan(&holding.ID); err != nil {
+		return fmt.Errorf("failed to add holding: %w", err)
+	}
+	log.Debug(fmt.Sprintf("Holding %d of %s added to portfolio %d", holding.ID, holding.Ticker, holding.PortfolioID))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Update the quantity, the average price and the currency of the
+// portfolio's holding
+// ----------------------------------------------------------------
+func (db *Database) UpdateHolding(holding *Holding) error {
+	query := "UPDATE holdings SET quantity = $1, average_price = $2, currency = $3 WHERE id = $4 AND portfolio_id = $5"
+	result, err := db.handle.Exec(query, holding.Quantity, holding.AveragePrice, holding.Currency, holding.ID, holding.PortfolioID)
+	if err != nil {
+		return fmt.Errorf("failed to update holding: %w", err)
+	}
+	return expectAffected(result, "holding", holding.ID)
+}
+
+// ----------------------------------------------------------------
+func (db *Database) DeleteHolding(portfolioID int, id int) error {
+	result, err := db.handle.Exec("DELETE FROM holdings WHERE id = $1 AND portfolio_id = $2", id, portfolioID)
+	if err != nil {
+		return fmt.Errorf("failed to delete holding: %w", err)
+	}
+	return expectAffected(result, "holding", id)
+}
+
+// ----------------------------------------------------------------
+// Get the portfolio rules matching the filter grouped by the
+// portfolio
+// ----------------------------------------------------------------
+func (db *Database) getPortfolioRules(filter string, args ...any) (map[int][]PortfolioRule, error) {
+	query := "SELECT id, portfolio_id, condition, threshold, COALESCE(ticker_id, ''), is_active, is_armed, last_triggered_at FROM portfolio_rules " +
+		filter + " ORDER BY id"
+	rows, err := db.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query portfolio rules: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Errorf("failed to close rows: %v", err)
+		}
+	}()
+
+	rules := make(map[int][]PortfolioRule)
+	for rows.Next() {
+		var rule PortfolioRule
+		var lastTriggeredAt sql.NullTime
+		if err := rows.Scan(&rule.ID, &rule.PortfolioID, &rule.Condition, &rule.Threshold, &rule.Ticker, &rule.Active, &rule.Armed, &lastTriggeredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		rule.LastTriggeredAt = lastTriggeredAt.Time
+		rules[rule.PortfolioID] = append(rules[rule.PortfolioID], rule)
+	}
+	return rules, nil
+}
+
+// ----------------------------------------------------------------
+func (db *Database) AddPortfolioRule(rule *PortfolioRule) error {
+	ticker := sql.NullString{String: rule.Ticker, Valid: rule.Ticker != ""}
+	query := "INSERT INTO portfolio_rules (portfolio_id, condition, threshold, ticker_id, is_active) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	row := db.handle.QueryRow(query, rule.PortfolioID, rule.Condition, rule.Threshold, ticker, rule.Active)
+	if err := row.Scan(&rule.ID); err != nil {
+		return fmt.Errorf("failed to add portfolio rule: %w", err)
+	}
+	rule.Armed = true
+	log.Debug(fmt.Sprintf("Rule %d added to portfolio %d", rule.ID, rule.PortfolioID))
+	return nil
+}
+
+// ----------------------------------------------------------------
+func (db *Database) DeletePortfolioRule(portfolioID int, id int) error {
+	result, err := db.handle.Exec("DELETE FROM portfolio_rules WHERE id = $1 AND portfolio_id = $2", id, portfolioID)
+	if err != nil {
+		return fmt.Errorf("failed to delete portfolio rule: %w", err)
+	}
+	return expectAffected(result, "rule", id)
+}
+
+// ----------------------------------------------------------------
+// Get the portfolios with active rules, along with their holdings
+// and active rules
+// ----------------------------------------------------------------
+func (db *Database) GetMonitoredPortfolios() ([]Portfolio, error) {
+	rules, err := db.getPortfolioRules("WHERE is_active = true")
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	holdings, err := db.getHoldings("WHERE holdings.portfolio_id IN (SELECT portfolio_id FROM portfolio_rules WHERE is_active = true)")
+	if err != nil {
+		return nil, err
+	}
+	portfolios, err := db.GetPortfolios()
+	if err != nil {
+		return nil, err
+	}
+
+	var monitored []Portfolio
+	for _, portfolio := range portfolios {
+		if len(rules[portfolio.ID]) == 0 {
+			continue
+		}
+		portfolio.Holdings = holdings[portfolio.ID]
+		portfolio.Rules = rules[portfolio.ID]
+		monitored = append(monitored, portfolio)
+	}
+	return monitored, nil
+}
+
+// ----------------------------------------------------------------
+// Persist the trigger state of the portfolio rule
+// ----------------------------------------------------------------
+func (db *Database) SetPortfolioRuleTriggerState(id int, armed bool, lastTriggeredAt time.Time) error {
+	var triggeredAt sql.NullTime
+	if !lastTriggeredAt.IsZero() {
+		triggeredAt = sql.NullTime{Time: lastTriggeredAt, Valid: true}
+	}
+	query := "UPDATE portfolio_rules SET is_armed = $1, last_triggered_at = $2 WHERE id = $3"
+	_, err := db.handle.Exec(query, armed, triggeredAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update portfolio rule trigger state: %w", err)
+	}
+	log.Debug(fmt.Sprintf("Portfolio rule %d armed status set to %t", id, armed))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Record the fired portfolio rule
+// ----------------------------------------------------------------
+func (db *Database) AddPortfolioAlert(alert *PortfolioAlert) error {
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+	ticker := sql.NullString{String: alert.Ticker, Valid: alert.Ticker != ""}
+	query := "INSERT INTO portfolio_alerts (rule_id, timestamp, condition, threshold, value, portfolio_value, ticker_id, published) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id"
+	row := db.handle.QueryRow(query, alert.RuleID, alert.Timestamp, alert.Condition, alert.Threshold, alert.Value, alert.PortfolioValue, ticker, alert.Published)
+	if err := row.Scan(&alert.ID); err != nil {
+		return fmt.Errorf("failed to record portfolio alert: %w", err)
+	}
+	log.Debug(fmt.Sprintf("Portfolio alert %d recorded for rule %d", alert.ID, alert.RuleID))
 	return nil
 }
 
@@ -1,7 +1,11 @@
 package godfather
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"math"
+	"regexp"
 	"testing"
 	"time"
 
@@ -16,16 +20,29 @@ func TestGetMOEXWatchlist_Success(t *testing.T) {
 	}
 	defer db.Close() //nolint:errcheck
 
-	rows1 := sqlmock.NewRows([]string{"ticker", "class_id", "notification_id", "target_price", "condition", "is_active"}).
-		AddRow("SBER", "stock", 1, 250.5, "above", true).
-		AddRow("GAZP", "stock", 2, 150.0, "below", false)
-	rows2 := sqlmock.NewRows([]string{"ticker", "class_id", "notification_id", "target_price", "condition", "is_active"}).
-		AddRow("SBER", "stock", 1, 250.5, "above", true).
-		AddRow("GAZP", "stock", 2, 150.0, "below", false)
+	lastTriggered := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	validFrom := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	validUntil := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows1 := sqlmock.NewRows([]string{"id", "ticker", "class_id", "engine", "market", "board", "notification_id", "target_price", "condition", "is_active", "mode", "hysteresis", "cooldown_seconds", "is_armed", "last_triggered_at",
+		"candle_interval", "period", "fast_period", "slow_period", "band_width", "lookback_days", "deadline",
+		"second_ticker", "second_class_id", "second_engine", "second_market", "second_board",
+		"valid_from", "valid_until", "schedule", "archived_at"}).
+		AddRow(1, "SBER", "stock", "stock", "shares", "TQBR", 1, 250.5, "above", true, "crossing", 2.5, 600, false, lastTriggered, 24, 0, 20, 50, 0, 0, 0, "", "", "", "", "",
+			validFrom, validUntil, "0-59 10 * * 1-5", nil).
+		AddRow(2, "GAZP", "stock", "", "", "", 2, 150.0, "below", false, "oneshot", 0, 0, true, nil, 24, 14, 0, 0, 0, 20, 43200, "SBERP", "stock", "stock", "shares", "TQBR",
+			nil, nil, "", nil)
+	rows2 := sqlmock.NewRows([]string{"id", "ticker", "class_id", "engine", "market", "board", "notification_id", "target_price", "condition", "is_active", "mode", "hysteresis", "cooldown_seconds", "is_armed", "last_triggered_at",
+		"candle_interval", "period", "fast_period", "slow_period", "band_width", "lookback_days", "deadline",
+		"second_ticker", "second_class_id", "second_engine", "second_market", "second_board",
+		"valid_from", "valid_until", "schedule", "archived_at"}).
+		AddRow(1, "SBER", "stock", "stock", "shares", "TQBR", 1, 250.5, "above", true, "crossing", 2.5, 600, false, lastTriggered, 24, 0, 20, 50, 0, 0, 0, "", "", "", "", "",
+			validFrom, validUntil, "0-59 10 * * 1-5", nil).
+		AddRow(2, "GAZP", "stock", "", "", "", 2, 150.0, "below", false, "oneshot", 0, 0, true, nil, 24, 14, 0, 0, 0, 20, 43200, "SBERP", "stock", "stock", "shares", "TQBR",
+			nil, nil, "", nil)
 
-	mock.ExpectQuery("SELECT moex_assets.ticker, moex_assets.class_id, moex_watchlist.notification_id, moex_watchlist.target_price::numeric, moex_watchlist.condition, moex_watchlist.is_active FROM moex_watchlist INNER JOIN moex_assets ON moex_watchlist.ticker_id = moex_assets.ticker WHERE moex_watchlist.is_active = true").
+	mock.ExpectQuery(regexp.QuoteMeta(moexWatchlistQuery + " WHERE moex_watchlist.is_active = true")).
 		WillReturnRows(rows1)
-	mock.ExpectQuery("SELECT moex_assets.ticker, moex_assets.class_id, moex_watchlist.notification_id, moex_watchlist.target_price::numeric, moex_watchlist.condition, moex_watchlist.is_active FROM moex_watchlist INNER JOIN moex_assets ON moex_watchlist.ticker_id = moex_assets.ticker").
+	mock.ExpectQuery(regexp.QuoteMeta(moexWatchlistQuery)).
 		WillReturnRows(rows2)
 
 	database := &Database{handle: db}
@@ -38,120 +55,817 @@ func TestGetMOEXWatchlist_Success(t *testing.T) {
 	if len(watchlist) != 2 {
 		t.Errorf("expected 2 items, got %d", len(watchlist))
 	}
-	if watchlist[0].Ticker != "SBER" {
+	if watchlist[0].ID != 1 || watchlist[0].Ticker != "SBER" || watchlist[0].Board != "TQBR" || watchlist[0].Market != "shares" {
 		t.Errorf("unexpected tickers: %+v", watchlist)
 	}
+	if watchlist[0].Mode != MOEXRuleCrossing || watchlist[0].Hysteresis != 2.5 || watchlist[0].Cooldown != 10*time.Minute ||
+		watchlist[0].Armed || !watchlist[0].LastTriggeredAt.Equal(lastTriggered) {
+		t.Errorf("unexpected trigger state: %+v", watchlist[0])
+	}
+	if watchlist[0].Params.FastPeriod != 20 || watchlist[0].Params.SlowPeriod != 50 || watchlist[0].Params.CandleInterval != 24 {
+		t.Errorf("unexpected rule params: %+v", watchlist[0].Params)
+	}
+	if watchlist[1].Params.LookbackDays != 20 || watchlist[1].Params.Deadline != 12*time.Hour {
+		t.Errorf("unexpected rule params: %+v", watchlist[1].Params)
+	}
+	if !watchlist[1].LastTriggeredAt.IsZero() {
+		t.Errorf("expected zero last trigger time, got %v", watchlist[1].LastTriggeredAt)
+	}
+	if watchlist[0].Pair.Ticker != "" || watchlist[1].Pair.Ticker != "SBERP" || watchlist[1].Pair.Board != "TQBR" {
+		t.Errorf("unexpected pair legs: %+v, %+v", watchlist[0].Pair, watchlist[1].Pair)
+	}
+	if !watchlist[0].ValidFrom.Equal(validFrom) || !watchlist[0].ValidUntil.Equal(validUntil) || watchlist[0].Schedule != "0-59 10 * * 1-5" {
+		t.Errorf("unexpected validity: %+v", watchlist[0])
+	}
+	if !watchlist[1].ValidFrom.IsZero() || !watchlist[1].ValidUntil.IsZero() || watchlist[1].Schedule != "" || !watchlist[1].ArchivedAt.IsZero() {
+		t.Errorf("expected no validity, got %+v", watchlist[1])
+	}
+
+	// Test successful retrieval of all watchlist items
+	watchlist, err = database.GetMOEXWatchlist(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(watchlist) != 2 {
+		t.Errorf("expected 2 items, got %d", len(watchlist))
+	}
+	if watchlist[0].Ticker != "SBER" || watchlist[1].Ticker != "GAZP" {
+		t.Errorf("unexpected tickers: %+v", watchlist)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetMOEXWatchlist_QueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("SELECT moex_watchlist.id, moex_assets.ticker").
+		WillReturnError(errors.New("query failed"))
+
+	database := &Database{handle: db}
+	_, err = database.GetMOEXWatchlist(false)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetMOEXWatchlist_ScanError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	columns := []string{"ticker", "notification_id", "target_price", "condition", "is_active"}
+	mock.ExpectQuery("SELECT moex_watchlist.id, moex_assets.ticker").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("SBER", "not-an-int", 250.5, "above", true))
+
+	database := &Database{handle: db}
+	_, err = database.GetMOEXWatchlist(false)
+	if err == nil {
+		t.Error("expected scan error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestInitDBFromEnv_MissingEnv(t *testing.T) {
+	t.Setenv("GODFATHER_DB_CONN", "")
+	database, err := InitDBFromEnv()
+	if err == nil {
+		t.Error("expected error for missing env var, got nil")
+	}
+	if database != nil {
+		t.Error("expected nil database on error")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestInitDBFromEnv_OpenError(t *testing.T) {
+	t.Setenv("GODFATHER_DB_CONN", "bad-conn-string")
+
+	database, err := InitDBFromEnv()
+	if err == nil || database != nil {
+		t.Error("expected error from sql.Open")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXWatchlistItemActiveStatus_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	id := 1
+	active := true
+	mock.ExpectExec("UPDATE moex_watchlist SET is_active = \\$1 WHERE id = \\$2").
+		WithArgs(active, id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	database := &Database{handle: db}
+	err = database.SetMOEXWatchlistItemActiveStatus(id, active)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXWatchlistItemActiveStatus_ExecError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	id := 2
+	active := false
+	mock.ExpectExec("UPDATE moex_watchlist SET is_active =").
+		WithArgs(active, id).
+		WillReturnError(errors.New("exec failed"))
+
+	database := &Database{handle: db}
+	err = database.SetMOEXWatchlistItemActiveStatus(id, active)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestArchiveMOEXWatchlistItem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	archivedAt := time.Date(2025, 6, 1, 0, 0, 5, 0, time.UTC)
+	mock.ExpectExec("UPDATE moex_watchlist SET is_active = false, archived_at = \\$1 WHERE id = \\$2").
+		WithArgs(archivedAt, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE moex_watchlist SET is_active = false").
+		WillReturnError(errors.New("update failed"))
+
+	database := &Database{handle: db}
+	if err := database.ArchiveMOEXWatchlistItem(3, archivedAt); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := database.ArchiveMOEXWatchlistItem(3, archivedAt); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXWatchlistItemTriggerState_Disarm(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	triggeredAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE moex_watchlist SET is_armed = \\$1, last_triggered_at = \\$2 WHERE id = \\$3").
+		WithArgs(false, triggeredAt, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	database := &Database{handle: db}
+	err = database.SetMOEXWatchlistItemTriggerState(3, false, triggeredAt)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXWatchlistItemTriggerState_NeverTriggered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectExec("UPDATE moex_watchlist SET is_armed =").
+		WithArgs(true, nil, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	database := &Database{handle: db}
+	err = database.SetMOEXWatchlistItemTriggerState(3, true, time.Time{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXWatchlistItemTriggerState_ExecError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectExec("UPDATE moex_watchlist SET is_armed =").
+		WillReturnError(errors.New("update failed"))
+
+	database := &Database{handle: db}
+	err = database.SetMOEXWatchlistItemTriggerState(3, true, time.Now())
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXAssetBoard_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectExec("UPDATE moex_assets SET engine = \\$1, market = \\$2, board = \\$3 WHERE ticker = \\$4").
+		WithArgs("stock", "bonds", "TQOB", "SU26238RMFS4").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	database := &Database{handle: db}
+	if err := database.SetMOEXAssetBoard("SU26238RMFS4", "stock", "bonds", "TQOB"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetMOEXAssetBoard_ExecError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectExec("UPDATE moex_assets SET engine =").
+		WillReturnError(errors.New("update failed"))
+
+	database := &Database{handle: db}
+	if err := database.SetMOEXAssetBoard("SBER", "stock", "shares", "TQBR"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestUpsertMOEXAssets_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	syncedAt := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	prepared := mock.ExpectPrepare("INSERT INTO moex_assets .* ON CONFLICT \\(ticker\\) DO UPDATE")
+	prepared.ExpectExec().
+		WithArgs("SBER", "stock", "Sberbank", "Sber", "RU0009029540", "stock", "shares", "TQBR", 10, "SUR", syncedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prepared.ExpectExec().
+		WithArgs("GAZP", "stock", "Gazprom", "Gazprom", "RU0007661625", "stock", "shares", "TQBR", 10, "SUR", syncedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	database := &Database{handle: db}
+	err = database.UpsertMOEXAssets([]MOEXAsset{
+		{Ticker: "SBER", ClassID: "stock", Name: "Sberbank", ShortName: "Sber", ISIN: "RU0009029540", Engine: "stock", Market: "shares",
+			Board: "TQBR", LotSize: 10, Currency: "SUR", SyncedAt: syncedAt},
+		{Ticker: "GAZP", ClassID: "stock", Name: "Gazprom", ShortName: "Gazprom", ISIN: "RU0007661625", Engine: "stock", Market: "shares",
+			Board: "TQBR", LotSize: 10, Currency: "SUR", SyncedAt: syncedAt},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestUpsertMOEXAssets_ExecError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO moex_assets").ExpectExec().
+		WillReturnError(errors.New("insert failed"))
+	mock.ExpectRollback()
+
+	database := &Database{handle: db}
+	if err := database.UpsertMOEXAssets([]MOEXAsset{{Ticker: "SBER", ClassID: "stock"}}); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMarkMOEXAssetsDelisted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	syncedAt := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE moex_assets SET is_delisted = TRUE WHERE engine = \\$1 AND market = \\$2 AND board = \\$3").
+		WithArgs("stock", "shares", "TQBR", syncedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE moex_assets SET is_delisted = TRUE").
+		WillReturnError(errors.New("update failed"))
+
+	database := &Database{handle: db}
+	count, err := database.MarkMOEXAssetsDelisted("stock", "shares", "TQBR", syncedAt)
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 delisted assets, got %d, %v", count, err)
+	}
+	if _, err := database.MarkMOEXAssetsDelisted("stock", "shares", "TQBR", syncedAt); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestUpsertMOEXCorporateEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	date := time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	prepared := mock.ExpectPrepare("INSERT INTO moex_corporate_events .* ON CONFLICT \\(ticker_id, kind, event_date\\) DO UPDATE")
+	prepared.ExpectExec().
+		WithArgs("SBER", MOEXDividend, date, 34.84, "RUB", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The unknown value and currency are stored as NULL
+	prepared.ExpectExec().
+		WithArgs("SU26238RMFS4", MOEXOffer, date, nil, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	database := &Database{handle: db}
+	err = database.UpsertMOEXCorporateEvents([]MOEXCorporateEvent{
+		{Ticker: "SBER", Kind: MOEXDividend, Date: date, Value: 34.84, Currency: "RUB"},
+		{Ticker: "SU26238RMFS4", Kind: MOEXOffer, Date: date, Value: math.NaN()},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetPendingMOEXEventNotices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	day := time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM moex_event_subscriptions INNER JOIN moex_corporate_events .* NOT EXISTS").
+		WithArgs(day).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notification_id", "event_id", "ticker_id", "kind", "event_date", "value", "currency"}).
+			AddRow(1, 2, 10, "SBER", MOEXDividend, date, 34.84, "RUB").
+			AddRow(3, 4, 11, "SU26238RMFS4", MOEXOffer, date, nil, ""))
+
+	database := &Database{handle: db}
+	notices, err := database.GetPendingMOEXEventNotices(day)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notices) != 2 {
+		t.Fatalf("expected 2 notices, got %d", len(notices))
+	}
+	if notices[0].SubscriptionID != 1 || notices[0].NotificationID != 2 || notices[0].Event.ID != 10 ||
+		notices[0].Event.Ticker != "SBER" || notices[0].Event.Value != 34.84 || !notices[0].Event.Date.Equal(date) {
+		t.Errorf("unexpected notice: %+v", notices[0])
+	}
+	if !math.IsNaN(notices[1].Event.Value) {
+		t.Errorf("expected unknown value, got %f", notices[1].Event.Value)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAddMOEXEventSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	// The subscription to all the assets has no ticker
+	mock.ExpectQuery("INSERT INTO moex_event_subscriptions").
+		WithArgs(2, nil, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	database := &Database{handle: db}
+	subscription := &MOEXEventSubscription{NotificationID: 2, DaysBefore: 3}
+	if err := database.AddMOEXEventSubscription(subscription); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subscription.ID != 5 {
+		t.Errorf("expected subscription 5, got %d", subscription.ID)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestDeleteMOEXEventSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectExec("DELETE FROM moex_event_subscriptions WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM moex_event_subscriptions WHERE id = \\$1").
+		WithArgs(6).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	database := &Database{handle: db}
+	if deleted, err := database.DeleteMOEXEventSubscription(5); err != nil || !deleted {
+		t.Errorf("expected subscription deleted, got %v, %v", deleted, err)
+	}
+	if deleted, err := database.DeleteMOEXEventSubscription(6); err != nil || deleted {
+		t.Errorf("expected no subscription deleted, got %v, %v", deleted, err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSearchMOEXAssets_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	syncedAt := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"ticker", "class_id", "name", "short_name", "isin", "engine", "market", "board", "lot_size", "currency", "is_delisted", "synced_at"}).
+		AddRow("SBER", "stock", "Sberbank", "Sber", "RU0009029540", "stock", "shares", "TQBR", 10, "SUR", false, syncedAt).
+		AddRow("SBERP", "stock", "Sberbank pref", "Sber-p", "RU0009029557", "", "", "", 0, "", false, nil)
+	mock.ExpectQuery(regexp.QuoteMeta(moexAssetsQuery+" WHERE (ticker ILIKE $1 OR isin ILIKE $1 OR short_name ILIKE $1 OR name ILIKE $1) AND is_delisted = FALSE")).
+		WithArgs("%SBER\\_%", "SBER\\_%", 20).
+		WillReturnRows(rows)
+
+	database := &Database{handle: db}
+	assets, err := database.SearchMOEXAssets("SBER_", false, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(assets) != 2 {
+		t.Fatalf("expected 2 assets, got %d", len(assets))
+	}
+	if assets[0].Board != "TQBR" || assets[0].LotSize != 10 || !assets[0].SyncedAt.Equal(syncedAt) {
+		t.Errorf("unexpected asset: %+v", assets[0])
+	}
+	if !assets[1].SyncedAt.IsZero() || assets[1].Board != "" {
+		t.Errorf("unexpected asset: %+v", assets[1])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSearchMOEXAssets_IncludeDelisted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("ILIKE \\$1\\) ORDER BY is_delisted").
+		WillReturnError(errors.New("query failed"))
+
+	database := &Database{handle: db}
+	if _, err := database.SearchMOEXAssets("SBER", true, 20); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestMOEXQuotesPartitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	day := time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("SELECT moex_quotes_create_partition($1::date)")).
+		WithArgs("2025-03-01").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT moex_quotes_create_partition").
+		WillReturnError(errors.New("permission denied"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT moex_quotes_drop_partitions($1::date)")).
+		WithArgs("2025-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"dropped"}).AddRow(3))
+
+	database := &Database{handle: db}
+	if err := database.EnsureMOEXQuotesPartition(day); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := database.EnsureMOEXQuotesPartition(day); err == nil {
+		t.Error("expected error, got nil")
+	}
+	dropped, err := database.DropMOEXQuotesBefore(day)
+	if err != nil || dropped != 3 {
+		t.Errorf("expected 3 dropped partitions, got %d, %v", dropped, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAddMOEXQuotes_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	timestamp := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	prepared := mock.ExpectPrepare("INSERT INTO moex_quotes")
+	prepared.ExpectExec().
+		WithArgs("SBER", timestamp, 310.5, sql.NullFloat64{Float64: 1000, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prepared.ExpectExec().
+		WithArgs("USD000UTSTOM", timestamp, 92.1, sql.NullFloat64{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	database := &Database{handle: db}
+	err = database.AddMOEXQuotes([]MOEXQuote{
+		{Ticker: "SBER", Timestamp: timestamp, Price: 310.5, VolToday: 1000},
+		{Ticker: "USD000UTSTOM", Timestamp: timestamp, Price: 92.1, VolToday: math.NaN()},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAddMOEXQuotes_InsertError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO moex_quotes").ExpectExec().
+		WillReturnError(errors.New("no partition"))
+	mock.ExpectRollback()
+
+	database := &Database{handle: db}
+	if err := database.AddMOEXQuotes([]MOEXQuote{{Ticker: "SBER", Price: 310.5}}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
 
-	// Test successful retrieval of all watchlist items
-	watchlist, err = database.GetMOEXWatchlist(false)
+// ----------------------------------------------------------------
+func TestRollupMOEXQuotes(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("failed to open sqlmock database: %v", err)
 	}
-	if len(watchlist) != 2 {
-		t.Errorf("expected 2 items, got %d", len(watchlist))
+	defer db.Close() //nolint:errcheck
+
+	since := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("SELECT ticker, $1, date_trunc('minute', timestamp) AS period")+".*FROM moex_quotes WHERE").
+		WithArgs(MOEXQuoteMinute, since).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT ticker, $1, date_trunc('hour', bucket) AS period")+".*"+regexp.QuoteMeta("WHERE interval = '1m'")).
+		WithArgs(MOEXQuoteHour, since).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	database := &Database{handle: db}
+	count, err := database.RollupMOEXQuotes(MOEXQuoteMinute, since)
+	if err != nil || count != 5 {
+		t.Errorf("expected 5 bars, got %d, %v", count, err)
 	}
-	if watchlist[0].Ticker != "SBER" || watchlist[1].Ticker != "GAZP" {
-		t.Errorf("unexpected tickers: %+v", watchlist)
+	count, err = database.RollupMOEXQuotes(MOEXQuoteHour, since)
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 bars, got %d, %v", count, err)
+	}
+	if _, err := database.RollupMOEXQuotes("5m", since); err == nil {
+		t.Error("expected error for unsupported interval, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
 	}
 }
 
 // ----------------------------------------------------------------
-func TestGetMOEXWatchlist_QueryError(t *testing.T) {
+func TestDeleteMOEXQuoteBarsBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	before := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM moex_quote_bars WHERE interval = $1 AND bucket < $2")).
+		WithArgs(MOEXQuoteMinute, before).
+		WillReturnResult(sqlmock.NewResult(0, 100))
+	mock.ExpectExec("DELETE FROM moex_quote_bars").
+		WillReturnError(errors.New("delete failed"))
+
+	database := &Database{handle: db}
+	count, err := database.DeleteMOEXQuoteBarsBefore(MOEXQuoteMinute, before)
+	if err != nil || count != 100 {
+		t.Errorf("expected 100 deleted bars, got %d, %v", count, err)
+	}
+	if _, err := database.DeleteMOEXQuoteBarsBefore(MOEXQuoteHour, before); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetMOEXQuoteHistory(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to open sqlmock database: %v", err)
 	}
 	defer db.Close() //nolint:errcheck
 
-	mock.ExpectQuery("SELECT moex_assets.ticker").
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timestamp, price, price, price, price FROM moex_quotes WHERE ticker = $1")).
+		WithArgs("SBER", from, to, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"timestamp", "open", "high", "low", "close"}).
+			AddRow(from.Add(time.Minute), 310.5, 310.5, 310.5, 310.5))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT bucket, open, high, low, close FROM moex_quote_bars WHERE ticker = $1 AND interval = $2")).
+		WithArgs("SBER", MOEXQuoteHour, from, to, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "open", "high", "low", "close"}).
+			AddRow(from.Add(10*time.Hour), 310.0, 312.0, 309.5, 311.0).
+			AddRow(from.Add(11*time.Hour), 311.0, 311.5, 310.0, 310.5))
+	mock.ExpectQuery("FROM moex_quote_bars").
 		WillReturnError(errors.New("query failed"))
 
 	database := &Database{handle: db}
-	_, err = database.GetMOEXWatchlist(false)
-	if err == nil {
+	bars, err := database.GetMOEXQuoteHistory("SBER", MOEXQuoteRaw, from, to, 1000)
+	if err != nil || len(bars) != 1 || bars[0].Close != 310.5 {
+		t.Errorf("unexpected raw history: %+v, %v", bars, err)
+	}
+	bars, err = database.GetMOEXQuoteHistory("SBER", MOEXQuoteHour, from, to, 1000)
+	if err != nil || len(bars) != 2 || bars[0].High != 312.0 || bars[1].Low != 310.0 {
+		t.Errorf("unexpected hourly history: %+v, %v", bars, err)
+	}
+	if _, err := database.GetMOEXQuoteHistory("SBER", MOEXQuoteDay, from, to, 1000); err == nil {
 		t.Error("expected error, got nil")
 	}
+	if _, err := database.GetMOEXQuoteHistory("SBER", "5m", from, to, 1000); err == nil {
+		t.Error("expected error for unsupported interval, got nil")
+	}
 }
 
 // ----------------------------------------------------------------
-func TestGetMOEXWatchlist_ScanError(t *testing.T) {
+func TestAddMOEXAlert_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to open sqlmock database: %v", err)
 	}
 	defer db.Close() //nolint:errcheck
 
-	columns := []string{"ticker", "notification_id", "target_price", "condition", "is_active"}
-	mock.ExpectQuery("SELECT moex_assets.ticker").
-		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow("SBER", "not-an-int", 250.5, "above", true))
+	mock.ExpectQuery("INSERT INTO moex_alerts").
+		WithArgs(1, sqlmock.AnyArg(), 310.5, 300.0, "above", true, sql.NullFloat64{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectQuery("INSERT INTO moex_alerts").
+		WithArgs(2, sqlmock.AnyArg(), 310.5, 5.0, "spread_above", true, sql.NullFloat64{Float64: 304.0, Valid: true}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(43))
 
 	database := &Database{handle: db}
-	_, err = database.GetMOEXWatchlist(false)
-	if err == nil {
-		t.Error("expected scan error, got nil")
+	alert := &MOEXAlert{WatchlistID: 1, Price: 310.5, TargetPrice: 300.0, Condition: "above", Published: true}
+	err = database.AddMOEXAlert(alert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alert.ID != 42 {
+		t.Errorf("expected alert ID 42, got %d", alert.ID)
+	}
+	if alert.Timestamp.IsZero() {
+		t.Error("expected timestamp to be set")
+	}
+
+	// The price of the second leg is stored for the pair rules
+	pairAlert := &MOEXAlert{WatchlistID: 2, Price: 310.5, TargetPrice: 5.0, Condition: "spread_above", Published: true,
+		SecondTicker: "SBERP", SecondPrice: 304.0}
+	if err := database.AddMOEXAlert(pairAlert); err != nil || pairAlert.ID != 43 {
+		t.Errorf("unexpected result: %d, %v", pairAlert.ID, err)
 	}
 }
 
 // ----------------------------------------------------------------
-func TestInitDBFromEnv_MissingEnv(t *testing.T) {
-	t.Setenv("GODFATHER_DB_CONN", "")
-	database, err := InitDBFromEnv()
-	if err == nil {
-		t.Error("expected error for missing env var, got nil")
+func TestAddMOEXAlert_InsertError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
 	}
-	if database != nil {
-		t.Error("expected nil database on error")
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("INSERT INTO moex_alerts").
+		WillReturnError(errors.New("insert failed"))
+
+	database := &Database{handle: db}
+	err = database.AddMOEXAlert(&MOEXAlert{WatchlistID: 1})
+	if err == nil {
+		t.Error("expected error, got nil")
 	}
 }
 
 // ----------------------------------------------------------------
-func TestInitDBFromEnv_OpenError(t *testing.T) {
-	t.Setenv("GODFATHER_DB_CONN", "bad-conn-string")
+func TestGetMOEXAlerts_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
 
-	database, err := InitDBFromEnv()
-	if err == nil || database != nil {
-		t.Error("expected error from sql.Open")
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "watchlist_id", "ticker_id", "price", "target_price", "condition", "published", "timestamp", "second_ticker_id", "second_price"}).
+		AddRow(3, 2, "SBER", 310.5, 5.0, "spread_above", true, to, "SBERP", 304.0).
+		AddRow(2, 1, "SBER", 310.5, 300.0, "above", true, to, "", nil).
+		AddRow(1, 1, "SBER", nil, nil, nil, false, from, "", nil)
+	mock.ExpectQuery(`FROM moex_alerts INNER JOIN moex_watchlist ON moex_alerts.watchlist_id = moex_watchlist.id WHERE moex_watchlist.ticker_id = \$1 AND moex_alerts.timestamp >= \$2 AND moex_alerts.timestamp <= \$3 ORDER BY moex_alerts.timestamp DESC`).
+		WithArgs("SBER", from, to).
+		WillReturnRows(rows)
+
+	database := &Database{handle: db}
+	alerts, err := database.GetMOEXAlerts(MOEXAlertFilter{Ticker: "SBER", From: from, To: to})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alerts) != 3 {
+		t.Fatalf("expected 3 alerts, got %d", len(alerts))
+	}
+	if alerts[0].SecondTicker != "SBERP" || alerts[0].SecondPrice != 304.0 {
+		t.Errorf("unexpected pair alert: %+v", alerts[0])
+	}
+	if alerts[1].Ticker != "SBER" || alerts[1].Price != 310.5 || !alerts[1].Published || alerts[1].SecondTicker != "" {
+		t.Errorf("unexpected alert: %+v", alerts[1])
+	}
+	if alerts[2].Price != 0 || alerts[2].Condition != "" {
+		t.Errorf("expected empty values for legacy alert, got %+v", alerts[2])
 	}
 }
 
 // ----------------------------------------------------------------
-func TestSetMOEXWatchlistItemActiveStatus_Success(t *testing.T) {
+func TestGetMOEXAlerts_NoFilter(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to open sqlmock database: %v", err)
 	}
 	defer db.Close() //nolint:errcheck
 
-	ticker := "SBER"
-	active := true
-	mock.ExpectExec("UPDATE moex_watchlist SET is_active =").
-		WithArgs(active, ticker).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`moex_watchlist.id ORDER BY moex_alerts.timestamp DESC`).
+		WithoutArgs().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "watchlist_id", "ticker_id", "price", "target_price", "condition", "published", "timestamp", "second_ticker_id", "second_price"}))
 
 	database := &Database{handle: db}
-	err = database.SetMOEXWatchlistItemActiveStatus(ticker, active)
+	alerts, err := database.GetMOEXAlerts(MOEXAlertFilter{})
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("expected no alerts, got %d", len(alerts))
 	}
 }
 
 // ----------------------------------------------------------------
-func TestSetMOEXWatchlistItemActiveStatus_ExecError(t *testing.T) {
+func TestGetMOEXAlerts_QueryError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to open sqlmock database: %v", err)
 	}
 	defer db.Close() //nolint:errcheck
 
-	ticker := "GAZP"
-	active := false
-	mock.ExpectExec("UPDATE moex_watchlist SET is_active =").
-		WithArgs(active, ticker).
-		WillReturnError(errors.New("exec failed"))
+	mock.ExpectQuery("SELECT moex_alerts.id").
+		WillReturnError(errors.New("query failed"))
 
 	database := &Database{handle: db}
-	err = database.SetMOEXWatchlistItemActiveStatus(ticker, active)
+	_, err = database.GetMOEXAlerts(MOEXAlertFilter{Ticker: "GAZP"})
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -428,3 +1142,292 @@ func TestDeleteUser_ExecError(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+// ----------------------------------------------------------------
+func TestCreatePortfolio(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("INSERT INTO portfolios").
+		WithArgs("Main", "RUB", sql.NullInt64{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	database := &Database{handle: db}
+	portfolio := &Portfolio{Name: "Main", Currency: "RUB"}
+	if err := database.CreatePortfolio(portfolio); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if portfolio.ID != 7 {
+		t.Errorf("expected portfolio ID 7, got %d", portfolio.ID)
+	}
+}
+
+// ----------------------------------------------------------------
+func holdingRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "portfolio_id", "ticker_id", "quantity", "average_price", "currency", "class_id", "engine", "market", "board"})
+}
+
+// ----------------------------------------------------------------
+func portfolioRuleRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "portfolio_id", "condition", "threshold", "ticker_id", "is_active", "is_armed", "last_triggered_at"})
+}
+
+// ----------------------------------------------------------------
+func TestGetPortfolio(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	triggeredAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, name, currency, COALESCE\\(notification_id, 0\\) FROM portfolios WHERE id = \\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "currency", "notification_id"}).AddRow(7, "Main", "RUB", 2))
+	mock.ExpectQuery("FROM holdings INNER JOIN moex_assets ON holdings.ticker_id = moex_assets.ticker WHERE holdings.portfolio_id = \\$1").
+		WithArgs(7).
+		WillReturnRows(holdingRows().
+			AddRow(1, 7, "SBER", 100.0, 250.0, "RUB", "stock", "stock", "shares", "TQBR").
+			AddRow(2, 7, "GAZP", 50.0, 160.0, "RUB", "stock", "", "", ""))
+	mock.ExpectQuery("FROM portfolio_rules WHERE portfolio_id = \\$1").
+		WithArgs(7).
+		WillReturnRows(portfolioRuleRows().
+			AddRow(3, 7, "weight_above", 20.0, "SBER", true, false, triggeredAt).
+			AddRow(4, 7, "drawdown_above", 5.0, "", true, true, nil))
+
+	database := &Database{handle: db}
+	portfolio, err := database.GetPortfolio(7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if portfolio.Name != "Main" || portfolio.NotificationID != 2 || len(portfolio.Holdings) != 2 || len(portfolio.Rules) != 2 {
+		t.Fatalf("unexpected portfolio: %+v", portfolio)
+	}
+	if holding := portfolio.Holdings[0]; holding.Ticker != "SBER" || holding.Quantity != 100 || holding.Board != "TQBR" {
+		t.Errorf("unexpected holding: %+v", holding)
+	}
+	if rule := portfolio.Rules[0]; rule.Ticker != "SBER" || rule.Armed || !rule.LastTriggeredAt.Equal(triggeredAt) {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+	if rule := portfolio.Rules[1]; rule.Ticker != "" || !rule.LastTriggeredAt.IsZero() {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetPortfolio_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("FROM portfolios WHERE id = \\$1").
+		WithArgs(7).
+		WillReturnError(sql.ErrNoRows)
+
+	database := &Database{handle: db}
+	_, err = database.GetPortfolio(7)
+	var notFound *PortfolioNotFound
+	if !errors.As(err, &notFound) || notFound.ID != 7 {
+		t.Errorf("expected the portfolio not found error, got %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestUpdateHolding_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectExec("UPDATE holdings SET quantity = \\$1, average_price = \\$2, currency = \\$3 WHERE id = \\$4 AND portfolio_id = \\$5").
+		WithArgs(10.0, 250.0, "RUB", 1, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE holdings").
+		WithArgs(10.0, 250.0, "RUB", 1, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	database := &Database{handle: db}
+	holding := &Holding{ID: 1, PortfolioID: 7, Quantity: 10, AveragePrice: 250, Currency: "RUB"}
+	if err := database.UpdateHolding(holding); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The holding of another portfolio is not updated
+	holding.PortfolioID = 8
+	var notFound *PortfolioNotFound
+	if err := database.UpdateHolding(holding); !errors.As(err, &notFound) || notFound.Entity != "holding" {
+		t.Errorf("expected the holding not found error, got %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAddPortfolioRule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("INSERT INTO portfolio_rules").
+		WithArgs(7, "weight_above", 20.0, sql.NullString{String: "SBER", Valid: true}, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("INSERT INTO portfolio_rules").
+		WithArgs(7, "drawdown_above", 5.0, sql.NullString{}, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	database := &Database{handle: db}
+	rule := &PortfolioRule{PortfolioID: 7, Condition: PortfolioWeightAbove, Threshold: 20, Ticker: "SBER", Active: true}
+	if err := database.AddPortfolioRule(rule); err != nil || rule.ID != 3 || !rule.Armed {
+		t.Errorf("unexpected result: %+v, %v", rule, err)
+	}
+	rule = &PortfolioRule{PortfolioID: 7, Condition: PortfolioDrawdownAbove, Threshold: 5, Active: true}
+	if err := database.AddPortfolioRule(rule); err != nil || rule.ID != 4 {
+		t.Errorf("unexpected result: %+v, %v", rule, err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetMonitoredPortfolios(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("FROM portfolio_rules WHERE is_active = true").
+		WillReturnRows(portfolioRuleRows().AddRow(4, 7, "drawdown_above", 5.0, "", true, true, nil))
+	mock.ExpectQuery("FROM holdings INNER JOIN moex_assets").
+		WillReturnRows(holdingRows().AddRow(1, 7, "SBER", 100.0, 250.0, "RUB", "stock", "stock", "shares", "TQBR"))
+	mock.ExpectQuery("FROM portfolios ORDER BY id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "currency", "notification_id"}).
+			AddRow(7, "Main", "RUB", 2).
+			AddRow(8, "Idle", "RUB", 0))
+
+	database := &Database{handle: db}
+	portfolios, err := database.GetMonitoredPortfolios()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The portfolios without active rules are not monitored
+	if len(portfolios) != 1 || portfolios[0].ID != 7 || len(portfolios[0].Holdings) != 1 || len(portfolios[0].Rules) != 1 {
+		t.Errorf("unexpected portfolios: %+v", portfolios)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestGetMonitoredPortfolios_NoRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery("FROM portfolio_rules WHERE is_active = true").
+		WillReturnRows(portfolioRuleRows())
+
+	database := &Database{handle: db}
+	portfolios, err := database.GetMonitoredPortfolios()
+	if err != nil || len(portfolios) != 0 {
+		t.Errorf("expected no portfolios, got %+v, %v", portfolios, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unexpected queries: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestSetPortfolioRuleTriggerState(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	triggeredAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE portfolio_rules SET is_armed = \\$1, last_triggered_at = \\$2 WHERE id = \\$3").
+		WithArgs(false, triggeredAt, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	database := &Database{handle: db}
+	if err := database.SetPortfolioRuleTriggerState(4, false, triggeredAt); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestAddPortfolioAlert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	firedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("INSERT INTO portfolio_alerts").
+		WithArgs(4, firedAt, PortfolioWeightAbove, 50.0, 60.0, 45000.0, sql.NullString{String: "SBER", Valid: true}, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("INSERT INTO portfolio_alerts").
+		WithArgs(5, sqlmock.AnyArg(), PortfolioDrawdownAbove, 5.0, 7.5, 45000.0, sql.NullString{}, false).
+		WillReturnError(errors.New("insert failed"))
+
+	database := &Database{handle: db}
+	alert := &PortfolioAlert{RuleID: 4, Condition: PortfolioWeightAbove, Threshold: 50, Value: 60, PortfolioValue: 45000,
+		Ticker: "SBER", Published: true, Timestamp: firedAt}
+	if err := database.AddPortfolioAlert(alert); err != nil || alert.ID != 9 {
+		t.Errorf("unexpected result: %d, %v", alert.ID, err)
+	}
+	alert = &PortfolioAlert{RuleID: 5, Condition: PortfolioDrawdownAbove, Threshold: 5, Value: 7.5, PortfolioValue: 45000}
+	if err := database.AddPortfolioAlert(alert); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestTryAdvisoryLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(int64(42)).
+		WillReturnError(errors.New("connection refused"))
+
+	database := &Database{handle: db}
+	ctx := context.Background()
+	lock, err := database.TryAdvisoryLock(ctx, 42)
+	if err != nil || lock == nil {
+		t.Fatalf("expected the lock, got %v, %v", lock, err)
+	}
+	if err := lock.Check(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Held by another session
+	if lock, err := database.TryAdvisoryLock(ctx, 42); err != nil || lock != nil {
+		t.Errorf("expected no lock, got %v, %v", lock, err)
+	}
+	if _, err := database.TryAdvisoryLock(ctx, 42); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
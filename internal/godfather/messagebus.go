@@ -2,6 +2,7 @@ package godfather
 
 import (
 	"fmt"
+	"time"
 
 	"log/slog"
 
@@ -74,6 +75,48 @@ func (mb *MessageBus) CreateStream(streamName string, streamSubjects string) err
 	return nil
 }
 
+// ----------------------------------------------------------------
+// Create the stream keeping the messages until they exceed the age
+// or the size limit, the limits of the existing stream are updated
+// ----------------------------------------------------------------
+func (mb *MessageBus) CreateLimitsStream(streamName string, streamSubjects string, maxAge time.Duration, maxBytes int64) error {
+	// Validate input parameters
+	if streamName == "" {
+		return fmt.Errorf("stream name cannot be empty")
+	}
+	if streamSubjects == "" {
+		return fmt.Errorf("stream subjects cannot be empty")
+	}
+	if maxAge <= 0 || maxBytes <= 0 {
+		return fmt.Errorf("stream limits must be positive")
+	}
+
+	config := &nats.StreamConfig{
+		Name:      streamName,
+		Subjects:  []string{streamSubjects},
+		Retention: nats.LimitsPolicy, // Messages are retained up to the limits regardless of the consumers
+		Discard:   nats.DiscardOld,
+		MaxAge:    maxAge,
+		MaxBytes:  maxBytes,
+		Storage:   nats.MemoryStorage,
+	}
+	stream, _ := mb.stream.StreamInfo(streamName)
+	if stream == nil {
+		if _, err := mb.stream.AddStream(config); err != nil {
+			return fmt.Errorf("failed to create stream '%s': %w", streamName, err)
+		}
+		slog.Debug("Created stream", "name", streamName, "subjects", streamSubjects, "max_age", maxAge)
+	} else {
+		// The storage type of the existing stream can't be changed
+		config.Storage = stream.Config.Storage
+		if _, err := mb.stream.UpdateStream(config); err != nil {
+			return fmt.Errorf("failed to update stream '%s': %w", streamName, err)
+		}
+		slog.Debug("Stream limits updated", "name", streamName, "max_age", maxAge)
+	}
+	return nil
+}
+
 // ----------------------------------------------------------------
 func (mb *MessageBus) Publish(subject string, message []byte) error {
 	if mb.connection == nil {
@@ -1,6 +1,30 @@
 package godfather
 
+import "time"
+
 type AlertMessage struct {
 	Subject        string `msgpack:"subject"`
 	NotificationId int    `msgpack:"notification_id"`
+	WatchlistId    int    `msgpack:"watchlist_id"`
+	PortfolioId    int    `msgpack:"portfolio_id,omitempty"` // set for the portfolio rules instead of the watchlist item
+}
+
+// ----------------------------------------------------------------
+// Live MOEX quote published on quotes.MOEX.<board>.<ticker>, the
+// session statistics not reported by ISS are NaN
+// ----------------------------------------------------------------
+type QuoteMessage struct {
+	Ticker    string    `msgpack:"ticker"`
+	Engine    string    `msgpack:"engine"`
+	Market    string    `msgpack:"market"`
+	Board     string    `msgpack:"board"`
+	Timestamp time.Time `msgpack:"timestamp"`
+	Price     float64   `msgpack:"price"`
+	Open      float64   `msgpack:"open"`
+	High      float64   `msgpack:"high"`
+	Low       float64   `msgpack:"low"`
+	WAPrice   float64   `msgpack:"waprice"`
+	ChangePct float64   `msgpack:"change_pct"`
+	VolToday  float64   `msgpack:"vol_today"`
+	ValToday  float64   `msgpack:"val_today"`
 }
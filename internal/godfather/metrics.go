@@ -37,14 +37,7 @@ func StartMetricsServer(ctx context.Context, url string, port int) (*MetricsServ
 		}
 	}()
 
-	<-ctx.Done()
-	slog.Info("Prometheus metrics server stopped")
-
-	// Gracefully shutdown the server
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	_ = server.Shutdown(shutdownCtx)
-
+	// The caller registers the metrics and stops the server once the context is done
 	return &MetricsServer{
 		Registry: reg,
 		Server:   server,
@@ -68,3 +61,24 @@ func (ms *MetricsServer) RegisterCounter(counter prometheus.Counter) {
 		ms.Registry.MustRegister(counter)
 	}
 }
+
+// ----------------------------------------------------------------
+func (ms *MetricsServer) RegisterGauge(gauge prometheus.Gauge) {
+	if ms.Registry != nil {
+		ms.Registry.MustRegister(gauge)
+	}
+}
+
+// ----------------------------------------------------------------
+func (ms *MetricsServer) RegisterGaugeVec(gauge *prometheus.GaugeVec) {
+	if ms.Registry != nil {
+		ms.Registry.MustRegister(gauge)
+	}
+}
+
+// ----------------------------------------------------------------
+func (ms *MetricsServer) RegisterHistogram(histogram prometheus.Histogram) {
+	if ms.Registry != nil {
+		ms.Registry.MustRegister(histogram)
+	}
+}
@@ -15,5 +15,14 @@ INSERT INTO moex_watchlist (id, ticker_id, notification_id, target_price, condit
 (3, 'USD000TSTTOM', 1, 75.00, 'above', TRUE),
 (4, 'EUR_RUB_TOM', 1, 90.00, 'below', TRUE);
 
+INSERT INTO moex_watchlist (id, ticker_id, notification_id, target_price, condition, is_active, mode, hysteresis, cooldown_seconds) VALUES
+(5, 'SBER', 1, 280.00, 'below', TRUE, 'crossing', 5.00, 3600);
+
+INSERT INTO moex_watchlist (id, ticker_id, notification_id, target_price, condition, is_active) VALUES
+(6, 'GAZP', 1, 3.00, 'volume_spike', TRUE);
+
+INSERT INTO moex_watchlist_params (watchlist_id, lookback_days, deadline) VALUES
+(6, 20, '12:00');
+
 COMMIT;
 